@@ -16,7 +16,8 @@ var (
 	printHelp    = flag.Bool("help", false, "print help and exit")
 	logDir       = flag.String("log-dir", "", "specify the log directory")
 	Config       = flag.String("config", "config.yaml", "specify the config.yaml path")
-	export       = flag.Bool("export", false, "Exports prices to a JSON file.")
+	export       = flag.Bool("export", false, "Exports default prices (including tiers) to a JSON file.")
+	reEncrypt    = flag.Bool("re-encrypt", false, "Re-encrypt channel keys and payment configs with the current master key and exit.")
 )
 
 func InitCli() {
@@ -61,5 +62,5 @@ func help() {
 	fmt.Println("Copyright (C) 2025 deanxv. All rights reserved.")
 	fmt.Println("Original copyright holder: JustSong")
 	fmt.Println("GitHub: https://github.com/deanxv/done-hub")
-	fmt.Println("Usage: done-hub [--port <port>] [--log-dir <log directory>] [--config <config.yaml path>] [--version] [--help]")
+	fmt.Println("Usage: done-hub [--port <port>] [--log-dir <log directory>] [--config <config.yaml path>] [--re-encrypt] [--version] [--help]")
 }
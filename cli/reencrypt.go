@@ -0,0 +1,21 @@
+package cli
+
+import (
+	"done-hub/common/logger"
+	"done-hub/model"
+	"os"
+)
+
+// HandleReEncrypt 使用当前主密钥重新加密已存储的密钥后退出，需在数据库初始化后调用
+func HandleReEncrypt() {
+	if !*reEncrypt {
+		return
+	}
+
+	if err := model.ReEncryptSecrets(); err != nil {
+		logger.FatalLog("re-encrypt secrets failed: " + err.Error())
+	}
+
+	model.CloseDB()
+	os.Exit(0)
+}
@@ -0,0 +1,25 @@
+package config
+
+// AffiliateEnabled 开启后充值返利按分销规则计入佣金账户，申请提现后到账；关闭时沿用 InviterRewardType 直接返利
+var AffiliateEnabled = false
+
+// AffiliateCommissionMonths 被邀请用户注册后多少个月内的充值产生佣金，0 表示不限
+var AffiliateCommissionMonths = 12
+
+// AffiliateTier1Rate 直接邀请人的佣金比例（百分比）
+var AffiliateTier1Rate = 10.0
+
+// AffiliateTier2Rate 邀请人的邀请人的佣金比例（百分比），0 表示不开启二级佣金
+var AffiliateTier2Rate = 0.0
+
+// AffiliateGroupRates 按邀请人所在分组覆盖佣金比例，分组 => [一级比例, 二级比例]
+var AffiliateGroupRates = map[string][]float64{}
+
+// AffiliateSettleDays 佣金的结算等待天数，期间订单退款会撤销佣金
+var AffiliateSettleDays = 7
+
+// AffiliateMinWithdrawQuota 单次提现的最低额度
+var AffiliateMinWithdrawQuota = 0
+
+// AffiliateFraudCheck 邀请人与被邀请人使用过相同 IP 和设备登录时冻结佣金，由管理员审核
+var AffiliateFraudCheck = true
@@ -44,6 +44,10 @@ var LarkAuthEnabled = false
 var TurnstileCheckEnabled = false
 var RegisterEnabled = true
 var OIDCAuthEnabled = false
+var PasskeyLoginEnabled = true
+
+// 管理员账号必须通过两步验证或通行密钥登录后才能访问管理接口
+var TwoFactorRequiredForAdmin = false
 
 // 是否开启内容审查
 var EnableSafe = false
@@ -219,6 +223,12 @@ var GeminiAPIEnabled = true
 // Claude
 var ClaudeAPIEnabled = true
 
+// Claude 批量请求计费折扣
+var ClaudeBatchDiscount = 0.5
+
+// 生成媒体转存后的保留天数，0 为永久保留
+var MediaStorageRetentionDays = 7
+
 const (
 	RoleGuestUser  = 0
 	RoleCommonUser = 1
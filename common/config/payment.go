@@ -3,3 +3,18 @@ package config
 var PaymentUSDRate = 7.3
 var PaymentMinAmount = 1
 var RechargeDiscount = ""
+
+// PaymentQuotaValidDays 在线充值额度的有效天数，0 表示永久有效
+var PaymentQuotaValidDays = 0
+
+// PaymentRefundClawback 订单退款时扣回额度的方式：none 不扣回，available 最多扣到余额为 0，negative 允许余额为负
+var PaymentRefundClawback = "available"
+
+// SubscriptionEnabled 是否开放订阅套餐购买，并按套餐提供模型免费次数
+var SubscriptionEnabled = false
+
+// SubscriptionRemindDays 订阅到期前多少天发送续订提醒，0 表示不提醒
+var SubscriptionRemindDays = 3
+
+// SubscriptionGraceDays 订阅到期后保留分组的宽限天数
+var SubscriptionGraceDays = 3
@@ -5,6 +5,8 @@ const (
 	UsageExtraCachedWrite = "cached_write_tokens" // 缓存写入
 	UsageExtraCachedRead  = "cached_read_tokens"  // 缓存读取
 
+	UsageExtraCachedStorage = "cached_storage_tokens" // 缓存存储（token * 小时）
+
 	UsageExtraInputAudio       = "input_audio_tokens"  // 输入音频
 	UsageExtraOutputAudio      = "output_audio_tokens" // 输出音频
 	UsageExtraReasoning        = "reasoning_tokens"    // 推理
@@ -0,0 +1,97 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// EphemeralTokenPrefix 临时令牌前缀，临时令牌由普通令牌签发，服务端不保存，凭签名校验
+const EphemeralTokenPrefix = "eph-"
+
+var ErrEphemeralTokenInvalid = errors.New("无效的临时令牌")
+
+// EphemeralClaims 临时令牌携带的限制，字段名保持简短以缩短令牌长度
+type EphemeralClaims struct {
+	Id          string   `json:"jti"`
+	Name        string   `json:"nam,omitempty"`
+	TokenId     int      `json:"tid"`
+	TokenName   string   `json:"tnm,omitempty"`
+	UserId      int      `json:"uid"`
+	Group       string   `json:"grp,omitempty"`
+	OrgId       int      `json:"org,omitempty"`
+	Models      []string `json:"mdl,omitempty"`
+	MaxRequests int      `json:"req,omitempty"`
+	MaxQuota    int      `json:"qta,omitempty"`
+	ExpiresAt   int64    `json:"exp"`
+}
+
+// ephemeralKey 由 user_token_secret 派生，与令牌哈希使用不同的密钥
+func ephemeralKey() []byte {
+	mac := hmac.New(sha256.New, jwtSecretBytes)
+	mac.Write([]byte("ephemeral-token"))
+	return mac.Sum(nil)
+}
+
+func signEphemeral(payload string) []byte {
+	mac := hmac.New(sha256.New, ephemeralKey())
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// SignEphemeralToken 生成临时令牌，未指定 Id 时随机生成
+func SignEphemeralToken(claims *EphemeralClaims) (string, error) {
+	if claims.Id == "" {
+		buf := make([]byte, 12)
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		claims.Id = hex.EncodeToString(buf)
+	}
+
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	signature := base64.RawURLEncoding.EncodeToString(signEphemeral(payload))
+
+	return EphemeralTokenPrefix + payload + "." + signature, nil
+}
+
+// ParseEphemeralToken 校验签名和有效期
+func ParseEphemeralToken(token string) (*EphemeralClaims, error) {
+	token = strings.TrimPrefix(token, EphemeralTokenPrefix)
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrEphemeralTokenInvalid
+	}
+
+	decodedSignature, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(decodedSignature, signEphemeral(payload)) {
+		return nil, ErrEphemeralTokenInvalid
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrEphemeralTokenInvalid
+	}
+
+	var claims EphemeralClaims
+	if err := json.Unmarshal(data, &claims); err != nil || claims.Id == "" || claims.TokenId == 0 || claims.UserId == 0 {
+		return nil, ErrEphemeralTokenInvalid
+	}
+
+	if claims.ExpiresAt < time.Now().Unix() {
+		return nil, errors.New("临时令牌已过期")
+	}
+
+	return &claims, nil
+}
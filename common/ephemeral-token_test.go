@@ -0,0 +1,87 @@
+package common_test
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"done-hub/common"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestClaims() *common.EphemeralClaims {
+	return &common.EphemeralClaims{
+		TokenId:   1,
+		UserId:    2,
+		Models:    []string{"gpt-4o"},
+		ExpiresAt: time.Now().Unix() + 600,
+	}
+}
+
+func TestSignEphemeralToken(t *testing.T) {
+	initTestUserToken(t)
+
+	key, err := common.SignEphemeralToken(newTestClaims())
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, common.EphemeralTokenPrefix))
+
+	claims, err := common.ParseEphemeralToken(key)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, claims.Id)
+	assert.Equal(t, 1, claims.TokenId)
+	assert.Equal(t, 2, claims.UserId)
+	assert.Equal(t, []string{"gpt-4o"}, claims.Models)
+}
+
+func TestParseEphemeralTokenTampered(t *testing.T) {
+	initTestUserToken(t)
+
+	key, err := common.SignEphemeralToken(newTestClaims())
+	assert.NoError(t, err)
+	payload, signature, _ := strings.Cut(strings.TrimPrefix(key, common.EphemeralTokenPrefix), ".")
+
+	// 修改内容后签名不再匹配
+	forged := newTestClaims()
+	forged.UserId = 3
+	forgedKey, err := common.SignEphemeralToken(forged)
+	assert.NoError(t, err)
+	forgedPayload, _, _ := strings.Cut(strings.TrimPrefix(forgedKey, common.EphemeralTokenPrefix), ".")
+
+	for _, invalid := range []string{
+		common.EphemeralTokenPrefix + payload,
+		common.EphemeralTokenPrefix + forgedPayload + "." + signature,
+		common.EphemeralTokenPrefix + payload + "." + base64.RawURLEncoding.EncodeToString([]byte("signature")),
+		common.EphemeralTokenPrefix + payload + ".!",
+	} {
+		_, err := common.ParseEphemeralToken(invalid)
+		assert.ErrorIs(t, err, common.ErrEphemeralTokenInvalid, invalid)
+	}
+}
+
+func TestParseEphemeralTokenSecret(t *testing.T) {
+	initTestUserToken(t)
+
+	key, err := common.SignEphemeralToken(newTestClaims())
+	assert.NoError(t, err)
+
+	// 更换 user_token_secret 后已签发的临时令牌失效
+	viper.Set("user_token_secret", "another-user-token-secret")
+	assert.NoError(t, common.InitUserToken())
+	_, err = common.ParseEphemeralToken(key)
+	assert.ErrorIs(t, err, common.ErrEphemeralTokenInvalid)
+}
+
+func TestParseEphemeralTokenExpired(t *testing.T) {
+	initTestUserToken(t)
+
+	claims := newTestClaims()
+	claims.ExpiresAt = time.Now().Unix() - 1
+	key, err := common.SignEphemeralToken(claims)
+	assert.NoError(t, err)
+
+	_, err = common.ParseEphemeralToken(key)
+	assert.Error(t, err)
+}
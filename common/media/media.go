@@ -0,0 +1,426 @@
+package media
+
+import (
+	"context"
+	"done-hub/common/config"
+	"done-hub/common/logger"
+	"done-hub/common/requester"
+	"done-hub/common/storage"
+	"done-hub/common/utils"
+	"done-hub/model"
+	"done-hub/types"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	PlatformImage      = "image"
+	PlatformRecraft    = "recraft"
+	PlatformMidjourney = "midjourney"
+
+	downloadTimeout = 2 * time.Minute
+	maxMediaSize    = 200 * 1024 * 1024 // 200MB
+
+	persistQueueSize   = 1000
+	persistWorkerCount = 4
+)
+
+// persistQueue 异步任务的转存在后台执行，避免阻塞任务轮询
+var persistQueue chan func()
+
+// InitPersistWorker 启动转存后台协程
+func InitPersistWorker() {
+	persistQueue = make(chan func(), persistQueueSize)
+	for i := 0; i < persistWorkerCount; i++ {
+		go func() {
+			for job := range persistQueue {
+				job()
+			}
+		}()
+	}
+}
+
+func enqueue(name string, job func()) {
+	if persistQueue == nil {
+		logger.SysError("media persist worker not started, skip " + name)
+		return
+	}
+
+	select {
+	case persistQueue <- job:
+	default:
+		// 队列已满时保留原地址，不阻塞调用方
+		logger.SysError("media persist queue is full, skip " + name)
+	}
+}
+
+// StorageKey 记录在任务上的存储对象
+type StorageKey struct {
+	Drive string `json:"drive"`
+	Key   string `json:"key"`
+}
+
+// Persister 将生成的媒体下载并上传到存储，同一请求或任务共用一个
+type Persister struct {
+	ctx      context.Context
+	userId   int
+	tokenId  int
+	platform string
+	taskId   string
+	keys     []StorageKey
+}
+
+func NewPersister(ctx context.Context, userId, tokenId int, platform, taskId string) *Persister {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return &Persister{
+		ctx:      ctx,
+		userId:   userId,
+		tokenId:  tokenId,
+		platform: platform,
+		taskId:   taskId,
+	}
+}
+
+// Enabled 根据请求上下文中的令牌设置和分组判断是否需要转存
+func Enabled(c *gin.Context) bool {
+	if !storage.HasDrive() {
+		return false
+	}
+
+	if setting, ok := c.Get("token_setting"); ok {
+		if tokenSetting, ok := setting.(*model.TokenSetting); ok && tokenSetting.MediaStorage {
+			return true
+		}
+	}
+
+	return groupEnabled(c.GetString("token_group"))
+}
+
+// EnabledByToken 异步任务在后台完成时，根据令牌和用户分组判断是否需要转存
+func EnabledByToken(userId, tokenId int) bool {
+	if !storage.HasDrive() {
+		return false
+	}
+
+	group := ""
+	if tokenId > 0 {
+		token, err := model.GetTokenById(tokenId)
+		if err == nil {
+			if token.Setting.Data().MediaStorage {
+				return true
+			}
+			group = token.Group
+		}
+	}
+
+	if group == "" {
+		group, _ = model.CacheGetUserGroup(userId)
+	}
+
+	return groupEnabled(group)
+}
+
+func groupEnabled(group string) bool {
+	userGroup := model.GlobalUserGroupRatio.GetBySymbol(group)
+	return userGroup != nil && userGroup.MediaStorage
+}
+
+// PersistImageResponse 转存图片响应，b64_json 上传后替换为 url
+func PersistImageResponse(c *gin.Context, response *types.ImageResponse) {
+	if response == nil || !Enabled(c) {
+		return
+	}
+
+	persister := NewPersister(c.Request.Context(), c.GetInt("id"), c.GetInt("token_id"), PlatformImage, "")
+	for i := range response.Data {
+		item := &response.Data[i]
+		if item.B64JSON != "" {
+			data, err := base64.StdEncoding.DecodeString(item.B64JSON)
+			if err != nil {
+				continue
+			}
+			if newURL, err := persister.PersistData(data, ""); err == nil {
+				item.URL = newURL
+				item.B64JSON = ""
+			}
+			continue
+		}
+
+		if item.URL != "" {
+			item.URL = persister.PersistURLOrOriginal(item.URL)
+		}
+	}
+}
+
+// PersistTask 异步任务成功后在后台转存 Data 中的媒体地址，需在保存任务后调用
+func PersistTask(task *model.Task) {
+	if task.Status != model.TaskStatusSuccess || len(task.Data) == 0 || !EnabledByToken(task.UserId, task.TokenID) {
+		return
+	}
+
+	id := task.ID
+	data := task.Data
+	persister := NewPersister(nil, task.UserId, task.TokenID, task.Platform, task.TaskID)
+	enqueue("task "+task.TaskID, func() {
+		newData := persister.PersistJSON(data)
+		storageKeys := persister.StorageKeys()
+		if storageKeys == "" {
+			return
+		}
+
+		if err := model.UpdateTaskStorage(id, newData, storageKeys); err != nil {
+			logger.SysError(fmt.Sprintf("update task %d storage failed: %s", id, err.Error()))
+		}
+	})
+}
+
+// PersistMidjourney Midjourney 任务成功后在后台转存图片，需在保存任务后调用
+func PersistMidjourney(task *model.Midjourney) {
+	if task.Status != "SUCCESS" || task.ImageUrl == "" || !EnabledByToken(task.UserId, task.TokenID) {
+		return
+	}
+
+	id := task.Id
+	imageUrl := task.ImageUrl
+	persister := NewPersister(nil, task.UserId, task.TokenID, PlatformMidjourney, task.MjId)
+	enqueue("midjourney "+task.MjId, func() {
+		newURL, err := persister.PersistURL(imageUrl)
+		if err != nil {
+			logger.SysError(fmt.Sprintf("persist media %s failed: %s", imageUrl, err.Error()))
+			return
+		}
+
+		if err := model.UpdateMidjourneyStorage(id, newURL, persister.StorageKeys()); err != nil {
+			logger.SysError(fmt.Sprintf("update midjourney %d storage failed: %s", id, err.Error()))
+		}
+	})
+}
+
+// PersistURL 下载地址内容并上传到存储，返回存储中的地址
+func (p *Persister) PersistURL(source string) (string, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, downloadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := requester.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download media status code: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxMediaSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxMediaSize {
+		return "", errors.New("media file too large")
+	}
+
+	ext := ""
+	if u, err := url.Parse(source); err == nil {
+		ext = path.Ext(u.Path)
+	}
+	if ext == "" || len(ext) > 6 {
+		ext = getExtension(resp.Header.Get("Content-Type"))
+	}
+
+	return p.persist(data, ext, source)
+}
+
+// PersistURLOrOriginal 转存失败时保留原地址
+func (p *Persister) PersistURLOrOriginal(source string) string {
+	newURL, err := p.PersistURL(source)
+	if err != nil {
+		logger.LogError(p.ctx, fmt.Sprintf("persist media %s failed: %s", source, err.Error()))
+		return source
+	}
+
+	return newURL
+}
+
+// PersistData 上传已有的媒体内容，ext 为空时根据内容判断
+func (p *Persister) PersistData(data []byte, ext string) (string, error) {
+	if ext == "" {
+		ext = getExtension(http.DetectContentType(data))
+	}
+
+	newURL, err := p.persist(data, ext, "")
+	if err != nil {
+		logger.LogError(p.ctx, "persist media data failed: "+err.Error())
+	}
+
+	return newURL, err
+}
+
+// PersistJSON 转存 JSON 中所有以 url 结尾的字段，以及 b64_json 字段
+func (p *Persister) PersistJSON(raw []byte) []byte {
+	var data any
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return raw
+	}
+
+	if !p.persistValue(data) {
+		return raw
+	}
+
+	newRaw, err := json.Marshal(data)
+	if err != nil {
+		return raw
+	}
+
+	return newRaw
+}
+
+// StorageKeys 本次转存的对象，用于记录到任务上
+func (p *Persister) StorageKeys() string {
+	if len(p.keys) == 0 {
+		return ""
+	}
+
+	keys, _ := json.Marshal(p.keys)
+	return string(keys)
+}
+
+func (p *Persister) persistValue(value any) (changed bool) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if str, ok := item.(string); ok {
+				if key == "b64_json" && str != "" {
+					data, err := base64.StdEncoding.DecodeString(str)
+					if err != nil {
+						continue
+					}
+					if newURL, err := p.PersistData(data, ""); err == nil {
+						v["url"] = newURL
+						delete(v, "b64_json")
+						changed = true
+					}
+					continue
+				}
+
+				if isMediaURL(key, str) {
+					if newURL := p.PersistURLOrOriginal(str); newURL != str {
+						v[key] = newURL
+						changed = true
+					}
+				}
+				continue
+			}
+
+			if p.persistValue(item) {
+				changed = true
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if p.persistValue(item) {
+				changed = true
+			}
+		}
+	}
+
+	return
+}
+
+func (p *Persister) persist(data []byte, ext, source string) (string, error) {
+	newURL, driveName, key, err := storage.UploadObject(data, utils.GetUUID()+ext)
+	if err != nil {
+		return "", err
+	}
+
+	object := &model.MediaObject{
+		UserId:    p.userId,
+		TokenId:   p.tokenId,
+		Platform:  p.platform,
+		TaskId:    p.taskId,
+		Drive:     driveName,
+		Key:       key,
+		URL:       newURL,
+		SourceURL: source,
+	}
+	if config.MediaStorageRetentionDays > 0 {
+		object.ExpireAt = utils.GetTimestamp() + int64(config.MediaStorageRetentionDays)*86400
+	}
+	if err := object.Insert(); err != nil {
+		logger.LogError(p.ctx, "insert media object failed: "+err.Error())
+	}
+
+	p.keys = append(p.keys, StorageKey{Drive: driveName, Key: key})
+
+	return newURL, nil
+}
+
+// DeleteExpired 删除已过保留期的对象，由定时任务调用
+func DeleteExpired() {
+	objects, err := model.GetExpiredMediaObjects(100)
+	if err != nil {
+		logger.SysError("get expired media objects failed: " + err.Error())
+		return
+	}
+
+	for _, object := range objects {
+		// 删除失败也移除记录，避免反复重试已不存在的对象
+		if object.Key != "" {
+			if err := storage.Delete(object.Drive, object.Key); err != nil {
+				logger.SysError(fmt.Sprintf("delete media object %d failed: %s", object.Id, err.Error()))
+			}
+		}
+
+		if err := object.Delete(); err != nil {
+			logger.SysError("delete media object record failed: " + err.Error())
+		}
+	}
+}
+
+func isMediaURL(key, value string) bool {
+	key = strings.ToLower(key)
+	if !strings.HasSuffix(key, "url") || key == "callback_url" {
+		return false
+	}
+
+	return strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://")
+}
+
+func getExtension(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+
+	switch mediaType {
+	case "image/jpeg":
+		return ".jpg"
+	case "audio/mpeg":
+		return ".mp3"
+	}
+
+	exts, err := mime.ExtensionsByType(mediaType)
+	if err != nil || len(exts) == 0 {
+		return ""
+	}
+
+	return exts[0]
+}
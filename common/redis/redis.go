@@ -79,6 +79,18 @@ func RedisDecrease(key string, value int64) error {
 	return RDB.DecrBy(ctx, key, value).Err()
 }
 
+// RedisIncrBy 增加计数并刷新过期时间，返回增加后的值
+func RedisIncrBy(key string, value int64, expiration time.Duration) (int64, error) {
+	ctx := context.Background()
+	pipe := RDB.TxPipeline()
+	incr := pipe.IncrBy(ctx, key, value)
+	pipe.Expire(ctx, key, expiration)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
 func NewScript(script string) *redis.Script {
 	return redis.NewScript(script)
 }
@@ -0,0 +1,287 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"done-hub/common/logger"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// 密文格式：enc:v1:<主密钥ID>:<被主密钥加密的数据密钥>:<被数据密钥加密的内容>
+// 每个值使用独立的数据密钥，轮换主密钥时只需重新加密数据密钥
+const (
+	encryptedPrefix = "enc:v1:"
+	dataKeySize     = 32
+
+	maskPlaceholder = "********"
+)
+
+var (
+	ErrKeyNotFound  = errors.New("secret: master key not found")
+	ErrInvalidValue = errors.New("secret: invalid encrypted value")
+
+	currentKeyId string
+	masterKeys   = map[string][]byte{}
+)
+
+// InitSecret 读取主密钥，未配置时不加密，已加密的数据仍需要对应的主密钥才能读取
+//
+//	encryption.key_id           当前主密钥 ID
+//	encryption.master_key       当前主密钥，可通过环境变量 ENCRYPTION_MASTER_KEY 设置
+//	encryption.master_key_file  从文件读取当前主密钥，用于对接外部密钥管理挂载的文件
+//	encryption.old_keys         旧主密钥，ID => 密钥，仅用于解密和轮换
+func InitSecret() error {
+	currentKeyId = ""
+	masterKeys = map[string][]byte{}
+
+	for keyId, key := range viper.GetStringMapString("encryption.old_keys") {
+		if err := addMasterKey(keyId, key); err != nil {
+			return err
+		}
+	}
+
+	key := viper.GetString("encryption.master_key")
+	if keyFile := viper.GetString("encryption.master_key_file"); key == "" && keyFile != "" {
+		content, err := os.ReadFile(keyFile)
+		if err != nil {
+			return fmt.Errorf("read master key file failed: %w", err)
+		}
+		key = strings.TrimSpace(string(content))
+	}
+
+	if key == "" {
+		logger.SysLog("encryption master key is not set, channel keys and payment configs will be stored in plaintext")
+		return nil
+	}
+
+	keyId := viper.GetString("encryption.key_id")
+	if keyId == "" {
+		keyId = "default"
+	}
+	if err := addMasterKey(keyId, key); err != nil {
+		return err
+	}
+	currentKeyId = keyId
+
+	return nil
+}
+
+func addMasterKey(keyId, key string) error {
+	if keyId == "" || strings.Contains(keyId, ":") {
+		return fmt.Errorf("invalid master key id: %s", keyId)
+	}
+
+	// 支持任意长度的密钥字符串，统一派生为 256 位
+	sum := sha256.Sum256([]byte(key))
+	masterKeys[keyId] = sum[:]
+
+	return nil
+}
+
+// Enabled 是否配置了当前主密钥
+func Enabled() bool {
+	return currentKeyId != ""
+}
+
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// Encrypt 使用当前主密钥加密，未配置主密钥、值为空或已加密时原样返回
+func Encrypt(plaintext string) (string, error) {
+	if !Enabled() || plaintext == "" || IsEncrypted(plaintext) {
+		return plaintext, nil
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	sealedData, err := seal(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+
+	sealedKey, err := seal(masterKeys[currentKeyId], dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	return encryptedPrefix + currentKeyId + ":" + sealedKey + ":" + sealedData, nil
+}
+
+// Decrypt 解密，未加密的旧数据原样返回
+func Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	_, dataKey, sealedData, err := openDataKey(value)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := open(dataKey, sealedData)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// Rotate 使用当前主密钥重新加密数据密钥，内容密文保持不变；未加密的值直接加密
+func Rotate(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return Encrypt(value)
+	}
+
+	if !Enabled() {
+		return "", ErrKeyNotFound
+	}
+
+	keyId, dataKey, sealedData, err := openDataKey(value)
+	if err != nil {
+		return "", err
+	}
+	if keyId == currentKeyId {
+		return value, nil
+	}
+
+	sealedKey, err := seal(masterKeys[currentKeyId], dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	return encryptedPrefix + currentKeyId + ":" + sealedKey + ":" + sealedData, nil
+}
+
+func openDataKey(value string) (keyId string, dataKey []byte, sealedData string, err error) {
+	parts := strings.Split(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if len(parts) != 3 {
+		return "", nil, "", ErrInvalidValue
+	}
+
+	masterKey, ok := masterKeys[parts[0]]
+	if !ok {
+		return "", nil, "", fmt.Errorf("%w: %s", ErrKeyNotFound, parts[0])
+	}
+
+	dataKey, err = open(masterKey, parts[1])
+	if err != nil {
+		return "", nil, "", err
+	}
+
+	return parts[0], dataKey, parts[2], nil
+}
+
+func seal(key, plaintext []byte) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return base64.RawStdEncoding.EncodeToString(gcm.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+func open(key []byte, sealed string) ([]byte, error) {
+	data, err := base64.RawStdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, ErrInvalidValue
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, ErrInvalidValue
+	}
+
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrInvalidValue
+	}
+
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// Mask 管理接口中展示的脱敏值，只保留首尾少量字符
+func Mask(plaintext string) string {
+	if plaintext == "" {
+		return ""
+	}
+
+	if len(plaintext) <= 12 {
+		return maskPlaceholder
+	}
+
+	return plaintext[:4] + maskPlaceholder + plaintext[len(plaintext)-4:]
+}
+
+// IsMasked 提交的值是否为脱敏值，是则表示未修改
+func IsMasked(value string) bool {
+	return strings.Contains(value, maskPlaceholder)
+}
+
+// MaskJSON 对 JSON 对象的每个字符串字段脱敏，保留字段结构方便前端编辑
+func MaskJSON(plaintext string) string {
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(plaintext), &fields); err != nil {
+		return Mask(plaintext)
+	}
+
+	for key, value := range fields {
+		if str, ok := value.(string); ok {
+			fields[key] = Mask(str)
+		}
+	}
+
+	masked, _ := json.Marshal(fields)
+	return string(masked)
+}
+
+// MergeMaskedJSON 将提交的 JSON 中仍为脱敏值的字段还原为原值
+func MergeMaskedJSON(submitted, stored string) string {
+	if !IsMasked(submitted) {
+		return submitted
+	}
+
+	var submittedFields, storedFields map[string]any
+	if json.Unmarshal([]byte(submitted), &submittedFields) != nil || json.Unmarshal([]byte(stored), &storedFields) != nil {
+		return stored
+	}
+
+	for key, value := range submittedFields {
+		if str, ok := value.(string); ok && IsMasked(str) {
+			submittedFields[key] = storedFields[key]
+		}
+	}
+
+	merged, _ := json.Marshal(submittedFields)
+	return string(merged)
+}
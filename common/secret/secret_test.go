@@ -0,0 +1,119 @@
+package secret_test
+
+import (
+	"strings"
+	"testing"
+
+	"done-hub/common/logger"
+	"done-hub/common/secret"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func initTestSecret(t *testing.T, keyId, masterKey string, oldKeys map[string]string) {
+	logger.Logger = zap.NewNop()
+	viper.Set("encryption.key_id", keyId)
+	viper.Set("encryption.master_key", masterKey)
+	viper.Set("encryption.master_key_file", "")
+	viper.Set("encryption.old_keys", oldKeys)
+	assert.NoError(t, secret.InitSecret())
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	initTestSecret(t, "k1", "master-key-1", nil)
+
+	plaintext := "sk-1234567890abcdef"
+	encrypted, err := secret.Encrypt(plaintext)
+	assert.NoError(t, err)
+	assert.True(t, secret.IsEncrypted(encrypted))
+	assert.NotContains(t, encrypted, plaintext)
+
+	// 每个值使用独立的数据密钥，相同明文的密文也不同
+	other, err := secret.Encrypt(plaintext)
+	assert.NoError(t, err)
+	assert.NotEqual(t, encrypted, other)
+
+	decrypted, err := secret.Decrypt(encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+
+	// 已加密的值不重复加密
+	again, err := secret.Encrypt(encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, encrypted, again)
+}
+
+func TestEncryptDisabled(t *testing.T) {
+	initTestSecret(t, "", "", nil)
+
+	value, err := secret.Encrypt("plain")
+	assert.NoError(t, err)
+	assert.Equal(t, "plain", value)
+
+	value, err = secret.Decrypt("plain")
+	assert.NoError(t, err)
+	assert.Equal(t, "plain", value)
+}
+
+func TestDecryptTampered(t *testing.T) {
+	initTestSecret(t, "k1", "master-key-1", nil)
+
+	encrypted, err := secret.Encrypt("sk-1234567890abcdef")
+	assert.NoError(t, err)
+
+	tampered := encrypted[:len(encrypted)-2] + "AA"
+	_, err = secret.Decrypt(tampered)
+	assert.Error(t, err)
+
+	_, err = secret.Decrypt(strings.Replace(encrypted, ":k1:", ":missing:", 1))
+	assert.Error(t, err)
+}
+
+func TestRotate(t *testing.T) {
+	initTestSecret(t, "k1", "master-key-1", nil)
+	encrypted, err := secret.Encrypt("sk-1234567890abcdef")
+	assert.NoError(t, err)
+
+	// 换用新主密钥后，旧数据仍可通过旧主密钥解密，轮换后只依赖新主密钥
+	initTestSecret(t, "k2", "master-key-2", map[string]string{"k1": "master-key-1"})
+	rotated, err := secret.Rotate(encrypted)
+	assert.NoError(t, err)
+	assert.NotEqual(t, encrypted, rotated)
+
+	initTestSecret(t, "k2", "master-key-2", nil)
+	decrypted, err := secret.Decrypt(rotated)
+	assert.NoError(t, err)
+	assert.Equal(t, "sk-1234567890abcdef", decrypted)
+
+	_, err = secret.Decrypt(encrypted)
+	assert.Error(t, err)
+}
+
+func TestMask(t *testing.T) {
+	assert.Equal(t, "", secret.Mask(""))
+
+	short := secret.Mask("short")
+	assert.True(t, secret.IsMasked(short))
+	assert.NotContains(t, short, "short")
+
+	masked := secret.Mask("sk-1234567890abcdef")
+	assert.True(t, secret.IsMasked(masked))
+	assert.True(t, strings.HasPrefix(masked, "sk-1"))
+	assert.True(t, strings.HasSuffix(masked, "cdef"))
+	assert.NotContains(t, masked, "567890")
+
+	assert.False(t, secret.IsMasked("sk-1234567890abcdef"))
+}
+
+func TestMergeMaskedJSON(t *testing.T) {
+	stored := `{"app_id":"app-1234567890","secret":"secret-1234567890"}`
+	masked := secret.MaskJSON(stored)
+	assert.True(t, secret.IsMasked(masked))
+	assert.NotContains(t, masked, "secret-1234567890")
+
+	// 未修改的字段还原为原值，修改过的字段使用提交的值
+	submitted := strings.Replace(masked, secret.Mask("app-1234567890"), "app-new", 1)
+	assert.JSONEq(t, `{"app_id":"app-new","secret":"secret-1234567890"}`, secret.MergeMaskedJSON(submitted, stored))
+}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/wneessen/go-mail"
 )
@@ -177,6 +178,35 @@ func SendQuotaWarningCodeEmail(userName, email string, quota int, noMoreQuota bo
 	return stmp.Render(email, subject, content)
 }
 
+func SendSubscriptionReminderEmail(userName, email, planName string, expiresAt int64, graceDays int) error {
+	stmp, err := GetSystemStmp()
+
+	if err != nil {
+		return err
+	}
+
+	contentTemp := `<p style="font-size: 30px">Hi <strong>%s,</strong></p>
+		<p>
+			您订阅的套餐 <strong>%s</strong> 将于 %s 到期，到期后保留 %d 天宽限期，宽限期结束后将恢复原分组。为了不影响您的使用，请及时续订。
+		</p>
+		
+		<p style="text-align: center; font-size: 13px;">
+			<a target="__blank" href="%s" class="button" style="color: #ffffff;">立即续订</a>
+		</p>
+		
+		<p style="color: #858585; padding-top: 15px;">
+			如果链接无法点击，请尝试点击下面的链接或将其复制到浏览器中打开<br> %s
+		</p>`
+
+	subject := "您的订阅即将到期"
+	renewLink := fmt.Sprintf("%s/topup", config.ServerAddress)
+	expiresTime := time.Unix(expiresAt, 0).Format("2006-01-02 15:04")
+
+	content := fmt.Sprintf(contentTemp, userName, planName, expiresTime, graceDays, renewLink, renewLink)
+
+	return stmp.Render(email, subject, content)
+}
+
 func DialAndSend(c *mail.Client, messages ...*mail.Msg) error {
 	ctx := context.Background()
 	if err := c.DialWithContext(ctx); err != nil {
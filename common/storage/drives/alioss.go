@@ -3,6 +3,9 @@ package drives
 import (
 	"bytes"
 	"fmt"
+	"net/url"
+	"strings"
+
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 )
 
@@ -11,14 +14,16 @@ type AliOSSUpload struct {
 	AccessKeyId     string
 	AccessKeySecret string
 	BucketName      string
+	CustomDomain    string // 转存对象的访问域名，为空时使用 Bucket 公共读地址
 }
 
-func NewAliOSSUpload(endpoint, accessKeyId, accessKeySecret, bucketName string) *AliOSSUpload {
+func NewAliOSSUpload(endpoint, accessKeyId, accessKeySecret, bucketName, cdnurl string) *AliOSSUpload {
 	return &AliOSSUpload{
 		Endpoint:        endpoint,
 		AccessKeyId:     accessKeyId,
 		AccessKeySecret: accessKeySecret,
 		BucketName:      bucketName,
+		CustomDomain:    strings.TrimSuffix(cdnurl, "/"),
 	}
 }
 
@@ -27,16 +32,9 @@ func (a *AliOSSUpload) Name() string {
 }
 
 func (a *AliOSSUpload) Upload(data []byte, fileName string) (string, error) {
-	// Create OSS Client
-	client, err := oss.New(a.Endpoint, a.AccessKeyId, a.AccessKeySecret)
-	if err != nil {
-		return "", fmt.Errorf("creating OSS client: %w", err)
-	}
-
-	// Create Bucket
-	bucket, err := client.Bucket(a.BucketName)
+	bucket, err := a.getBucket()
 	if err != nil {
-		return "", fmt.Errorf("getting bucket: %w", err)
+		return "", err
 	}
 
 	// Upload File
@@ -54,3 +52,67 @@ func (a *AliOSSUpload) Upload(data []byte, fileName string) (string, error) {
 
 	return objectURL, nil
 }
+
+// UploadObject 上传并返回文件名作为对象 key
+// 转存的对象需要长期访问，不能使用会过期的签名地址，返回自定义域名或公共读地址
+func (a *AliOSSUpload) UploadObject(data []byte, fileName string) (string, string, error) {
+	bucket, err := a.getBucket()
+	if err != nil {
+		return "", "", err
+	}
+
+	reader := bytes.NewReader(data)
+	err = bucket.PutObject(fileName, reader)
+	if err != nil {
+		return "", "", fmt.Errorf("uploading file: %w", err)
+	}
+
+	return a.objectURL(fileName), fileName, nil
+}
+
+func (a *AliOSSUpload) objectURL(key string) string {
+	if a.CustomDomain != "" {
+		return fmt.Sprintf("%s/%s", a.CustomDomain, key)
+	}
+
+	endpoint := a.Endpoint
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return fmt.Sprintf("https://%s.%s/%s", a.BucketName, a.Endpoint, key)
+	}
+
+	return fmt.Sprintf("%s://%s.%s/%s", u.Scheme, a.BucketName, u.Host, key)
+}
+
+func (a *AliOSSUpload) Delete(key string) error {
+	bucket, err := a.getBucket()
+	if err != nil {
+		return err
+	}
+
+	if err = bucket.DeleteObject(key); err != nil {
+		return fmt.Errorf("deleting file: %w", err)
+	}
+
+	return nil
+}
+
+func (a *AliOSSUpload) getBucket() (*oss.Bucket, error) {
+	// Create OSS Client
+	client, err := oss.New(a.Endpoint, a.AccessKeyId, a.AccessKeySecret)
+	if err != nil {
+		return nil, fmt.Errorf("creating OSS client: %w", err)
+	}
+
+	// Create Bucket
+	bucket, err := client.Bucket(a.BucketName)
+	if err != nil {
+		return nil, fmt.Errorf("getting bucket: %w", err)
+	}
+
+	return bucket, nil
+}
@@ -26,7 +26,8 @@ type ImgurResponse struct {
 }
 
 type ImgurData struct {
-	Link string `json:"link"`
+	Link       string `json:"link"`
+	DeleteHash string `json:"deletehash"`
 }
 
 func (i *ImgurUpload) Name() string {
@@ -34,6 +35,12 @@ func (i *ImgurUpload) Name() string {
 }
 
 func (i *ImgurUpload) Upload(data []byte, fileName string) (string, error) {
+	url, _, err := i.UploadObject(data, fileName)
+	return url, err
+}
+
+// UploadObject 上传并返回 deletehash 作为对象 key
+func (i *ImgurUpload) UploadObject(data []byte, fileName string) (string, string, error) {
 	client := requester.NewHTTPRequester("", nil)
 
 	var formBody bytes.Buffer
@@ -41,7 +48,7 @@ func (i *ImgurUpload) Upload(data []byte, fileName string) (string, error) {
 
 	err := builder.CreateFormFileReader("image", bytes.NewReader(data), fileName)
 	if err != nil {
-		return "", fmt.Errorf("creating form file: %w", err)
+		return "", "", fmt.Errorf("creating form file: %w", err)
 	}
 	builder.Close()
 
@@ -58,7 +65,7 @@ func (i *ImgurUpload) Upload(data []byte, fileName string) (string, error) {
 	req.ContentLength = int64(formBody.Len())
 
 	if err != nil {
-		return "", fmt.Errorf("new request failed: %w", err)
+		return "", "", fmt.Errorf("new request failed: %w", err)
 	}
 
 	defer req.Body.Close()
@@ -66,12 +73,37 @@ func (i *ImgurUpload) Upload(data []byte, fileName string) (string, error) {
 	imgurResponse := &ImgurResponse{}
 	_, errWithCode := client.SendRequest(req, imgurResponse, false)
 	if errWithCode != nil {
-		return "", fmt.Errorf("%s", errWithCode.Message)
+		return "", "", fmt.Errorf("%s", errWithCode.Message)
+	}
+
+	if !imgurResponse.Success {
+		return "", "", fmt.Errorf("upload failed Status: %d", imgurResponse.Status)
+	}
+
+	return imgurResponse.Data.Link, imgurResponse.Data.DeleteHash, nil
+}
+
+func (i *ImgurUpload) Delete(key string) error {
+	client := requester.NewHTTPRequester("", nil)
+
+	headers := map[string]string{
+		"Authorization": "Client-ID " + i.ClientId,
+	}
+
+	req, err := client.NewRequest(http.MethodDelete, imgurUploadURL+"/"+key, client.WithHeader(headers))
+	if err != nil {
+		return fmt.Errorf("new request failed: %w", err)
+	}
+
+	imgurResponse := &ImgurResponse{}
+	_, errWithCode := client.SendRequest(req, imgurResponse, false)
+	if errWithCode != nil {
+		return fmt.Errorf("%s", errWithCode.Message)
 	}
 
 	if !imgurResponse.Success {
-		return "", fmt.Errorf("upload failed Status: %d", imgurResponse.Status)
+		return fmt.Errorf("delete failed Status: %d", imgurResponse.Status)
 	}
 
-	return imgurResponse.Data.Link, nil
+	return nil
 }
@@ -40,24 +40,17 @@ func (a *S3Upload) Name() string {
 }
 
 func (a *S3Upload) Upload(data []byte, s3Key string) (string, error) {
+	url, _, err := a.UploadObject(data, s3Key)
+	return url, err
+}
 
-	// 创建 S3 会话
-	sess, err := session.NewSession(&aws.Config{
-		Credentials: credentials.NewStaticCredentials(
-			a.AccessKeyId,
-			a.AccessKeySecret,
-			"",
-		),
-		Endpoint:         aws.String(a.EndPoint),
-		Region:           aws.String("auto"),
-		S3ForcePathStyle: aws.Bool(true),
-	})
+// UploadObject 上传并返回带日期前缀的完整 key
+func (a *S3Upload) UploadObject(data []byte, s3Key string) (string, string, error) {
+	svc, err := a.newClient()
 	if err != nil {
-		return "", fmt.Errorf("failed to create session: %v", err)
+		return "", "", err
 	}
 
-	svc := s3.New(sess)
-
 	// 获取当前日期作为文件名前缀
 	now := time.Now()
 	datePrefix := fmt.Sprintf("%d-%02d-%02d/", now.Year(), now.Month(), now.Day())
@@ -73,7 +66,7 @@ func (a *S3Upload) Upload(data []byte, s3Key string) (string, error) {
 
 	if err == nil {
 		// 文件已存在，直接返回自定义域名 URL
-		return fmt.Sprintf("%s/%s", a.CustomDomain, datedKey), nil
+		return fmt.Sprintf("%s/%s", a.CustomDomain, datedKey), datedKey, nil
 	}
 	fileBytes := bytes.NewReader(data)
 
@@ -95,8 +88,44 @@ func (a *S3Upload) Upload(data []byte, s3Key string) (string, error) {
 	_, err = svc.PutObject(putObjectInput)
 
 	if err != nil {
-		return "", fmt.Errorf("failed to upload file to S3: %v", err)
+		return "", "", fmt.Errorf("failed to upload file to S3: %v", err)
+	}
+
+	return fmt.Sprintf("%s/%s", a.CustomDomain, datedKey), datedKey, nil
+}
+
+func (a *S3Upload) Delete(key string) error {
+	svc, err := a.newClient()
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(a.BucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete file from S3: %v", err)
+	}
+
+	return nil
+}
+
+func (a *S3Upload) newClient() (*s3.S3, error) {
+	// 创建 S3 会话
+	sess, err := session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentials(
+			a.AccessKeyId,
+			a.AccessKeySecret,
+			"",
+		),
+		Endpoint:         aws.String(a.EndPoint),
+		Region:           aws.String("auto"),
+		S3ForcePathStyle: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %v", err)
 	}
 
-	return fmt.Sprintf("%s/%s", a.CustomDomain, datedKey), nil
+	return s3.New(sess), nil
 }
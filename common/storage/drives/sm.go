@@ -8,6 +8,7 @@ import (
 )
 
 var smUploadURL = "https://sm.ms/api/v2/upload"
+var smDeleteURL = "https://sm.ms/api/v2/delete/"
 
 type SMUpload struct {
 	Secret string
@@ -22,7 +23,7 @@ type SMData struct {
 	// Storename string `json:"storename"`
 	// Size      int    `json:"size"`
 	// Path      string `json:"path"`
-	// Hash      string `json:"hash"`
+	Hash string `json:"hash"`
 	// Delete    string `json:"delete"`
 	// Page      string `json:"page"`
 }
@@ -46,6 +47,12 @@ func (sm *SMUpload) Name() string {
 }
 
 func (sm *SMUpload) Upload(data []byte, fileName string) (string, error) {
+	url, _, err := sm.UploadObject(data, fileName)
+	return url, err
+}
+
+// UploadObject 上传并返回图片 hash 作为对象 key
+func (sm *SMUpload) UploadObject(data []byte, fileName string) (string, string, error) {
 	client := requester.NewHTTPRequester("", nil)
 
 	var formBody bytes.Buffer
@@ -53,7 +60,7 @@ func (sm *SMUpload) Upload(data []byte, fileName string) (string, error) {
 
 	err := builder.CreateFormFileReader("smfile", bytes.NewReader(data), fileName)
 	if err != nil {
-		return "", fmt.Errorf("creating form file: %w", err)
+		return "", "", fmt.Errorf("creating form file: %w", err)
 	}
 	builder.WriteField("format", "json")
 	builder.Close()
@@ -72,7 +79,7 @@ func (sm *SMUpload) Upload(data []byte, fileName string) (string, error) {
 	req.ContentLength = int64(formBody.Len())
 
 	if err != nil {
-		return "", fmt.Errorf("new request failed: %w", err)
+		return "", "", fmt.Errorf("new request failed: %w", err)
 	}
 
 	defer req.Body.Close()
@@ -80,12 +87,37 @@ func (sm *SMUpload) Upload(data []byte, fileName string) (string, error) {
 	smResponse := &SMResponse{}
 	_, errWithCode := client.SendRequest(req, smResponse, false)
 	if errWithCode != nil {
-		return "", fmt.Errorf("%s", errWithCode.Message)
+		return "", "", fmt.Errorf("%s", errWithCode.Message)
+	}
+
+	if !smResponse.Success {
+		return "", "", fmt.Errorf("upload failed: %s", smResponse.Message)
+	}
+
+	return smResponse.Data.URL, smResponse.Data.Hash, nil
+}
+
+func (sm *SMUpload) Delete(key string) error {
+	client := requester.NewHTTPRequester("", nil)
+
+	headers := map[string]string{
+		"Authorization": sm.Secret,
+	}
+
+	req, err := client.NewRequest(http.MethodGet, smDeleteURL+key+"?format=json", client.WithHeader(headers))
+	if err != nil {
+		return fmt.Errorf("new request failed: %w", err)
+	}
+
+	smResponse := &SMResponse{}
+	_, errWithCode := client.SendRequest(req, smResponse, false)
+	if errWithCode != nil {
+		return fmt.Errorf("%s", errWithCode.Message)
 	}
 
 	if !smResponse.Success {
-		return "", fmt.Errorf("upload failed: %s", smResponse.Message)
+		return fmt.Errorf("delete failed: %s", smResponse.Message)
 	}
 
-	return smResponse.Data.URL, nil
+	return nil
 }
@@ -36,7 +36,9 @@ func InitALIOSSStorage() {
 		return
 	}
 
-	aliUpload := drives.NewAliOSSUpload(endpoint, accessKeyId, accessKeySecret, bucketName)
+	cdnurl := viper.GetString("storage.alioss.cdnurl")
+
+	aliUpload := drives.NewAliOSSUpload(endpoint, accessKeyId, accessKeySecret, bucketName, cdnurl)
 	AddStorageDrive(aliUpload)
 }
 
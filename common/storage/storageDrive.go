@@ -7,6 +7,13 @@ type StorageDrive interface {
 	Name() string
 }
 
+// ObjectStorageDrive 可返回对象 key 并按 key 删除的存储，用于需要清理的持久化文件
+type ObjectStorageDrive interface {
+	StorageDrive
+	UploadObject(data []byte, fileName string) (url string, key string, err error)
+	Delete(key string) error
+}
+
 func New() *Storage {
 	storageDrive := &Storage{
 		drives: make(map[string]StorageDrive, 0),
@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"done-hub/common/logger"
+	"errors"
 	"fmt"
 )
 
@@ -32,3 +33,47 @@ func Upload(data []byte, fileName string) string {
 
 	return storageDrives.Upload(ctx, data, fileName)
 }
+
+func (s *Storage) UploadObject(ctx context.Context, data []byte, fileName string) (url, driveName, key string, err error) {
+	for name, drive := range s.drives {
+		objectDrive, ok := drive.(ObjectStorageDrive)
+		if !ok {
+			continue
+		}
+
+		url, key, err = objectDrive.UploadObject(data, fileName)
+		if err != nil {
+			logger.LogError(ctx, fmt.Sprintf("%s err: %s", name, err.Error()))
+			continue
+		}
+
+		return url, name, key, nil
+	}
+
+	return "", "", "", errors.New("no available storage drive")
+}
+
+func (s *Storage) Delete(driveName, key string) error {
+	objectDrive, ok := s.drives[driveName].(ObjectStorageDrive)
+	if !ok {
+		return fmt.Errorf("storage drive %s not found", driveName)
+	}
+
+	return objectDrive.Delete(key)
+}
+
+// UploadObject 上传文件，返回地址、所用存储名称及对象 key，删除时需要后两者
+func UploadObject(data []byte, fileName string) (url, driveName, key string, err error) {
+	//lint:ignore SA1029 reason: 需要使用该类型作为错误处理
+	ctx := context.WithValue(context.Background(), logger.RequestIdKey, "UploadObject")
+
+	return storageDrives.UploadObject(ctx, data, fileName)
+}
+
+func Delete(driveName, key string) error {
+	return storageDrives.Delete(driveName, key)
+}
+
+func HasDrive() bool {
+	return len(storageDrives.drives) > 0
+}
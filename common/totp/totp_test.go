@@ -0,0 +1,49 @@
+package totp_test
+
+import (
+	"testing"
+	"time"
+
+	"done-hub/common/totp"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// RFC 6238 附录 B 的 SHA1 测试向量，取后 6 位
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGenerate(t *testing.T) {
+	cases := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, expected := range cases {
+		code, err := totp.Generate(rfcSecret, time.Unix(unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, code, unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code, _ := totp.Generate(rfcSecret, now)
+
+	step, ok := totp.Validate(rfcSecret, code, now)
+	assert.True(t, ok)
+	assert.Equal(t, now.Unix()/30, step)
+
+	// 允许前后一个时间窗口的误差，返回口令实际所在的时间步
+	step, ok = totp.Validate(rfcSecret, code, now.Add(30*time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, now.Unix()/30, step)
+
+	_, ok = totp.Validate(rfcSecret, code, now.Add(90*time.Second))
+	assert.False(t, ok)
+	_, ok = totp.Validate(rfcSecret, "12345", now)
+	assert.False(t, ok)
+	_, ok = totp.Validate("not base32!", code, now)
+	assert.False(t, ok)
+}
@@ -3,17 +3,27 @@ package common
 import (
 	"bytes"
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"hash"
+	"strings"
 	"sync"
 
 	"github.com/spf13/viper"
 	"github.com/sqids/sqids-go"
 )
 
+const (
+	// 随机密钥的字节数，base64 编码后为 48 个字符
+	secretTokenBytes     = 36
+	secretTokenLength    = 48
+	secretTokenPrefixLen = 4
+)
+
 var (
 	hashidsMinLength = 15
 	hashids          *sqids.Sqids
@@ -102,3 +112,48 @@ func ValidateToken(token string) (tokenID, userID int, err error) {
 
 	return int(numbers[0]), int(numbers[1]), nil
 }
+
+// GenerateSecretToken 生成带随机密钥的令牌，服务端只保存哈希，完整令牌仅在生成时返回
+// prefix 由 id 编码和密钥前几位组成，用于在列表中辨认令牌
+func GenerateSecretToken(tokenID, userID int) (token, prefix string, err error) {
+	payload, err := hashids.Encode([]uint64{uint64(tokenID), uint64(userID)})
+	if err != nil {
+		return "", "", err
+	}
+
+	secretBytes := make([]byte, secretTokenBytes)
+	if _, err = rand.Read(secretBytes); err != nil {
+		return "", "", err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(secretBytes)
+
+	return payload + "_" + secret, payload + "_" + secret[:secretTokenPrefixLen], nil
+}
+
+// ParseSecretToken 解析 GenerateSecretToken 生成的令牌中的 id，不校验密钥
+func ParseSecretToken(token string) (tokenID, userID int, err error) {
+	parts := strings.SplitN(token, "_", 2)
+	if len(parts) != 2 || len(parts[1]) != secretTokenLength {
+		return 0, 0, fmt.Errorf("无效的令牌")
+	}
+
+	numbers := hashids.Decode(parts[0])
+	if len(numbers) != 2 {
+		return 0, 0, fmt.Errorf("无效的令牌")
+	}
+
+	return int(numbers[0]), int(numbers[1]), nil
+}
+
+// HashToken 令牌的带密钥哈希，用于存储和查找
+func HashToken(token string) string {
+	h := hmacPool.Get().(hash.Hash)
+	defer func() {
+		h.Reset()
+		hmacPool.Put(h)
+	}()
+
+	h.Write([]byte(token))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,60 @@
+package common_test
+
+import (
+	"strings"
+	"testing"
+
+	"done-hub/common"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func initTestUserToken(t *testing.T) {
+	viper.Set("user_token_secret", "test-user-token-secret")
+	viper.Set("hashids_salt", "")
+	assert.NoError(t, common.InitUserToken())
+}
+
+func TestGenerateSecretToken(t *testing.T) {
+	initTestUserToken(t)
+
+	token, prefix, err := common.GenerateSecretToken(12, 34)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, prefix))
+	assert.NotEqual(t, token, prefix)
+
+	tokenId, userId, err := common.ParseSecretToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, 12, tokenId)
+	assert.Equal(t, 34, userId)
+
+	// 每次生成的密钥都不同
+	other, _, err := common.GenerateSecretToken(12, 34)
+	assert.NoError(t, err)
+	assert.NotEqual(t, token, other)
+}
+
+func TestParseSecretTokenInvalid(t *testing.T) {
+	initTestUserToken(t)
+
+	token, prefix, err := common.GenerateSecretToken(1, 1)
+	assert.NoError(t, err)
+
+	for _, key := range []string{"", "invalid", prefix, token + "x", "_" + strings.SplitN(token, "_", 2)[1]} {
+		_, _, err := common.ParseSecretToken(key)
+		assert.Error(t, err, key)
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	initTestUserToken(t)
+
+	token, _, err := common.GenerateSecretToken(1, 2)
+	assert.NoError(t, err)
+
+	hash := common.HashToken(token)
+	assert.Len(t, hash, 64)
+	assert.Equal(t, hash, common.HashToken(token))
+	assert.NotEqual(t, hash, common.HashToken(token+"x"))
+}
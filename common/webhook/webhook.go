@@ -0,0 +1,298 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"done-hub/common"
+	"done-hub/common/logger"
+	"done-hub/common/utils"
+	"done-hub/model"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	EventTaskSucceeded = "task.succeeded"
+	EventTaskFailed    = "task.failed"
+
+	requestTimeout   = 10 * time.Second
+	maxResponseBytes = 1024
+)
+
+// 失败后的重试间隔，用完后标记为投递失败
+var retryBackoffs = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	30 * time.Minute,
+	2 * time.Hour,
+	6 * time.Hour,
+}
+
+type Payload struct {
+	Event      string `json:"event"`
+	Platform   string `json:"platform"`
+	TaskId     string `json:"task_id"`
+	Action     string `json:"action"`
+	Status     string `json:"status"`
+	FailReason string `json:"fail_reason,omitempty"`
+	Data       any    `json:"data,omitempty"`
+	Timestamp  int64  `json:"timestamp"`
+}
+
+var errForbiddenAddress = errors.New("回调地址不能指向内网或本机地址")
+
+// httpClient 回调专用的客户端，不走代理，建立连接时校验实际连接的 IP，重定向和 DNS 重绑定同样会被拦截
+var httpClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: requestTimeout,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if !isPublicIP(net.ParseIP(host)) {
+					return errForbiddenAddress
+				}
+				return nil
+			},
+		}).DialContext,
+		MaxIdleConnsPerHost: 4,
+	},
+}
+
+// isPublicIP 排除回环、内网、链路本地、组播和运营商级 NAT 地址
+func isPublicIP(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	if ip4 := ip.To4(); ip4 != nil && ip4[0] == 100 && ip4[1]&0xc0 == 64 {
+		return false
+	}
+	return true
+}
+
+// ValidateURL 回调地址只允许 http/https，且不能是本机或内网地址；域名解析后的地址在投递时校验
+func ValidateURL(hook string) error {
+	u, err := url.Parse(hook)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return errors.New("回调地址无效，仅支持 http/https")
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errForbiddenAddress
+	}
+	if ip := net.ParseIP(host); ip != nil && !isPublicIP(ip) {
+		return errForbiddenAddress
+	}
+
+	return nil
+}
+
+// Sign 签名内容为 "时间戳.请求体"，结果为 HMAC-SHA256 的十六进制
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// TaskFinished 任务进入终态时投递回调
+func TaskFinished(task *model.Task) {
+	if task.NotifyHook == "" {
+		return
+	}
+
+	event := EventTaskSucceeded
+	if task.Status == model.TaskStatusFailure {
+		event = EventTaskFailed
+	}
+
+	payload := &Payload{
+		Event:      event,
+		Platform:   task.Platform,
+		TaskId:     task.TaskID,
+		Action:     task.Action,
+		Status:     string(task.Status),
+		FailReason: task.FailReason,
+		Data:       json.RawMessage(task.Data),
+		Timestamp:  utils.GetTimestamp(),
+	}
+	if len(task.Data) == 0 {
+		payload.Data = nil
+	}
+
+	enqueue(task.UserId, task.TokenID, task.NotifyHook, payload)
+}
+
+// MidjourneyFinished Midjourney 任务进入终态时投递回调
+func MidjourneyFinished(task *model.Midjourney) {
+	if task.NotifyHook == "" {
+		return
+	}
+
+	event := EventTaskSucceeded
+	if task.Status == "FAILURE" {
+		event = EventTaskFailed
+	}
+
+	enqueue(task.UserId, task.TokenID, task.NotifyHook, &Payload{
+		Event:      event,
+		Platform:   "midjourney",
+		TaskId:     task.MjId,
+		Action:     task.Action,
+		Status:     task.Status,
+		FailReason: task.FailReason,
+		Data: map[string]any{
+			"image_url":   task.ImageUrl,
+			"progress":    task.Progress,
+			"prompt_en":   task.PromptEn,
+			"finish_time": task.FinishTime,
+		},
+		Timestamp: utils.GetTimestamp(),
+	})
+}
+
+func enqueue(userId, tokenId int, hook string, payload *Payload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logger.SysError("marshal webhook payload failed: " + err.Error())
+		return
+	}
+
+	delivery := &model.WebhookDelivery{
+		UserId:   userId,
+		TokenId:  tokenId,
+		Platform: payload.Platform,
+		TaskId:   payload.TaskId,
+		Event:    payload.Event,
+		URL:      hook,
+		Payload:  string(body),
+		Status:   model.WebhookDeliveryStatusPending,
+		// 首次投递由当前协程完成，避免重试任务同时拾取
+		NextRetryAt: utils.GetTimestamp() + int64(retryBackoffs[0].Seconds()),
+	}
+	if err := delivery.Insert(); err != nil {
+		logger.SysError("insert webhook delivery failed: " + err.Error())
+		return
+	}
+
+	common.SafeGoroutine(func() {
+		Deliver(delivery)
+	})
+}
+
+// Deliver 投递一次，并根据结果安排下次重试
+func Deliver(delivery *model.WebhookDelivery) error {
+	delivery.Attempts++
+	code, err := send(delivery)
+	delivery.ResponseCode = code
+
+	if err == nil {
+		delivery.Status = model.WebhookDeliveryStatusSuccess
+		delivery.LastError = ""
+	} else {
+		delivery.LastError = err.Error()
+		if delivery.Attempts > len(retryBackoffs) {
+			delivery.Status = model.WebhookDeliveryStatusFailed
+		} else {
+			delivery.Status = model.WebhookDeliveryStatusPending
+			delivery.NextRetryAt = utils.GetTimestamp() + int64(retryBackoffs[delivery.Attempts-1].Seconds())
+		}
+	}
+
+	if updateErr := delivery.Update(); updateErr != nil {
+		logger.SysError("update webhook delivery failed: " + updateErr.Error())
+	}
+
+	return err
+}
+
+// Redeliver 管理员手动重新投递，重置重试次数
+func Redeliver(delivery *model.WebhookDelivery) error {
+	delivery.Attempts = 0
+	return Deliver(delivery)
+}
+
+// RetryPendingDeliveries 重试到期的投递记录，由定时任务调用
+func RetryPendingDeliveries() {
+	deliveries, err := model.GetPendingWebhookDeliveries(100)
+	if err != nil {
+		logger.SysError("get pending webhook deliveries failed: " + err.Error())
+		return
+	}
+
+	for _, delivery := range deliveries {
+		Deliver(delivery)
+	}
+}
+
+func send(delivery *model.WebhookDelivery) (int, error) {
+	if err := ValidateURL(delivery.URL); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	// 没有签名密钥的回调无法校验来源，不投递
+	secret, err := getTokenSecret(delivery.TokenId)
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := utils.GetTimestamp()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", strconv.Itoa(delivery.Id))
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", "sha256="+Sign(secret, timestamp, body))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+		return resp.StatusCode, fmt.Errorf("status code: %d, body: %s", resp.StatusCode, string(respBody))
+	}
+
+	return resp.StatusCode, nil
+}
+
+func getTokenSecret(tokenId int) (string, error) {
+	if tokenId == 0 {
+		return "", errors.New("缺少签名密钥")
+	}
+
+	token, err := model.GetTokenById(tokenId)
+	if err != nil {
+		return "", errors.New("令牌不存在，无法签名")
+	}
+
+	return token.EnsureWebhookSecret()
+}
@@ -0,0 +1,184 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"done-hub/common"
+	"done-hub/common/config"
+	"done-hub/common/logger"
+	"done-hub/common/utils"
+	"done-hub/model"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func setupWebhookTestDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.Token{}, &model.WebhookDelivery{}); err != nil {
+		t.Fatal(err)
+	}
+
+	model.DB = db
+	logger.Logger = zap.NewNop()
+	common.UsingSQLite = true
+	config.RedisEnabled = false
+
+	viper.Set("user_token_secret", "test-user-token-secret")
+	if err := common.InitUserToken(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// useTestServer 将所有回调请求转发到测试服务器，测试服务器监听在本机，绕过建立连接时的地址校验
+func useTestServer(t *testing.T, handler http.HandlerFunc) {
+	server := httptest.NewServer(handler)
+	client := httpClient
+	httpClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+			},
+		},
+	}
+	t.Cleanup(func() {
+		httpClient = client
+		server.Close()
+	})
+}
+
+func createTestDelivery(t *testing.T, tokenId int) *model.WebhookDelivery {
+	delivery := &model.WebhookDelivery{
+		UserId:  1,
+		TokenId: tokenId,
+		TaskId:  "task-1",
+		Event:   EventTaskSucceeded,
+		URL:     "http://hooks.example.com/callback",
+		Payload: `{"event":"task.succeeded","task_id":"task-1"}`,
+		Status:  model.WebhookDeliveryStatusPending,
+	}
+	assert.NoError(t, delivery.Insert())
+	return delivery
+}
+
+func TestValidateURL(t *testing.T) {
+	for _, hook := range []string{
+		"https://hooks.example.com/callback",
+		"http://8.8.8.8:8080/callback",
+	} {
+		assert.NoError(t, ValidateURL(hook), hook)
+	}
+
+	for _, hook := range []string{
+		"ftp://hooks.example.com/callback",
+		"hooks.example.com/callback",
+		"http://localhost/callback",
+		"http://api.localhost/callback",
+		"http://127.0.0.1/callback",
+		"http://10.0.0.1/callback",
+		"http://169.254.169.254/latest/meta-data",
+		"http://100.64.0.1/callback",
+		"http://[::1]/callback",
+		"http://0.0.0.0/callback",
+	} {
+		assert.Error(t, ValidateURL(hook), hook)
+	}
+}
+
+func TestDialBlocksPrivateAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// 域名解析到本机时，在建立连接时拦截
+	_, err := httpClient.Get(server.URL)
+	assert.ErrorContains(t, err, errForbiddenAddress.Error())
+}
+
+func TestDeliverSigned(t *testing.T) {
+	setupWebhookTestDB(t)
+	token := &model.Token{UserId: 1, Name: "test"}
+	assert.NoError(t, model.DB.Create(token).Error)
+
+	var header http.Header
+	var body []byte
+	useTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = io.ReadAll(r.Body)
+	})
+
+	delivery := createTestDelivery(t, token.Id)
+	assert.NoError(t, Deliver(delivery))
+	assert.Equal(t, model.WebhookDeliveryStatusSuccess, delivery.Status)
+	assert.Equal(t, http.StatusOK, delivery.ResponseCode)
+
+	// 早期令牌没有签名密钥时自动生成，签名可以用令牌的密钥校验
+	stored, err := model.GetTokenById(token.Id)
+	assert.NoError(t, err)
+	secret := stored.Setting.Data().Webhook.Secret
+	assert.NotEmpty(t, secret)
+
+	timestamp, _ := strconv.ParseInt(header.Get("X-Webhook-Timestamp"), 10, 64)
+	assert.Equal(t, delivery.Payload, string(body))
+	assert.Equal(t, "sha256="+Sign(secret, timestamp, body), header.Get("X-Webhook-Signature"))
+	assert.Equal(t, EventTaskSucceeded, header.Get("X-Webhook-Event"))
+	assert.Equal(t, strconv.Itoa(delivery.Id), header.Get("X-Webhook-Id"))
+}
+
+func TestDeliverRetry(t *testing.T) {
+	setupWebhookTestDB(t)
+	token := &model.Token{UserId: 1, Name: "test"}
+	assert.NoError(t, model.DB.Create(token).Error)
+
+	useTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	// 失败后按退避间隔重试，次数用完后标记为失败
+	delivery := createTestDelivery(t, token.Id)
+	for i := 1; i <= len(retryBackoffs); i++ {
+		assert.Error(t, Deliver(delivery))
+		assert.Equal(t, model.WebhookDeliveryStatusPending, delivery.Status)
+		assert.Equal(t, utils.GetTimestamp()+int64(retryBackoffs[i-1].Seconds()), delivery.NextRetryAt)
+	}
+	assert.Error(t, Deliver(delivery))
+	assert.Equal(t, model.WebhookDeliveryStatusFailed, delivery.Status)
+	assert.Equal(t, http.StatusInternalServerError, delivery.ResponseCode)
+
+	// 手动重新投递时重置重试次数
+	assert.Error(t, Redeliver(delivery))
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, model.WebhookDeliveryStatusPending, delivery.Status)
+}
+
+func TestDeliverWithoutToken(t *testing.T) {
+	setupWebhookTestDB(t)
+	requested := false
+	useTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+	})
+
+	// 没有签名密钥的回调不投递
+	delivery := createTestDelivery(t, 0)
+	assert.Error(t, Deliver(delivery))
+	assert.False(t, requested)
+
+	delivery = createTestDelivery(t, 99)
+	assert.Error(t, Deliver(delivery))
+	assert.False(t, requested)
+}
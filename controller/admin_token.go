@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"done-hub/common"
+	"done-hub/common/utils"
+	"done-hub/model"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetAdminTokensList(c *gin.Context) {
+	userId := c.GetInt("id")
+	var params model.GenericParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	tokens, err := model.GetAdminTokensList(userId, &params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    tokens,
+	})
+}
+
+func GetAdminTokenScopes(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    model.AdminTokenScopes,
+	})
+}
+
+func AddAdminToken(c *gin.Context) {
+	userId := c.GetInt("id")
+	token := model.AdminToken{}
+	if err := c.ShouldBindJSON(&token); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if token.Name == "" || len(token.Name) > 50 {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("令牌名称不能为空且不能超过50个字符"))
+		return
+	}
+	if err := model.ValidateAdminTokenSetting(&token); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	cleanToken := model.AdminToken{
+		UserId:      userId,
+		Name:        token.Name,
+		Scopes:      token.Scopes,
+		AllowIps:    token.AllowIps,
+		ExpiredTime: token.ExpiredTime,
+		CreatedTime: utils.GetTimestamp(),
+	}
+	if err := cleanToken.Insert(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	// 完整令牌只在创建时返回一次
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    cleanToken,
+	})
+}
+
+func UpdateAdminToken(c *gin.Context) {
+	userId := c.GetInt("id")
+	token := model.AdminToken{}
+	if err := c.ShouldBindJSON(&token); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if token.Name == "" || len(token.Name) > 50 {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("令牌名称不能为空且不能超过50个字符"))
+		return
+	}
+	if err := model.ValidateAdminTokenSetting(&token); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	cleanToken, err := model.GetAdminTokenByIds(token.Id, userId)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	cleanToken.Name = token.Name
+	cleanToken.Scopes = token.Scopes
+	cleanToken.AllowIps = token.AllowIps
+	cleanToken.ExpiredTime = token.ExpiredTime
+	if token.Status != 0 {
+		cleanToken.Status = token.Status
+	}
+
+	if err = cleanToken.Update(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    cleanToken,
+	})
+}
+
+func DeleteAdminToken(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	userId := c.GetInt("id")
+	if err := model.DeleteAdminTokenById(id, userId); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
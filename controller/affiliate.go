@@ -0,0 +1,246 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"done-hub/common"
+	"done-hub/common/config"
+	"done-hub/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateAffiliateWithdrawalRequest struct {
+	Quota   int    `json:"quota"`
+	Method  string `json:"method"`
+	Account string `json:"account"`
+	Remark  string `json:"remark"`
+}
+
+type ReviewAffiliateCommissionRequest struct {
+	Approve bool   `json:"approve"`
+	Remark  string `json:"remark"`
+}
+
+type ReviewAffiliateWithdrawalRequest struct {
+	PayoutNo string `json:"payout_no"`
+	Remark   string `json:"remark"`
+}
+
+// GetSelfAffiliate 当前用户的佣金账户概况和佣金规则
+func GetSelfAffiliate(c *gin.Context) {
+	userId := c.GetInt("id")
+	summary, err := model.GetAffiliateSummary(userId)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	group := ""
+	if user, err := model.GetUserById(userId, false); err == nil {
+		group = user.Group
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"summary":            summary,
+			"enabled":            config.AffiliateEnabled,
+			"tier1_rate":         model.AffiliateRate(group, 1),
+			"tier2_rate":         model.AffiliateRate(group, 2),
+			"commission_months":  config.AffiliateCommissionMonths,
+			"settle_days":        config.AffiliateSettleDays,
+			"min_withdraw_quota": config.AffiliateMinWithdrawQuota,
+		},
+	})
+}
+
+func GetSelfAffiliateCommissions(c *gin.Context) {
+	var params model.AffiliateCommissionsListParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	params.AffiliateId = c.GetInt("id")
+
+	commissions, err := model.GetAffiliateCommissionsList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    commissions,
+	})
+}
+
+func GetSelfAffiliateWithdrawals(c *gin.Context) {
+	var params model.AffiliateWithdrawalsListParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	params.UserId = c.GetInt("id")
+
+	withdrawals, err := model.GetAffiliateWithdrawalsList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    withdrawals,
+	})
+}
+
+// CreateAffiliateWithdrawal 用户申请佣金提现，提现到余额或线下打款均需管理员审核
+func CreateAffiliateWithdrawal(c *gin.Context) {
+	if !config.AffiliateEnabled {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("分销功能未开启"))
+		return
+	}
+
+	var req CreateAffiliateWithdrawalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("invalid request"))
+		return
+	}
+
+	withdrawal := &model.AffiliateWithdrawal{
+		UserId:  c.GetInt("id"),
+		Quota:   req.Quota,
+		Method:  req.Method,
+		Account: req.Account,
+		Remark:  req.Remark,
+	}
+	if err := model.CreateAffiliateWithdrawal(withdrawal); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    withdrawal,
+	})
+}
+
+func GetAffiliateCommissionList(c *gin.Context) {
+	var params model.AffiliateCommissionsListParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	commissions, err := model.GetAffiliateCommissionsList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    commissions,
+	})
+}
+
+// ReviewAffiliateCommission 审核疑似自我邀请而被冻结的佣金
+func ReviewAffiliateCommission(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	var req ReviewAffiliateCommissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("invalid request"))
+		return
+	}
+
+	if err := model.ReviewAffiliateCommission(id, req.Approve, req.Remark, c.GetInt("id")); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func GetAffiliateWithdrawalList(c *gin.Context) {
+	var params model.AffiliateWithdrawalsListParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	withdrawals, err := model.GetAffiliateWithdrawalsList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    withdrawals,
+	})
+}
+
+func ApproveAffiliateWithdrawal(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	var req ReviewAffiliateWithdrawalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("invalid request"))
+		return
+	}
+
+	if err := model.ApproveAffiliateWithdrawal(id, req.PayoutNo, req.Remark, c.GetInt("id")); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func RejectAffiliateWithdrawal(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	var req ReviewAffiliateWithdrawalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("invalid request"))
+		return
+	}
+
+	if err := model.RejectAffiliateWithdrawal(id, req.Remark, c.GetInt("id")); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
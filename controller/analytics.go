@@ -36,6 +36,7 @@ func GetStatisticsByPeriod(c *gin.Context) {
 	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
 	groupType := c.Query("group_type")
 	userID, _ := strconv.Atoi(c.Query("user_id"))
+	campaignID, _ := strconv.Atoi(c.Query("campaign_id"))
 
 	statisticsByPeriod := &StatisticsByPeriod{}
 
@@ -54,7 +55,7 @@ func GetStatisticsByPeriod(c *gin.Context) {
 		statisticsByPeriod.ChannelStatistics = channelStatistics
 	}
 
-	redemptionStatistics, err := model.GetStatisticsRedemptionByPeriod(startTimestamp, endTimestamp)
+	redemptionStatistics, err := model.GetStatisticsRedemptionByPeriod(startTimestamp, endTimestamp, campaignID)
 	if err == nil {
 		statisticsByPeriod.RedemptionStatistics = redemptionStatistics
 	}
@@ -236,7 +237,7 @@ func GetRechargeStatisticsByTimeRange(c *gin.Context) {
 		}
 	} else {
 		// 指定时间范围数据
-		redemptionStats, err := model.GetStatisticsRedemptionByPeriod(startTimestamp, endTimestamp)
+		redemptionStats, err := model.GetStatisticsRedemptionByPeriod(startTimestamp, endTimestamp, 0)
 		if err != nil {
 			// 记录错误但不中断
 			fmt.Printf("获取时间范围兑换码统计失败: %v\n", err)
@@ -284,3 +285,59 @@ func GetRechargeStatisticsByTimeRange(c *gin.Context) {
 		"data":    rechargeStats,
 	})
 }
+
+// GetMarginStatistics 按渠道、模型、分组或时间汇总收入、上游成本和毛利，默认最近 7 天按渠道统计
+func GetMarginStatistics(c *gin.Context) {
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	if endTimestamp == 0 {
+		endTimestamp = time.Now().Unix()
+	}
+	if startTimestamp == 0 {
+		startTimestamp = endTimestamp - 7*24*3600
+	}
+	groupBy := c.DefaultQuery("group_by", "channel")
+
+	statistics, err := model.GetMarginStatistics(groupBy, startTimestamp, endTimestamp)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    statistics,
+	})
+}
+
+// GetChannelBalanceReconciliation 对比渠道上游余额的减少和记录的成本，默认最近 7 天
+func GetChannelBalanceReconciliation(c *gin.Context) {
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	if endTimestamp == 0 {
+		endTimestamp = time.Now().Unix()
+	}
+	if startTimestamp == 0 {
+		startTimestamp = endTimestamp - 7*24*3600
+	}
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+
+	reconciliation, err := model.GetChannelBalanceReconciliation(channelId, startTimestamp, endTimestamp)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    reconciliation,
+	})
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"done-hub/common"
+	"done-hub/model"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxAuditLogExport = 50000
+
+func GetAuditLogsList(c *gin.Context) {
+	var params model.AuditLogsListParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	logs, err := model.GetAuditLogsList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    logs,
+	})
+}
+
+// VerifyAuditLogs 校验审计日志哈希链是否完整
+func VerifyAuditLogs(c *gin.Context) {
+	brokenId, total, err := model.VerifyAuditLogs()
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"valid":     brokenId == 0,
+			"broken_id": brokenId,
+			"total":     total,
+		},
+	})
+}
+
+// ExportAuditLogs 按查询条件导出，format 为 csv 或 json（默认）
+func ExportAuditLogs(c *gin.Context) {
+	var params model.AuditLogsListParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	logs, err := model.GetAuditLogsForExport(&params, maxAuditLogExport)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	fileName := fmt.Sprintf("audit-logs-%s", time.Now().Format("20060102150405"))
+	if c.Query("format") != "csv" {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.json", fileName))
+		c.JSON(http.StatusOK, logs)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.csv", fileName))
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{"id", "created_at", "actor_id", "actor_name", "actor_ip", "admin_token_id", "action", "entity_type", "entity_id", "before", "after", "diff", "prev_hash", "hash"})
+	for _, log := range logs {
+		writer.Write([]string{
+			strconv.Itoa(log.Id),
+			strconv.FormatInt(log.CreatedAt, 10),
+			strconv.Itoa(log.ActorId),
+			log.ActorName,
+			log.ActorIp,
+			strconv.Itoa(log.AdminTokenId),
+			log.Action,
+			log.EntityType,
+			log.EntityId,
+			log.Before,
+			log.After,
+			log.Diff,
+			log.PrevHash,
+			log.Hash,
+		})
+	}
+	writer.Flush()
+}
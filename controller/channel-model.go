@@ -20,8 +20,13 @@ func GetModelList(c *gin.Context) {
 		})
 		return
 	}
-	keys := strings.Split(channel.Key, "\n")
-	channel.Key = keys[0]
+	if err = channel.RestoreMaskedSecrets(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 
 	if channel.Key == "" {
 		if channel.Id == 0 {
@@ -42,6 +47,17 @@ func GetModelList(c *gin.Context) {
 		}
 	}
 
+	// 库中的 key 是密文，解密后再取多个 key 中的第一个
+	channel, err = channel.Decrypted()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	channel.Key = strings.Split(channel.Key, "\n")[0]
+
 	provider := providers.GetProvider(channel, c)
 	if provider == nil {
 		c.JSON(http.StatusOK, gin.H{
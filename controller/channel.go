@@ -24,6 +24,9 @@ func GetChannelsList(c *gin.Context) {
 		common.APIRespondWithError(c, http.StatusOK, err)
 		return
 	}
+	for _, channel := range *channels.Data {
+		channel.MaskSecrets()
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -48,6 +51,7 @@ func GetChannel(c *gin.Context) {
 		})
 		return
 	}
+	channel.MaskSecrets()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -164,6 +168,10 @@ func UpdateChannel(c *gin.Context) {
 		})
 		return
 	}
+	if err = channel.RestoreMaskedSecrets(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
 	if channel.Models == "" {
 		err = channel.Update(false)
 	} else {
@@ -176,6 +184,7 @@ func UpdateChannel(c *gin.Context) {
 		})
 		return
 	}
+	channel.MaskSecrets()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
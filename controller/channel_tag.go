@@ -22,6 +22,9 @@ func GetChannelsTagList(c *gin.Context) {
 		common.APIRespondWithError(c, http.StatusOK, err)
 		return
 	}
+	for _, channel := range channelsTag {
+		channel.MaskSecrets()
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -53,6 +56,7 @@ func GetChannelsTag(c *gin.Context) {
 		common.APIRespondWithError(c, http.StatusOK, err)
 		return
 	}
+	channel.MaskSecrets()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
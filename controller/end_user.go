@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"done-hub/common"
+	"done-hub/model"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const endUserStatisticsLimit = 100
+
+// GetUserEndUserStatistics 按终端用户汇总当前用户的消费，默认统计最近 7 天
+func GetUserEndUserStatistics(c *gin.Context) {
+	var params model.EndUserStatisticsParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	if params.StartTimestamp == 0 && params.EndTimestamp == 0 {
+		params.StartTimestamp = time.Now().AddDate(0, 0, -7).Unix()
+	}
+
+	statistics, err := model.GetUserEndUserStatistics(c.GetInt("id"), &params, endUserStatisticsLimit)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无法获取统计信息"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    statistics,
+	})
+}
@@ -8,7 +8,9 @@ import (
 	"context"
 	"done-hub/common"
 	"done-hub/common/logger"
+	"done-hub/common/media"
 	"done-hub/common/requester"
+	"done-hub/common/webhook"
 	"done-hub/model"
 	provider "done-hub/providers/midjourney"
 	"encoding/json"
@@ -136,6 +138,11 @@ func UpdateMidjourneyTaskBulk() {
 }
 
 func MjTaskHandler(midjourneyChannel *model.Channel, taskIds []string, taskM map[string]*model.Midjourney) error {
+	midjourneyChannel, err := midjourneyChannel.Decrypted()
+	if err != nil {
+		return fmt.Errorf("decrypt channel key error: %v", err)
+	}
+
 	requestUrl := fmt.Sprintf("%s/mj/task/list-by-condition", *midjourneyChannel.BaseURL)
 
 	body, _ := json.Marshal(map[string]any{
@@ -185,6 +192,7 @@ func MjTaskHandler(midjourneyChannel *model.Channel, taskIds []string, taskM map
 		if !checkMjTaskNeedUpdate(task, responseItem) {
 			continue
 		}
+		finished := task.IsFinished()
 		task.Code = 1
 		task.Progress = responseItem.Progress
 		task.PromptEn = responseItem.PromptEn
@@ -225,6 +233,12 @@ func MjTaskHandler(midjourneyChannel *model.Channel, taskIds []string, taskM map
 		err = task.Update()
 		if err != nil {
 			logger.LogError(ctx, "UpdateMidjourneyTask task error: "+err.Error())
+			continue
+		}
+
+		if !finished && task.IsFinished() {
+			media.PersistMidjourney(task)
+			webhook.MidjourneyFinished(task)
 		}
 	}
 
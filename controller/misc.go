@@ -37,6 +37,7 @@ func GetStatus(c *gin.Context) {
 			"footer_html":         config.Footer,
 			"wechat_qrcode":       config.WeChatAccountQRCodeImageURL,
 			"wechat_login":        config.WeChatAuthEnabled,
+			"passkey_login":       config.PasskeyLoginEnabled,
 			"server_address":      config.ServerAddress,
 			"turnstile_check":     config.TurnstileCheckEnabled,
 			"turnstile_site_key":  config.TurnstileSiteKey,
@@ -50,6 +51,8 @@ func GetStatus(c *gin.Context) {
 			"PaymentUSDRate":      config.PaymentUSDRate,
 			"PaymentMinAmount":    config.PaymentMinAmount,
 			"RechargeDiscount":    config.RechargeDiscount,
+			"SubscriptionEnabled": config.SubscriptionEnabled,
+			"AffiliateEnabled":    config.AffiliateEnabled,
 			"EnableSafe":          config.EnableSafe,
 			"SafeToolName":        config.SafeToolName,
 			"SafeKeyWords":        config.SafeKeyWords,
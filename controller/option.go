@@ -92,6 +92,23 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "AffiliateGroupRates":
+		if _, err := model.ParseAffiliateGroupRates(option.Value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "分组佣金比例格式错误：" + err.Error(),
+			})
+			return
+		}
+	case "AffiliateTier1Rate", "AffiliateTier2Rate":
+		value, err := strconv.ParseFloat(option.Value, 64)
+		if err != nil || value < 0 || value > 100 {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "佣金比例应在0-100之间",
+			})
+			return
+		}
 	case "InviterRewardValue":
 		value, err := strconv.Atoi(option.Value)
 		if err != nil {
@@ -70,16 +70,17 @@ func CreateOrder(c *gin.Context) {
 
 	// 创建订单
 	order := &model.Order{
-		UserId:        userId,
-		GatewayId:     paymentService.Payment.ID,
-		TradeNo:       tradeNo,
-		Amount:        orderReq.Amount,
-		OrderAmount:   payMoney,
-		OrderCurrency: paymentService.Payment.Currency,
-		Fee:           fee,
-		Discount:      discount,
-		Status:        model.OrderStatusPending,
-		Quota:         orderReq.Amount * int(config.QuotaPerUnit),
+		UserId:         userId,
+		GatewayId:      paymentService.Payment.ID,
+		TradeNo:        tradeNo,
+		Amount:         orderReq.Amount,
+		OrderAmount:    payMoney,
+		OrderCurrency:  paymentService.Payment.Currency,
+		Fee:            fee,
+		Discount:       discount,
+		Status:         model.OrderStatusPending,
+		Quota:          orderReq.Amount * int(config.QuotaPerUnit),
+		QuotaValidDays: config.PaymentQuotaValidDays,
 	}
 
 	err = order.Insert()
@@ -136,7 +137,7 @@ func PaymentCallback(c *gin.Context) {
 	}
 
 	payNotify, err := paymentService.HandleCallback(c, paymentService.Payment.Config)
-	if err != nil {
+	if err != nil || payNotify == nil {
 		return
 	}
 
@@ -148,38 +149,53 @@ func PaymentCallback(c *gin.Context) {
 		logger.SysError(fmt.Sprintf("gateway callback failed to find order, trade_no: %s,", payNotify.TradeNo))
 		return
 	}
-	//fmt.Println(order.Status, order.Status != model.OrderStatusPending)
 
-	if order.Status != model.OrderStatusPending {
+	// 网关会重复发送回调，只有第一次将订单标记为成功的回调入账
+	paid, err := model.MarkOrderPaid(order, payNotify.GatewayNo)
+	if err != nil {
+		logger.SysError(fmt.Sprintf("gateway callback failed to update order, trade_no: %s,", payNotify.TradeNo))
+		return
+	}
+	if !paid {
+		// 已入账的订单收到不同网关单号的回调，说明用户重复支付，需要人工退款
+		if order.GatewayNo != "" && order.GatewayNo != payNotify.GatewayNo {
+			_, err = model.RecordPaymentMismatch(order, model.PaymentMismatchDuplicate, payNotify.GatewayNo,
+				fmt.Sprintf("订单已由 %s 支付，又收到 %s 的支付回调", order.GatewayNo, payNotify.GatewayNo))
+			if err != nil {
+				logger.SysError(fmt.Sprintf("failed to record duplicate payment, trade_no: %s, error: %s", payNotify.TradeNo, err.Error()))
+			}
+		}
 		return
 	}
 
-	order.GatewayNo = payNotify.GatewayNo
-	order.Status = model.OrderStatusSuccess
-	err = order.Update()
-	if err != nil {
-		logger.SysError(fmt.Sprintf("gateway callback failed to update order, trade_no: %s,", payNotify.TradeNo))
+	fulfillOrder(order, c.ClientIP())
+}
+
+// fulfillOrder 订单支付成功后开通订阅或增加额度，调用前需要已通过 MarkOrderPaid 将订单标记为成功
+func fulfillOrder(order *model.Order, ip string) {
+	if order.PlanId > 0 {
+		completeSubscriptionOrder(order, ip)
 		return
 	}
 
-	err = model.IncreaseUserQuota(order.UserId, order.Quota)
+	err := model.GrantUserQuota(order.UserId, order.Quota, model.QuotaLotSourceTopup, order.TradeNo, model.QuotaValidDaysToExpiresAt(order.QuotaValidDays), "在线充值")
 	if err != nil {
-		logger.SysError(fmt.Sprintf("gateway callback failed to increase user quota, trade_no: %s,", payNotify.TradeNo))
+		logger.SysError(fmt.Sprintf("gateway callback failed to increase user quota, trade_no: %s,", order.TradeNo))
 		return
 	}
 
 	// Try to upgrade user group based on cumulative recharge amount
 	err = model.CheckAndUpgradeUserGroup(order.UserId, order.Quota)
 	if err != nil {
-		logger.SysError(fmt.Sprintf("failed to check and upgrade user group, trade_no: %s, error: %s", payNotify.TradeNo, err.Error()))
+		logger.SysError(fmt.Sprintf("failed to check and upgrade user group, trade_no: %s, error: %s", order.TradeNo, err.Error()))
 	}
 
-	model.RecordQuotaLog(order.UserId, model.LogTypeTopup, order.Quota, c.ClientIP(), fmt.Sprintf("在线充值成功，充值积分: %d，支付金额：%.2f %s", order.Quota, order.OrderAmount, order.OrderCurrency))
+	model.RecordQuotaLog(order.UserId, model.LogTypeTopup, order.Quota, ip, fmt.Sprintf("在线充值成功，充值积分: %d，支付金额：%.2f %s", order.Quota, order.OrderAmount, order.OrderCurrency))
 
 	// 处理邀请人充值返利
-	err = model.ProcessInviterReward(order.UserId, order.Quota, c.ClientIP())
+	err = model.ProcessInviterReward(order.UserId, order.Quota, model.AffiliateSourceTopup, order.TradeNo, ip)
 	if err != nil {
-		logger.SysError(fmt.Sprintf("failed to process inviter reward, trade_no: %s, error: %s", payNotify.TradeNo, err.Error()))
+		logger.SysError(fmt.Sprintf("failed to process inviter reward, trade_no: %s, error: %s", order.TradeNo, err.Error()))
 	}
 }
 
@@ -207,8 +223,13 @@ func CheckOrderStatus(c *gin.Context) {
 func calculateOrderAmount(payment *model.Payment, amount int) (discountMoney, fee, payMoney float64) {
 	// 获取折扣
 	discount := common.GetRechargeDiscount(strconv.Itoa(amount))
-	newMoney := float64(amount) * discount // 折后价值
-	oldTotal := float64(amount)            //原价值
+	return calculatePayMoney(payment, float64(amount), discount)
+}
+
+// calculatePayMoney 按折扣、手续费和汇率计算实付金额，amount 为美元金额
+func calculatePayMoney(payment *model.Payment, amount float64, discount float64) (discountMoney, fee, payMoney float64) {
+	newMoney := amount * discount // 折后价值
+	oldTotal := amount            //原价值
 	if payment.PercentFee > 0 {
 		//手续费=（原始价值*折扣*手续费率）
 		fee = utils.Decimal(newMoney*payment.PercentFee, 2) //折后手续
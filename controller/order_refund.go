@@ -0,0 +1,227 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"done-hub/common"
+	"done-hub/common/logger"
+	"done-hub/model"
+	"done-hub/payment"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RefundOrderRequest struct {
+	Money  float64 `json:"money"`
+	Quota  *int    `json:"quota"`
+	Reason string  `json:"reason"`
+}
+
+type ConfirmOrderRefundRequest struct {
+	// success 网关已完成退款，扣回额度；fail 网关退款失败，释放预占的退款金额
+	Action  string `json:"action" binding:"required,oneof=success fail"`
+	Message string `json:"message"`
+}
+
+type ResolvePaymentMismatchRequest struct {
+	// complete 将网关已支付但未入账的订单补入账，ignore 仅标记为已处理
+	Action string `json:"action" binding:"required,oneof=complete ignore"`
+	Remark string `json:"remark"`
+}
+
+// RefundOrder 管理员发起原路退款
+func RefundOrder(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	var req RefundOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("invalid request"))
+		return
+	}
+
+	order, err := model.GetOrderById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("订单不存在"))
+		return
+	}
+
+	refund, err := payment.RefundOrder(order, &payment.RefundOrderRequest{
+		Money:      req.Money,
+		Quota:      req.Quota,
+		Reason:     req.Reason,
+		OperatorId: c.GetInt("id"),
+	})
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    refund,
+	})
+}
+
+// ConfirmOrderRefund 管理员确认处理中的退款结果
+func ConfirmOrderRefund(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	var req ConfirmOrderRefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("invalid request"))
+		return
+	}
+
+	refund, err := model.GetOrderRefundById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("退款记录不存在"))
+		return
+	}
+
+	if err := payment.ConfirmRefund(refund, req.Action == "success", req.Message); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    refund,
+	})
+}
+
+func GetOrderRefunds(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	refunds, err := model.GetOrderRefunds(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    refunds,
+	})
+}
+
+func GetPaymentMismatchList(c *gin.Context) {
+	var params model.SearchPaymentMismatchParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	mismatches, err := model.GetPaymentMismatchList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    mismatches,
+	})
+}
+
+// ResolvePaymentMismatch 处理对账异常，未入账的订单可以选择补入账
+func ResolvePaymentMismatch(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	var req ResolvePaymentMismatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("invalid request"))
+		return
+	}
+
+	mismatch, err := model.GetPaymentMismatchById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("异常记录不存在"))
+		return
+	}
+	if req.Action == "complete" && mismatch.Type != model.PaymentMismatchMissingCallback {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("只有未入账的订单可以补入账"))
+		return
+	}
+
+	resolution := req.Action
+	if req.Remark != "" {
+		resolution = fmt.Sprintf("%s: %s", req.Action, req.Remark)
+	}
+	if err := model.ResolvePaymentMismatch(mismatch, resolution, c.GetInt("id")); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if req.Action == "complete" {
+		if err := completeMismatchOrder(mismatch, c.ClientIP()); err != nil {
+			common.APIRespondWithError(c, http.StatusOK, err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// completeMismatchOrder 补入账，与支付回调共用同一把锁和幂等更新，回调已入账时不会重复增加额度
+func completeMismatchOrder(mismatch *model.PaymentMismatch, ip string) error {
+	LockOrder(mismatch.GatewayNo)
+	defer UnlockOrder(mismatch.GatewayNo)
+
+	order, err := model.GetOrderById(mismatch.OrderId)
+	if err != nil {
+		return errors.New("订单不存在")
+	}
+
+	paid, err := model.MarkOrderPaid(order, mismatch.GatewayNo)
+	if err != nil {
+		return err
+	}
+	if !paid {
+		return errors.New("订单已入账，无需处理")
+	}
+
+	logger.SysLog(fmt.Sprintf("order %s completed by reconciliation", order.TradeNo))
+	fulfillOrder(order, ip)
+	return nil
+}
+
+// ReconcilePayments 立即执行一次对账
+func ReconcilePayments(c *gin.Context) {
+	result, err := payment.Reconcile()
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    result,
+	})
+}
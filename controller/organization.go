@@ -0,0 +1,634 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"done-hub/common"
+	"done-hub/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OrganizationRequest struct {
+	Name string `json:"name"`
+}
+
+type OrganizationMemberRequest struct {
+	Username   string `json:"username"`
+	Role       string `json:"role"`
+	QuotaLimit int    `json:"quota_limit"`
+}
+
+type OrganizationQuotaRequest struct {
+	Quota int `json:"quota"`
+}
+
+type OrganizationStatusRequest struct {
+	Status int `json:"status"`
+}
+
+// getOrganizationMember 当前用户在路径中组织的成员记录，action 不为空时校验角色权限，失败时已写入响应
+func getOrganizationMember(c *gin.Context, action string) (*model.OrganizationMember, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return nil, false
+	}
+
+	member, err := model.GetOrganizationMember(id, c.GetInt("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return nil, false
+	}
+	if action != "" && !model.OrganizationRoleCan(member.Role, action) {
+		common.APIRespondWithError(c, http.StatusOK, model.ErrOrganizationPermission)
+		return nil, false
+	}
+	return member, true
+}
+
+func GetSelfOrganizations(c *gin.Context) {
+	organizations, err := model.GetUserOrganizations(c.GetInt("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    organizations,
+	})
+}
+
+func CreateOrganization(c *gin.Context) {
+	var req OrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("invalid request"))
+		return
+	}
+
+	organization, err := model.CreateOrganization(req.Name, c.GetInt("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    organization,
+	})
+}
+
+func GetSelfOrganization(c *gin.Context) {
+	member, ok := getOrganizationMember(c, "")
+	if !ok {
+		return
+	}
+
+	organization, err := model.GetOrganizationById(member.OrganizationId)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"organization": organization,
+			"member":       member,
+		},
+	})
+}
+
+func UpdateOrganization(c *gin.Context) {
+	member, ok := getOrganizationMember(c, "manage")
+	if !ok {
+		return
+	}
+
+	var req OrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("invalid request"))
+		return
+	}
+
+	if err := model.UpdateOrganizationName(member.OrganizationId, req.Name); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// DeleteOrganization 解散组织，仅创建者可以操作
+func DeleteOrganization(c *gin.Context) {
+	member, ok := getOrganizationMember(c, "")
+	if !ok {
+		return
+	}
+	if member.Role != model.OrganizationRoleOwner {
+		common.APIRespondWithError(c, http.StatusOK, model.ErrOrganizationPermission)
+		return
+	}
+
+	if err := model.DeleteOrganization(member.OrganizationId); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func GetOrganizationMembers(c *gin.Context) {
+	member, ok := getOrganizationMember(c, "")
+	if !ok {
+		return
+	}
+
+	members, err := model.GetOrganizationMembers(member.OrganizationId)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    members,
+	})
+}
+
+// InviteOrganizationMember 邀请用户加入组织，用户接受邀请后才成为成员
+func InviteOrganizationMember(c *gin.Context) {
+	member, ok := getOrganizationMember(c, "manage")
+	if !ok {
+		return
+	}
+
+	var req OrganizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("invalid request"))
+		return
+	}
+	// 管理员只能邀请开发者和财务，管理员由创建者指定
+	if req.Role == model.OrganizationRoleAdmin && member.Role != model.OrganizationRoleOwner {
+		common.APIRespondWithError(c, http.StatusOK, model.ErrOrganizationPermission)
+		return
+	}
+
+	invitation, err := model.InviteOrganizationMember(member.OrganizationId, member.UserId, req.Username, req.Role, req.QuotaLimit)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    invitation,
+	})
+}
+
+func GetOrganizationInvitations(c *gin.Context) {
+	member, ok := getOrganizationMember(c, "manage")
+	if !ok {
+		return
+	}
+
+	invitations, err := model.GetOrganizationInvitations(member.OrganizationId)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    invitations,
+	})
+}
+
+// RevokeOrganizationInvitation 撤销尚未接受的邀请
+func RevokeOrganizationInvitation(c *gin.Context) {
+	member, ok := getOrganizationMember(c, "manage")
+	if !ok {
+		return
+	}
+	invitationId, err := strconv.Atoi(c.Param("invitation_id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	if err := model.DeleteOrganizationInvitation(invitationId, member.OrganizationId, 0); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// GetSelfOrganizationInvitations 当前用户收到的组织邀请
+func GetSelfOrganizationInvitations(c *gin.Context) {
+	invitations, err := model.GetUserOrganizationInvitations(c.GetInt("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    invitations,
+	})
+}
+
+func AcceptOrganizationInvitation(c *gin.Context) {
+	invitationId, err := strconv.Atoi(c.Param("invitation_id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	member, err := model.AcceptOrganizationInvitation(invitationId, c.GetInt("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    member,
+	})
+}
+
+func DeclineOrganizationInvitation(c *gin.Context) {
+	invitationId, err := strconv.Atoi(c.Param("invitation_id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	if err := model.DeleteOrganizationInvitation(invitationId, 0, c.GetInt("id")); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// getManagedOrganizationMember 路径中 user_id 对应的成员，只有创建者可以操作管理员，allowSelf 为 false 时不能操作自己
+func getManagedOrganizationMember(c *gin.Context, operator *model.OrganizationMember, allowSelf bool) (*model.OrganizationMember, bool) {
+	userId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return nil, false
+	}
+
+	target, err := model.GetOrganizationMember(operator.OrganizationId, userId)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("成员不存在"))
+		return nil, false
+	}
+	if target.UserId == operator.UserId {
+		if !allowSelf {
+			common.APIRespondWithError(c, http.StatusOK, errors.New("不能修改自己的角色和额度上限"))
+			return nil, false
+		}
+		return target, true
+	}
+	if operator.Role != model.OrganizationRoleOwner &&
+		(target.Role == model.OrganizationRoleOwner || target.Role == model.OrganizationRoleAdmin) {
+		common.APIRespondWithError(c, http.StatusOK, model.ErrOrganizationPermission)
+		return nil, false
+	}
+	return target, true
+}
+
+func UpdateOrganizationMember(c *gin.Context) {
+	member, ok := getOrganizationMember(c, "manage")
+	if !ok {
+		return
+	}
+	target, ok := getManagedOrganizationMember(c, member, false)
+	if !ok {
+		return
+	}
+
+	var req OrganizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("invalid request"))
+		return
+	}
+	if req.Role == model.OrganizationRoleAdmin && member.Role != model.OrganizationRoleOwner {
+		common.APIRespondWithError(c, http.StatusOK, model.ErrOrganizationPermission)
+		return
+	}
+
+	if err := model.UpdateOrganizationMember(target.OrganizationId, target.UserId, req.Role, req.QuotaLimit); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// RemoveOrganizationMember 移除成员，成员也可以主动退出组织
+func RemoveOrganizationMember(c *gin.Context) {
+	member, ok := getOrganizationMember(c, "")
+	if !ok {
+		return
+	}
+	if c.Param("user_id") != strconv.Itoa(member.UserId) && !model.OrganizationRoleCan(member.Role, "manage") {
+		common.APIRespondWithError(c, http.StatusOK, model.ErrOrganizationPermission)
+		return
+	}
+	target, ok := getManagedOrganizationMember(c, member, true)
+	if !ok {
+		return
+	}
+
+	if err := model.RemoveOrganizationMember(target.OrganizationId, target.UserId); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// FundOrganization 从个人额度向组织额度池转入额度
+func FundOrganization(c *gin.Context) {
+	member, ok := getOrganizationMember(c, "billing")
+	if !ok {
+		return
+	}
+
+	var req OrganizationQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("invalid request"))
+		return
+	}
+
+	if err := model.FundOrganization(member.OrganizationId, member.UserId, req.Quota); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// GetOrganizationTokens 组织令牌列表，开发者只能看到自己创建的令牌
+func GetOrganizationTokens(c *gin.Context) {
+	member, ok := getOrganizationMember(c, "token")
+	if !ok {
+		return
+	}
+
+	userId := member.UserId
+	if model.OrganizationRoleCan(member.Role, "manage") {
+		userId = 0
+	}
+	tokens, err := model.GetOrganizationTokens(member.OrganizationId, userId)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    tokens,
+	})
+}
+
+// DeleteOrganizationToken 管理员删除成员创建的组织令牌
+func DeleteOrganizationToken(c *gin.Context) {
+	member, ok := getOrganizationMember(c, "manage")
+	if !ok {
+		return
+	}
+
+	tokenId, err := strconv.Atoi(c.Param("token_id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+	token, err := model.GetTokenById(tokenId)
+	if err != nil || token.OrganizationId != member.OrganizationId {
+		common.APIRespondWithError(c, http.StatusOK, model.ErrTokenNotFound)
+		return
+	}
+
+	if err := model.DeleteTokenById(token.Id, token.UserId); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// GetOrganizationLogs 组织令牌的消费日志，没有财务权限的成员只能看到自己的日志
+func GetOrganizationLogs(c *gin.Context) {
+	member, ok := getOrganizationMember(c, "")
+	if !ok {
+		return
+	}
+
+	var params model.LogsListParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	userId := member.UserId
+	if model.OrganizationRoleCan(member.Role, "billing") {
+		userId = 0
+	}
+	logs, err := model.GetOrganizationLogsList(member.OrganizationId, userId, &params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    logs,
+	})
+}
+
+// GetOrganizationStatistics 组织按天和模型、按成员的消费统计，默认最近 7 天
+func GetOrganizationStatistics(c *gin.Context) {
+	member, ok := getOrganizationMember(c, "billing")
+	if !ok {
+		return
+	}
+
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	if endTimestamp == 0 {
+		endTimestamp = time.Now().Unix()
+	}
+	if startTimestamp == 0 {
+		startTimestamp = endTimestamp - 7*24*3600
+	}
+
+	models, err := model.GetOrganizationModelStatisticsByPeriod(member.OrganizationId, startTimestamp, endTimestamp)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	members, err := model.GetOrganizationMemberStatistics(member.OrganizationId, startTimestamp, endTimestamp)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"models":  models,
+			"members": members,
+		},
+	})
+}
+
+func GetOrganizationInvoice(c *gin.Context) {
+	member, ok := getOrganizationMember(c, "billing")
+	if !ok {
+		return
+	}
+
+	invoices, err := model.GetOrganizationInvoices(member.OrganizationId)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    invoices,
+	})
+}
+
+// GetOrganizationInvoiceDetail 组织指定月份的账单详情，date 格式为 YYYY-MM
+func GetOrganizationInvoiceDetail(c *gin.Context) {
+	member, ok := getOrganizationMember(c, "billing")
+	if !ok {
+		return
+	}
+
+	invoices, err := model.GetOrganizationInvoiceDetail(member.OrganizationId, c.Query("date"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    invoices,
+	})
+}
+
+func GetOrganizationList(c *gin.Context) {
+	var params model.GenericParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	organizations, err := model.GetOrganizationsList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    organizations,
+	})
+}
+
+// AdjustOrganizationQuota 管理员调整组织额度，quota 为负数时扣减
+func AdjustOrganizationQuota(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	var req OrganizationQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("invalid request"))
+		return
+	}
+
+	if err := model.AdjustOrganizationQuota(id, req.Quota); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func UpdateOrganizationStatus(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	var req OrganizationStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("invalid request"))
+		return
+	}
+
+	if err := model.UpdateOrganizationStatus(id, req.Status); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
@@ -0,0 +1,266 @@
+package controller
+
+import (
+	"done-hub/common"
+	"done-hub/common/config"
+	"done-hub/common/logger"
+	"done-hub/model"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+const (
+	passkeyRegistrationKey = "passkey_registration"
+	passkeyLoginKey        = "passkey_login"
+)
+
+// getWebAuthn 依据服务器地址构建依赖方配置，服务器地址可在运行时修改，因此每次重新构建
+func getWebAuthn() (*webauthn.WebAuthn, error) {
+	serverURL, err := url.Parse(config.ServerAddress)
+	if err != nil || serverURL.Hostname() == "" {
+		return nil, errors.New("服务器地址配置错误，无法使用通行密钥")
+	}
+
+	return webauthn.New(&webauthn.Config{
+		RPID:          serverURL.Hostname(),
+		RPDisplayName: config.SystemName,
+		RPOrigins:     []string{serverURL.Scheme + "://" + serverURL.Host},
+	})
+}
+
+func saveWebAuthnSession(c *gin.Context, key string, data *webauthn.SessionData) error {
+	content, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	session := sessions.Default(c)
+	session.Set(key, string(content))
+	return session.Save()
+}
+
+// loadWebAuthnSession 读取并删除会话中的挑战，每个挑战只能使用一次
+func loadWebAuthnSession(c *gin.Context, key string) (*webauthn.SessionData, error) {
+	session := sessions.Default(c)
+	content, _ := session.Get(key).(string)
+	session.Delete(key)
+	session.Save()
+
+	if content == "" {
+		return nil, errors.New("验证已过期，请重试")
+	}
+
+	var data webauthn.SessionData
+	if err := json.Unmarshal([]byte(content), &data); err != nil {
+		return nil, errors.New("验证已过期，请重试")
+	}
+
+	return &data, nil
+}
+
+func BeginPasskeyRegistration(c *gin.Context) {
+	web, err := getWebAuthn()
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	user, err := model.GetUserById(c.GetInt("id"), false)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	passkeyUser, err := model.NewPasskeyUser(user)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(passkeyUser.Credentials))
+	for _, credential := range passkeyUser.Credentials {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	creation, sessionData, err := web.BeginRegistration(
+		passkeyUser,
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+		webauthn.WithExclusions(exclusions),
+	)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := saveWebAuthnSession(c, passkeyRegistrationKey, sessionData); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无法保存会话信息，请重试"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    creation,
+	})
+}
+
+// FinishPasskeyRegistration 请求体为浏览器返回的凭据，名称通过 name 参数传递
+func FinishPasskeyRegistration(c *gin.Context) {
+	name := c.Query("name")
+	if len(name) > 50 {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("名称不能超过50个字符"))
+		return
+	}
+
+	web, err := getWebAuthn()
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	sessionData, err := loadWebAuthnSession(c, passkeyRegistrationKey)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	user, err := model.GetUserById(c.GetInt("id"), false)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	passkeyUser, err := model.NewPasskeyUser(user)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	credential, err := web.FinishRegistration(passkeyUser, *sessionData, c.Request)
+	if err != nil {
+		logger.SysLog("passkey registration failed: " + err.Error())
+		common.APIRespondWithError(c, http.StatusOK, errors.New("通行密钥验证失败"))
+		return
+	}
+
+	if err := model.AddPasskey(user.Id, name, credential); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func DeletePasskey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	if err := model.DeletePasskey(id, c.GetInt("id")); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// BeginPasskeyLogin 使用可发现凭据登录，无需先输入用户名
+func BeginPasskeyLogin(c *gin.Context) {
+	if !config.PasskeyLoginEnabled {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("管理员关闭了通行密钥登录"))
+		return
+	}
+
+	web, err := getWebAuthn()
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	assertion, sessionData, err := web.BeginDiscoverableLogin()
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := saveWebAuthnSession(c, passkeyLoginKey, sessionData); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无法保存会话信息，请重试"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    assertion,
+	})
+}
+
+// FinishPasskeyLogin 通行密钥本身包含持有设备和用户验证两个因素，登录后视为已通过两步验证
+func FinishPasskeyLogin(c *gin.Context) {
+	if !config.PasskeyLoginEnabled {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("管理员关闭了通行密钥登录"))
+		return
+	}
+
+	web, err := getWebAuthn()
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	sessionData, err := loadWebAuthnSession(c, passkeyLoginKey)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	var passkey *model.Passkey
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		passkey, err = model.GetPasskeyByCredentialId(rawID)
+		if err != nil || strconv.Itoa(passkey.UserId) != string(userHandle) {
+			return nil, errors.New("passkey not found")
+		}
+
+		user, err := model.GetUserById(passkey.UserId, false)
+		if err != nil {
+			return nil, err
+		}
+
+		return model.NewPasskeyUser(user)
+	}
+
+	webUser, credential, err := web.FinishPasskeyLogin(handler, *sessionData, c.Request)
+	if err != nil {
+		logger.SysLog("passkey login failed: " + err.Error())
+		common.APIRespondWithError(c, http.StatusOK, errors.New("通行密钥验证失败"))
+		return
+	}
+
+	user := webUser.(*model.PasskeyUser).User
+	if user.Status != config.UserStatusEnabled {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("用户已被封禁"))
+		return
+	}
+
+	if err := passkey.UpdateCredential(credential); err != nil {
+		logger.SysError("failed to update passkey: " + err.Error())
+	}
+
+	completeLogin(user, c, true)
+}
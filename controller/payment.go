@@ -23,6 +23,9 @@ func GetPaymentList(c *gin.Context) {
 		common.APIRespondWithError(c, http.StatusOK, err)
 		return
 	}
+	for _, payment := range *payments.Data {
+		payment.MaskSecrets()
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -48,6 +51,7 @@ func GetPayment(c *gin.Context) {
 		})
 		return
 	}
+	payment.MaskSecrets()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -85,6 +89,7 @@ func AddPayment(c *gin.Context) {
 		return
 	}
 
+	payment.MaskSecrets()
 	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
 		"message": "Payment added successfully",
@@ -106,11 +111,17 @@ func UpdatePayment(c *gin.Context) {
 		overwrite = false
 	}
 
+	if err = payment.RestoreMaskedSecrets(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
 	err = payment.Update(overwrite)
 	if err != nil {
 		common.APIRespondWithError(c, http.StatusOK, err)
 		return
 	}
+	payment.MaskSecrets()
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
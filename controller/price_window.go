@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"done-hub/common"
+	"done-hub/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetPriceWindowList(c *gin.Context) {
+	var params model.GenericParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	windows, err := model.GetPriceWindowsList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    windows,
+	})
+}
+
+func GetPriceWindow(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	window, err := model.GetPriceWindowById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    window,
+	})
+}
+
+func AddPriceWindow(c *gin.Context) {
+	window := model.PriceWindow{}
+	if err := c.ShouldBindJSON(&window); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := window.Validate(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	window.Id = 0
+	if err := window.Insert(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    window,
+	})
+}
+
+func UpdatePriceWindow(c *gin.Context) {
+	window := model.PriceWindow{}
+	if err := c.ShouldBindJSON(&window); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if _, err := model.GetPriceWindowById(window.Id); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("分时价格不存在"))
+		return
+	}
+	if err := window.Validate(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := window.Update(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    window,
+	})
+}
+
+func DeletePriceWindow(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	if err := model.DeletePriceWindowById(id); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
@@ -171,6 +171,72 @@ func BatchDeletePrices(c *gin.Context) {
 	})
 }
 
+func GetGroupPrices(c *gin.Context) {
+	group := c.Query("group")
+	if group == "" {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("group is required"))
+		return
+	}
+
+	prices, err := model.GetGroupPrices(group)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    prices,
+	})
+}
+
+type GroupPriceBatchRequest struct {
+	OriginalModels []string `json:"original_models"`
+	model.BatchGroupPrices
+}
+
+func BatchSetGroupPrices(c *gin.Context) {
+	pricesBatch := &GroupPriceBatchRequest{}
+	if err := c.ShouldBindJSON(pricesBatch); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := model.PricingInstance.BatchSetGroupPrices(&pricesBatch.BatchGroupPrices, pricesBatch.OriginalModels); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+type GroupPriceBatchDeleteRequest struct {
+	Group  string   `json:"group" binding:"required"`
+	Models []string `json:"models" binding:"required"`
+}
+
+func BatchDeleteGroupPrices(c *gin.Context) {
+	pricesBatch := &GroupPriceBatchDeleteRequest{}
+	if err := c.ShouldBindJSON(pricesBatch); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := model.PricingInstance.BatchDeleteGroupPrices(pricesBatch.Group, pricesBatch.Models); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
 func SyncPricing(c *gin.Context) {
 	updateMode := c.DefaultQuery("updateMode", string(model.PriceUpdateModeSystem))
 
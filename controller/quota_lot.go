@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"done-hub/common"
+	"done-hub/model"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSelfQuotaLots 当前用户的额度批次明细
+func GetSelfQuotaLots(c *gin.Context) {
+	respondQuotaLots(c, c.GetInt("id"))
+}
+
+func GetUserQuotaLots(c *gin.Context) {
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	respondQuotaLots(c, userId)
+}
+
+func respondQuotaLots(c *gin.Context, userId int) {
+	var params model.QuotaLotsListParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	lots, err := model.GetUserQuotaLots(userId, &params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    lots,
+	})
+}
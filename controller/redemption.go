@@ -77,6 +77,13 @@ func AddRedemption(c *gin.Context) {
 		})
 		return
 	}
+	if redemption.QuotaValidDays < 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "额度有效天数不能为负数",
+		})
+		return
+	}
 	if redemption.Count > 100 {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -88,11 +95,12 @@ func AddRedemption(c *gin.Context) {
 	for i := 0; i < redemption.Count; i++ {
 		key := utils.GetUUID()
 		cleanRedemption := model.Redemption{
-			UserId:      c.GetInt("id"),
-			Name:        redemption.Name,
-			Key:         key,
-			CreatedTime: utils.GetTimestamp(),
-			Quota:       redemption.Quota,
+			UserId:         c.GetInt("id"),
+			Name:           redemption.Name,
+			Key:            key,
+			CreatedTime:    utils.GetTimestamp(),
+			Quota:          redemption.Quota,
+			QuotaValidDays: redemption.QuotaValidDays,
 		}
 		err = cleanRedemption.Insert()
 		if err != nil {
@@ -153,6 +161,7 @@ func UpdateRedemption(c *gin.Context) {
 		// If you add more fields, please also update redemption.Update()
 		cleanRedemption.Name = redemption.Name
 		cleanRedemption.Quota = redemption.Quota
+		cleanRedemption.QuotaValidDays = redemption.QuotaValidDays
 	}
 	err = cleanRedemption.Update()
 	if err != nil {
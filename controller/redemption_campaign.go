@@ -0,0 +1,250 @@
+package controller
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"done-hub/common"
+	"done-hub/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GenerateCampaignRedemptionsRequest struct {
+	Count   int `json:"count"`
+	MaxUses int `json:"max_uses"`
+}
+
+func GetRedemptionCampaignList(c *gin.Context) {
+	var params model.GenericParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	campaigns, err := model.GetRedemptionCampaignsList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    campaigns,
+	})
+}
+
+func GetRedemptionCampaign(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	campaign, err := model.GetRedemptionCampaignById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    campaign,
+	})
+}
+
+func AddRedemptionCampaign(c *gin.Context) {
+	campaign := model.RedemptionCampaign{}
+	if err := c.ShouldBindJSON(&campaign); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := campaign.Validate(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	campaign.Id = 0
+	campaign.UserId = c.GetInt("id")
+	if err := campaign.Insert(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    campaign,
+	})
+}
+
+func UpdateRedemptionCampaign(c *gin.Context) {
+	campaign := model.RedemptionCampaign{}
+	if err := c.ShouldBindJSON(&campaign); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if _, err := model.GetRedemptionCampaignById(campaign.Id); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("活动不存在"))
+		return
+	}
+	if err := campaign.Validate(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := campaign.Update(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    campaign,
+	})
+}
+
+func DeleteRedemptionCampaign(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	if err := model.DeleteRedemptionCampaignById(id); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// GenerateCampaignRedemptions 为活动批量生成兑换码，返回生成的兑换码
+func GenerateCampaignRedemptions(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	var req GenerateCampaignRedemptionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("invalid request"))
+		return
+	}
+	if req.MaxUses == 0 {
+		req.MaxUses = 1
+	}
+
+	campaign, err := model.GetRedemptionCampaignById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("活动不存在"))
+		return
+	}
+
+	redemptions, err := model.GenerateCampaignRedemptions(campaign, req.Count, req.MaxUses, c.GetInt("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	keys := make([]string, 0, len(redemptions))
+	for _, redemption := range redemptions {
+		keys = append(keys, redemption.Key)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    keys,
+	})
+}
+
+// ExportCampaignRedemptions 导出活动下的全部兑换码为 csv
+func ExportCampaignRedemptions(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	campaign, err := model.GetRedemptionCampaignById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("活动不存在"))
+		return
+	}
+
+	redemptions, err := model.GetCampaignRedemptions(campaign.Id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	fileName := fmt.Sprintf("redemption-campaign-%d-%s", campaign.Id, time.Now().Format("20060102150405"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.csv", fileName))
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{"id", "key", "status", "quota", "max_uses", "used_count", "created_time", "redeemed_time"})
+	for _, redemption := range redemptions {
+		writer.Write([]string{
+			strconv.Itoa(redemption.Id),
+			redemption.Key,
+			strconv.Itoa(redemption.Status),
+			strconv.Itoa(redemption.Quota),
+			strconv.Itoa(redemption.MaxUses),
+			strconv.Itoa(redemption.UsedCount),
+			strconv.FormatInt(redemption.CreatedTime, 10),
+			strconv.FormatInt(redemption.RedeemedTime, 10),
+		})
+	}
+	writer.Flush()
+}
+
+// GetRedemptionCampaignStatistics 活动的兑换汇总，以及指定时间段内按天的兑换统计
+func GetRedemptionCampaignStatistics(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	if endTimestamp == 0 {
+		endTimestamp = time.Now().Unix()
+	}
+
+	summary, err := model.GetRedemptionCampaignStatistics(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	daily, err := model.GetStatisticsRedemptionByPeriod(startTimestamp, endTimestamp, id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"summary": summary,
+			"daily":   daily,
+		},
+	})
+}
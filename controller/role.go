@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"done-hub/common"
+	"done-hub/common/utils"
+	"done-hub/model"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetRoles(c *gin.Context) {
+	roles, err := model.GetRolesAll()
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    roles,
+	})
+}
+
+func GetPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    model.Permissions,
+	})
+}
+
+func AddRole(c *gin.Context) {
+	role := model.Role{}
+	if err := c.ShouldBindJSON(&role); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if err := role.Validate(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if err := model.CheckGrantPermissions(c.GetInt("id"), c.GetInt("role"), role.Permissions); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	role.Id = 0
+	role.CreatedTime = utils.GetTimestamp()
+	if err := role.Insert(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    role,
+	})
+}
+
+func UpdateRole(c *gin.Context) {
+	role := model.Role{}
+	if err := c.ShouldBindJSON(&role); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if err := role.Validate(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	// 修改前后的权限都不能超出自己拥有的权限
+	origin, err := model.GetRoleById(role.Id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if err := model.CheckGrantPermissions(c.GetInt("id"), c.GetInt("role"), slices.Concat(origin.Permissions, role.Permissions)); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := role.Update(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    role,
+	})
+}
+
+func DeleteRole(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	role, err := model.GetRoleById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if err := model.CheckGrantPermissions(c.GetInt("id"), c.GetInt("role"), role.Permissions); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := role.Delete(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
@@ -0,0 +1,309 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"done-hub/common"
+	"done-hub/common/config"
+	"done-hub/common/logger"
+	"done-hub/common/utils"
+	"done-hub/model"
+	"done-hub/payment"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SubscriptionOrderRequest struct {
+	UUID   string `json:"uuid" binding:"required"`
+	PlanId int    `json:"plan_id" binding:"required"`
+	Months int    `json:"months"`
+}
+
+// GetSubscriptionPlans 可购买的订阅套餐
+func GetSubscriptionPlans(c *gin.Context) {
+	plans, err := model.GetEnabledSubscriptionPlans()
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    plans,
+	})
+}
+
+// GetSelfSubscription 当前用户生效中的订阅，没有订阅时 data 为空
+func GetSelfSubscription(c *gin.Context) {
+	subscription, err := model.GetUserCurrentSubscription(c.GetInt("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": true,
+				"message": "",
+				"data":    nil,
+			})
+			return
+		}
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    subscription,
+	})
+}
+
+// CreateSubscriptionOrder 通过现有支付网关购买订阅套餐
+func CreateSubscriptionOrder(c *gin.Context) {
+	if !config.SubscriptionEnabled {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("订阅功能未开启"))
+		return
+	}
+
+	var orderReq SubscriptionOrderRequest
+	if err := c.ShouldBindJSON(&orderReq); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("invalid request"))
+		return
+	}
+	if orderReq.Months == 0 {
+		orderReq.Months = 1
+	}
+	if orderReq.Months < 0 || orderReq.Months > model.SubscriptionMaxMonths {
+		common.APIRespondWithError(c, http.StatusOK, fmt.Errorf("订阅月数必须在 1 到 %d 之间", model.SubscriptionMaxMonths))
+		return
+	}
+
+	plan, err := model.GetSubscriptionPlanById(orderReq.PlanId)
+	if err != nil || plan.Enable == nil || !*plan.Enable {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("套餐不存在"))
+		return
+	}
+
+	userId := c.GetInt("id")
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("用户不存在"))
+		return
+	}
+
+	// 关闭用户未完成的订单
+	go model.CloseUnfinishedOrder()
+
+	paymentService, err := payment.NewPaymentService(orderReq.UUID)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	// 套餐价格不参与充值折扣
+	price := plan.Price * float64(orderReq.Months)
+	discount, fee, payMoney := calculatePayMoney(paymentService.Payment, price, 1)
+	tradeNo := utils.GenerateTradeNo()
+	payRequest, err := paymentService.Pay(tradeNo, payMoney, user)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("创建支付失败，请稍后再试"))
+		return
+	}
+
+	order := &model.Order{
+		UserId:        userId,
+		GatewayId:     paymentService.Payment.ID,
+		TradeNo:       tradeNo,
+		Amount:        int(math.Ceil(price)),
+		OrderAmount:   payMoney,
+		OrderCurrency: paymentService.Payment.Currency,
+		Fee:           fee,
+		Discount:      discount,
+		Status:        model.OrderStatusPending,
+		PlanId:        plan.Id,
+		PlanMonths:    orderReq.Months,
+	}
+
+	err = order.Insert()
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("创建订单失败，请稍后再试"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": &OrderResponse{
+			TradeNo:    tradeNo,
+			PayRequest: payRequest,
+		},
+	})
+}
+
+// completeSubscriptionOrder 订阅订单支付成功后开通订阅
+func completeSubscriptionOrder(order *model.Order, ip string) {
+	subscription, err := model.ActivateSubscription(order.UserId, order.PlanId, order.PlanMonths, order.TradeNo)
+	if err != nil {
+		logger.SysError(fmt.Sprintf("gateway callback failed to activate subscription, trade_no: %s, error: %s", order.TradeNo, err.Error()))
+		return
+	}
+
+	model.RecordQuotaLog(order.UserId, model.LogTypeTopup, 0, ip, fmt.Sprintf("订阅 %s %d 个周期，支付金额：%.2f %s", subscription.PlanName, order.PlanMonths, order.OrderAmount, order.OrderCurrency))
+}
+
+func GetSubscriptionPlanList(c *gin.Context) {
+	var params model.GenericParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	plans, err := model.GetSubscriptionPlansList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    plans,
+	})
+}
+
+func GetSubscriptionPlan(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	plan, err := model.GetSubscriptionPlanById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    plan,
+	})
+}
+
+func AddSubscriptionPlan(c *gin.Context) {
+	plan := model.SubscriptionPlan{}
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if plan.DurationDays == 0 {
+		plan.DurationDays = 30
+	}
+	if err := plan.Validate(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	plan.Id = 0
+	if err := plan.Insert(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    plan,
+	})
+}
+
+func UpdateSubscriptionPlan(c *gin.Context) {
+	plan := model.SubscriptionPlan{}
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if _, err := model.GetSubscriptionPlanById(plan.Id); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("套餐不存在"))
+		return
+	}
+	if err := plan.Validate(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := plan.Update(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    plan,
+	})
+}
+
+// DeleteSubscriptionPlan 删除套餐，已开通的订阅不受影响
+func DeleteSubscriptionPlan(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	if err := model.DeleteSubscriptionPlanById(id); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func GetUserSubscriptionList(c *gin.Context) {
+	var params model.SearchUserSubscriptionParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	subscriptions, err := model.GetUserSubscriptionsList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    subscriptions,
+	})
+}
+
+func CancelUserSubscription(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	if err := model.CancelUserSubscription(id); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
@@ -4,12 +4,14 @@ import (
 	"done-hub/common"
 	"done-hub/common/config"
 	"done-hub/common/utils"
+	"done-hub/common/webhook"
 	"done-hub/model"
 	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
 )
 
 func GetUserTokensList(c *gin.Context) {
@@ -81,12 +83,27 @@ func GetPlaygroundToken(c *gin.Context) {
 			return
 		}
 		token = &cleanToken
+	} else if token.IsHashed() {
+		// 新版令牌无法取回明文，只返回展示前缀，由前端使用已保存的令牌，需要时再轮换
+		if c.Query("rotate") != "true" {
+			c.JSON(http.StatusOK, gin.H{
+				"success": true,
+				"message": "",
+				"data":    gin.H{"key": "", "prefix": token.Key},
+			})
+			return
+		}
+
+		if _, err = token.ResetKey(); err != nil {
+			common.APIRespondWithError(c, http.StatusOK, err)
+			return
+		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
-		"data":    token.Key,
+		"data":    gin.H{"key": token.Key, "prefix": ""},
 	})
 }
 
@@ -126,6 +143,23 @@ func AddToken(c *gin.Context) {
 		common.APIRespondWithError(c, http.StatusOK, err)
 		return
 	}
+	if setting.Webhook.Secret == "" {
+		setting.Webhook.Secret = utils.GetRandomString(32)
+	}
+	token.Setting.JSONType = datatypes.NewJSONType(setting)
+
+	// 组织令牌需要当前用户在组织中有创建令牌的权限
+	if token.OrganizationId > 0 {
+		member, err := model.GetOrganizationMember(token.OrganizationId, userId)
+		if err != nil {
+			common.APIRespondWithError(c, http.StatusOK, err)
+			return
+		}
+		if !model.OrganizationRoleCan(member.Role, "token") {
+			common.APIRespondWithError(c, http.StatusOK, model.ErrOrganizationPermission)
+			return
+		}
+	}
 
 	cleanToken := model.Token{
 		UserId: userId,
@@ -138,6 +172,7 @@ func AddToken(c *gin.Context) {
 		UnlimitedQuota: token.UnlimitedQuota,
 		Group:          token.Group,
 		Setting:        token.Setting,
+		OrganizationId: token.OrganizationId,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -147,9 +182,34 @@ func AddToken(c *gin.Context) {
 		})
 		return
 	}
+	// 完整令牌只在创建时返回一次
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
+		"data":    cleanToken,
+	})
+}
+
+// RotateToken 轮换令牌，旧令牌立即失效，新令牌只返回一次
+func RotateToken(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	userId := c.GetInt("id")
+	token, err := model.GetTokenByIds(id, userId)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	key, err := token.ResetKey()
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    key,
 	})
 }
 
@@ -242,7 +302,15 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.RemainQuota = token.RemainQuota
 		cleanToken.UnlimitedQuota = token.UnlimitedQuota
 		cleanToken.Group = token.Group
-		cleanToken.Setting = token.Setting
+
+		// 未提交签名密钥时沿用原密钥
+		if setting.Webhook.Secret == "" {
+			setting.Webhook.Secret = cleanToken.Setting.Data().Webhook.Secret
+		}
+		if setting.Webhook.Secret == "" {
+			setting.Webhook.Secret = utils.GetRandomString(32)
+		}
+		cleanToken.Setting.JSONType = datatypes.NewJSONType(setting)
 	}
 	err = cleanToken.Update()
 	if err != nil {
@@ -282,11 +350,21 @@ func validateTokenSetting(setting *model.TokenSetting) error {
 		return nil
 	}
 
+	if setting.Webhook.URL != "" {
+		if err := webhook.ValidateURL(setting.Webhook.URL); err != nil {
+			return err
+		}
+	}
+
 	if setting.Heartbeat.Enabled {
 		if setting.Heartbeat.TimeoutSeconds < 30 || setting.Heartbeat.TimeoutSeconds > 90 {
 			return errors.New("heartbeat timeout seconds must be between 30 and 90")
 		}
 	}
 
+	if setting.EndUser.RPM < 0 || setting.EndUser.DailyQuota < 0 {
+		return errors.New("end user limits must not be negative")
+	}
+
 	return nil
 }
@@ -0,0 +1,257 @@
+package controller
+
+import (
+	"done-hub/common"
+	"done-hub/common/config"
+	"done-hub/common/limit"
+	"done-hub/common/totp"
+	"done-hub/model"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	pendingTwoFactorIdKey   = "pending_2fa_id"
+	pendingTwoFactorTimeKey = "pending_2fa_time"
+	// 密码验证通过后需要在该时间内完成两步验证
+	pendingTwoFactorTimeout = 5 * 60
+	// 每个用户每分钟允许的验证次数
+	twoFactorAttemptsPerMinute = 5
+)
+
+var (
+	twoFactorLimiter     limit.RateLimiter
+	twoFactorLimiterOnce sync.Once
+)
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// allowTwoFactorAttempt 按用户限制验证次数，防止暴力猜测口令
+func allowTwoFactorAttempt(userId int) bool {
+	twoFactorLimiterOnce.Do(func() {
+		twoFactorLimiter = limit.NewAPILimiter(twoFactorAttemptsPerMinute)
+	})
+	return twoFactorLimiter.Allow(fmt.Sprintf("two_factor:%d", userId))
+}
+
+func verifyTwoFactorCode(userId int, code string) error {
+	if !allowTwoFactorAttempt(userId) {
+		return errors.New("验证次数过多，请稍后再试")
+	}
+	return model.VerifyTwoFactor(userId, code)
+}
+
+// LoginTwoFactor 校验密码或第三方登录后的两步验证码，通过后完成登录
+func LoginTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	session := sessions.Default(c)
+	userId, _ := session.Get(pendingTwoFactorIdKey).(int)
+	pendingTime, _ := session.Get(pendingTwoFactorTimeKey).(int64)
+	if userId == 0 || time.Now().Unix()-pendingTime > pendingTwoFactorTimeout {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("登录已过期，请重新登录"))
+		return
+	}
+
+	if err := verifyTwoFactorCode(userId, req.Code); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	user, err := model.GetUserById(userId, false)
+	if err != nil || user.Status != config.UserStatusEnabled {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("用户已被封禁"))
+		return
+	}
+
+	completeLogin(user, c, true)
+}
+
+// GetTwoFactorStatus 当前用户的两步验证和通行密钥状态
+func GetTwoFactorStatus(c *gin.Context) {
+	userId := c.GetInt("id")
+
+	totpEnabled := false
+	recoveryCodes := 0
+	if twoFactor, err := model.GetTwoFactor(userId); err == nil && twoFactor.Enabled {
+		totpEnabled = true
+		recoveryCodes = twoFactor.RecoveryCodesRemaining()
+	}
+
+	passkeys, err := model.GetPasskeysByUserId(userId)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"totp_enabled":             totpEnabled,
+			"recovery_codes_remaining": recoveryCodes,
+			"passkeys":                 passkeys,
+			"required":                 config.TwoFactorRequiredForAdmin && c.GetInt("role") >= config.RoleAdminUser,
+			"verified":                 sessions.Default(c).Get("two_factor") == true,
+		},
+	})
+}
+
+// SetupTwoFactor 生成动态口令密钥，返回密钥和用于扫码的链接
+func SetupTwoFactor(c *gin.Context) {
+	key, err := model.SetupTwoFactor(c.GetInt("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"secret": key,
+			"url":    totp.URL(config.SystemName, c.GetString("username"), key),
+		},
+	})
+}
+
+// EnableTwoFactor 校验口令后启用两步验证，返回恢复码
+func EnableTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	userId := c.GetInt("id")
+	if !allowTwoFactorAttempt(userId) {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("验证次数过多，请稍后再试"))
+		return
+	}
+
+	codes, err := model.EnableTwoFactor(userId, req.Code)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	// 已证明持有口令，当前会话视为已通过两步验证
+	markSessionTwoFactor(c)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+func DisableTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	userId := c.GetInt("id")
+	if !allowTwoFactorAttempt(userId) {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("验证次数过多，请稍后再试"))
+		return
+	}
+
+	if err := model.DisableTwoFactor(userId, req.Code); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	userId := c.GetInt("id")
+	if !allowTwoFactorAttempt(userId) {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("验证次数过多，请稍后再试"))
+		return
+	}
+
+	codes, err := model.RegenerateRecoveryCodes(userId, req.Code)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// ResetUserTwoFactor 管理员清除用户的两步验证和通行密钥，用于用户丢失设备的情况
+func ResetUserTwoFactor(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	user, err := model.GetUserById(id, false)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	myRole := c.GetInt("role")
+	if myRole <= user.Role && myRole != config.RoleRootUser {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无权重置同级或更高等级用户的两步验证"))
+		return
+	}
+
+	if err := model.ResetTwoFactor(id); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if err := model.DeletePasskeysByUserId(id); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func markSessionTwoFactor(c *gin.Context) {
+	session := sessions.Default(c)
+	if session.Get("username") == nil {
+		return
+	}
+	session.Set("two_factor", true)
+	session.Save()
+}
@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"math"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -66,13 +67,57 @@ func Login(c *gin.Context) {
 }
 
 // setup session & cookies and then return user info
+// 启用了两步验证的账号只记录待验证状态，通过 LoginTwoFactor 校验后再完成登录
 func setupLogin(user *model.User, c *gin.Context) {
+	if !model.IsTwoFactorEnabled(user.Id) {
+		completeLogin(user, c, false)
+		return
+	}
+
 	session := sessions.Default(c)
+	for _, key := range []string{"id", "username", "role", "status", "two_factor", "session_token"} {
+		session.Delete(key)
+	}
+	session.Set(pendingTwoFactorIdKey, user.Id)
+	session.Set(pendingTwoFactorTimeKey, time.Now().Unix())
+	if err := session.Save(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "无法保存会话信息，请重试",
+			"success": false,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "",
+		"success": true,
+		"data": gin.H{
+			"require_2fa": true,
+		},
+	})
+}
+
+// completeLogin 写入登录会话，twoFactor 表示本次登录经过了两步验证或通行密钥
+func completeLogin(user *model.User, c *gin.Context, twoFactor bool) {
+	session := sessions.Default(c)
+	session.Delete(pendingTwoFactorIdKey)
+	session.Delete(pendingTwoFactorTimeKey)
 	session.Set("id", user.Id)
 	session.Set("username", user.Username)
 	session.Set("role", user.Role)
 	session.Set("status", user.Status)
-	err := session.Save()
+	session.Set("two_factor", twoFactor)
+
+	token, err := model.CreateUserSession(user.Id, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "无法保存会话信息，请重试",
+			"success": false,
+		})
+		return
+	}
+	session.Set("session_token", token)
+	err = session.Save()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"message": "无法保存会话信息，请重试",
@@ -101,6 +146,9 @@ func setupLogin(user *model.User, c *gin.Context) {
 
 func Logout(c *gin.Context) {
 	session := sessions.Default(c)
+	if token, ok := session.Get("session_token").(string); ok {
+		model.DeleteUserSessionByToken(token)
+	}
 	session.Clear()
 	err := session.Save()
 	if err != nil {
@@ -326,6 +374,14 @@ func GenerateAccessToken(c *gin.Context) {
 		})
 		return
 	}
+	// access token 可以访问管理接口，要求两步验证时只能在通过两步验证的会话中生成
+	if config.TwoFactorRequiredForAdmin && user.Role >= config.RoleAdminUser && sessions.Default(c).Get("two_factor") != true {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员要求管理账号启用两步验证，请在个人设置中启用后重新登录",
+		})
+		return
+	}
 	user.AccessToken = utils.GetUUID()
 
 	if model.DB.Where("access_token = ?", user.AccessToken).First(user).RowsAffected != 0 {
@@ -394,6 +450,7 @@ func GetSelf(c *gin.Context) {
 	if err == nil {
 		user.AffCount = int(affCount)
 	}
+	user.Permissions = model.GetUserPermissions(user.Id, user.Role)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -402,9 +459,16 @@ func GetSelf(c *gin.Context) {
 	})
 }
 
+// updateUserRequest 额度只在请求中明确传入时修改，避免编辑资料时用旧值覆盖期间的消费
+type updateUserRequest struct {
+	model.User
+	Quota *int `json:"quota"`
+}
+
 func UpdateUser(c *gin.Context) {
-	var updatedUser model.User
-	err := json.NewDecoder(c.Request.Body).Decode(&updatedUser)
+	var req updateUserRequest
+	err := json.NewDecoder(c.Request.Body).Decode(&req)
+	updatedUser := req.User
 	if err != nil || updatedUser.Id == 0 {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -460,6 +524,8 @@ func UpdateUser(c *gin.Context) {
 		updatedUser.Password = "" // rollback to what it should be
 	}
 	updatePassword := updatedUser.Password != ""
+	// 角色只能通过 SetUserRole 分配
+	updatedUser.RoleId = 0
 	if err := updatedUser.Update(updatePassword); err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -467,8 +533,16 @@ func UpdateUser(c *gin.Context) {
 		})
 		return
 	}
-	if originUser.Quota != updatedUser.Quota {
-		model.RecordLog(originUser.Id, model.LogTypeManage, fmt.Sprintf("管理员将用户额度从 %s修改为 %s", common.LogQuota(originUser.Quota), common.LogQuota(updatedUser.Quota)))
+	if updatePassword || (updatedUser.Status == config.UserStatusDisabled && originUser.Status != config.UserStatusDisabled) {
+		model.RevokeUserSessions(updatedUser.Id, 0)
+	}
+	// 用户信息更新不写入额度，额度差值按管理员增减处理，同步创建或扣减额度批次
+	if req.Quota != nil && *req.Quota != originUser.Quota {
+		if err := model.ChangeUserQuota(originUser.Id, *req.Quota-originUser.Quota, false, 0, "管理员修改用户额度"); err != nil {
+			common.APIRespondWithError(c, http.StatusOK, err)
+			return
+		}
+		model.RecordLog(originUser.Id, model.LogTypeManage, fmt.Sprintf("管理员将用户额度从 %s修改为 %s", common.LogQuota(originUser.Quota), common.LogQuota(*req.Quota)))
 	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -476,6 +550,66 @@ func UpdateUser(c *gin.Context) {
 	})
 }
 
+type setUserRoleRequest struct {
+	Id     int `json:"id"`
+	RoleId int `json:"role_id"`
+}
+
+// SetUserRole 为用户分配管理后台角色，role_id 为 0 时取消
+func SetUserRole(c *gin.Context) {
+	var req setUserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Id == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+
+	originUser, err := model.GetUserById(req.Id, false)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	myRole := c.GetInt("role")
+	if myRole <= originUser.Role && myRole != config.RoleRootUser {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权更新同权限等级或更高权限等级的用户信息",
+		})
+		return
+	}
+
+	if req.RoleId != 0 && originUser.Role < config.RoleAdminUser {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "角色只能分配给管理员",
+		})
+		return
+	}
+
+	// 分配的角色和用户原有的权限都不能超出自己拥有的权限
+	permissions := model.GetUserPermissions(originUser.Id, originUser.Role)
+	if role := model.GlobalRoles.Get(req.RoleId); role != nil {
+		permissions = slices.Concat(permissions, role.Permissions)
+	}
+	if err := model.CheckGrantPermissions(c.GetInt("id"), myRole, permissions); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := model.SetUserRoleId(req.Id, req.RoleId); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	model.RecordLog(originUser.Id, model.LogTypeManage, fmt.Sprintf("管理员将用户角色修改为 #%d", req.RoleId))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
 func UpdateSelf(c *gin.Context) {
 	var user model.User
 	err := json.NewDecoder(c.Request.Body).Decode(&user)
@@ -515,6 +649,10 @@ func UpdateSelf(c *gin.Context) {
 		})
 		return
 	}
+	// 修改密码后其他设备上的会话全部失效
+	if updatePassword {
+		model.RevokeUserSessions(cleanUser.Id, c.GetInt("user_session_id"))
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -727,6 +865,14 @@ func ManageUser(c *gin.Context) {
 		})
 		return
 	}
+	// 会话中保存了登录时的状态和角色，禁用或降级后需要重新登录
+	if req.Action == "disable" || req.Action == "demote" {
+		model.RevokeUserSessions(user.Id, 0)
+	}
+	// 角色只对管理员生效，降级后取消角色，避免再次升级时沿用旧角色
+	if req.Action == "demote" && user.RoleId != 0 {
+		model.SetUserRoleId(user.Id, 0)
+	}
 	clearUser := model.User{
 		Role:   user.Role,
 		Status: user.Status,
@@ -818,8 +964,9 @@ func TopUp(c *gin.Context) {
 }
 
 type ChangeUserQuotaRequest struct {
-	Quota  int    `json:"quota" form:"quota"`
-	Remark string `json:"remark" form:"remark"`
+	Quota     int    `json:"quota" form:"quota"`
+	Remark    string `json:"remark" form:"remark"`
+	ExpiresAt int64  `json:"expires_at" form:"expires_at"`
 }
 
 func ChangeUserQuota(c *gin.Context) {
@@ -842,7 +989,12 @@ func ChangeUserQuota(c *gin.Context) {
 		return
 	}
 
-	err = model.ChangeUserQuota(userId, req.Quota, false)
+	if req.ExpiresAt != 0 && req.Quota < 0 {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("扣减额度不能设置过期时间"))
+		return
+	}
+
+	err = model.ChangeUserQuota(userId, req.Quota, false, req.ExpiresAt, req.Remark)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -852,6 +1004,9 @@ func ChangeUserQuota(c *gin.Context) {
 	}
 
 	remark := fmt.Sprintf("管理员增减用户额度 %s", common.LogQuota(req.Quota))
+	if req.ExpiresAt != 0 {
+		remark = fmt.Sprintf("%s, 有效期至 %s", remark, time.Unix(req.ExpiresAt, 0).Format("2006-01-02 15:04:05"))
+	}
 
 	if req.Remark != "" {
 		remark = fmt.Sprintf("%s, 备注: %s", remark, req.Remark)
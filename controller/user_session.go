@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"done-hub/common"
+	"done-hub/common/config"
+	"done-hub/model"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSelfSessions 当前用户的登录会话，标记当前所在的会话
+func GetSelfSessions(c *gin.Context) {
+	sessions, err := model.GetUserSessions(c.GetInt("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	currentId := c.GetInt("user_session_id")
+	for _, session := range sessions {
+		session.Current = session.Id == currentId
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    sessions,
+	})
+}
+
+func RevokeSelfSession(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return
+	}
+
+	if err := model.RevokeUserSession(c.GetInt("id"), id); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// RevokeOtherSelfSessions 退出除当前会话外的所有设备
+func RevokeOtherSelfSessions(c *gin.Context) {
+	if err := model.RevokeUserSessions(c.GetInt("id"), c.GetInt("user_session_id")); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func GetUserSessions(c *gin.Context) {
+	user, ok := getManageableUser(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := model.GetUserSessions(user.Id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    sessions,
+	})
+}
+
+// RevokeUserSessions 撤销用户的会话，指定 session_id 时只撤销该会话
+func RevokeUserSessions(c *gin.Context) {
+	user, ok := getManageableUser(c)
+	if !ok {
+		return
+	}
+
+	var err error
+	if sessionId, _ := strconv.Atoi(c.Query("session_id")); sessionId > 0 {
+		err = model.RevokeUserSession(user.Id, sessionId)
+	} else {
+		err = model.RevokeUserSessions(user.Id, 0)
+	}
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// getManageableUser 读取路由中的用户，只能管理比自己等级低的用户
+func getManageableUser(c *gin.Context) (*model.User, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的参数"))
+		return nil, false
+	}
+
+	user, err := model.GetUserById(id, false)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return nil, false
+	}
+
+	myRole := c.GetInt("role")
+	if myRole <= user.Role && myRole != config.RoleRootUser {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无权管理同权限等级或更高权限等级的用户"))
+		return nil, false
+	}
+
+	return user, true
+}
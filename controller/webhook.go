@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"done-hub/common"
+	"done-hub/common/webhook"
+	"done-hub/model"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetWebhookDeliveries(c *gin.Context) {
+	var params model.WebhookDeliveryQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	deliveries, err := model.GetAllWebhookDeliveries(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    deliveries,
+	})
+}
+
+func RedeliverWebhook(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	delivery, err := model.GetWebhookDeliveryById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	// 投递失败时同样返回最新的投递记录，便于查看失败原因
+	err = webhook.Redeliver(delivery)
+	message := ""
+	if err != nil {
+		message = err.Error()
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": err == nil,
+		"message": message,
+		"data":    delivery,
+	})
+}
@@ -3,8 +3,12 @@ package cron
 import (
 	"done-hub/common/config"
 	"done-hub/common/logger"
+	"done-hub/common/media"
 	"done-hub/common/scheduler"
+	"done-hub/common/webhook"
 	"done-hub/model"
+	"done-hub/payment"
+	"fmt"
 	"github.com/spf13/viper"
 	"time"
 
@@ -59,6 +63,124 @@ func InitCron() {
 		}),
 	)
 
+	// 每小时清理过期的 Gemini 缓存绑定记录
+	err = scheduler.Manager.AddJob(
+		"clean_gemini_cached_contents",
+		gocron.DurationJob(time.Hour),
+		gocron.NewTask(func() {
+			count, err := model.DeleteExpiredGeminiCachedContents()
+			if err != nil {
+				logger.SysError("Clean gemini cached contents error: " + err.Error())
+				return
+			}
+			if count > 0 {
+				logger.SysLog(fmt.Sprintf("清理过期 Gemini 缓存记录 %d 条", count))
+			}
+		}),
+	)
+
+	// 每分钟重试到期的任务回调
+	err = scheduler.Manager.AddJob(
+		"retry_webhook_deliveries",
+		gocron.DurationJob(time.Minute),
+		gocron.NewTask(func() {
+			webhook.RetryPendingDeliveries()
+		}),
+	)
+
+	// 每小时清理超过保留期的转存媒体
+	err = scheduler.Manager.AddJob(
+		"delete_expired_media",
+		gocron.DurationJob(time.Hour),
+		gocron.NewTask(func() {
+			media.DeleteExpired()
+		}),
+	)
+
+	// 每小时清理长时间未活动的登录会话
+	err = scheduler.Manager.AddJob(
+		"delete_expired_user_sessions",
+		gocron.DurationJob(time.Hour),
+		gocron.NewTask(func() {
+			count, err := model.DeleteExpiredUserSessions()
+			if err != nil {
+				logger.SysError("Clean user sessions error: " + err.Error())
+				return
+			}
+			if count > 0 {
+				logger.SysLog(fmt.Sprintf("清理过期登录会话 %d 条", count))
+			}
+		}),
+	)
+
+	// 每 10 分钟处理到期的额度批次
+	err = scheduler.Manager.AddJob(
+		"expire_quota_lots",
+		gocron.DurationJob(10*time.Minute),
+		gocron.NewTask(func() {
+			count, err := model.ExpireQuotaLots()
+			if err != nil {
+				logger.SysError("Expire quota lots error: " + err.Error())
+			}
+			if count > 0 {
+				logger.SysLog(fmt.Sprintf("到期额度批次 %d 笔", count))
+			}
+		}),
+	)
+
+	// 每 10 分钟恢复到期的活动升级分组
+	err = scheduler.Manager.AddJob(
+		"expire_campaign_group_upgrades",
+		gocron.DurationJob(10*time.Minute),
+		gocron.NewTask(func() {
+			count, err := model.ExpireCampaignGroupUpgrades()
+			if err != nil {
+				logger.SysError("Expire campaign group upgrades error: " + err.Error())
+			}
+			if count > 0 {
+				logger.SysLog(fmt.Sprintf("恢复到期的活动升级分组 %d 个", count))
+			}
+		}),
+	)
+
+	// 每 10 分钟发放订阅周期额度，处理续订提醒和到期的订阅
+	err = scheduler.Manager.AddJob(
+		"process_subscriptions",
+		gocron.DurationJob(10*time.Minute),
+		gocron.NewTask(func() {
+			model.ProcessSubscriptions()
+		}),
+	)
+
+	// 每 10 分钟结算到期的分销佣金
+	err = scheduler.Manager.AddJob(
+		"settle_affiliate_commissions",
+		gocron.DurationJob(10*time.Minute),
+		gocron.NewTask(func() {
+			count, err := model.SettleAffiliateCommissions()
+			if err != nil {
+				logger.SysError("Settle affiliate commissions error: " + err.Error())
+			}
+			if count > 0 {
+				logger.SysLog(fmt.Sprintf("结算分销佣金 %d 笔", count))
+			}
+		}),
+	)
+
+	// 每天凌晨三点对账前两天的订单
+	err = scheduler.Manager.AddJob(
+		"reconcile_payments",
+		gocron.DailyJob(1, gocron.NewAtTimes(gocron.NewAtTime(3, 0, 0))),
+		gocron.NewTask(func() {
+			result, err := payment.Reconcile()
+			if err != nil {
+				logger.SysError("Reconcile payments error: " + err.Error())
+				return
+			}
+			logger.SysLog(fmt.Sprintf("支付对账完成，检查 %d 笔，跳过 %d 笔，失败 %d 笔，新增异常 %d 笔", result.Checked, result.Skipped, result.Failed, result.Flagged))
+		}),
+	)
+
 	// 开启自动更新 并且设置了有效自动更新时间 同时自动更新模式不是system 则会从服务器拉取最新价格表
 	autoPriceUpdatesInterval := viper.GetInt("auto_price_updates_interval")
 	autoPriceUpdates := viper.GetBool("auto_price_updates")
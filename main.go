@@ -6,11 +6,13 @@ import (
 	"done-hub/common/cache"
 	"done-hub/common/config"
 	"done-hub/common/logger"
+	"done-hub/common/media"
 	"done-hub/common/notify"
 	"done-hub/common/oidc"
 	"done-hub/common/redis"
 	"done-hub/common/requester"
 	"done-hub/common/search"
+	"done-hub/common/secret"
 	"done-hub/common/storage"
 	"done-hub/common/telegram"
 	"done-hub/controller"
@@ -53,9 +55,16 @@ func main() {
 		logger.FatalLog("failed to initialize user token: " + err.Error())
 	}
 
+	// Initialize encryption master key
+	err = secret.InitSecret()
+	if err != nil {
+		logger.FatalLog("failed to initialize encryption key: " + err.Error())
+	}
+
 	// Initialize SQL Database
 	model.SetupDB()
 	defer model.CloseDB()
+	cli.HandleReEncrypt()
 	// Initialize Redis
 	redis.InitRedisClient()
 	cache.InitCacheManager()
@@ -79,6 +88,7 @@ func main() {
 	notify.InitNotifier()
 	cron.InitCron()
 	storage.InitStorage()
+	media.InitPersistWorker()
 	search.InitSearcher()
 	// 初始化安全检查器
 	safty.InitSaftyTools()
@@ -111,6 +121,8 @@ func initMemoryCache() {
 func initSync() {
 	// go controller.AutomaticallyUpdateChannels(viper.GetInt("channel.update_frequency"))
 	go controller.AutomaticallyTestChannels(viper.GetInt("channel.test_frequency"))
+	// 角色只在处理修改请求的节点上立即重新加载，其他节点定期同步
+	go model.SyncRoles(viper.GetInt("sync_frequency"))
 }
 
 func initHttpServer() {
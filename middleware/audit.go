@@ -0,0 +1,182 @@
+package middleware
+
+import (
+	"bytes"
+	"done-hub/model"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxAuditBodySize     = 1 << 20 // 1MB
+	maxAuditResponseSize = 64 << 10
+)
+
+// auditResponseWriter 保留响应开头部分，用于判断操作是否成功以及获取新建实体的 ID
+type auditResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditResponseWriter) Write(data []byte) (int, error) {
+	if remain := maxAuditResponseSize - w.body.Len(); remain > 0 {
+		w.body.Write(data[:min(len(data), remain)])
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// auditRouteEntities 按路由确定被修改的实体类型，同一分组下的子资源放在分组前面
+var auditRouteEntities = []struct {
+	prefix     string
+	entityType string
+}{
+	{"/api/user/", "users"},
+	{"/api/user_group/", "groups"},
+	{"/api/option/", "options"},
+	{"/api/system_info/", "options"},
+	{"/api/channel/", "channels"},
+	{"/api/channel_tag/", "channels"},
+	{"/api/sse/channel/", "channels"},
+	{"/api/admin_token/", "admin_tokens"},
+	{"/api/role/", "roles"},
+	{"/api/redemption/campaign", "redemption_campaigns"},
+	{"/api/redemption/", "redemptions"},
+	{"/api/prices/window", "price_windows"},
+	{"/api/prices/", "prices"},
+	{"/api/model_ownedby/", "model_ownedby"},
+	{"/api/payment/order/", "orders"},
+	{"/api/payment/refund/", "order_refunds"},
+	{"/api/payment/mismatch/", "payment_mismatches"},
+	{"/api/payment/reconcile", "payment_mismatches"},
+	{"/api/payment/affiliate/commission/", "affiliate_commissions"},
+	{"/api/payment/affiliate/withdrawal/", "affiliate_withdrawals"},
+	{"/api/payment/plan", "subscription_plans"},
+	{"/api/payment/subscription/", "user_subscriptions"},
+	{"/api/payment/", "payments"},
+	{"/api/organization/", "organizations"},
+	{"/api/webhook/", "webhook_deliveries"},
+	{"/api/log/", "logs"},
+}
+
+type auditResponse struct {
+	Success *bool           `json:"success"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// nextWithAudit 执行后续处理，需要权限的修改操作成功后记录审计日志
+func nextWithAudit(c *gin.Context, permission string) {
+	if permission == "" || c.Request.Method == http.MethodGet {
+		c.Next()
+		return
+	}
+
+	entityType := auditEntityType(c.FullPath())
+
+	var body []byte
+	if c.Request.Body != nil && c.Request.ContentLength >= 0 && c.Request.ContentLength <= maxAuditBodySize {
+		body, _ = io.ReadAll(c.Request.Body)
+		c.Request.Body.Close()
+		c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+	}
+
+	entityId := auditEntityId(c, entityType, body)
+	before := model.LoadAuditEntity(entityType, entityId)
+
+	writer := &auditResponseWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	c.Next()
+	c.Writer = writer.ResponseWriter
+
+	if writer.Status() >= http.StatusBadRequest {
+		return
+	}
+
+	var response auditResponse
+	if json.Unmarshal(writer.body.Bytes(), &response) == nil {
+		if response.Success != nil && !*response.Success {
+			return
+		}
+
+		// 新建的实体从响应中获取 ID
+		if entityId == "" {
+			entityId = jsonFieldString(response.Data, "id")
+		}
+	}
+
+	var after any
+	if c.Request.Method != http.MethodDelete {
+		after = model.LoadAuditEntity(entityType, entityId)
+		if after == nil && len(body) > 0 {
+			after = body
+		}
+	}
+
+	model.RecordAuditLog(&model.AuditLog{
+		ActorId:      c.GetInt("id"),
+		ActorIp:      c.ClientIP(),
+		AdminTokenId: c.GetInt("admin_token_id"),
+		Action:       c.Request.Method + " " + c.FullPath(),
+		EntityType:   entityType,
+		EntityId:     entityId,
+	}, before, after)
+}
+
+// auditEntityType 根据路由获取被修改的实体类型，未列出的路由使用 /api 后的第一段路径
+func auditEntityType(path string) string {
+	for _, route := range auditRouteEntities {
+		if strings.HasPrefix(path, route.prefix) {
+			return route.entityType
+		}
+	}
+
+	return strings.SplitN(strings.TrimPrefix(path, "/api/"), "/", 2)[0]
+}
+
+// auditEntityId 从路由参数或请求内容中获取被修改实体的 ID
+func auditEntityId(c *gin.Context, entityType string, body []byte) string {
+	if id := c.Param("id"); id != "" {
+		return id
+	}
+
+	switch entityType {
+	case "channels":
+		if tag := c.Param("tag"); tag != "" {
+			return "tag:" + tag
+		}
+	case "prices":
+		if modelName := strings.TrimPrefix(c.Param("model"), "/"); modelName != "" {
+			modelName, _ = url.PathUnescape(modelName)
+			return modelName
+		}
+		return jsonFieldString(body, "model")
+	case "options":
+		return jsonFieldString(body, "key")
+	}
+
+	return jsonFieldString(body, "id")
+}
+
+func jsonFieldString(data []byte, field string) string {
+	var fields map[string]any
+	if len(data) == 0 || json.Unmarshal(data, &fields) != nil {
+		return ""
+	}
+
+	switch value := fields[field].(type) {
+	case string:
+		return value
+	case float64:
+		if value == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%.0f", value)
+	}
+
+	return ""
+}
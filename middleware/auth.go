@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"done-hub/common"
 	"done-hub/common/config"
 	"done-hub/common/utils"
 	"done-hub/model"
@@ -12,12 +13,14 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func authHelper(c *gin.Context, minRole int) {
+// authHelper 校验登录状态或管理令牌，permission 不为空时还需要拥有该权限
+func authHelper(c *gin.Context, minRole int, permission string) {
 	session := sessions.Default(c)
 	username := session.Get("username")
 	role := session.Get("role")
 	id := session.Get("id")
 	status := session.Get("status")
+	sessionAuth := username != nil
 	if username == nil {
 		// Check access token
 		accessToken := c.Request.Header.Get("Authorization")
@@ -33,6 +36,10 @@ func authHelper(c *gin.Context, minRole int) {
 			}
 			accessToken = fmt.Sprintf("Bearer %s", token)
 		}
+		if strings.HasPrefix(strings.TrimPrefix(accessToken, "Bearer "), model.AdminTokenPrefix) {
+			adminTokenAuth(c, strings.TrimPrefix(accessToken, "Bearer "), minRole, permission)
+			return
+		}
 		user := model.ValidateAccessToken(accessToken)
 		if user != nil && user.Username != "" {
 			// Token is valid
@@ -49,6 +56,22 @@ func authHelper(c *gin.Context, minRole int) {
 			return
 		}
 	}
+	var userSession *model.UserSession
+	if sessionAuth {
+		token, _ := session.Get("session_token").(string)
+		var err error
+		userSession, err = model.ValidateUserSession(token, id.(int))
+		if err != nil {
+			session.Clear()
+			session.Save()
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			c.Abort()
+			return
+		}
+	}
 	if status.(int) == config.UserStatusDisabled {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -57,7 +80,7 @@ func authHelper(c *gin.Context, minRole int) {
 		c.Abort()
 		return
 	}
-	if role.(int) < minRole {
+	if role.(int) < minRole || (permission != "" && !model.UserHasPermission(id.(int), role.(int), permission)) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "无权进行此操作，权限不足",
@@ -65,10 +88,137 @@ func authHelper(c *gin.Context, minRole int) {
 		c.Abort()
 		return
 	}
+	// 要求管理账号使用两步验证时，未经两步验证或通行密钥登录的会话不能访问管理接口
+	// access token 无法证明经过两步验证，只允许已绑定两步验证或通行密钥的账号使用，令牌本身也只能在通过两步验证的会话中生成
+	if config.TwoFactorRequiredForAdmin && (permission != "" || minRole >= config.RoleAdminUser) && !twoFactorSatisfied(session, sessionAuth, id.(int)) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员要求管理账号启用两步验证，请在个人设置中启用后重新登录",
+		})
+		c.Abort()
+		return
+	}
+	if userSession != nil {
+		userSession.Touch(c.ClientIP())
+		c.Set("user_session_id", userSession.Id)
+	}
 	c.Set("username", username)
 	c.Set("role", role)
 	c.Set("id", id)
-	c.Next()
+	nextWithAudit(c, permission)
+}
+
+func twoFactorSatisfied(session sessions.Session, sessionAuth bool, userId int) bool {
+	if sessionAuth {
+		return session.Get("two_factor") == true
+	}
+	return model.IsTwoFactorEnabled(userId) || model.HasPasskey(userId)
+}
+
+// adminTokenResources 未指定权限的路由分组（用户自身的数据）对应的管理令牌权限资源，未列出的分组不允许使用管理令牌
+var adminTokenResources = map[string]string{
+	"user":  "users",
+	"token": "tokens",
+	"log":   "logs",
+	"mj":    "tasks",
+	"task":  "tasks",
+}
+
+// adminTokenDeniedPaths 账号安全相关的自助接口不允许使用管理令牌，
+// 避免令牌修改密码、关闭两步验证、管理通行密钥和会话，或生成不受权限限制的 access token
+var adminTokenDeniedPaths = []string{
+	"/api/user/self",
+	"/api/user/token",
+	"/api/user/2fa",
+	"/api/user/passkey",
+	"/api/user/sessions",
+}
+
+// adminTokenScope 未指定权限的路由根据路由计算所需权限，GET 为 read，其他方法为 write
+func adminTokenScope(c *gin.Context, permission string) string {
+	if permission != "" {
+		return permission
+	}
+
+	path := c.FullPath()
+	for _, denied := range adminTokenDeniedPaths {
+		if path == denied || strings.HasPrefix(path, denied+"/") {
+			return ""
+		}
+	}
+
+	parts := strings.Split(strings.TrimPrefix(path, "/api/"), "/")
+	resource, ok := adminTokenResources[parts[0]]
+	if !ok {
+		return ""
+	}
+
+	if c.Request.Method == http.MethodGet {
+		return resource + ":read"
+	}
+
+	return resource + ":write"
+}
+
+func adminTokenAuth(c *gin.Context, key string, minRole int, permission string) {
+	token, user, err := model.ValidateAdminToken(key)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权进行此操作，" + err.Error(),
+		})
+		c.Abort()
+		return
+	}
+
+	if !token.AllowIp(c.ClientIP()) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权进行此操作，管理令牌不允许该 IP 使用",
+		})
+		c.Abort()
+		return
+	}
+
+	if user.Status == config.UserStatusDisabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "用户已被封禁",
+		})
+		c.Abort()
+		return
+	}
+
+	// 令牌权限不会超过所属用户的角色
+	scope := adminTokenScope(c, permission)
+	if user.Role < minRole || scope == "" || !token.HasScope(scope) ||
+		(permission != "" && !model.UserHasPermission(user.Id, user.Role, permission)) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权进行此操作，权限不足",
+		})
+		c.Abort()
+		return
+	}
+
+	// 和 access token 一样，要求管理账号使用两步验证时，未绑定两步验证或通行密钥的账号不能使用管理令牌
+	if config.TwoFactorRequiredForAdmin && !twoFactorSatisfied(nil, false, user.Id) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员要求管理账号启用两步验证，请在个人设置中启用后重新登录",
+		})
+		c.Abort()
+		return
+	}
+
+	token.RecordUse(c.ClientIP())
+	model.RecordAdminTokenLog(token, c.ClientIP(), fmt.Sprintf("管理令牌调用 %s %s", c.Request.Method, c.Request.URL.Path))
+
+	c.Set("username", user.Username)
+	c.Set("role", user.Role)
+	c.Set("id", user.Id)
+	c.Set("admin_token_id", token.Id)
+	nextWithAudit(c, permission)
 }
 
 func TrySetUserBySession() func(c *gin.Context) {
@@ -97,24 +247,35 @@ func TrySetUserBySession() func(c *gin.Context) {
 
 func UserAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
-		authHelper(c, config.RoleCommonUser)
+		authHelper(c, config.RoleCommonUser, "")
 	}
 }
 
 func AdminAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
-		authHelper(c, config.RoleAdminUser)
+		authHelper(c, config.RoleAdminUser, "")
 	}
 }
 
 func RootAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
-		authHelper(c, config.RoleRootUser)
+		authHelper(c, config.RoleRootUser, "")
+	}
+}
+
+// PermissionAuth 按管理后台权限校验，只有管理员可用，权限来自用户的角色，见 model.GetUserPermissions
+func PermissionAuth(permission string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		authHelper(c, config.RoleAdminUser, permission)
 	}
 }
 
 func tokenAuth(c *gin.Context, key string) {
 	key = strings.TrimPrefix(key, "Bearer ")
+	if strings.HasPrefix(key, common.EphemeralTokenPrefix) {
+		ephemeralTokenAuth(c, key)
+		return
+	}
 	key = strings.TrimPrefix(key, "sk-")
 
 	if len(key) < 48 {
@@ -135,6 +296,7 @@ func tokenAuth(c *gin.Context, key string) {
 	c.Set("token_name", token.Name)
 	c.Set("token_group", token.Group)
 	c.Set("token_setting", utils.GetPointer(token.Setting.Data()))
+	c.Set("organization_id", token.OrganizationId)
 	if len(parts) > 1 {
 		if model.IsAdmin(token.UserId) {
 			if strings.HasPrefix(parts[1], "!") {
@@ -159,6 +321,24 @@ func tokenAuth(c *gin.Context, key string) {
 	c.Next()
 }
 
+// ephemeralTokenAuth 临时令牌校验签名、用量和父令牌状态，沿用父令牌的分组和设置，消费计入父令牌
+func ephemeralTokenAuth(c *gin.Context, key string) {
+	claims, token, err := model.ValidateEphemeralToken(key)
+	if err != nil {
+		abortWithMessage(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	c.Set("id", token.UserId)
+	c.Set("token_id", token.Id)
+	c.Set("token_name", token.Name)
+	c.Set("token_group", claims.Group)
+	c.Set("token_setting", utils.GetPointer(token.Setting.Data()))
+	c.Set("organization_id", token.OrganizationId)
+	c.Set("ephemeral_token", claims)
+	c.Next()
+}
+
 func OpenaiAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
 		isWebSocket := c.GetHeader("Upgrade") == "websocket"
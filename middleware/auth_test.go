@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"done-hub/common"
+	"done-hub/common/config"
+	"done-hub/common/logger"
+	"done-hub/model"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// setupAuthTestDB 使用独立的内存数据库，测试之间互不影响
+func setupAuthTestDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.User{}, &model.AdminToken{}, &model.Log{}, &model.Role{}, &model.TwoFactor{}, &model.Passkey{}); err != nil {
+		t.Fatal(err)
+	}
+
+	model.DB = db
+	logger.Logger = zap.NewNop()
+	common.UsingSQLite = true
+	config.RedisEnabled = false
+
+	viper.Set("user_token_secret", "test-user-token-secret")
+	if err := common.InitUserToken(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func createTestAuthUser(t *testing.T, username string, role int) *model.User {
+	user := &model.User{
+		Username:    username,
+		Role:        role,
+		Status:      config.UserStatusEnabled,
+		AccessToken: username + "-access",
+		AffCode:     username + "-aff",
+	}
+	if err := model.DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return user
+}
+
+func createTestAdminToken(t *testing.T, user *model.User, scopes ...string) string {
+	token := &model.AdminToken{UserId: user.Id, Name: "test", Status: config.TokenStatusEnabled, Scopes: scopes, ExpiredTime: -1}
+	if err := token.Insert(); err != nil {
+		t.Fatal(err)
+	}
+
+	return token.Key
+}
+
+// newAuthTestRouter 按 router/api-router.go 的分组注册用户接口
+func newAuthTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(sessions.Sessions("session", cookie.NewStore([]byte("test-session-secret"))))
+
+	ok := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	}
+
+	userRoute := router.Group("/api/user")
+	selfRoute := userRoute.Group("/")
+	selfRoute.Use(UserAuth())
+	{
+		selfRoute.GET("/dashboard", ok)
+		selfRoute.GET("/self", ok)
+		selfRoute.PUT("/self", ok)
+		selfRoute.GET("/token", ok)
+		selfRoute.POST("/2fa/disable", ok)
+		selfRoute.DELETE("/passkey/:id", ok)
+		selfRoute.DELETE("/sessions", ok)
+	}
+	userRoute.GET("/", PermissionAuth("users:read"), ok)
+	userRoute.PUT("/", PermissionAuth("users:write"), ok)
+	userRoute.DELETE("/2fa/:id", PermissionAuth("users:write"), ok)
+
+	return router
+}
+
+func requestSuccess(t *testing.T, router *gin.Engine, method, path, key string) bool {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+key)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response struct {
+		Success bool `json:"success"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	return response.Success
+}
+
+func TestAdminTokenDeniedSelfServiceRoutes(t *testing.T) {
+	setupAuthTestDB(t)
+	router := newAuthTestRouter()
+	key := createTestAdminToken(t, createTestAuthUser(t, "root", config.RoleRootUser), "users:read", "users:write")
+
+	// 账号安全相关的自助接口即使令牌拥有 users 权限也不能访问
+	denied := [][2]string{
+		{http.MethodGet, "/api/user/self"},
+		{http.MethodPut, "/api/user/self"},
+		{http.MethodGet, "/api/user/token"},
+		{http.MethodPost, "/api/user/2fa/disable"},
+		{http.MethodDelete, "/api/user/passkey/1"},
+		{http.MethodDelete, "/api/user/sessions"},
+	}
+	for _, route := range denied {
+		assert.False(t, requestSuccess(t, router, route[0], route[1], key), route[1])
+	}
+
+	// 其他自助接口和指定了权限的管理接口不受影响
+	assert.True(t, requestSuccess(t, router, http.MethodGet, "/api/user/dashboard", key))
+	assert.True(t, requestSuccess(t, router, http.MethodGet, "/api/user/", key))
+	assert.True(t, requestSuccess(t, router, http.MethodDelete, "/api/user/2fa/1", key))
+}
+
+func TestAdminTokenScope(t *testing.T) {
+	setupAuthTestDB(t)
+	router := newAuthTestRouter()
+	key := createTestAdminToken(t, createTestAuthUser(t, "root", config.RoleRootUser), "users:read")
+
+	assert.True(t, requestSuccess(t, router, http.MethodGet, "/api/user/", key))
+	assert.True(t, requestSuccess(t, router, http.MethodGet, "/api/user/dashboard", key))
+	assert.False(t, requestSuccess(t, router, http.MethodDelete, "/api/user/2fa/1", key))
+}
+
+func TestPermissionAuthRoles(t *testing.T) {
+	setupAuthTestDB(t)
+	router := newAuthTestRouter()
+
+	role := &model.Role{Name: "support", Permissions: []string{"users:read"}}
+	if err := role.Insert(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 角色只细分管理员的权限，分配给普通用户也不能访问管理接口
+	commonUser := createTestAuthUser(t, "common", config.RoleCommonUser)
+	assert.NoError(t, model.DB.Model(commonUser).Update("role_id", role.Id).Error)
+	assert.False(t, requestSuccess(t, router, http.MethodGet, "/api/user/", commonUser.AccessToken))
+
+	admin := createTestAuthUser(t, "admin", config.RoleAdminUser)
+	assert.True(t, requestSuccess(t, router, http.MethodPut, "/api/user/", admin.AccessToken))
+
+	assert.NoError(t, model.DB.Model(admin).Update("role_id", role.Id).Error)
+	assert.True(t, requestSuccess(t, router, http.MethodGet, "/api/user/", admin.AccessToken))
+	assert.False(t, requestSuccess(t, router, http.MethodPut, "/api/user/", admin.AccessToken))
+}
+
+func TestAdminTokenTwoFactorRequired(t *testing.T) {
+	setupAuthTestDB(t)
+	router := newAuthTestRouter()
+	config.TwoFactorRequiredForAdmin = true
+	t.Cleanup(func() {
+		config.TwoFactorRequiredForAdmin = false
+	})
+
+	admin := createTestAuthUser(t, "root", config.RoleRootUser)
+	key := createTestAdminToken(t, admin, "users:read")
+
+	// 要求两步验证时，未绑定两步验证的账号不能使用之前创建的管理令牌
+	assert.False(t, requestSuccess(t, router, http.MethodGet, "/api/user/", key))
+	assert.False(t, requestSuccess(t, router, http.MethodGet, "/api/user/", admin.AccessToken))
+
+	assert.NoError(t, model.DB.Create(&model.TwoFactor{UserId: admin.Id, Enabled: true}).Error)
+	assert.True(t, requestSuccess(t, router, http.MethodGet, "/api/user/", key))
+	assert.True(t, requestSuccess(t, router, http.MethodGet, "/api/user/", admin.AccessToken))
+}
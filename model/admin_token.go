@@ -0,0 +1,241 @@
+package model
+
+import (
+	"done-hub/common"
+	"done-hub/common/config"
+	"done-hub/common/logger"
+	"done-hub/common/utils"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// AdminTokenPrefix 管理令牌前缀，用于和旧版 AccessToken 区分
+const AdminTokenPrefix = "adm-"
+
+// AdminScopeAll 拥有用户角色允许的全部权限
+const AdminScopeAll = "*"
+
+// AdminTokenScopes 可分配给管理令牌的权限，格式为 资源:read 或 资源:write
+var AdminTokenScopes = []string{
+	"channels:read", "channels:write",
+	"users:read", "users:write",
+	"groups:read", "groups:write",
+	"prices:read", "prices:write",
+	"payments:read", "payments:write",
+	"redemptions:read", "redemptions:write",
+	"tokens:read", "tokens:write",
+	"logs:read", "logs:write",
+	"audit_logs:read",
+	"tasks:read",
+	"analytics:read",
+	"webhooks:read", "webhooks:write",
+	"options:read", "options:write",
+}
+
+var ErrAdminTokenInvalid = errors.New("无效的管理令牌")
+
+// AdminToken 管理接口令牌，每个管理员可创建多个，按权限、有效期和 IP 限制使用
+type AdminToken struct {
+	Id           int                         `json:"id"`
+	UserId       int                         `json:"user_id" gorm:"index"`
+	Name         string                      `json:"name" gorm:"type:varchar(50)"`
+	Key          string                      `json:"key" gorm:"type:varchar(64)"` // 展示前缀，完整令牌只在创建时返回
+	KeyHash      string                      `json:"-" gorm:"type:varchar(64);index"`
+	Status       int                         `json:"status" gorm:"default:1"`
+	Scopes       datatypes.JSONSlice[string] `json:"scopes" gorm:"type:json"`
+	AllowIps     string                      `json:"allow_ips" gorm:"type:text"`            // 每行一个 IP 或 CIDR，为空不限制
+	ExpiredTime  int64                       `json:"expired_time" gorm:"bigint;default:-1"` // -1 means never expired
+	AccessedTime int64                       `json:"accessed_time" gorm:"bigint"`
+	AccessedIp   string                      `json:"accessed_ip" gorm:"type:varchar(64);default:''"`
+	CreatedTime  int64                       `json:"created_time" gorm:"bigint"`
+	DeletedAt    gorm.DeletedAt              `json:"-" gorm:"index"`
+}
+
+var allowedAdminTokenOrderFields = map[string]bool{
+	"id":            true,
+	"name":          true,
+	"status":        true,
+	"expired_time":  true,
+	"accessed_time": true,
+	"created_time":  true,
+}
+
+func (token *AdminToken) AfterCreate(tx *gorm.DB) (err error) {
+	return token.resetKey(tx)
+}
+
+// resetKey 生成新的管理令牌，库中只保存前缀和哈希，完整令牌仅在本次写回 token.Key
+func (token *AdminToken) resetKey(tx *gorm.DB) error {
+	tokenKey, prefix, err := common.GenerateSecretToken(token.Id, token.UserId)
+	if err != nil {
+		return err
+	}
+
+	tokenKey = AdminTokenPrefix + tokenKey
+	keyHash := common.HashToken(tokenKey)
+	err = tx.Model(token).Updates(map[string]any{"key": AdminTokenPrefix + prefix, "key_hash": keyHash}).Error
+	if err != nil {
+		return err
+	}
+
+	token.Key = tokenKey
+	token.KeyHash = keyHash
+
+	return nil
+}
+
+// HasScope 是否拥有指定权限，不在 AdminTokenScopes 中的权限（如角色管理）不能通过管理令牌使用
+func (token *AdminToken) HasScope(scope string) bool {
+	if !slices.Contains(AdminTokenScopes, scope) {
+		return false
+	}
+
+	return slices.Contains(token.Scopes, AdminScopeAll) || slices.Contains(token.Scopes, scope)
+}
+
+// AllowIp 是否允许该 IP 使用
+func (token *AdminToken) AllowIp(ip string) bool {
+	if strings.TrimSpace(token.AllowIps) == "" {
+		return true
+	}
+
+	clientIp := net.ParseIP(ip)
+	for _, allow := range splitAllowIps(token.AllowIps) {
+		if allow == ip {
+			return true
+		}
+
+		if _, subnet, err := net.ParseCIDR(allow); err == nil && clientIp != nil && subnet.Contains(clientIp) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func splitAllowIps(allowIps string) []string {
+	return strings.FieldsFunc(allowIps, func(r rune) bool {
+		return r == '\n' || r == ',' || r == ' ' || r == '\r'
+	})
+}
+
+// ValidateAdminTokenSetting 校验权限和 IP 限制
+func ValidateAdminTokenSetting(token *AdminToken) error {
+	if len(token.Scopes) == 0 {
+		return errors.New("至少需要一个权限")
+	}
+
+	for _, scope := range token.Scopes {
+		if scope != AdminScopeAll && !slices.Contains(AdminTokenScopes, scope) {
+			return fmt.Errorf("无效的权限: %s", scope)
+		}
+	}
+
+	for _, allow := range splitAllowIps(token.AllowIps) {
+		if net.ParseIP(allow) == nil {
+			if _, _, err := net.ParseCIDR(allow); err != nil {
+				return fmt.Errorf("无效的 IP: %s", allow)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidateAdminToken 校验管理令牌，返回令牌和所属用户
+func ValidateAdminToken(key string) (*AdminToken, *User, error) {
+	tokenId, userId, err := common.ParseSecretToken(strings.TrimPrefix(key, AdminTokenPrefix))
+	if err != nil || tokenId == 0 || userId == 0 {
+		return nil, nil, ErrAdminTokenInvalid
+	}
+
+	var token AdminToken
+	err = DB.Where("key_hash = ?", common.HashToken(key)).First(&token).Error
+	if err != nil || token.Id != tokenId || token.UserId != userId {
+		return nil, nil, ErrAdminTokenInvalid
+	}
+
+	if token.Status != config.TokenStatusEnabled {
+		return nil, nil, ErrTokenStatusUnavailable
+	}
+
+	if token.ExpiredTime != -1 && token.ExpiredTime < utils.GetTimestamp() {
+		return nil, nil, ErrTokenExpired
+	}
+
+	user, err := GetUserById(userId, false)
+	if err != nil {
+		return nil, nil, ErrAdminTokenInvalid
+	}
+
+	return &token, user, nil
+}
+
+// RecordUse 记录最近一次使用的时间和 IP
+func (token *AdminToken) RecordUse(ip string) {
+	DB.Model(token).UpdateColumns(map[string]any{
+		"accessed_time": utils.GetTimestamp(),
+		"accessed_ip":   ip,
+	})
+}
+
+func GetAdminTokensList(userId int, params *GenericParams) (*DataResult[AdminToken], error) {
+	var tokens []*AdminToken
+	db := DB.Where("user_id = ?", userId)
+
+	if params.Keyword != "" {
+		db = db.Where("name LIKE ?", params.Keyword+"%")
+	}
+
+	return PaginateAndOrder(db, &params.PaginationParams, &tokens, allowedAdminTokenOrderFields)
+}
+
+func GetAdminTokenByIds(id int, userId int) (*AdminToken, error) {
+	if id == 0 || userId == 0 {
+		return nil, errors.New("id 或 userId 为空！")
+	}
+
+	var token AdminToken
+	err := DB.First(&token, "id = ? and user_id = ?", id, userId).Error
+	return &token, err
+}
+
+func (token *AdminToken) Insert() error {
+	return DB.Create(token).Error
+}
+
+func (token *AdminToken) Update() error {
+	return DB.Model(token).Select("name", "status", "scopes", "allow_ips", "expired_time").Updates(token).Error
+}
+
+func DeleteAdminTokenById(id int, userId int) error {
+	token, err := GetAdminTokenByIds(id, userId)
+	if err != nil {
+		return err
+	}
+
+	return DB.Delete(token).Error
+}
+
+// RecordAdminTokenLog 记录管理令牌的每次调用
+func RecordAdminTokenLog(token *AdminToken, ip, content string) {
+	username, _ := CacheGetUsername(token.UserId)
+	log := &Log{
+		UserId:    token.UserId,
+		Username:  username,
+		CreatedAt: utils.GetTimestamp(),
+		Type:      LogTypeManage,
+		TokenName: token.Name,
+		SourceIp:  ip,
+		Content:   content,
+	}
+	if err := DB.Create(log).Error; err != nil {
+		logger.SysError("failed to record admin token log: " + err.Error())
+	}
+}
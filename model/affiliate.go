@@ -0,0 +1,582 @@
+package model
+
+import (
+	"done-hub/common"
+	"done-hub/common/config"
+	"done-hub/common/logger"
+	"done-hub/common/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AffiliateCommissionStatusPending  = "pending"  // 结算等待期内
+	AffiliateCommissionStatusHeld     = "held"     // 疑似自我邀请，等待审核
+	AffiliateCommissionStatusSettled  = "settled"  // 已计入佣金余额
+	AffiliateCommissionStatusRejected = "rejected" // 已撤销
+)
+
+const (
+	AffiliateWithdrawalStatusPending  = "pending"
+	AffiliateWithdrawalStatusApproved = "approved"
+	AffiliateWithdrawalStatusRejected = "rejected"
+)
+
+const (
+	AffiliateWithdrawMethodQuota    = "quota"    // 转入账户余额
+	AffiliateWithdrawMethodExternal = "external" // 线下打款，审核时登记打款记录
+)
+
+const (
+	AffiliateSourceTopup      = "topup"
+	AffiliateSourceRedemption = "redemption"
+)
+
+// affiliateMaxTier 最多支持的佣金层级
+const affiliateMaxTier = 2
+
+// AffiliateAccount 佣金账户，与旧的 aff_quota 分开记录，避免开启分销后旧的返利被重复提现
+type AffiliateAccount struct {
+	UserId         int   `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+	Balance        int   `json:"balance" gorm:"default:0"`         // 可提现佣金
+	TotalEarned    int   `json:"total_earned" gorm:"default:0"`    // 累计结算佣金
+	TotalWithdrawn int   `json:"total_withdrawn" gorm:"default:0"` // 累计提现佣金
+	UpdatedTime    int64 `json:"updated_time" gorm:"bigint"`
+}
+
+// AffiliateCommission 佣金流水，同一来源同一层级只产生一条
+type AffiliateCommission struct {
+	Id          int     `json:"id"`
+	AffiliateId int     `json:"affiliate_id" gorm:"index"`
+	InviteeId   int     `json:"invitee_id" gorm:"index"`
+	Tier        int     `json:"tier" gorm:"uniqueIndex:idx_affiliate_commission_source"`
+	Source      string  `json:"source" gorm:"type:varchar(32);uniqueIndex:idx_affiliate_commission_source"`
+	SourceId    string  `json:"source_id" gorm:"type:varchar(64);uniqueIndex:idx_affiliate_commission_source"`
+	BaseQuota   int     `json:"base_quota" gorm:"default:0"`
+	Rate        float64 `json:"rate" gorm:"default:0"`
+	Quota       int     `json:"quota" gorm:"default:0"`
+	Status      string  `json:"status" gorm:"type:varchar(16);index"`
+	Remark      string  `json:"remark" gorm:"type:varchar(255);default:''"`
+	SettleAt    int64   `json:"settle_at" gorm:"bigint;index"`
+	ReviewerId  int     `json:"reviewer_id" gorm:"default:0"`
+	CreatedTime int64   `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64   `json:"updated_time" gorm:"bigint"`
+}
+
+// AffiliateWithdrawal 佣金提现申请，申请时即从佣金余额中扣除，拒绝后退回
+type AffiliateWithdrawal struct {
+	Id          int    `json:"id"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	Quota       int    `json:"quota" gorm:"default:0"`
+	Method      string `json:"method" gorm:"type:varchar(16)"`
+	Account     string `json:"account" gorm:"type:varchar(255);default:''"` // 线下打款的收款账户
+	Remark      string `json:"remark" gorm:"type:varchar(255);default:''"`
+	Status      string `json:"status" gorm:"type:varchar(16);index"`
+	PayoutNo    string `json:"payout_no" gorm:"type:varchar(100);default:''"` // 线下打款的流水号
+	AdminRemark string `json:"admin_remark" gorm:"type:varchar(255);default:''"`
+	ReviewerId  int    `json:"reviewer_id" gorm:"default:0"`
+	ReviewedAt  int64  `json:"reviewed_at" gorm:"bigint;default:0"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+type AffiliateCommissionsListParams struct {
+	PaginationParams
+	AffiliateId int    `form:"affiliate_id"`
+	InviteeId   int    `form:"invitee_id"`
+	Tier        int    `form:"tier"`
+	Status      string `form:"status"`
+}
+
+type AffiliateWithdrawalsListParams struct {
+	PaginationParams
+	UserId int    `form:"user_id"`
+	Method string `form:"method"`
+	Status string `form:"status"`
+}
+
+var allowedAffiliateCommissionOrderFields = map[string]bool{
+	"id":           true,
+	"quota":        true,
+	"settle_at":    true,
+	"created_time": true,
+}
+
+var allowedAffiliateWithdrawalOrderFields = map[string]bool{
+	"id":           true,
+	"quota":        true,
+	"created_time": true,
+}
+
+func AffiliateGroupRates2JSONString() string {
+	jsonBytes, err := json.Marshal(config.AffiliateGroupRates)
+	if err != nil {
+		logger.SysError("error marshalling affiliate group rates: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateAffiliateGroupRatesByJSONString(jsonStr string) error {
+	rates, err := ParseAffiliateGroupRates(jsonStr)
+	if err != nil {
+		return err
+	}
+	config.AffiliateGroupRates = rates
+	return nil
+}
+
+// ParseAffiliateGroupRates 解析分组佣金比例，每个分组最多两个层级，比例在 0 到 100 之间
+func ParseAffiliateGroupRates(jsonStr string) (map[string][]float64, error) {
+	rates := make(map[string][]float64)
+	if jsonStr == "" {
+		return rates, nil
+	}
+	if err := json.Unmarshal([]byte(jsonStr), &rates); err != nil {
+		return nil, err
+	}
+
+	for group, groupRates := range rates {
+		if len(groupRates) == 0 || len(groupRates) > affiliateMaxTier {
+			return nil, fmt.Errorf("分组 %s 的佣金比例需要 1 到 %d 个层级", group, affiliateMaxTier)
+		}
+		for _, rate := range groupRates {
+			if rate < 0 || rate > 100 {
+				return nil, fmt.Errorf("分组 %s 的佣金比例应在0-100之间", group)
+			}
+		}
+	}
+	return rates, nil
+}
+
+// AffiliateRate 邀请人在指定层级的佣金比例，分组未配置该层级时使用默认比例
+func AffiliateRate(group string, tier int) float64 {
+	if rates, ok := config.AffiliateGroupRates[group]; ok && len(rates) >= tier {
+		return rates[tier-1]
+	}
+	if tier == 1 {
+		return config.AffiliateTier1Rate
+	}
+	return config.AffiliateTier2Rate
+}
+
+// CreateAffiliateCommissions 被邀请用户充值后为各层级的邀请人记录佣金，佣金在结算等待期后计入佣金余额
+// 只有实际支付的充值订单产生佣金，兑换码等非付费来源不计佣金
+func CreateAffiliateCommissions(invitee *User, baseQuota int, source string, sourceId string) error {
+	if invitee.InviterId == 0 || baseQuota <= 0 || source != AffiliateSourceTopup {
+		return nil
+	}
+
+	now := utils.GetTimestamp()
+	if config.AffiliateCommissionMonths > 0 && invitee.CreatedTime+int64(config.AffiliateCommissionMonths)*30*24*3600 < now {
+		return nil
+	}
+
+	affiliateId := invitee.InviterId
+	for tier := 1; tier <= affiliateMaxTier && affiliateId != 0 && affiliateId != invitee.Id; tier++ {
+		var affiliate User
+		err := DB.Where("id = ?", affiliateId).First(&affiliate).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		affiliateId = affiliate.InviterId
+
+		if affiliate.Status != config.UserStatusEnabled {
+			continue
+		}
+
+		rate := AffiliateRate(affiliate.Group, tier)
+		quota := int(float64(baseQuota) * rate / 100)
+		if quota <= 0 {
+			continue
+		}
+
+		commission := &AffiliateCommission{
+			AffiliateId: affiliate.Id,
+			InviteeId:   invitee.Id,
+			Tier:        tier,
+			Source:      source,
+			SourceId:    sourceId,
+			BaseQuota:   baseQuota,
+			Rate:        rate,
+			Quota:       quota,
+			Status:      AffiliateCommissionStatusPending,
+			SettleAt:    now + int64(config.AffiliateSettleDays)*24*3600,
+			CreatedTime: now,
+			UpdatedTime: now,
+		}
+		if config.AffiliateFraudCheck {
+			if reason := detectSelfReferral(affiliate.Id, invitee.Id); reason != "" {
+				commission.Status = AffiliateCommissionStatusHeld
+				commission.Remark = reason
+			}
+		}
+
+		// 同一来源重复处理时唯一索引冲突，跳过即可
+		if err := DB.Create(commission).Error; err != nil {
+			var count int64
+			DB.Model(&AffiliateCommission{}).Where("source = ? AND source_id = ? AND tier = ?", source, sourceId, tier).Count(&count)
+			if count > 0 {
+				continue
+			}
+			return err
+		}
+
+		RecordLog(affiliate.Id, LogTypeSystem, fmt.Sprintf("邀请用户充值产生%d级佣金 %s（充值额度: %s, 佣金比例: %.2f%%）",
+			tier, common.LogQuota(quota), common.LogQuota(baseQuota), rate))
+	}
+
+	return nil
+}
+
+// detectSelfReferral 邀请人与被邀请人使用过相同的 IP 和设备登录时返回原因
+// 共享网络下 IP 相同、同款浏览器下 User-Agent 相同都很常见，只有两者同时相同才认为是同一设备
+func detectSelfReferral(affiliateId int, inviteeId int) string {
+	var count int64
+	DB.Table("user_sessions AS invitee").
+		Joins("JOIN user_sessions AS affiliate ON affiliate.ip = invitee.ip AND affiliate.user_agent = invitee.user_agent").
+		Where("invitee.user_id = ? AND affiliate.user_id = ? AND invitee.ip != '' AND invitee.user_agent != ''", inviteeId, affiliateId).
+		Count(&count)
+	if count > 0 {
+		return "邀请人与被邀请人使用过相同的 IP 和设备登录"
+	}
+
+	return ""
+}
+
+// SettleAffiliateCommissions 结算等待期已过的佣金，计入佣金余额
+func SettleAffiliateCommissions() (int, error) {
+	now := utils.GetTimestamp()
+	settled := 0
+
+	for {
+		var commissions []*AffiliateCommission
+		err := DB.Where("status = ? AND settle_at <= ?", AffiliateCommissionStatusPending, now).
+			Order("id").Limit(100).Find(&commissions).Error
+		if err != nil {
+			return settled, err
+		}
+		if len(commissions) == 0 {
+			return settled, nil
+		}
+
+		for _, commission := range commissions {
+			if err := settleAffiliateCommission(commission, AffiliateCommissionStatusPending, 0); err != nil {
+				return settled, err
+			}
+			settled++
+		}
+	}
+}
+
+func settleAffiliateCommission(commission *AffiliateCommission, fromStatus string, reviewerId int) error {
+	now := utils.GetTimestamp()
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&AffiliateCommission{}).Where("id = ? AND status = ?", commission.Id, fromStatus).Updates(map[string]any{
+			"status":       AffiliateCommissionStatusSettled,
+			"reviewer_id":  reviewerId,
+			"updated_time": now,
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		account := AffiliateAccount{UserId: commission.AffiliateId}
+		if err := tx.FirstOrCreate(&account, AffiliateAccount{UserId: commission.AffiliateId}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&AffiliateAccount{}).Where("user_id = ?", commission.AffiliateId).Updates(map[string]any{
+			"balance":      gorm.Expr("balance + ?", commission.Quota),
+			"total_earned": gorm.Expr("total_earned + ?", commission.Quota),
+			"updated_time": now,
+		}).Error
+	})
+}
+
+// RevokeAffiliateCommissions 充值退款后撤销尚未结算的佣金，已结算的佣金不再追回
+func RevokeAffiliateCommissions(source string, sourceId string, reason string) (int64, error) {
+	result := DB.Model(&AffiliateCommission{}).
+		Where("source = ? AND source_id = ? AND status IN ?", source, sourceId, []string{AffiliateCommissionStatusPending, AffiliateCommissionStatusHeld}).
+		Updates(map[string]any{
+			"status":       AffiliateCommissionStatusRejected,
+			"remark":       truncateString(reason, 255),
+			"updated_time": utils.GetTimestamp(),
+		})
+	return result.RowsAffected, result.Error
+}
+
+// ReduceAffiliateCommissions 充值订单部分退款后按退款比例扣减尚未结算的佣金，扣减到 0 时撤销
+func ReduceAffiliateCommissions(source string, sourceId string, ratio float64, reason string) (int64, error) {
+	if ratio <= 0 {
+		return 0, nil
+	}
+
+	var commissions []*AffiliateCommission
+	err := DB.Where("source = ? AND source_id = ? AND status IN ?", source, sourceId, []string{AffiliateCommissionStatusPending, AffiliateCommissionStatusHeld}).
+		Find(&commissions).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var reduced int64
+	now := utils.GetTimestamp()
+	for _, commission := range commissions {
+		// 按原始佣金计算扣减额，多次部分退款累计扣减
+		reduce := int(math.Round(float64(commission.BaseQuota) * commission.Rate / 100 * ratio))
+		if reduce <= 0 {
+			continue
+		}
+
+		updates := map[string]any{
+			"quota":        gorm.Expr("quota - ?", min(reduce, commission.Quota)),
+			"remark":       truncateString(reason, 255),
+			"updated_time": now,
+		}
+		if reduce >= commission.Quota {
+			updates["status"] = AffiliateCommissionStatusRejected
+		}
+
+		result := DB.Model(&AffiliateCommission{}).Where("id = ? AND status = ? AND quota = ?", commission.Id, commission.Status, commission.Quota).Updates(updates)
+		if result.Error != nil {
+			return reduced, result.Error
+		}
+		reduced += result.RowsAffected
+	}
+
+	return reduced, nil
+}
+
+// ReviewAffiliateCommission 审核被冻结的佣金，通过后立即计入佣金余额
+func ReviewAffiliateCommission(id int, approve bool, remark string, reviewerId int) error {
+	var commission AffiliateCommission
+	if err := DB.First(&commission, id).Error; err != nil {
+		return errors.New("佣金记录不存在")
+	}
+	if commission.Status != AffiliateCommissionStatusHeld {
+		return errors.New("只能审核被冻结的佣金")
+	}
+
+	if approve {
+		return settleAffiliateCommission(&commission, AffiliateCommissionStatusHeld, reviewerId)
+	}
+
+	result := DB.Model(&AffiliateCommission{}).Where("id = ? AND status = ?", id, AffiliateCommissionStatusHeld).Updates(map[string]any{
+		"status":       AffiliateCommissionStatusRejected,
+		"remark":       truncateString(remark, 255),
+		"reviewer_id":  reviewerId,
+		"updated_time": utils.GetTimestamp(),
+	})
+	return result.Error
+}
+
+func GetAffiliateCommissionsList(params *AffiliateCommissionsListParams) (*DataResult[AffiliateCommission], error) {
+	var commissions []*AffiliateCommission
+
+	tx := DB.Model(&AffiliateCommission{})
+	if params.AffiliateId != 0 {
+		tx = tx.Where("affiliate_id = ?", params.AffiliateId)
+	}
+	if params.InviteeId != 0 {
+		tx = tx.Where("invitee_id = ?", params.InviteeId)
+	}
+	if params.Tier != 0 {
+		tx = tx.Where("tier = ?", params.Tier)
+	}
+	if params.Status != "" {
+		tx = tx.Where("status = ?", params.Status)
+	}
+
+	return PaginateAndOrder[AffiliateCommission](tx, &params.PaginationParams, &commissions, allowedAffiliateCommissionOrderFields)
+}
+
+type AffiliateSummary struct {
+	AffiliateAccount
+	PendingQuota int   `json:"pending_quota"`
+	HeldQuota    int   `json:"held_quota"`
+	Tier1Count   int64 `json:"tier1_count"`
+	Tier2Count   int64 `json:"tier2_count"`
+}
+
+func GetAffiliateSummary(userId int) (*AffiliateSummary, error) {
+	summary := &AffiliateSummary{}
+	summary.UserId = userId
+	if err := DB.Where("user_id = ?", userId).Limit(1).Find(&summary.AffiliateAccount).Error; err != nil {
+		return nil, err
+	}
+
+	var sums []struct {
+		Status string
+		Quota  int
+	}
+	err := DB.Model(&AffiliateCommission{}).Select("status, sum(quota) as quota").
+		Where("affiliate_id = ? AND status IN ?", userId, []string{AffiliateCommissionStatusPending, AffiliateCommissionStatusHeld}).
+		Group("status").Scan(&sums).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, sum := range sums {
+		if sum.Status == AffiliateCommissionStatusPending {
+			summary.PendingQuota = sum.Quota
+		} else {
+			summary.HeldQuota = sum.Quota
+		}
+	}
+
+	if err := DB.Model(&User{}).Where("inviter_id = ?", userId).Count(&summary.Tier1Count).Error; err != nil {
+		return nil, err
+	}
+	err = DB.Model(&User{}).Where("inviter_id IN (?)", DB.Model(&User{}).Select("id").Where("inviter_id = ?", userId)).
+		Count(&summary.Tier2Count).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// CreateAffiliateWithdrawal 申请提现，佣金余额不足时失败
+func CreateAffiliateWithdrawal(withdrawal *AffiliateWithdrawal) error {
+	if withdrawal.Quota <= 0 {
+		return errors.New("提现额度必须大于 0")
+	}
+	if withdrawal.Quota < config.AffiliateMinWithdrawQuota {
+		return fmt.Errorf("单次提现不能低于 %s", common.LogQuota(config.AffiliateMinWithdrawQuota))
+	}
+	switch withdrawal.Method {
+	case AffiliateWithdrawMethodQuota:
+		withdrawal.Account = ""
+	case AffiliateWithdrawMethodExternal:
+		if withdrawal.Account == "" {
+			return errors.New("请填写收款账户")
+		}
+	default:
+		return errors.New("不支持的提现方式")
+	}
+
+	withdrawal.Account = truncateString(withdrawal.Account, 255)
+	withdrawal.Remark = truncateString(withdrawal.Remark, 255)
+	withdrawal.Status = AffiliateWithdrawalStatusPending
+	withdrawal.CreatedTime = utils.GetTimestamp()
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&AffiliateAccount{}).Where("user_id = ? AND balance >= ?", withdrawal.UserId, withdrawal.Quota).Updates(map[string]any{
+			"balance":      gorm.Expr("balance - ?", withdrawal.Quota),
+			"updated_time": withdrawal.CreatedTime,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("佣金余额不足")
+		}
+
+		return tx.Create(withdrawal).Error
+	})
+}
+
+// ApproveAffiliateWithdrawal 通过提现申请，转入余额的申请直接增加用户额度，线下打款的申请登记打款流水号
+func ApproveAffiliateWithdrawal(id int, payoutNo string, remark string, reviewerId int) error {
+	var withdrawal AffiliateWithdrawal
+	if err := DB.First(&withdrawal, id).Error; err != nil {
+		return errors.New("提现申请不存在")
+	}
+	if withdrawal.Status != AffiliateWithdrawalStatusPending {
+		return errors.New("提现申请已处理")
+	}
+
+	now := utils.GetTimestamp()
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&AffiliateWithdrawal{}).Where("id = ? AND status = ?", id, AffiliateWithdrawalStatusPending).Updates(map[string]any{
+			"status":       AffiliateWithdrawalStatusApproved,
+			"payout_no":    truncateString(payoutNo, 100),
+			"admin_remark": truncateString(remark, 255),
+			"reviewer_id":  reviewerId,
+			"reviewed_at":  now,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("提现申请已处理")
+		}
+
+		err := tx.Model(&AffiliateAccount{}).Where("user_id = ?", withdrawal.UserId).Updates(map[string]any{
+			"total_withdrawn": gorm.Expr("total_withdrawn + ?", withdrawal.Quota),
+			"updated_time":    now,
+		}).Error
+		if err != nil || withdrawal.Method != AffiliateWithdrawMethodQuota {
+			return err
+		}
+
+		return grantQuotaLot(tx, withdrawal.UserId, withdrawal.Quota, QuotaLotSourceAffiliate, fmt.Sprintf("%d", withdrawal.Id), 0, "佣金提现")
+	})
+	if err != nil {
+		return err
+	}
+
+	if withdrawal.Method == AffiliateWithdrawMethodQuota {
+		clearUserQuotaCache(withdrawal.UserId)
+		RecordLog(withdrawal.UserId, LogTypeTopup, fmt.Sprintf("佣金提现到余额 %s", common.LogQuota(withdrawal.Quota)))
+	} else {
+		RecordLog(withdrawal.UserId, LogTypeSystem, fmt.Sprintf("佣金提现 %s 已打款", common.LogQuota(withdrawal.Quota)))
+	}
+	return nil
+}
+
+// RejectAffiliateWithdrawal 拒绝提现申请，退回佣金余额
+func RejectAffiliateWithdrawal(id int, remark string, reviewerId int) error {
+	var withdrawal AffiliateWithdrawal
+	if err := DB.First(&withdrawal, id).Error; err != nil {
+		return errors.New("提现申请不存在")
+	}
+
+	now := utils.GetTimestamp()
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&AffiliateWithdrawal{}).Where("id = ? AND status = ?", id, AffiliateWithdrawalStatusPending).Updates(map[string]any{
+			"status":       AffiliateWithdrawalStatusRejected,
+			"admin_remark": truncateString(remark, 255),
+			"reviewer_id":  reviewerId,
+			"reviewed_at":  now,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("提现申请已处理")
+		}
+
+		return tx.Model(&AffiliateAccount{}).Where("user_id = ?", withdrawal.UserId).Updates(map[string]any{
+			"balance":      gorm.Expr("balance + ?", withdrawal.Quota),
+			"updated_time": now,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	RecordLog(withdrawal.UserId, LogTypeSystem, fmt.Sprintf("佣金提现 %s 被拒绝，已退回佣金余额", common.LogQuota(withdrawal.Quota)))
+	return nil
+}
+
+func GetAffiliateWithdrawalsList(params *AffiliateWithdrawalsListParams) (*DataResult[AffiliateWithdrawal], error) {
+	var withdrawals []*AffiliateWithdrawal
+
+	tx := DB.Model(&AffiliateWithdrawal{})
+	if params.UserId != 0 {
+		tx = tx.Where("user_id = ?", params.UserId)
+	}
+	if params.Method != "" {
+		tx = tx.Where("method = ?", params.Method)
+	}
+	if params.Status != "" {
+		tx = tx.Where("status = ?", params.Status)
+	}
+
+	return PaginateAndOrder[AffiliateWithdrawal](tx, &params.PaginationParams, &withdrawals, allowedAffiliateWithdrawalOrderFields)
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"testing"
+
+	"done-hub/common/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAffiliateCommissionRefund(t *testing.T) {
+	setupTestDB(t, &User{}, &AffiliateCommission{}, &Log{})
+	config.AffiliateFraudCheck = false
+	config.AffiliateCommissionMonths = 0
+	config.AffiliateTier1Rate = 10
+
+	createTestUser(t, 1)
+	invitee := createTestUser(t, 2)
+	assert.NoError(t, DB.Model(invitee).Update("inviter_id", 1).Error)
+
+	// 兑换码不产生佣金
+	assert.NoError(t, CreateAffiliateCommissions(invitee, 10000, AffiliateSourceRedemption, "1"))
+	var count int64
+	DB.Model(&AffiliateCommission{}).Count(&count)
+	assert.Zero(t, count)
+
+	assert.NoError(t, CreateAffiliateCommissions(invitee, 10000, AffiliateSourceTopup, "T1"))
+	commission := &AffiliateCommission{}
+	assert.NoError(t, DB.Where("source_id = ?", "T1").First(commission).Error)
+	assert.Equal(t, 1000, commission.Quota)
+
+	// 退款 30% 后扣减 30% 的佣金，再退款 70% 后撤销
+	reduced, err := ReduceAffiliateCommissions(AffiliateSourceTopup, "T1", 0.3, "partial")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, reduced)
+	assert.NoError(t, DB.First(commission, commission.Id).Error)
+	assert.Equal(t, 700, commission.Quota)
+	assert.Equal(t, AffiliateCommissionStatusPending, commission.Status)
+
+	_, err = ReduceAffiliateCommissions(AffiliateSourceTopup, "T1", 0.7, "partial")
+	assert.NoError(t, err)
+	assert.NoError(t, DB.First(commission, commission.Id).Error)
+	assert.Equal(t, 0, commission.Quota)
+	assert.Equal(t, AffiliateCommissionStatusRejected, commission.Status)
+}
+
+func TestDetectSelfReferral(t *testing.T) {
+	setupTestDB(t, &UserSession{})
+
+	const ua = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0"
+	sessions := []*UserSession{
+		{UserId: 1, TokenHash: "a1", Ip: "1.1.1.1", UserAgent: ua},
+		{UserId: 2, TokenHash: "b1", Ip: "1.1.1.1", UserAgent: "Mozilla/5.0 (Macintosh) Safari/605.1"},
+		{UserId: 2, TokenHash: "b2", Ip: "2.2.2.2", UserAgent: ua},
+	}
+	assert.NoError(t, DB.Create(sessions).Error)
+
+	// 只有 IP 相同或只有 User-Agent 相同不算同一设备
+	assert.Empty(t, detectSelfReferral(1, 2))
+
+	assert.NoError(t, DB.Create(&UserSession{UserId: 2, TokenHash: "b3", Ip: "1.1.1.1", UserAgent: ua}).Error)
+	assert.NotEmpty(t, detectSelfReferral(1, 2))
+	assert.Empty(t, detectSelfReferral(1, 3))
+}
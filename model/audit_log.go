@@ -0,0 +1,350 @@
+package model
+
+import (
+	"crypto/sha256"
+	"done-hub/common/logger"
+	"done-hub/common/utils"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AuditLog 管理操作审计记录，每条记录包含上一条的哈希，修改或删除任意记录都会使之后的校验失败
+type AuditLog struct {
+	Id           int    `json:"id"`
+	CreatedAt    int64  `json:"created_at" gorm:"bigint;index"`
+	ActorId      int    `json:"actor_id" gorm:"index"`
+	ActorName    string `json:"actor_name" gorm:"type:varchar(50);default:''"`
+	ActorIp      string `json:"actor_ip" gorm:"type:varchar(64);default:''"`
+	AdminTokenId int    `json:"admin_token_id" gorm:"default:0"`
+	Action       string `json:"action" gorm:"type:varchar(255)"`
+	EntityType   string `json:"entity_type" gorm:"type:varchar(50);index:idx_audit_entity,priority:1"`
+	EntityId     string `json:"entity_id" gorm:"type:varchar(191);index:idx_audit_entity,priority:2"`
+	Before       string `json:"before" gorm:"type:text"`
+	After        string `json:"after" gorm:"type:text"`
+	Diff         string `json:"diff" gorm:"type:text"`
+	PrevHash     string `json:"prev_hash" gorm:"type:varchar(64)"`
+	Hash         string `json:"hash" gorm:"type:varchar(64)"`
+}
+
+// AuditChainHead 哈希链的链头，只有一行，写入审计记录时锁定该行，保证多个实例写入时哈希链连续
+type AuditChainHead struct {
+	Id   int    `json:"id"`
+	Hash string `json:"hash" gorm:"type:varchar(64);default:''"`
+}
+
+const auditChainHeadId = 1
+
+type AuditLogsListParams struct {
+	PaginationParams
+	EntityType     string `form:"entity_type"`
+	EntityId       string `form:"entity_id"`
+	ActorId        int    `form:"actor_id"`
+	StartTimestamp int64  `form:"start_timestamp"`
+	EndTimestamp   int64  `form:"end_timestamp"`
+}
+
+var allowedAuditLogOrderFields = map[string]bool{
+	"id":         true,
+	"created_at": true,
+}
+
+const auditRedacted = "[REDACTED]"
+
+// auditSecretFields 审计记录中脱敏的字段，只记录是否发生了变化
+var auditSecretFields = map[string]bool{
+	"key":          true,
+	"key_hash":     true,
+	"other":        true,
+	"password":     true,
+	"access_token": true,
+	"config":       true,
+	"secret":       true,
+}
+
+// auditLoaders 按实体类型加载当前状态，用于记录修改前后的值
+var auditLoaders = map[string]func(id string) (any, error){
+	"channels": func(id string) (any, error) {
+		return GetChannelById(utils.String2Int(id))
+	},
+	"users": func(id string) (any, error) {
+		return GetUserById(utils.String2Int(id), false)
+	},
+	"groups": func(id string) (any, error) {
+		return GetUserGroupsById(utils.String2Int(id))
+	},
+	"prices": func(id string) (any, error) {
+		var price Price
+		err := DB.Where("model = ?", id).First(&price).Error
+		return &price, err
+	},
+	"payments": func(id string) (any, error) {
+		return GetPaymentByID(utils.String2Int(id))
+	},
+	"redemptions": func(id string) (any, error) {
+		return GetRedemptionById(utils.String2Int(id))
+	},
+	"roles": func(id string) (any, error) {
+		return GetRoleById(utils.String2Int(id))
+	},
+	"admin_tokens": func(id string) (any, error) {
+		var token AdminToken
+		err := DB.First(&token, "id = ?", utils.String2Int(id)).Error
+		return &token, err
+	},
+	"options": func(id string) (any, error) {
+		option, err := GetOption(id)
+		return &option, err
+	},
+}
+
+// LoadAuditEntity 加载实体当前状态，不支持的类型或不存在时返回 nil
+func LoadAuditEntity(entityType, entityId string) any {
+	loader, ok := auditLoaders[entityType]
+	if !ok || entityId == "" {
+		return nil
+	}
+
+	entity, err := loader(entityId)
+	if err != nil || entity == nil || reflect.ValueOf(entity).IsNil() {
+		return nil
+	}
+
+	return entity
+}
+
+// RecordAuditLog 记录一次管理操作，before/after 为实体或请求内容，敏感字段会被脱敏
+func RecordAuditLog(auditLog *AuditLog, before, after any) {
+	beforeMap := redactAuditValue(auditLog.EntityType, toAuditMap(before))
+	afterMap := redactAuditValue(auditLog.EntityType, toAuditMap(after))
+
+	auditLog.Before = marshalAuditValue(beforeMap)
+	auditLog.After = marshalAuditValue(afterMap)
+	auditLog.Diff = marshalAuditValue(diffAuditMap(beforeMap, afterMap))
+	auditLog.CreatedAt = utils.GetTimestamp()
+	auditLog.ActorName, _ = CacheGetUsername(auditLog.ActorId)
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		head, err := lockAuditChainHead(tx)
+		if err != nil {
+			return err
+		}
+
+		auditLog.PrevHash = head.Hash
+		auditLog.Hash = auditLog.computeHash()
+		if err := tx.Create(auditLog).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(head).Update("hash", auditLog.Hash).Error
+	})
+	if err != nil {
+		logger.SysError("failed to record audit log: " + err.Error())
+	}
+}
+
+// lockAuditChainHead 锁定并返回链头，其他实例的写入会等待当前事务提交
+func lockAuditChainHead(tx *gorm.DB) (*AuditChainHead, error) {
+	var head AuditChainHead
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", auditChainHeadId).First(&head).Error
+	return &head, err
+}
+
+// initAuditChainHead 创建链头，已有审计记录时以最后一条记录的哈希初始化
+func initAuditChainHead(db *gorm.DB) error {
+	var last AuditLog
+	if err := db.Select("hash").Order("id desc").Limit(1).Find(&last).Error; err != nil {
+		return err
+	}
+
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&AuditChainHead{Id: auditChainHeadId, Hash: last.Hash}).Error
+}
+
+func (a *AuditLog) computeHash() string {
+	content := strings.Join([]string{
+		a.PrevHash,
+		strconv.FormatInt(a.CreatedAt, 10),
+		strconv.Itoa(a.ActorId),
+		a.ActorName,
+		a.ActorIp,
+		strconv.Itoa(a.AdminTokenId),
+		a.Action,
+		a.EntityType,
+		a.EntityId,
+		a.Before,
+		a.After,
+		a.Diff,
+	}, "\n")
+
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyAuditLogs 校验哈希链，返回第一条校验失败的记录 ID，0 表示完整
+func VerifyAuditLogs() (brokenId int, total int, err error) {
+	prevHash := ""
+	lastId := 0
+	for {
+		var logs []*AuditLog
+		err = DB.Where("id > ?", lastId).Order("id asc").Limit(500).Find(&logs).Error
+		if err != nil || len(logs) == 0 {
+			return 0, total, err
+		}
+
+		for _, log := range logs {
+			total++
+			if log.PrevHash != prevHash || log.computeHash() != log.Hash {
+				return log.Id, total, nil
+			}
+			prevHash = log.Hash
+			lastId = log.Id
+		}
+	}
+}
+
+func auditLogsQuery(params *AuditLogsListParams) *gorm.DB {
+	db := DB.Model(&AuditLog{})
+	if params.EntityType != "" {
+		db = db.Where("entity_type = ?", params.EntityType)
+	}
+	if params.EntityId != "" {
+		db = db.Where("entity_id = ?", params.EntityId)
+	}
+	if params.ActorId != 0 {
+		db = db.Where("actor_id = ?", params.ActorId)
+	}
+	if params.StartTimestamp != 0 {
+		db = db.Where("created_at >= ?", params.StartTimestamp)
+	}
+	if params.EndTimestamp != 0 {
+		db = db.Where("created_at <= ?", params.EndTimestamp)
+	}
+
+	return db
+}
+
+func GetAuditLogsList(params *AuditLogsListParams) (*DataResult[AuditLog], error) {
+	var logs []*AuditLog
+	return PaginateAndOrder(auditLogsQuery(params), &params.PaginationParams, &logs, allowedAuditLogOrderFields)
+}
+
+// GetAuditLogsForExport 按条件导出，按 ID 升序以便重新校验哈希链
+func GetAuditLogsForExport(params *AuditLogsListParams, limit int) ([]*AuditLog, error) {
+	var logs []*AuditLog
+	err := auditLogsQuery(params).Order("id asc").Limit(limit).Find(&logs).Error
+	return logs, err
+}
+
+func toAuditMap(value any) map[string]any {
+	if value == nil {
+		return nil
+	}
+
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		data, _ = json.Marshal(v)
+	}
+
+	result := map[string]any{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		if len(data) == 0 {
+			return nil
+		}
+		return map[string]any{"raw": string(data)}
+	}
+
+	return result
+}
+
+func redactAuditValue(entityType string, value map[string]any) map[string]any {
+	if value == nil {
+		return nil
+	}
+
+	for key, item := range value {
+		if isAuditSecretField(entityType, key, value) {
+			if item != nil && item != "" {
+				value[key] = auditRedacted + ":" + auditFingerprint(item)
+			}
+			continue
+		}
+
+		if nested, ok := item.(map[string]any); ok {
+			value[key] = redactAuditValue(entityType, nested)
+		}
+	}
+
+	return value
+}
+
+func isAuditSecretField(entityType, key string, value map[string]any) bool {
+	// 系统设置的 key 为设置名称，按名称判断值是否为密钥
+	if entityType == "options" {
+		if key != "value" {
+			return false
+		}
+
+		optionKey, _ := value["key"].(string)
+		lowerKey := strings.ToLower(optionKey)
+		for _, word := range []string{"secret", "token", "key", "password"} {
+			if strings.Contains(lowerKey, word) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return auditSecretFields[strings.ToLower(key)]
+}
+
+// auditFingerprint 敏感值的短指纹，只用于判断是否变化，无法还原原值
+func auditFingerprint(value any) string {
+	data, _ := json.Marshal(value)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:4])
+}
+
+func diffAuditMap(before, after map[string]any) map[string]any {
+	if before == nil || after == nil {
+		return nil
+	}
+
+	diff := map[string]any{}
+	for key, afterValue := range after {
+		beforeValue, ok := before[key]
+		if !ok || !reflect.DeepEqual(beforeValue, afterValue) {
+			diff[key] = map[string]any{"before": beforeValue, "after": afterValue}
+		}
+	}
+	for key, beforeValue := range before {
+		if _, ok := after[key]; !ok {
+			diff[key] = map[string]any{"before": beforeValue, "after": nil}
+		}
+	}
+
+	return diff
+}
+
+func marshalAuditValue(value map[string]any) string {
+	if value == nil {
+		return ""
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+
+	return string(data)
+}
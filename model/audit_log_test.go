@@ -0,0 +1,105 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func recordTestAuditLog(t *testing.T, entityId string, before, after any) *AuditLog {
+	auditLog := &AuditLog{ActorId: 1, Action: "PUT /api/channel/", EntityType: "channels", EntityId: entityId}
+	RecordAuditLog(auditLog, before, after)
+	if auditLog.Id == 0 {
+		t.Fatal("audit log not recorded")
+	}
+
+	return auditLog
+}
+
+func TestAuditLogHashChain(t *testing.T) {
+	setupTestDB(t, &User{}, &AuditLog{}, &AuditChainHead{})
+	assert.NoError(t, initAuditChainHead(DB))
+	createTestUser(t, 1)
+
+	first := recordTestAuditLog(t, "1", map[string]any{"name": "a"}, map[string]any{"name": "b"})
+	second := recordTestAuditLog(t, "1", map[string]any{"name": "b"}, map[string]any{"name": "c"})
+	third := recordTestAuditLog(t, "2", nil, map[string]any{"name": "d"})
+
+	assert.Empty(t, first.PrevHash)
+	assert.Equal(t, first.Hash, second.PrevHash)
+	assert.Equal(t, second.Hash, third.PrevHash)
+	assert.Equal(t, "user1", first.ActorName)
+
+	var head AuditChainHead
+	assert.NoError(t, DB.First(&head, auditChainHeadId).Error)
+	assert.Equal(t, third.Hash, head.Hash)
+
+	brokenId, total, err := VerifyAuditLogs()
+	assert.NoError(t, err)
+	assert.Zero(t, brokenId)
+	assert.Equal(t, 3, total)
+
+	// 篡改内容后从该条开始校验失败
+	assert.NoError(t, DB.Model(second).Update("after", `{"name":"x"}`).Error)
+	brokenId, total, err = VerifyAuditLogs()
+	assert.NoError(t, err)
+	assert.Equal(t, second.Id, brokenId)
+	assert.Equal(t, 2, total)
+
+	// 删除中间一条后，下一条的 prev_hash 对不上
+	assert.NoError(t, DB.Model(second).Update("after", second.After).Error)
+	assert.NoError(t, DB.Delete(second).Error)
+	brokenId, _, err = VerifyAuditLogs()
+	assert.NoError(t, err)
+	assert.Equal(t, third.Id, brokenId)
+}
+
+func TestInitAuditChainHeadFromLastLog(t *testing.T) {
+	setupTestDB(t, &User{}, &AuditLog{}, &AuditChainHead{})
+	createTestUser(t, 1)
+
+	// 升级前已有审计记录时，链头接在最后一条记录之后
+	existing := &AuditLog{CreatedAt: 1700000000, ActorId: 1, EntityType: "channels", EntityId: "1"}
+	existing.Hash = existing.computeHash()
+	assert.NoError(t, DB.Create(existing).Error)
+
+	assert.NoError(t, initAuditChainHead(DB))
+	assert.NoError(t, initAuditChainHead(DB))
+
+	next := recordTestAuditLog(t, "1", nil, map[string]any{"name": "a"})
+	assert.Equal(t, existing.Hash, next.PrevHash)
+
+	brokenId, total, err := VerifyAuditLogs()
+	assert.NoError(t, err)
+	assert.Zero(t, brokenId)
+	assert.Equal(t, 2, total)
+}
+
+func TestAuditLogRedactsSecrets(t *testing.T) {
+	setupTestDB(t, &User{}, &AuditLog{}, &AuditChainHead{})
+	assert.NoError(t, initAuditChainHead(DB))
+	createTestUser(t, 1)
+
+	auditLog := recordTestAuditLog(t, "1",
+		map[string]any{"name": "a", "key": "sk-old"},
+		map[string]any{"name": "a", "key": "sk-new"},
+	)
+	assert.NotContains(t, auditLog.Before, "sk-old")
+	assert.NotContains(t, auditLog.After, "sk-new")
+
+	// 脱敏后仍能看出密钥发生了变化
+	diff := map[string]map[string]string{}
+	assert.NoError(t, json.Unmarshal([]byte(auditLog.Diff), &diff))
+	assert.Contains(t, diff, "key")
+	assert.NotContains(t, diff, "name")
+	assert.True(t, strings.HasPrefix(diff["key"]["before"], auditRedacted+":"))
+	assert.NotEqual(t, diff["key"]["before"], diff["key"]["after"])
+
+	// 系统设置按设置名称判断是否为密钥
+	option := redactAuditValue("options", map[string]any{"key": "GitHubClientSecret", "value": "s3cret"})
+	assert.NotEqual(t, "s3cret", option["value"])
+	option = redactAuditValue("options", map[string]any{"key": "SystemName", "value": "Done Hub"})
+	assert.Equal(t, "Done Hub", option["value"])
+}
@@ -15,11 +15,15 @@ var (
 	TokenCacheSeconds           = 0
 	UserGroupCacheKey           = "user_group:%d"
 	UserTokensKey               = "token:%s"
+	TokenIdCacheKey             = "token_id:%d"
 	UsernameCacheKey            = "user_name:%d"
 	UserQuotaCacheKey           = "user_quota:%d"
 	UserEnabledCacheKey         = "user_enabled:%d"
 	UserRealtimeQuotaKey        = "user_realtime_quota:%d"
 	UserRealtimeQuotaExpiration = 24 * time.Hour
+	UserQuotaLotsCacheKey       = "user_quota_lots:%d"
+	UserQuotaLotsExpiration     = 10 * time.Minute
+	OrganizationQuotaCacheKey   = "organization_quota:%d:%d"
 
 	OldUserTokensCacheKey = "old_user_tokens_cache"
 )
@@ -40,6 +44,38 @@ func CacheGetTokenByKey(key string) (*Token, error) {
 	return token, err
 }
 
+func CacheGetTokenByKeyHash(keyHash string) (*Token, error) {
+	if !config.RedisEnabled {
+		return GetTokenByKeyHash(keyHash)
+	}
+
+	token, err := cache.GetOrSetCache(
+		fmt.Sprintf(UserTokensKey, keyHash),
+		time.Duration(TokenCacheSeconds)*time.Second,
+		func() (*Token, error) {
+			return GetTokenByKeyHash(keyHash)
+		},
+		cache.CacheTimeout)
+
+	return token, err
+}
+
+func CacheGetTokenById(id int) (*Token, error) {
+	if !config.RedisEnabled {
+		return GetTokenById(id)
+	}
+
+	token, err := cache.GetOrSetCache(
+		fmt.Sprintf(TokenIdCacheKey, id),
+		time.Duration(TokenCacheSeconds)*time.Second,
+		func() (*Token, error) {
+			return GetTokenById(id)
+		},
+		cache.CacheTimeout)
+
+	return token, err
+}
+
 func CacheGetUserGroup(id int) (group string, err error) {
 	if !config.RedisEnabled {
 		return GetUserGroup(id)
@@ -100,6 +136,28 @@ func CacheDecreaseUserQuota(id int, quota int) error {
 	return err
 }
 
+// CacheGetOrganizationAvailableQuota 成员可使用的组织额度，缓存只用于请求前的余额检查，实际扣费在数据库中按条件扣减
+func CacheGetOrganizationAvailableQuota(organizationId int, userId int) (quota int, err error) {
+	if !config.RedisEnabled {
+		return GetOrganizationAvailableQuota(organizationId, userId)
+	}
+	key := fmt.Sprintf(OrganizationQuotaCacheKey, organizationId, userId)
+	quotaString, err := redis.RedisGet(key)
+	if err != nil {
+		quota, err = GetOrganizationAvailableQuota(organizationId, userId)
+		if err != nil {
+			return 0, err
+		}
+		err = redis.RedisSet(key, fmt.Sprintf("%d", quota), time.Duration(TokenCacheSeconds)*time.Second)
+		if err != nil {
+			logger.SysError("Redis set organization quota error: " + err.Error())
+		}
+		return quota, err
+	}
+	quota, err = strconv.Atoi(quotaString)
+	return quota, err
+}
+
 func CacheIsUserEnabled(userId int) (bool, error) {
 	if !config.RedisEnabled {
 		return IsUserEnabled(userId)
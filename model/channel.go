@@ -2,10 +2,13 @@ package model
 
 import (
 	"crypto/md5"
+	"done-hub/common"
 	"done-hub/common/config"
 	"done-hub/common/logger"
+	"done-hub/common/secret"
 	"done-hub/common/utils"
 	"encoding/hex"
+	"errors"
 	"slices"
 	"strings"
 
@@ -17,6 +20,7 @@ type Channel struct {
 	Id                 int     `json:"id"`
 	Type               int     `json:"type" form:"type" gorm:"default:0"`
 	Key                string  `json:"key" form:"key" gorm:"type:text"`
+	KeyHash            string  `json:"-" gorm:"type:varchar(64);index"`
 	Status             int     `json:"status" form:"status" gorm:"default:1"`
 	Name               string  `json:"name" form:"name" gorm:"index"`
 	Weight             *uint   `json:"weight" gorm:"default:1"`
@@ -40,6 +44,9 @@ type Channel struct {
 	OnlyChat           bool    `json:"only_chat" form:"only_chat" gorm:"default:false"`
 	PreCost            int     `json:"pre_cost" form:"pre_cost" gorm:"default:1"`
 	CompatibleResponse bool    `json:"compatible_response" gorm:"default:false"`
+	CostRatio          float64 `json:"cost_ratio" gorm:"default:0"` // 上游成本相对模型基础价格的倍率，0 表示不计算成本
+
+	CostPrices *datatypes.JSONType[map[string]ChannelCostPrice] `json:"cost_prices,omitempty" gorm:"type:json"` // 按模型设置的上游价格，优先于成本倍率
 
 	DisabledStream *datatypes.JSONSlice[string] `json:"disabled_stream,omitempty" gorm:"type:json"`
 
@@ -55,6 +62,87 @@ func (c *Channel) AllowStream(modelName string) bool {
 	return !slices.Contains(*c.DisabledStream, modelName)
 }
 
+// channelSecretOtherTypes Other 中保存供应商密钥信息的渠道类型，这些渠道的 Other 和 Key 一样加密保存
+// 其他渠道的 Other 只保存 API 版本、区域等参数，明文保存以便搜索和批量修改
+var channelSecretOtherTypes = map[int]bool{
+	config.ChannelTypeVertexAI: true, // Region|ProjectID
+}
+
+func (c *Channel) otherIsSecret() bool {
+	return channelSecretOtherTypes[c.Type]
+}
+
+// Decrypted 返回 Key 和 Other 解密后的副本，只在构建供应商等需要明文时使用，不要写回缓存
+func (c *Channel) Decrypted() (*Channel, error) {
+	if !secret.IsEncrypted(c.Key) && !secret.IsEncrypted(c.Other) {
+		return c, nil
+	}
+
+	decrypted := *c
+	var err error
+	if decrypted.Key, err = secret.Decrypt(c.Key); err != nil {
+		return nil, err
+	}
+	if decrypted.Other, err = secret.Decrypt(c.Other); err != nil {
+		return nil, err
+	}
+
+	return &decrypted, nil
+}
+
+// MaskSecrets 管理接口返回前对 Key 和保存密钥信息的 Other 脱敏
+func (c *Channel) MaskSecrets() {
+	key, _ := secret.Decrypt(c.Key)
+	c.Key = secret.Mask(key)
+	if c.otherIsSecret() {
+		other, _ := secret.Decrypt(c.Other)
+		c.Other = secret.Mask(other)
+	}
+}
+
+// RestoreMaskedSecrets 提交的 Key 或 Other 仍为脱敏值时沿用库中的值
+func (c *Channel) RestoreMaskedSecrets() error {
+	if !secret.IsMasked(c.Key) && !secret.IsMasked(c.Other) {
+		return nil
+	}
+
+	if c.Id == 0 {
+		return errors.New("渠道不存在")
+	}
+
+	stored, err := GetChannelById(c.Id)
+	if err != nil {
+		return err
+	}
+
+	if secret.IsMasked(c.Key) {
+		c.Key = stored.Key
+		c.KeyHash = stored.KeyHash
+	}
+	if secret.IsMasked(c.Other) {
+		c.Other = stored.Other
+	}
+
+	return nil
+}
+
+// encryptSecrets 写库前加密 Key 和保存密钥信息的 Other，已加密的值保持不变
+func (c *Channel) encryptSecrets() (err error) {
+	// 密文每次都不同，按 key 搜索时使用哈希匹配
+	if c.Key != "" && !secret.IsEncrypted(c.Key) {
+		c.KeyHash = common.HashToken(c.Key)
+	}
+
+	if c.Key, err = secret.Encrypt(c.Key); err != nil {
+		return err
+	}
+
+	if c.otherIsSecret() {
+		c.Other, err = secret.Encrypt(c.Other)
+	}
+	return err
+}
+
 type PluginType map[string]map[string]interface{}
 
 var allowedChannelOrderFields = map[string]bool{
@@ -115,8 +203,9 @@ func GetChannelsList(params *SearchChannelsParams) (*DataResult[Channel], error)
 	}
 
 	if params.Key != "" {
-		db = db.Where(quotePostgresField("key")+" = ?", params.Key)
-		tagDB = tagDB.Where(quotePostgresField("key")+" = ?", params.Key)
+		keyHash := common.HashToken(params.Key)
+		db = db.Where("("+quotePostgresField("key")+" = ? OR key_hash = ?)", params.Key, keyHash)
+		tagDB = tagDB.Where("("+quotePostgresField("key")+" = ? OR key_hash = ?)", params.Key, keyHash)
 	}
 
 	if params.TestModel != "" {
@@ -171,6 +260,12 @@ func BatchDeleteChannel(ids []int) (int64, error) {
 }
 
 func BatchInsertChannels(channels []Channel) error {
+	for i := range channels {
+		if err := channels[i].encryptSecrets(); err != nil {
+			return err
+		}
+	}
+
 	err := DB.Omit("UsedQuota").Create(&channels).Error
 	if err != nil {
 		return err
@@ -286,7 +381,9 @@ func (c *Channel) SetProxy() {
 	}
 
 	if strings.Contains(*c.Proxy, "%s") {
-		md5Str := md5.Sum([]byte(c.Key))
+		// 使用明文计算，避免重新加密后代理会话标识变化
+		key, _ := secret.Decrypt(c.Key)
+		md5Str := md5.Sum([]byte(key))
 		idStr := hex.EncodeToString(md5Str[:])
 		*c.Proxy = strings.Replace(*c.Proxy, "%s", idStr, 1)
 	}
@@ -322,6 +419,10 @@ func (channel *Channel) GetCustomParameter() string {
 }
 
 func (channel *Channel) Insert() error {
+	if err := channel.encryptSecrets(); err != nil {
+		return err
+	}
+
 	err := DB.Omit("UsedQuota").Create(channel).Error
 	if err == nil {
 		ChannelGroup.Load()
@@ -342,7 +443,10 @@ func (channel *Channel) Update(overwrite bool) error {
 }
 
 func (channel *Channel) UpdateRaw(overwrite bool) error {
-	var err error
+	err := channel.encryptSecrets()
+	if err != nil {
+		return err
+	}
 
 	if overwrite {
 		err = DB.Model(channel).Select("*").Omit("UsedQuota").Updates(channel).Error
@@ -367,13 +471,21 @@ func (channel *Channel) UpdateResponseTime(responseTime int64) {
 }
 
 func (channel *Channel) UpdateBalance(balance float64) {
+	channel.UpdateBalanceWithCurrency(balance, ChannelBalanceCurrencyUSD)
+}
+
+// UpdateBalanceWithCurrency 上游余额不是美元时记录其单位，对账时据此换算
+func (channel *Channel) UpdateBalanceWithCurrency(balance float64, currency string) {
+	now := utils.GetTimestamp()
 	err := DB.Model(channel).Select("balance_updated_time", "balance").Updates(Channel{
-		BalanceUpdatedTime: utils.GetTimestamp(),
+		BalanceUpdatedTime: now,
 		Balance:            balance,
 	}).Error
 	if err != nil {
 		logger.SysError("failed to update balance: " + err.Error())
+		return
 	}
+	recordChannelBalanceSnapshot(channel.Id, balance, currency, now)
 }
 
 func (channel *Channel) Delete() error {
@@ -0,0 +1,231 @@
+package model
+
+import (
+	"done-hub/common/config"
+	"done-hub/common/logger"
+	"done-hub/common/utils"
+	"errors"
+)
+
+// ChannelCostPrice 渠道上游的模型价格，单位与模型价格相同
+type ChannelCostPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// 上游余额的单位，成本按美元记录，其他单位对账时需要换算
+const (
+	ChannelBalanceCurrencyUSD     = "USD"
+	ChannelBalanceCurrencyCNY     = "CNY"
+	ChannelBalanceCurrencyCredits = "credits" // 平台积分，无法换算成金额
+)
+
+// ChannelBalanceSnapshot 每次查询上游余额时记录的快照，用于和记录的成本对账
+type ChannelBalanceSnapshot struct {
+	Id          int     `json:"id"`
+	ChannelId   int     `json:"channel_id" gorm:"index:idx_channel_balance_time,priority:1"`
+	Balance     float64 `json:"balance"`
+	Currency    string  `json:"currency" gorm:"type:varchar(16);default:'USD'"`
+	CreatedTime int64   `json:"created_time" gorm:"bigint;index:idx_channel_balance_time,priority:2"`
+}
+
+// GetCostPrice 返回模型在渠道上的上游价格，按模型设置的价格优先，其次按成本倍率乘以模型基础价格，都未设置时返回 false
+func (channel *Channel) GetCostPrice(modelName string, basePrice *Price, promptTokens int) (input float64, output float64, ok bool) {
+	if channel.CostPrices != nil {
+		if price, exists := channel.CostPrices.Data()[modelName]; exists {
+			return price.Input, price.Output, true
+		}
+	}
+
+	if channel.CostRatio <= 0 {
+		return 0, 0, false
+	}
+
+	input, output = basePrice.GetInput(), basePrice.GetOutput()
+	if tier := basePrice.GetTier(promptTokens); tier != nil {
+		input, output = tier.Input, tier.Output
+	}
+	return input * channel.CostRatio, output * channel.CostRatio, true
+}
+
+// GetExtraCostRatio 额外服务的成本倍率，只配置了模型成本价时按原价计算
+func (channel *Channel) GetExtraCostRatio() float64 {
+	if channel.CostRatio > 0 {
+		return channel.CostRatio
+	}
+	return 1
+}
+
+func recordChannelBalanceSnapshot(channelId int, balance float64, currency string, createdTime int64) {
+	err := DB.Create(&ChannelBalanceSnapshot{
+		ChannelId:   channelId,
+		Balance:     balance,
+		Currency:    currency,
+		CreatedTime: createdTime,
+	}).Error
+	if err != nil {
+		logger.SysError("failed to record channel balance snapshot: " + err.Error())
+	}
+}
+
+type MarginStatistics struct {
+	Dimension    string  `json:"dimension"`
+	ChannelName  string  `json:"channel_name,omitempty"`
+	RequestCount int64   `json:"request_count"`
+	Quota        int64   `json:"quota"`
+	CostQuota    int64   `json:"cost_quota"`
+	MarginQuota  int64   `json:"margin_quota"`
+	MarginRate   float64 `json:"margin_rate"` // 毛利率，收入为 0 时为 0
+}
+
+// GetMarginStatistics 按渠道、模型、分组或时间（day/month）汇总消费日志的收入和上游成本
+func GetMarginStatistics(groupBy string, startTimestamp, endTimestamp int64) ([]*MarginStatistics, error) {
+	var dimensionSelect string
+	switch groupBy {
+	case "channel":
+		dimensionSelect = "channel_id as dimension"
+	case "model":
+		dimensionSelect = "model_name as dimension"
+	case "group":
+		dimensionSelect = "group_name as dimension"
+	case "day", "month":
+		dimensionSelect = getTimestampGroupsSelect("created_at", groupBy, "dimension")
+	default:
+		return nil, errors.New("无效的统计维度")
+	}
+
+	var statistics []*MarginStatistics
+	err := DB.Model(&Log{}).
+		Select(dimensionSelect+", count(*) as request_count, sum(quota) as quota, sum(cost_quota) as cost_quota").
+		Where("type = ? AND created_at BETWEEN ? AND ?", LogTypeConsume, startTimestamp, endTimestamp).
+		Group("dimension").
+		Order("dimension").
+		Scan(&statistics).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range statistics {
+		item.MarginQuota = item.Quota - item.CostQuota
+		if item.Quota > 0 {
+			item.MarginRate = float64(item.MarginQuota) / float64(item.Quota)
+		}
+	}
+
+	if groupBy == "channel" {
+		fillMarginChannelNames(statistics)
+	}
+
+	return statistics, nil
+}
+
+func fillMarginChannelNames(statistics []*MarginStatistics) {
+	ids := make([]int, 0, len(statistics))
+	for _, item := range statistics {
+		ids = append(ids, utils.String2Int(item.Dimension))
+	}
+
+	var channels []*Channel
+	if err := DB.Unscoped().Select("id", "name").Where("id IN (?)", ids).Find(&channels).Error; err != nil {
+		return
+	}
+
+	names := make(map[int]string, len(channels))
+	for _, channel := range channels {
+		names[channel.Id] = channel.Name
+	}
+	for _, item := range statistics {
+		item.ChannelName = names[utils.String2Int(item.Dimension)]
+	}
+}
+
+type ChannelBalanceReconciliation struct {
+	ChannelId    int     `json:"channel_id"`
+	ChannelName  string  `json:"channel_name"`
+	Currency     string  `json:"currency"` // 上游余额的单位
+	StartBalance float64 `json:"start_balance"`
+	EndBalance   float64 `json:"end_balance"`
+	BalanceDrop  float64 `json:"balance_drop"`  // 上游余额减少的金额，单位同 Currency，期间有充值时会偏小
+	CostQuota    int64   `json:"cost_quota"`    // 期间记录的上游成本
+	CostAmount   float64 `json:"cost_amount"`   // 记录的上游成本换算成美元
+	Comparable   bool    `json:"comparable"`    // 余额单位能否换算成美元，不能时不计算差额
+	Difference   float64 `json:"difference"`    // 余额减少换算成美元 - 记录成本，为正说明记录的成本偏低
+	SnapshotFrom int64   `json:"snapshot_from"` // 实际用于对比的第一条余额快照时间
+	SnapshotTo   int64   `json:"snapshot_to"`
+}
+
+// balanceToUSD 将上游余额换算成美元，无法换算时返回 false
+func balanceToUSD(amount float64, currency string) (float64, bool) {
+	switch currency {
+	case "", ChannelBalanceCurrencyUSD:
+		return amount, true
+	case ChannelBalanceCurrencyCNY:
+		if config.PaymentUSDRate <= 0 {
+			return 0, false
+		}
+		return amount / config.PaymentUSDRate, true
+	default:
+		return 0, false
+	}
+}
+
+// GetChannelBalanceReconciliation 对比渠道在时间段内上游余额的减少和日志中记录的成本，channelId 为 0 时返回所有有余额快照的渠道
+func GetChannelBalanceReconciliation(channelId int, startTimestamp, endTimestamp int64) ([]*ChannelBalanceReconciliation, error) {
+	var channelIds []int
+	tx := DB.Model(&ChannelBalanceSnapshot{}).Where("created_time BETWEEN ? AND ?", startTimestamp, endTimestamp)
+	if channelId > 0 {
+		tx = tx.Where("channel_id = ?", channelId)
+	}
+	if err := tx.Distinct("channel_id").Pluck("channel_id", &channelIds).Error; err != nil {
+		return nil, err
+	}
+
+	results := make([]*ChannelBalanceReconciliation, 0, len(channelIds))
+	for _, id := range channelIds {
+		var first, last ChannelBalanceSnapshot
+		err := DB.Where("channel_id = ? AND created_time BETWEEN ? AND ?", id, startTimestamp, endTimestamp).
+			Order("created_time").First(&first).Error
+		if err != nil {
+			return nil, err
+		}
+		err = DB.Where("channel_id = ? AND created_time BETWEEN ? AND ?", id, startTimestamp, endTimestamp).
+			Order("created_time desc").First(&last).Error
+		if err != nil {
+			return nil, err
+		}
+
+		// 只统计两次快照之间的成本，保证和余额变化的区间一致
+		var costQuota int64
+		err = DB.Model(&Log{}).Select("COALESCE(sum(cost_quota), 0)").
+			Where("channel_id = ? AND type = ? AND created_at > ? AND created_at <= ?", id, LogTypeConsume, first.CreatedTime, last.CreatedTime).
+			Scan(&costQuota).Error
+		if err != nil {
+			return nil, err
+		}
+
+		item := &ChannelBalanceReconciliation{
+			ChannelId:    id,
+			Currency:     last.Currency,
+			StartBalance: first.Balance,
+			EndBalance:   last.Balance,
+			BalanceDrop:  first.Balance - last.Balance,
+			CostQuota:    costQuota,
+			CostAmount:   float64(costQuota) / config.QuotaPerUnit,
+			SnapshotFrom: first.CreatedTime,
+			SnapshotTo:   last.CreatedTime,
+		}
+		// 期间余额单位发生变化时两次快照不可比
+		if first.Currency == last.Currency {
+			if drop, ok := balanceToUSD(item.BalanceDrop, item.Currency); ok {
+				item.Comparable = true
+				item.Difference = drop - item.CostAmount
+			}
+		}
+		if channel, err := GetChannelById(id); err == nil {
+			item.ChannelName = channel.Name
+		}
+		results = append(results, item)
+	}
+
+	return results, nil
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"crypto/md5"
 	"done-hub/common/config"
+	"done-hub/common/secret"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -60,10 +61,14 @@ func GetChannelsTag(tag string) (*ChannelTagCollection, error) {
 
 	channelTag.KeyMap = make(map[string]int)
 	for _, c := range channels {
-		keyMd5 := md5.Sum([]byte(c.Key))
+		key, err := secret.Decrypt(c.Key)
+		if err != nil {
+			return nil, err
+		}
+		keyMd5 := md5.Sum([]byte(key))
 		keyMd5Str := hex.EncodeToString(keyMd5[:])
 		channelTag.KeyMap[keyMd5Str] = c.Id
-		channelTag.Key += c.Key + "\n"
+		channelTag.Key += key + "\n"
 	}
 
 	channelTag.Key = strings.TrimRight(channelTag.Key, "\n")
@@ -80,6 +85,20 @@ func UpdateChannelsTag(tag string, channel *Channel) error {
 		return errors.New("key不能为空")
 	}
 
+	// 提交的仍为脱敏值时不修改 key 和 other
+	if secret.IsMasked(channel.Key) {
+		channel.Key = channelTag.Key
+	}
+	if secret.IsMasked(channel.Other) {
+		channel.Other = channelTag.Other
+	}
+	other := channel.Other
+	if channelSecretOtherTypes[channel.Type] {
+		if other, err = secret.Encrypt(channel.Other); err != nil {
+			return err
+		}
+	}
+
 	addKeys := []string{}
 	delIds := []int{}
 
@@ -126,6 +145,11 @@ func UpdateChannelsTag(tag string, channel *Channel) error {
 			addChannel := *channel
 			addChannel.Name = fmt.Sprintf("%s_%d", channel.Name, maxKey)
 			addChannel.Key = key
+			addChannel.Other = other
+			if err = addChannel.encryptSecrets(); err != nil {
+				tx.Rollback()
+				return err
+			}
 			addChannel.Balance = 0
 			addChannel.BalanceUpdatedTime = 0
 			addChannel.UsedQuota = 0
@@ -145,7 +169,7 @@ func UpdateChannelsTag(tag string, channel *Channel) error {
 	err = tx.Model(Channel{}).Where("tag = ?", tag).Updates(
 		Channel{
 			BaseURL:            channel.BaseURL,
-			Other:              channel.Other,
+			Other:              other,
 			Models:             channel.Models,
 			Group:              channel.Group,
 			Tag:                channel.Tag,
@@ -0,0 +1,151 @@
+package model
+
+import (
+	"testing"
+
+	"done-hub/common"
+	"done-hub/common/config"
+	"done-hub/common/secret"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func initTestChannelSecret(t *testing.T) {
+	viper.Set("encryption.key_id", "test")
+	viper.Set("encryption.master_key", "test-master-key")
+	if err := secret.InitSecret(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		viper.Set("encryption.master_key", "")
+		secret.InitSecret()
+	})
+}
+
+// createTestChannel 按 Insert 的方式加密后写入，不重新加载渠道分组
+func createTestChannel(t *testing.T, key, other string) *Channel {
+	channel := &Channel{Type: 3, Name: "azure", Key: key, Other: other}
+	if err := channel.encryptSecrets(); err != nil {
+		t.Fatal(err)
+	}
+	if err := DB.Omit("UsedQuota").Create(channel).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return channel
+}
+
+func TestChannelEncryptSecrets(t *testing.T) {
+	setupTestDB(t, &Channel{})
+	initTestChannelSecret(t)
+
+	channel := &Channel{Key: "sk-1234567890abcdef", Other: "2024-05-01-preview"}
+	assert.NoError(t, channel.encryptSecrets())
+
+	// 只有 Key 加密保存，Other 为 API 版本等参数，保存明文
+	assert.True(t, secret.IsEncrypted(channel.Key))
+	assert.Equal(t, common.HashToken("sk-1234567890abcdef"), channel.KeyHash)
+	assert.Equal(t, "2024-05-01-preview", channel.Other)
+
+	decrypted, err := channel.Decrypted()
+	assert.NoError(t, err)
+	assert.Equal(t, "sk-1234567890abcdef", decrypted.Key)
+	assert.Equal(t, "2024-05-01-preview", decrypted.Other)
+}
+
+func TestChannelEncryptSecretOther(t *testing.T) {
+	setupTestDB(t, &Channel{})
+	initTestChannelSecret(t)
+
+	// Vertex AI 的 Other 保存项目信息，和 Key 一样加密保存并脱敏返回
+	channel := &Channel{Type: config.ChannelTypeVertexAI, Key: "{\"private_key\":\"secret\"}", Other: "us-central1|my-project-123456"}
+	assert.NoError(t, channel.encryptSecrets())
+	assert.True(t, secret.IsEncrypted(channel.Other))
+	assert.NoError(t, DB.Omit("UsedQuota").Create(channel).Error)
+
+	decrypted, err := channel.Decrypted()
+	assert.NoError(t, err)
+	assert.Equal(t, "us-central1|my-project-123456", decrypted.Other)
+
+	masked := *channel
+	masked.MaskSecrets()
+	assert.True(t, secret.IsMasked(masked.Other))
+	assert.NotContains(t, masked.Other, "project")
+
+	// 只修改 Key 时沿用库中的 Other
+	submitted := Channel{Id: channel.Id, Type: config.ChannelTypeVertexAI, Key: "new-key", Other: masked.Other}
+	assert.NoError(t, submitted.RestoreMaskedSecrets())
+	assert.Equal(t, channel.Other, submitted.Other)
+	assert.Equal(t, "new-key", submitted.Key)
+}
+
+func TestChannelMaskSecrets(t *testing.T) {
+	setupTestDB(t, &Channel{})
+	initTestChannelSecret(t)
+
+	stored := createTestChannel(t, "sk-1234567890abcdef", "2024-05-01-preview")
+
+	masked := *stored
+	masked.MaskSecrets()
+	assert.True(t, secret.IsMasked(masked.Key))
+	assert.NotContains(t, masked.Key, "567890")
+	assert.Equal(t, "2024-05-01-preview", masked.Other)
+
+	// 提交脱敏值时沿用库中的 Key
+	assert.NoError(t, masked.RestoreMaskedSecrets())
+	assert.Equal(t, stored.Key, masked.Key)
+	assert.Equal(t, stored.KeyHash, masked.KeyHash)
+
+	submitted := Channel{Id: stored.Id, Key: "sk-new-key-1234567890"}
+	assert.NoError(t, submitted.RestoreMaskedSecrets())
+	assert.Equal(t, "sk-new-key-1234567890", submitted.Key)
+}
+
+func TestChannelSearchOtherAndKey(t *testing.T) {
+	setupTestDB(t, &Channel{})
+	initTestChannelSecret(t)
+
+	stored := createTestChannel(t, "sk-1234567890abcdef", "2024-05-01-preview")
+	createTestChannel(t, "", "2023-01-01")
+
+	params := &SearchChannelsParams{Channel: Channel{Other: "2024-05"}}
+	result, err := GetChannelsList(params)
+	assert.NoError(t, err)
+	if assert.Len(t, *result.Data, 1) {
+		assert.Equal(t, stored.Id, (*result.Data)[0].Id)
+	}
+
+	// 密钥加密保存，按哈希匹配
+	params = &SearchChannelsParams{Channel: Channel{Key: "sk-1234567890abcdef"}}
+	result, err = GetChannelsList(params)
+	assert.NoError(t, err)
+	if assert.Len(t, *result.Data, 1) {
+		assert.Equal(t, stored.Id, (*result.Data)[0].Id)
+	}
+}
+
+func TestChannelBalanceReconciliationCurrency(t *testing.T) {
+	setupTestDB(t, &Channel{}, &ChannelBalanceSnapshot{}, &Log{})
+
+	recordChannelBalanceSnapshot(1, 73, ChannelBalanceCurrencyCNY, 100)
+	recordChannelBalanceSnapshot(1, 0, ChannelBalanceCurrencyCNY, 200)
+	recordChannelBalanceSnapshot(2, 1000, ChannelBalanceCurrencyCredits, 100)
+	recordChannelBalanceSnapshot(2, 500, ChannelBalanceCurrencyCredits, 200)
+
+	results, err := GetChannelBalanceReconciliation(0, 0, 300)
+	assert.Nil(t, err)
+	assert.Len(t, results, 2)
+
+	for _, item := range results {
+		switch item.ChannelId {
+		case 1:
+			assert.True(t, item.Comparable)
+			assert.InDelta(t, 73/config.PaymentUSDRate, item.Difference, 1e-9)
+		case 2:
+			assert.False(t, item.Comparable)
+			assert.Equal(t, float64(0), item.Difference)
+			assert.Equal(t, float64(500), item.BalanceDrop)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package model
+
+import (
+	"done-hub/common/utils"
+	"encoding/json"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ClaudeObjectTypeBatch = "batch"
+	ClaudeObjectTypeFile  = "file"
+)
+
+// ClaudeObject 记录 Claude 批量请求和文件与接收它的渠道的绑定关系
+type ClaudeObject struct {
+	Id        int    `json:"id"`
+	ObjectId  string `json:"object_id" gorm:"type:varchar(100);index"`
+	Type      string `json:"type" gorm:"type:varchar(20);index"`
+	UserId    int    `json:"user_id" gorm:"index"`
+	TokenId   int    `json:"token_id" gorm:"default:0"`
+	ChannelId int    `json:"channel_id" gorm:"index"`
+	// 批量请求提交时的模型，计费按用户请求的模型而不是渠道映射后的模型
+	ModelName string `json:"model_name" gorm:"type:varchar(100);default:''"`
+	// 批量请求中包含多个模型时，记录 custom_id 对应的模型，JSON 对象
+	Models    string `json:"-" gorm:"type:text"`
+	Billed    bool   `json:"billed" gorm:"default:false"`
+	Quota     int    `json:"quota" gorm:"default:0"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+	// Bedrock、Vertex AI 批量推理任务的标识，ObjectId 为返回给用户的批量 ID
+	UpstreamId string `json:"-" gorm:"type:varchar(255);default:''"`
+}
+
+func GetClaudeObject(userId int, objectType, objectId string) (*ClaudeObject, error) {
+	if objectId == "" {
+		return nil, errors.New("id 为空")
+	}
+
+	object := &ClaudeObject{}
+	err := DB.Where("user_id = ? and type = ? and object_id = ?", userId, objectType, objectId).First(object).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("对象不存在")
+	}
+
+	return object, err
+}
+
+// GetUserClaudeObjects 按创建顺序倒序分页，afterId 为上一页最后一条的 id
+func GetUserClaudeObjects(userId int, objectType string, afterId, limit int) ([]*ClaudeObject, error) {
+	var objects []*ClaudeObject
+	tx := DB.Where("user_id = ? and type = ?", userId, objectType)
+	if afterId > 0 {
+		tx = tx.Where("id < ?", afterId)
+	}
+
+	err := tx.Order("id desc").Limit(limit).Find(&objects).Error
+
+	return objects, err
+}
+
+// SetRequestModels 记录批量请求中每个 custom_id 请求的模型
+func (object *ClaudeObject) SetRequestModels(models map[string]string) {
+	object.ModelName = ""
+	object.Models = ""
+	for _, modelName := range models {
+		if object.ModelName == "" {
+			object.ModelName = modelName
+			continue
+		}
+		if object.ModelName != modelName {
+			data, _ := json.Marshal(models)
+			object.Models = string(data)
+			return
+		}
+	}
+}
+
+// RequestModelResolver 返回按 custom_id 查询请求模型的函数，未记录时返回空字符串
+func (object *ClaudeObject) RequestModelResolver() func(customId string) string {
+	models := make(map[string]string)
+	if object.Models != "" {
+		_ = json.Unmarshal([]byte(object.Models), &models)
+	}
+
+	return func(customId string) string {
+		if modelName, ok := models[customId]; ok {
+			return modelName
+		}
+
+		return object.ModelName
+	}
+}
+
+func (object *ClaudeObject) Insert() error {
+	object.CreatedAt = utils.GetTimestamp()
+	return DB.Create(object).Error
+}
+
+func (object *ClaudeObject) Delete() error {
+	return DB.Delete(object).Error
+}
+
+// MarkBilled 标记批量请求已计费，返回 false 表示已被其他请求计费
+func (object *ClaudeObject) MarkBilled() (bool, error) {
+	result := DB.Model(&ClaudeObject{}).Where("id = ? and billed = ?", object.Id, false).Update("billed", true)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+func (object *ClaudeObject) UpdateQuota(quota int) error {
+	object.Quota = quota
+	return DB.Model(object).Update("quota", quota).Error
+}
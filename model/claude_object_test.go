@@ -0,0 +1,24 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClaudeObjectRequestModels(t *testing.T) {
+	object := &ClaudeObject{}
+	object.SetRequestModels(map[string]string{"a": "claude-sonnet-4", "b": "claude-sonnet-4"})
+	assert.Equal(t, "claude-sonnet-4", object.ModelName)
+	assert.Empty(t, object.Models)
+	assert.Equal(t, "claude-sonnet-4", object.RequestModelResolver()("a"))
+
+	object.SetRequestModels(map[string]string{"a": "claude-sonnet-4", "b": "claude-opus-4"})
+	assert.NotEmpty(t, object.Models)
+	resolver := object.RequestModelResolver()
+	assert.Equal(t, "claude-sonnet-4", resolver("a"))
+	assert.Equal(t, "claude-opus-4", resolver("b"))
+
+	legacy := &ClaudeObject{}
+	assert.Empty(t, legacy.RequestModelResolver()("a"))
+}
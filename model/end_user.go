@@ -0,0 +1,97 @@
+package model
+
+import (
+	"done-hub/common/limit"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrEndUserRateLimited    = errors.New("终端用户请求过于频繁，请稍后再试")
+	ErrEndUserQuotaExhausted = errors.New("终端用户今日额度已用尽")
+
+	// endUserLimiters 按每分钟请求数复用限流器
+	endUserLimiters sync.Map
+)
+
+// CheckEndUserLimit 检查终端用户的频率和每日额度限制
+func CheckEndUserLimit(tokenId int, endUser string, setting *EndUserSetting) error {
+	if endUser == "" || setting == nil {
+		return nil
+	}
+
+	if setting.RPM > 0 {
+		limiter, _ := endUserLimiters.LoadOrStore(setting.RPM, limit.NewAPILimiter(setting.RPM))
+		if !limiter.(limit.RateLimiter).Allow(fmt.Sprintf("end_user_rpm:%d:%s", tokenId, endUser)) {
+			return ErrEndUserRateLimited
+		}
+	}
+
+	if setting.DailyQuota > 0 {
+		used, err := usageCounters.Get(endUserQuotaKey(tokenId, endUser))
+		if err != nil {
+			return err
+		}
+		if used >= int64(setting.DailyQuota) {
+			return ErrEndUserQuotaExhausted
+		}
+	}
+
+	return nil
+}
+
+// RecordEndUserQuota 记录终端用户当日的消费额度
+func RecordEndUserQuota(tokenId int, endUser string, setting *EndUserSetting, quota int) {
+	if endUser == "" || setting == nil || setting.DailyQuota <= 0 || quota <= 0 {
+		return
+	}
+
+	now := time.Now()
+	endOfDay := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	usageCounters.Increase(endUserQuotaKey(tokenId, endUser), int64(quota), endOfDay.Unix()+3600)
+}
+
+func endUserQuotaKey(tokenId int, endUser string) string {
+	return fmt.Sprintf("end_user_quota:%d:%s:%s", tokenId, time.Now().Format("20060102"), endUser)
+}
+
+type EndUserStatisticsParams struct {
+	StartTimestamp int64  `form:"start_timestamp"`
+	EndTimestamp   int64  `form:"end_timestamp"`
+	TokenName      string `form:"token_name"`
+	EndUser        string `form:"end_user"`
+}
+
+type EndUserStatistic struct {
+	EndUser          string `json:"end_user" gorm:"column:end_user"`
+	RequestCount     int64  `json:"request_count" gorm:"column:request_count"`
+	Quota            int64  `json:"quota" gorm:"column:quota"`
+	PromptTokens     int64  `json:"prompt_tokens" gorm:"column:prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens" gorm:"column:completion_tokens"`
+	LastRequestAt    int64  `json:"last_request_at" gorm:"column:last_request_at"`
+}
+
+// GetUserEndUserStatistics 按终端用户汇总消费，按额度从高到低返回前 limit 个
+func GetUserEndUserStatistics(userId int, params *EndUserStatisticsParams, limit int) (statistics []*EndUserStatistic, err error) {
+	tx := DB.Table("logs").
+		Select("end_user, COUNT(*) as request_count, COALESCE(SUM(quota), 0) as quota, COALESCE(SUM(prompt_tokens), 0) as prompt_tokens, COALESCE(SUM(completion_tokens), 0) as completion_tokens, MAX(created_at) as last_request_at").
+		Where("user_id = ? AND type = ? AND end_user <> ''", userId, LogTypeConsume)
+
+	if params.StartTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", params.StartTimestamp)
+	}
+	if params.EndTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", params.EndTimestamp)
+	}
+	if params.TokenName != "" {
+		tx = tx.Where("token_name = ?", params.TokenName)
+	}
+	if params.EndUser != "" {
+		tx = tx.Where("end_user = ?", params.EndUser)
+	}
+
+	err = tx.Group("end_user").Order("quota desc").Limit(limit).Scan(&statistics).Error
+	return statistics, err
+}
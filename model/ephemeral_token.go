@@ -0,0 +1,157 @@
+package model
+
+import (
+	"done-hub/common"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	EphemeralTokenDefaultTTL = 10 * 60
+	EphemeralTokenMaxTTL     = 60 * 60
+	ephemeralTokenMaxModels  = 50
+)
+
+type EphemeralTokenRequest struct {
+	Name        string   `json:"name"`
+	ExpiresIn   int64    `json:"expires_in"`
+	Models      []string `json:"models"`
+	MaxRequests int      `json:"max_requests"`
+	MaxQuota    int      `json:"max_quota"`
+}
+
+// MintEphemeralToken 由普通令牌签发临时令牌，有效期以分钟计，额度仍从父令牌扣除
+func MintEphemeralToken(token *Token, req *EphemeralTokenRequest) (string, *common.EphemeralClaims, error) {
+	if req.ExpiresIn == 0 {
+		req.ExpiresIn = EphemeralTokenDefaultTTL
+	}
+	if req.ExpiresIn < 0 || req.ExpiresIn > EphemeralTokenMaxTTL {
+		return "", nil, fmt.Errorf("有效期必须在 1 到 %d 秒之间", EphemeralTokenMaxTTL)
+	}
+	if req.MaxRequests < 0 || req.MaxQuota < 0 {
+		return "", nil, errors.New("请求次数和额度上限不能为负数")
+	}
+	if len(req.Models) > ephemeralTokenMaxModels {
+		return "", nil, fmt.Errorf("模型数量不能超过 %d 个", ephemeralTokenMaxModels)
+	}
+	if len(req.Name) > 50 {
+		return "", nil, errors.New("名称不能超过50个字符")
+	}
+
+	expiresAt := time.Now().Unix() + req.ExpiresIn
+	// 不能超过父令牌的有效期
+	if token.ExpiredTime != -1 && token.ExpiredTime < expiresAt {
+		expiresAt = token.ExpiredTime
+	}
+
+	claims := &common.EphemeralClaims{
+		Name:        req.Name,
+		TokenId:     token.Id,
+		TokenName:   token.Name,
+		UserId:      token.UserId,
+		Group:       token.Group,
+		OrgId:       token.OrganizationId,
+		Models:      req.Models,
+		MaxRequests: req.MaxRequests,
+		MaxQuota:    req.MaxQuota,
+		ExpiresAt:   expiresAt,
+	}
+
+	key, err := common.SignEphemeralToken(claims)
+	return key, claims, err
+}
+
+// ValidateEphemeralToken 校验临时令牌和父令牌并计入一次请求，超出请求次数或额度上限时返回错误
+// 父令牌被禁用、过期、用尽、删除或更换分组后，由它签发的临时令牌同时失效
+func ValidateEphemeralToken(key string) (*common.EphemeralClaims, *Token, error) {
+	claims, err := common.ParseEphemeralToken(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if userEnabled, err := CacheIsUserEnabled(claims.UserId); err != nil || !userEnabled {
+		return nil, nil, common.ErrEphemeralTokenInvalid
+	}
+
+	parent, err := CacheGetTokenById(claims.TokenId)
+	if err != nil || parent.UserId != claims.UserId || parent.Group != claims.Group {
+		return nil, nil, common.ErrEphemeralTokenInvalid
+	}
+	if err := parent.checkAvailable(); err != nil {
+		return nil, nil, err
+	}
+
+	if claims.MaxQuota > 0 {
+		used, err := usageCounters.Get(ephemeralUsageKey(claims, "quota"))
+		if err != nil {
+			return nil, nil, err
+		}
+		if used >= int64(claims.MaxQuota) {
+			return nil, nil, errors.New("临时令牌额度已用尽")
+		}
+	}
+
+	if claims.MaxRequests > 0 {
+		requests, err := usageCounters.Increase(ephemeralUsageKey(claims, "requests"), 1, claims.ExpiresAt+60)
+		if err != nil {
+			return nil, nil, err
+		}
+		if requests > int64(claims.MaxRequests) {
+			return nil, nil, errors.New("临时令牌请求次数已用尽")
+		}
+	}
+
+	return claims, parent, nil
+}
+
+// CheckEphemeralTokenQuota 检查临时令牌的剩余额度是否足够，不占用额度
+func CheckEphemeralTokenQuota(claims *common.EphemeralClaims, quota int) error {
+	if claims == nil || claims.MaxQuota <= 0 {
+		return nil
+	}
+
+	used, err := usageCounters.Get(ephemeralUsageKey(claims, "quota"))
+	if err != nil {
+		return err
+	}
+	if used+int64(quota) > int64(claims.MaxQuota) {
+		return errors.New("临时令牌剩余额度不足")
+	}
+
+	return nil
+}
+
+// ReserveEphemeralTokenQuota 按预估额度占用临时令牌的剩余额度，剩余额度不足时返回错误
+// 并发请求各自占用，避免同时通过校验后超出上限，请求结束后由 RecordEphemeralTokenQuota 按实际消费修正
+func ReserveEphemeralTokenQuota(claims *common.EphemeralClaims, quota int) error {
+	if claims == nil || claims.MaxQuota <= 0 || quota <= 0 {
+		return nil
+	}
+
+	key := ephemeralUsageKey(claims, "quota")
+	used, err := usageCounters.Increase(key, int64(quota), claims.ExpiresAt+60)
+	if err != nil {
+		return err
+	}
+	if used > int64(claims.MaxQuota) {
+		usageCounters.Increase(key, -int64(quota), claims.ExpiresAt+60)
+		return errors.New("临时令牌剩余额度不足")
+	}
+
+	return nil
+}
+
+// RecordEphemeralTokenQuota 记录临时令牌的消费额度，quota 为相对已占用额度的差值，可以为负数
+func RecordEphemeralTokenQuota(claims *common.EphemeralClaims, quota int) {
+	if claims == nil || claims.MaxQuota <= 0 || quota == 0 {
+		return
+	}
+
+	usageCounters.Increase(ephemeralUsageKey(claims, "quota"), int64(quota), claims.ExpiresAt+60)
+}
+
+// ephemeralUsageKey 临时令牌的用量计数，保留到令牌过期后一分钟，覆盖过期时仍在进行中的请求
+func ephemeralUsageKey(claims *common.EphemeralClaims, name string) string {
+	return fmt.Sprintf("ephemeral_token:%s:%s", claims.Id, name)
+}
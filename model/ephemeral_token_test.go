@@ -0,0 +1,164 @@
+package model
+
+import (
+	"testing"
+
+	"done-hub/common"
+	"done-hub/common/config"
+	"done-hub/common/utils"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/datatypes"
+)
+
+func mintTestEphemeralToken(t *testing.T, token *Token, req *EphemeralTokenRequest) string {
+	key, _, err := MintEphemeralToken(token, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func TestEphemeralTokenInheritsParent(t *testing.T) {
+	setupTestDB(t, &User{}, &Token{})
+	user := createTestUser(t, 1)
+	token, _ := createTestToken(t, user.Id)
+
+	setting := TokenSetting{EndUser: EndUserSetting{RPM: 10, DailyQuota: 1000}}
+	token.Group = "vip"
+	token.Setting.JSONType = datatypes.NewJSONType(setting)
+	assert.NoError(t, token.Update())
+
+	key := mintTestEphemeralToken(t, token, &EphemeralTokenRequest{})
+	claims, parent, err := ValidateEphemeralToken(key)
+	assert.NoError(t, err)
+	assert.Equal(t, token.Id, claims.TokenId)
+	assert.Equal(t, token.Id, parent.Id)
+	assert.Equal(t, "vip", parent.Group)
+	assert.Equal(t, setting.EndUser, parent.Setting.Data().EndUser)
+}
+
+func TestEphemeralTokenParentUnavailable(t *testing.T) {
+	setupTestDB(t, &User{}, &Token{})
+	user := createTestUser(t, 1)
+
+	cases := map[string]func(token *Token){
+		"disabled": func(token *Token) {
+			token.Status = config.TokenStatusDisabled
+			assert.NoError(t, token.Update())
+		},
+		"expired": func(token *Token) {
+			token.ExpiredTime = utils.GetTimestamp() - 1
+			assert.NoError(t, token.Update())
+		},
+		"exhausted": func(token *Token) {
+			token.UnlimitedQuota = false
+			token.RemainQuota = 0
+			assert.NoError(t, token.Update())
+		},
+		"deleted": func(token *Token) {
+			assert.NoError(t, DeleteTokenById(token.Id, token.UserId))
+		},
+	}
+
+	for name, change := range cases {
+		token, _ := createTestToken(t, user.Id)
+		key := mintTestEphemeralToken(t, token, &EphemeralTokenRequest{})
+
+		_, _, err := ValidateEphemeralToken(key)
+		assert.NoError(t, err, name)
+
+		change(token)
+		_, _, err = ValidateEphemeralToken(key)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestEphemeralTokenOtherUser(t *testing.T) {
+	setupTestDB(t, &User{}, &Token{})
+	user := createTestUser(t, 1)
+	other := createTestUser(t, 2)
+	token, _ := createTestToken(t, user.Id)
+
+	// 签名有效但父令牌不属于该用户
+	claims := &common.EphemeralClaims{TokenId: token.Id, UserId: other.Id, ExpiresAt: utils.GetTimestamp() + 600}
+	key, err := common.SignEphemeralToken(claims)
+	assert.NoError(t, err)
+
+	_, _, err = ValidateEphemeralToken(key)
+	assert.ErrorIs(t, err, common.ErrEphemeralTokenInvalid)
+}
+
+func TestEphemeralTokenMaxRequests(t *testing.T) {
+	setupTestDB(t, &User{}, &Token{})
+	user := createTestUser(t, 1)
+	token, _ := createTestToken(t, user.Id)
+
+	key := mintTestEphemeralToken(t, token, &EphemeralTokenRequest{MaxRequests: 2})
+	for i := 0; i < 2; i++ {
+		_, _, err := ValidateEphemeralToken(key)
+		assert.NoError(t, err)
+	}
+
+	_, _, err := ValidateEphemeralToken(key)
+	assert.Error(t, err)
+}
+
+func TestEphemeralTokenGroupChanged(t *testing.T) {
+	setupTestDB(t, &User{}, &Token{})
+	user := createTestUser(t, 1)
+	token, _ := createTestToken(t, user.Id)
+
+	key := mintTestEphemeralToken(t, token, &EphemeralTokenRequest{})
+	claims, _, err := ValidateEphemeralToken(key)
+	assert.NoError(t, err)
+	assert.Equal(t, token.Group, claims.Group)
+
+	// 父令牌更换分组后，按原分组签发的临时令牌失效
+	token.Group = "vip"
+	assert.NoError(t, token.Update())
+	_, _, err = ValidateEphemeralToken(key)
+	assert.ErrorIs(t, err, common.ErrEphemeralTokenInvalid)
+}
+
+func TestEphemeralTokenReserveQuota(t *testing.T) {
+	setupTestDB(t, &User{}, &Token{})
+	user := createTestUser(t, 1)
+	token, _ := createTestToken(t, user.Id)
+
+	key := mintTestEphemeralToken(t, token, &EphemeralTokenRequest{MaxQuota: 1000})
+	claims, _, err := ValidateEphemeralToken(key)
+	assert.NoError(t, err)
+
+	// 预估额度超出剩余额度时拒绝，且不占用额度
+	assert.NoError(t, ReserveEphemeralTokenQuota(claims, 600))
+	assert.Error(t, ReserveEphemeralTokenQuota(claims, 600))
+	assert.NoError(t, ReserveEphemeralTokenQuota(claims, 400))
+
+	// 实际消费少于占用时退还差额
+	RecordEphemeralTokenQuota(claims, -500)
+	assert.NoError(t, ReserveEphemeralTokenQuota(claims, 500))
+
+	_, _, err = ValidateEphemeralToken(key)
+	assert.Error(t, err)
+}
+
+func TestEphemeralTokenCheckQuota(t *testing.T) {
+	setupTestDB(t, &User{}, &Token{})
+	user := createTestUser(t, 1)
+	token, _ := createTestToken(t, user.Id)
+
+	key := mintTestEphemeralToken(t, token, &EphemeralTokenRequest{MaxQuota: 1000})
+	claims, _, err := ValidateEphemeralToken(key)
+	assert.NoError(t, err)
+
+	assert.NoError(t, CheckEphemeralTokenQuota(claims, 1000))
+	assert.Error(t, CheckEphemeralTokenQuota(claims, 1001))
+
+	// 检查不占用额度
+	RecordEphemeralTokenQuota(claims, 400)
+	assert.NoError(t, CheckEphemeralTokenQuota(claims, 600))
+	assert.Error(t, CheckEphemeralTokenQuota(claims, 601))
+	assert.NoError(t, CheckEphemeralTokenQuota(nil, 10000))
+}
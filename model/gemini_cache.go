@@ -0,0 +1,82 @@
+package model
+
+import (
+	"done-hub/common/utils"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// GeminiCachedContent 记录 Gemini/VertexAI cachedContents 与创建渠道的绑定关系
+type GeminiCachedContent struct {
+	Id         int    `json:"id"`
+	CacheId    string `json:"cache_id" gorm:"type:varchar(100);index"`
+	Name       string `json:"name" gorm:"type:varchar(255)"` // 上游返回的完整名称
+	UserId     int    `json:"user_id" gorm:"index"`
+	TokenId    int    `json:"token_id" gorm:"default:0"`
+	ChannelId  int    `json:"channel_id" gorm:"index"`
+	Model      string `json:"model" gorm:"type:varchar(100)"`
+	TokenCount int    `json:"token_count" gorm:"default:0"`
+	ExpireTime int64  `json:"expire_time" gorm:"bigint;index"`
+	CreatedAt  int64  `json:"created_at" gorm:"bigint"`
+	UpdatedAt  int64  `json:"updated_at" gorm:"bigint"`
+}
+
+// GetGeminiCacheId 从 cachedContents/xxx 或 projects/.../cachedContents/xxx 中取出缓存 id
+func GetGeminiCacheId(name string) string {
+	name = strings.TrimSuffix(name, "/")
+	if idx := strings.LastIndex(name, "/"); idx >= 0 {
+		return name[idx+1:]
+	}
+
+	return name
+}
+
+func GetGeminiCachedContent(userId int, name string) (*GeminiCachedContent, error) {
+	cacheId := GetGeminiCacheId(name)
+	if cacheId == "" {
+		return nil, errors.New("缓存名称为空")
+	}
+
+	cache := &GeminiCachedContent{}
+	err := DB.Where("user_id = ? and cache_id = ?", userId, cacheId).First(cache).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("缓存不存在或已过期")
+	}
+
+	return cache, err
+}
+
+func GetUserGeminiCachedContents(userId int) ([]*GeminiCachedContent, error) {
+	var caches []*GeminiCachedContent
+	err := DB.Where("user_id = ? and expire_time > ?", userId, utils.GetTimestamp()).Order("id desc").Find(&caches).Error
+
+	return caches, err
+}
+
+func (cache *GeminiCachedContent) Insert() error {
+	cache.CacheId = GetGeminiCacheId(cache.Name)
+	cache.CreatedAt = utils.GetTimestamp()
+	cache.UpdatedAt = cache.CreatedAt
+
+	return DB.Create(cache).Error
+}
+
+func (cache *GeminiCachedContent) UpdateExpireTime(expireTime int64) error {
+	cache.ExpireTime = expireTime
+	cache.UpdatedAt = utils.GetTimestamp()
+
+	return DB.Model(cache).Select("expire_time", "updated_at").Updates(cache).Error
+}
+
+func (cache *GeminiCachedContent) Delete() error {
+	return DB.Delete(cache).Error
+}
+
+// DeleteExpiredGeminiCachedContents 清理已过期的缓存绑定记录
+func DeleteExpiredGeminiCachedContents() (int64, error) {
+	result := DB.Where("expire_time <= ?", utils.GetTimestamp()).Delete(&GeminiCachedContent{})
+
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,178 @@
+package model
+
+import (
+	"done-hub/common/utils"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+const (
+	GroupPriceTypeRatio = "ratio" // 使用指定倍率代替分组倍率
+	GroupPriceTypeFixed = "fixed" // 直接使用指定的输入输出价格，不再乘分组倍率
+)
+
+// GroupPrice 分组下单个模型的价格覆盖，未设置的模型仍按基础价格乘分组倍率计费
+type GroupPrice struct {
+	Id          int     `json:"id"`
+	Group       string  `json:"group" gorm:"type:varchar(50);uniqueIndex:idx_group_price_model"`
+	Model       string  `json:"model" gorm:"type:varchar(100);uniqueIndex:idx_group_price_model"`
+	Type        string  `json:"type" gorm:"type:varchar(20);default:'ratio'"`
+	Ratio       float64 `json:"ratio" gorm:"default:1"`
+	Input       float64 `json:"input" gorm:"default:0"`
+	Output      float64 `json:"output" gorm:"default:0"`
+	UpdatedTime int64   `json:"updated_time" gorm:"bigint"`
+}
+
+type BatchGroupPrices struct {
+	Group  string     `json:"group" binding:"required"`
+	Models []string   `json:"models" binding:"required"`
+	Price  GroupPrice `json:"price" binding:"required"`
+}
+
+func (price *GroupPrice) Validate() error {
+	switch price.Type {
+	case GroupPriceTypeRatio:
+		if price.Ratio < 0 {
+			return errors.New("倍率不能为负数")
+		}
+	case GroupPriceTypeFixed:
+		if price.Input < 0 || price.Output < 0 {
+			return errors.New("价格不能为负数")
+		}
+	default:
+		return errors.New("无效的价格类型")
+	}
+	return nil
+}
+
+func GetAllGroupPrices() ([]*GroupPrice, error) {
+	var prices []*GroupPrice
+	err := DB.Order("id").Find(&prices).Error
+	return prices, err
+}
+
+func GetGroupPrices(group string) ([]*GroupPrice, error) {
+	var prices []*GroupPrice
+	err := DB.Where(&GroupPrice{Group: group}).Order("model").Find(&prices).Error
+	return prices, err
+}
+
+// BatchSetGroupPrices 批量设置分组下的模型价格，originalModels 中不在 models 里的覆盖会被删除
+func (p *Pricing) BatchSetGroupPrices(batchPrices *BatchGroupPrices, originalModels []string) error {
+	if GlobalUserGroupRatio.GetBySymbol(batchPrices.Group) == nil {
+		return errors.New("分组不存在")
+	}
+	if err := batchPrices.Price.Validate(); err != nil {
+		return err
+	}
+
+	var deleteModels []string
+	for _, model := range originalModels {
+		if !utils.Contains(model, batchPrices.Models) {
+			deleteModels = append(deleteModels, model)
+		}
+	}
+
+	now := utils.GetTimestamp()
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if len(deleteModels) > 0 {
+			if err := deleteGroupPrices(tx, batchPrices.Group, deleteModels); err != nil {
+				return err
+			}
+		}
+
+		for _, model := range batchPrices.Models {
+			price := batchPrices.Price
+			price.Id = 0
+			price.Group = batchPrices.Group
+			price.Model = model
+			price.UpdatedTime = now
+
+			var existing GroupPrice
+			err := tx.Where(&GroupPrice{Group: price.Group, Model: price.Model}).Limit(1).Find(&existing).Error
+			if err != nil {
+				return err
+			}
+			if existing.Id > 0 {
+				price.Id = existing.Id
+				err = tx.Select("type", "ratio", "input", "output", "updated_time").Updates(&price).Error
+			} else {
+				err = tx.Create(&price).Error
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return p.Init()
+}
+
+// BatchDeleteGroupPrices 删除分组下的模型价格覆盖，恢复按分组倍率计费
+func (p *Pricing) BatchDeleteGroupPrices(group string, models []string) error {
+	if err := deleteGroupPrices(DB, group, models); err != nil {
+		return err
+	}
+
+	return p.Init()
+}
+
+func deleteGroupPrices(tx *gorm.DB, group string, models []string) error {
+	return tx.Where(&GroupPrice{Group: group}).Where("model IN (?)", models).Delete(&GroupPrice{}).Error
+}
+
+func (p *Pricing) loadGroupPrices() error {
+	prices, err := GetAllGroupPrices()
+	if err != nil {
+		return err
+	}
+
+	groupPrices := make(map[string]map[string]*GroupPrice)
+	for _, price := range prices {
+		if _, ok := groupPrices[price.Group]; !ok {
+			groupPrices[price.Group] = make(map[string]*GroupPrice)
+		}
+		groupPrices[price.Group][price.Model] = price
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	p.GroupPrices = groupPrices
+	return nil
+}
+
+// GetGroupPrice 返回分组下模型的价格覆盖，没有设置时返回 nil
+func (p *Pricing) GetGroupPrice(group string, modelName string) *GroupPrice {
+	p.RLock()
+	defer p.RUnlock()
+
+	if prices, ok := p.GroupPrices[group]; ok {
+		return prices[modelName]
+	}
+	return nil
+}
+
+// ResolveGroupPrice 返回模型在分组下实际使用的价格和倍率
+func (p *Pricing) ResolveGroupPrice(group string, modelName string, groupRatio float64) (*Price, float64, *GroupPrice) {
+	price := p.GetPrice(modelName)
+	groupPrice := p.GetGroupPrice(group, modelName)
+	if groupPrice == nil {
+		return price, groupRatio, nil
+	}
+
+	if groupPrice.Type == GroupPriceTypeRatio {
+		return price, groupPrice.Ratio, groupPrice
+	}
+
+	fixedPrice := *price
+	fixedPrice.Input = groupPrice.Input
+	fixedPrice.Output = groupPrice.Output
+	fixedPrice.Tiers = nil
+	return &fixedPrice, 1, groupPrice
+}
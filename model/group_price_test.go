@@ -0,0 +1,51 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/datatypes"
+)
+
+func TestResolveGroupPrice(t *testing.T) {
+	setupTestDB(t, &Price{}, &GroupPrice{}, &PriceWindow{})
+	tiers := datatypes.NewJSONType([]PriceTier{{Threshold: 200000, Input: 4, Output: 8}})
+	assert.NoError(t, DB.Create(&Price{Model: "gpt-4o", Type: TokensPriceType, Input: 2, Output: 4, Tiers: &tiers}).Error)
+	assert.NoError(t, DB.Create([]*GroupPrice{
+		{Group: "vip", Model: "gpt-4o", Type: GroupPriceTypeRatio, Ratio: 0.5},
+		{Group: "svip", Model: "gpt-4o", Type: GroupPriceTypeFixed, Input: 1, Output: 1.5},
+	}).Error)
+
+	pricing := &Pricing{}
+	assert.NoError(t, pricing.Init())
+
+	// 没有覆盖时使用基础价格和分组倍率
+	price, ratio, groupPrice := pricing.ResolveGroupPrice("default", "gpt-4o", 1.2)
+	assert.Equal(t, 2.0, price.Input)
+	assert.Equal(t, 1.2, ratio)
+	assert.Nil(t, groupPrice)
+
+	// 倍率覆盖代替分组倍率
+	price, ratio, groupPrice = pricing.ResolveGroupPrice("vip", "gpt-4o", 1.2)
+	assert.Equal(t, 2.0, price.Input)
+	assert.Equal(t, 0.5, ratio)
+	assert.Equal(t, GroupPriceTypeRatio, groupPrice.Type)
+
+	// 固定价格不再乘分组倍率，也不使用阶梯价格，且不修改基础价格
+	price, ratio, groupPrice = pricing.ResolveGroupPrice("svip", "gpt-4o", 1.2)
+	assert.Equal(t, 1.0, price.Input)
+	assert.Equal(t, 1.5, price.Output)
+	assert.Nil(t, price.Tiers)
+	assert.Equal(t, 1.0, ratio)
+	assert.Equal(t, GroupPriceTypeFixed, groupPrice.Type)
+	assert.Equal(t, 2.0, pricing.GetPrice("gpt-4o").Input)
+	assert.NotNil(t, pricing.GetPrice("gpt-4o").Tiers)
+}
+
+func TestGroupPriceValidate(t *testing.T) {
+	assert.NoError(t, (&GroupPrice{Type: GroupPriceTypeRatio, Ratio: 0}).Validate())
+	assert.NoError(t, (&GroupPrice{Type: GroupPriceTypeFixed, Input: 1, Output: 2}).Validate())
+	assert.Error(t, (&GroupPrice{Type: GroupPriceTypeRatio, Ratio: -1}).Validate())
+	assert.Error(t, (&GroupPrice{Type: GroupPriceTypeFixed, Input: -1}).Validate())
+	assert.Error(t, (&GroupPrice{Type: "discount"}).Validate())
+}
@@ -22,12 +22,16 @@ type Log struct {
 	TokenName        string                             `json:"token_name" gorm:"index;default:''"`
 	ModelName        string                             `json:"model_name" gorm:"index;index:index_username_model_name,priority:1;default:''"`
 	Quota            int                                `json:"quota" gorm:"default:0"`
+	CostQuota        int                                `json:"cost_quota" gorm:"default:0"` // 按渠道上游价格计算的成本
 	PromptTokens     int                                `json:"prompt_tokens" gorm:"default:0"`
 	CompletionTokens int                                `json:"completion_tokens" gorm:"default:0"`
 	ChannelId        int                                `json:"channel_id" gorm:"index"`
 	RequestTime      int                                `json:"request_time" gorm:"default:0"`
 	IsStream         bool                               `json:"is_stream" gorm:"default:false"`
 	SourceIp         string                             `json:"source_ip" gorm:"default:''"`
+	EndUser          string                             `json:"end_user" gorm:"type:varchar(64);index;default:''"`
+	OrganizationId   int                                `json:"organization_id" gorm:"index;default:0"`
+	GroupName        string                             `json:"group_name" gorm:"type:varchar(50);index;default:''"`
 	Metadata         datatypes.JSONType[map[string]any] `json:"metadata" gorm:"type:json"`
 
 	Channel *Channel `json:"channel" gorm:"foreignKey:Id;references:ChannelId"`
@@ -89,11 +93,15 @@ func RecordConsumeLog(
 	modelName string,
 	tokenName string,
 	quota int,
+	costQuota int,
 	content string,
 	requestTime int,
 	isStream bool,
 	metadata map[string]any,
-	sourceIp string) {
+	sourceIp string,
+	endUser string,
+	organizationId int,
+	groupName string) {
 	logger.LogInfo(ctx, fmt.Sprintf("record consume log: userId=%d, channelId=%d, promptTokens=%d, completionTokens=%d, modelName=%s, tokenName=%s, quota=%d, content=%s ,sourceIp=%s", userId, channelId, promptTokens, completionTokens, modelName, tokenName, quota, content, sourceIp))
 	if !config.LogConsumeEnabled {
 		return
@@ -112,10 +120,14 @@ func RecordConsumeLog(
 		TokenName:        tokenName,
 		ModelName:        modelName,
 		Quota:            quota,
+		CostQuota:        costQuota,
 		ChannelId:        channelId,
 		RequestTime:      requestTime,
 		IsStream:         isStream,
 		SourceIp:         sourceIp,
+		EndUser:          endUser,
+		OrganizationId:   organizationId,
+		GroupName:        groupName,
 	}
 
 	if metadata != nil {
@@ -138,6 +150,7 @@ type LogsListParams struct {
 	TokenName      string `form:"token_name"`
 	ChannelId      int    `form:"channel_id"`
 	SourceIp       string `form:"source_ip"`
+	EndUser        string `form:"end_user"`
 }
 
 var allowedLogsOrderFields = map[string]bool{
@@ -182,6 +195,9 @@ func GetLogsList(params *LogsListParams) (*DataResult[Log], error) {
 	if params.SourceIp != "" {
 		tx = tx.Where("source_ip = ?", params.SourceIp)
 	}
+	if params.EndUser != "" {
+		tx = tx.Where("end_user = ?", params.EndUser)
+	}
 
 	return PaginateAndOrder[Log](tx, &params.PaginationParams, &logs, allowedLogsOrderFields)
 }
@@ -206,6 +222,9 @@ func GetUserLogsList(userId int, params *LogsListParams) (*DataResult[Log], erro
 	if params.EndTimestamp != 0 {
 		tx = tx.Where("created_at <= ?", params.EndTimestamp)
 	}
+	if params.EndUser != "" {
+		tx = tx.Where("end_user = ?", params.EndUser)
+	}
 
 	return PaginateAndOrder[Log](tx, &params.PaginationParams, &logs, allowedLogsOrderFields)
 }
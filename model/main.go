@@ -27,6 +27,7 @@ func SetupDB() {
 	}
 	ChannelGroup.Load()
 	GlobalUserGroupRatio.Load()
+	GlobalRoles.Load()
 	config.RootUserEmail = GetRootUserEmail()
 	NewModelOwnedBys()
 
@@ -152,6 +153,18 @@ func InitDB() (err error) {
 		if err != nil {
 			return err
 		}
+		err = db.AutoMigrate(&GroupPrice{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&PriceWindow{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&ChannelBalanceSnapshot{})
+		if err != nil {
+			return err
+		}
 		err = db.AutoMigrate(&Midjourney{})
 		if err != nil {
 			return err
@@ -184,6 +197,91 @@ func InitDB() (err error) {
 			return err
 		}
 
+		err = db.AutoMigrate(&GeminiCachedContent{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&ClaudeObject{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&WebhookDelivery{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&MediaObject{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&AdminToken{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&Role{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&AuditLog{}, &AuditChainHead{})
+		if err != nil {
+			return err
+		}
+
+		err = initAuditChainHead(db)
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&TwoFactor{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&Passkey{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&UserSession{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&QuotaLot{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&SubscriptionPlan{}, &UserSubscription{}, &SubscriptionAllowanceUsage{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&OrderRefund{}, &PaymentMismatch{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&AffiliateAccount{}, &AffiliateCommission{}, &AffiliateWithdrawal{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&RedemptionCampaign{}, &RedemptionUsage{})
+		if err != nil {
+			return err
+		}
+
+		err = db.AutoMigrate(&Organization{}, &OrganizationMember{}, &OrganizationInvitation{})
+		if err != nil {
+			return err
+		}
+
 		if config.UserInvoiceMonth {
 			err = db.AutoMigrate(&StatisticsMonthGeneratedHistory{})
 			if err != nil {
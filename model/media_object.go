@@ -0,0 +1,39 @@
+package model
+
+import (
+	"done-hub/common/utils"
+)
+
+// MediaObject 转存到存储中的生成媒体，用于到期清理
+type MediaObject struct {
+	Id        int    `json:"id"`
+	UserId    int    `json:"user_id" gorm:"index"`
+	TokenId   int    `json:"token_id" gorm:"default:0"`
+	Platform  string `json:"platform" gorm:"type:varchar(30);index"`
+	TaskId    string `json:"task_id" gorm:"type:varchar(100);index"`
+	Drive     string `json:"drive" gorm:"type:varchar(30)"`
+	Key       string `json:"key" gorm:"type:varchar(512)"`
+	URL       string `json:"url" gorm:"type:varchar(1024)"`
+	SourceURL string `json:"source_url" gorm:"type:varchar(1024)"`
+	ExpireAt  int64  `json:"expire_at" gorm:"bigint;index"` // 0 为永久保留
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+}
+
+// GetExpiredMediaObjects 获取已到期的媒体对象
+func GetExpiredMediaObjects(limit int) ([]*MediaObject, error) {
+	var objects []*MediaObject
+	err := DB.Where("expire_at > 0 and expire_at <= ?", utils.GetTimestamp()).
+		Order("id").Limit(limit).Find(&objects).Error
+
+	return objects, err
+}
+
+func (object *MediaObject) Insert() error {
+	object.CreatedAt = utils.GetTimestamp()
+
+	return DB.Create(object).Error
+}
+
+func (object *MediaObject) Delete() error {
+	return DB.Delete(object).Error
+}
@@ -46,6 +46,8 @@ type Midjourney struct {
 	Properties  string `json:"properties"`
 	Mode        string `json:"mode,omitempty"`
 	TokenID     int    `json:"token_id" gorm:"default:0"`
+	NotifyHook  string `json:"notify_hook"`
+	StorageKeys string `json:"storage_keys" gorm:"type:text"`
 }
 
 // TaskQueryParams 用于包含所有搜索条件的结构体，可以根据需求添加更多字段
@@ -118,6 +120,11 @@ func GetAllMJTasks(params *MJTaskQueryParams) (*DataResult[Midjourney], error) {
 	return PaginateAndOrder(query, &params.PaginationParams, &tasks, allowedMidjourneyOrderFields)
 }
 
+// IsFinished 任务是否已处于终态
+func (midjourney *Midjourney) IsFinished() bool {
+	return midjourney.Status == "SUCCESS" || midjourney.Status == "FAILURE"
+}
+
 func GetAllUnFinishTasks() []*Midjourney {
 	var tasks []*Midjourney
 	// get all tasks progress is not 100%
@@ -163,6 +170,14 @@ func (midjourney *Midjourney) Update() error {
 	return DB.Save(midjourney).Error
 }
 
+// UpdateMidjourneyStorage 后台转存完成后只更新图片地址和转存对象
+func UpdateMidjourneyStorage(id int, imageUrl, storageKeys string) error {
+	return DB.Model(&Midjourney{}).Where("id = ?", id).Updates(map[string]any{
+		"image_url":    imageUrl,
+		"storage_keys": storageKeys,
+	}).Error
+}
+
 func MjBulkUpdate(mjIds []string, params map[string]any) error {
 	return DB.Model(&Midjourney{}).
 		Where("mj_id in (?)", mjIds).
@@ -33,6 +33,8 @@ func InitOptionMap() {
 	config.GlobalOption.RegisterBool("WeChatAuthEnabled", &config.WeChatAuthEnabled)
 	config.GlobalOption.RegisterBool("LarkAuthEnabled", &config.LarkAuthEnabled)
 	config.GlobalOption.RegisterBool("OIDCAuthEnabled", &config.OIDCAuthEnabled)
+	config.GlobalOption.RegisterBool("PasskeyLoginEnabled", &config.PasskeyLoginEnabled)
+	config.GlobalOption.RegisterBool("TwoFactorRequiredForAdmin", &config.TwoFactorRequiredForAdmin)
 	config.GlobalOption.RegisterBool("TurnstileCheckEnabled", &config.TurnstileCheckEnabled)
 	config.GlobalOption.RegisterBool("RegisterEnabled", &config.RegisterEnabled)
 	config.GlobalOption.RegisterBool("AutomaticDisableChannelEnabled", &config.AutomaticDisableChannelEnabled)
@@ -96,6 +98,24 @@ func InitOptionMap() {
 	config.GlobalOption.RegisterString("ChatImageRequestProxy", &config.ChatImageRequestProxy)
 	config.GlobalOption.RegisterFloat("PaymentUSDRate", &config.PaymentUSDRate)
 	config.GlobalOption.RegisterInt("PaymentMinAmount", &config.PaymentMinAmount)
+	config.GlobalOption.RegisterInt("PaymentQuotaValidDays", &config.PaymentQuotaValidDays)
+	config.GlobalOption.RegisterString("PaymentRefundClawback", &config.PaymentRefundClawback)
+	config.GlobalOption.RegisterBool("SubscriptionEnabled", &config.SubscriptionEnabled)
+	config.GlobalOption.RegisterInt("SubscriptionRemindDays", &config.SubscriptionRemindDays)
+	config.GlobalOption.RegisterInt("SubscriptionGraceDays", &config.SubscriptionGraceDays)
+
+	config.GlobalOption.RegisterBool("AffiliateEnabled", &config.AffiliateEnabled)
+	config.GlobalOption.RegisterInt("AffiliateCommissionMonths", &config.AffiliateCommissionMonths)
+	config.GlobalOption.RegisterFloat("AffiliateTier1Rate", &config.AffiliateTier1Rate)
+	config.GlobalOption.RegisterFloat("AffiliateTier2Rate", &config.AffiliateTier2Rate)
+	config.GlobalOption.RegisterInt("AffiliateSettleDays", &config.AffiliateSettleDays)
+	config.GlobalOption.RegisterInt("AffiliateMinWithdrawQuota", &config.AffiliateMinWithdrawQuota)
+	config.GlobalOption.RegisterBool("AffiliateFraudCheck", &config.AffiliateFraudCheck)
+	config.GlobalOption.RegisterCustom("AffiliateGroupRates", func() string {
+		return AffiliateGroupRates2JSONString()
+	}, func(value string) error {
+		return UpdateAffiliateGroupRatesByJSONString(value)
+	}, "")
 
 	config.GlobalOption.RegisterCustom("RechargeDiscount", func() string {
 		return common.RechargeDiscount2JSONString()
@@ -112,6 +132,8 @@ func InitOptionMap() {
 
 	config.GlobalOption.RegisterBool("GeminiAPIEnabled", &config.GeminiAPIEnabled)
 	config.GlobalOption.RegisterBool("ClaudeAPIEnabled", &config.ClaudeAPIEnabled)
+	config.GlobalOption.RegisterFloat("ClaudeBatchDiscount", &config.ClaudeBatchDiscount)
+	config.GlobalOption.RegisterInt("MediaStorageRetentionDays", &config.MediaStorageRetentionDays)
 
 	config.GlobalOption.RegisterCustom("DisableChannelKeywords", func() string {
 		return common.DisableChannelKeywordsInstance.GetKeywords()
@@ -9,28 +9,34 @@ import (
 type OrderStatus string
 
 const (
-	OrderStatusPending OrderStatus = "pending"
-	OrderStatusSuccess OrderStatus = "success"
-	OrderStatusFailed  OrderStatus = "failed"
-	OrderStatusClosed  OrderStatus = "closed"
+	OrderStatusPending  OrderStatus = "pending"
+	OrderStatusSuccess  OrderStatus = "success"
+	OrderStatusFailed   OrderStatus = "failed"
+	OrderStatusClosed   OrderStatus = "closed"
+	OrderStatusRefunded OrderStatus = "refunded"
 )
 
 type Order struct {
-	ID            int            `json:"id"`
-	UserId        int            `json:"user_id"`
-	GatewayId     int            `json:"gateway_id"`
-	TradeNo       string         `json:"trade_no" gorm:"type:varchar(50);uniqueIndex"`
-	GatewayNo     string         `json:"gateway_no" gorm:"type:varchar(100)"`
-	Amount        int            `json:"amount" gorm:"default:0"`
-	OrderAmount   float64        `json:"order_amount" gorm:"type:decimal(10,2);default:0"`
-	OrderCurrency CurrencyType   `json:"order_currency" gorm:"type:varchar(16)"`
-	Quota         int            `json:"quota" gorm:"type:int;default:0"`
-	Fee           float64        `json:"fee" gorm:"type:decimal(10,2);default:0"`
-	Discount      float64        `json:"discount" gorm:"type:decimal(10,2);default:0"`
-	Status        OrderStatus    `json:"status" gorm:"type:varchar(32)"`
-	CreatedAt     int            `json:"created_at"`
-	UpdatedAt     int            `json:"-"`
-	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
+	ID             int            `json:"id"`
+	UserId         int            `json:"user_id"`
+	GatewayId      int            `json:"gateway_id"`
+	TradeNo        string         `json:"trade_no" gorm:"type:varchar(50);uniqueIndex"`
+	GatewayNo      string         `json:"gateway_no" gorm:"type:varchar(100)"`
+	Amount         int            `json:"amount" gorm:"default:0"`
+	OrderAmount    float64        `json:"order_amount" gorm:"type:decimal(10,2);default:0"`
+	OrderCurrency  CurrencyType   `json:"order_currency" gorm:"type:varchar(16)"`
+	Quota          int            `json:"quota" gorm:"type:int;default:0"`
+	QuotaValidDays int            `json:"quota_valid_days" gorm:"default:0"`                   // 到账额度的有效天数，下单时确定，0 表示永久有效
+	PlanId         int            `json:"plan_id" gorm:"default:0"`                            // 订阅套餐订单的套餐 ID，为 0 时是充值订单
+	PlanMonths     int            `json:"plan_months" gorm:"default:0"`                        // 订阅的周期数
+	RefundedAmount float64        `json:"refunded_amount" gorm:"type:decimal(10,2);default:0"` // 已退款金额，与 OrderAmount 同币种
+	RefundedQuota  int            `json:"refunded_quota" gorm:"default:0"`                     // 退款时已扣回的额度
+	Fee            float64        `json:"fee" gorm:"type:decimal(10,2);default:0"`
+	Discount       float64        `json:"discount" gorm:"type:decimal(10,2);default:0"`
+	Status         OrderStatus    `json:"status" gorm:"type:varchar(32)"`
+	CreatedAt      int            `json:"created_at"`
+	UpdatedAt      int            `json:"-"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
 // 查询并关闭未完成的订单
@@ -52,6 +58,37 @@ func GetUserOrder(userId int, tradeNo string) (*Order, error) {
 	return &order, err
 }
 
+// MarkOrderPaid 将待支付或已关闭的订单标记为支付成功，重复回调时返回 false，保证只入账一次
+// 已关闭的订单仍可能在关闭后完成支付，同样需要入账
+func MarkOrderPaid(order *Order, gatewayNo string) (bool, error) {
+	result := DB.Model(&Order{}).Where("id = ? AND status IN ?", order.ID, []OrderStatus{OrderStatusPending, OrderStatusClosed}).Updates(map[string]any{
+		"status":     OrderStatusSuccess,
+		"gateway_no": gatewayNo,
+	})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+
+	order.Status = OrderStatusSuccess
+	order.GatewayNo = gatewayNo
+	return true, nil
+}
+
+// GetReconcileOrders 需要对账的订单，包含支付成功、已退款以及未入账的订单
+func GetReconcileOrders(start, end int64) ([]*Order, error) {
+	var orders []*Order
+	err := DB.Where("created_at >= ? AND created_at < ? AND status IN ?", start, end,
+		[]OrderStatus{OrderStatusPending, OrderStatusClosed, OrderStatusSuccess, OrderStatusRefunded}).
+		Order("id").Find(&orders).Error
+	return orders, err
+}
+
+func GetOrderById(id int) (*Order, error) {
+	var order Order
+	err := DB.First(&order, id).Error
+	return &order, err
+}
+
 func (o *Order) Insert() error {
 	return DB.Create(o).Error
 }
@@ -0,0 +1,230 @@
+package model
+
+import (
+	"done-hub/common/utils"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+const (
+	OrderRefundStatusPending    = "pending"
+	OrderRefundStatusProcessing = "processing"
+	OrderRefundStatusSuccess    = "success"
+	OrderRefundStatusFailed     = "failed"
+)
+
+// 退款扣回额度的方式
+const (
+	RefundClawbackNone      = "none"      // 不扣回
+	RefundClawbackAvailable = "available" // 按退款比例扣回，最多扣到余额为 0
+	RefundClawbackNegative  = "negative"  // 按退款比例扣回，余额不足时允许为负
+)
+
+// refundAmountEpsilon 金额比较的误差，订单金额精确到分
+const refundAmountEpsilon = 0.001
+
+// OrderRefund 订单退款记录，一个订单可以多次部分退款
+type OrderRefund struct {
+	Id              int     `json:"id"`
+	OrderId         int     `json:"order_id" gorm:"index"`
+	UserId          int     `json:"user_id" gorm:"index"`
+	TradeNo         string  `json:"trade_no" gorm:"type:varchar(50);index"`
+	RefundNo        string  `json:"refund_no" gorm:"type:varchar(50);uniqueIndex"`
+	GatewayRefundNo string  `json:"gateway_refund_no" gorm:"type:varchar(100);default:''"`
+	Money           float64 `json:"money" gorm:"type:decimal(10,2);default:0"`
+	Quota           int     `json:"quota" gorm:"default:0"` // 实际扣回的额度
+	// 退款时计算的待扣回额度，网关处理中的退款在确认完成后才扣回
+	ClawbackQuota    int    `json:"clawback_quota" gorm:"default:0"`
+	ClawbackNegative bool   `json:"clawback_negative" gorm:"default:false"`
+	Status           string `json:"status" gorm:"type:varchar(16)"`
+	Reason           string `json:"reason" gorm:"type:varchar(255);default:''"`
+	Message          string `json:"message" gorm:"type:varchar(255);default:''"` // 网关返回的失败原因
+	OperatorId       int    `json:"operator_id" gorm:"default:0"`
+	CreatedAt        int64  `json:"created_at" gorm:"bigint"`
+	UpdatedAt        int64  `json:"updated_at" gorm:"bigint"`
+}
+
+func GetOrderRefundById(id int) (*OrderRefund, error) {
+	var refund OrderRefund
+	err := DB.First(&refund, id).Error
+	return &refund, err
+}
+
+// GetProcessingOrderRefunds 网关已受理但尚未完成的退款
+func GetProcessingOrderRefunds() ([]*OrderRefund, error) {
+	var refunds []*OrderRefund
+	err := DB.Where("status = ?", OrderRefundStatusProcessing).Order("id").Find(&refunds).Error
+	return refunds, err
+}
+
+func GetOrderRefunds(orderId int) ([]*OrderRefund, error) {
+	var refunds []*OrderRefund
+	err := DB.Where("order_id = ?", orderId).Order("id desc").Find(&refunds).Error
+	return refunds, err
+}
+
+// CreateOrderRefund 预占订单的可退金额并创建退款记录，防止并发退款超过订单金额
+func CreateOrderRefund(order *Order, refund *OrderRefund) error {
+	now := utils.GetTimestamp()
+	refund.OrderId = order.ID
+	refund.UserId = order.UserId
+	refund.TradeNo = order.TradeNo
+	refund.Status = OrderRefundStatusPending
+	refund.Reason = truncateString(refund.Reason, 255)
+	refund.CreatedAt = now
+	refund.UpdatedAt = now
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Order{}).
+			Where("id = ? AND status = ? AND refunded_amount + ? <= order_amount + ?", order.ID, OrderStatusSuccess, refund.Money, refundAmountEpsilon).
+			Update("refunded_amount", gorm.Expr("refunded_amount + ?", refund.Money))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("订单不可退款或退款金额超过可退金额")
+		}
+
+		return tx.Create(refund).Error
+	})
+}
+
+// ProcessOrderRefund 网关已受理但退款尚未完成，记录待扣回的额度，确认完成后再扣回
+func ProcessOrderRefund(refund *OrderRefund) error {
+	refund.Status = OrderRefundStatusProcessing
+	refund.UpdatedAt = utils.GetTimestamp()
+
+	return DB.Model(&OrderRefund{}).Where("id = ? AND status = ?", refund.Id, OrderRefundStatusPending).Updates(map[string]any{
+		"status":            refund.Status,
+		"gateway_refund_no": refund.GatewayRefundNo,
+		"clawback_quota":    refund.ClawbackQuota,
+		"clawback_negative": refund.ClawbackNegative,
+		"updated_at":        refund.UpdatedAt,
+	}).Error
+}
+
+// ClaimOrderRefund 将退款从 from 状态标记为成功，已被其他请求处理时返回 false，保证额度只扣回一次
+func ClaimOrderRefund(refund *OrderRefund, from string) (bool, error) {
+	result := DB.Model(&OrderRefund{}).Where("id = ? AND status = ?", refund.Id, from).Updates(map[string]any{
+		"status":     OrderRefundStatusSuccess,
+		"updated_at": utils.GetTimestamp(),
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	refund.Status = OrderRefundStatusSuccess
+	return result.RowsAffected > 0, nil
+}
+
+// FailOrderRefund 网关退款失败，释放预占的退款金额，只处理未完成的退款
+func FailOrderRefund(refund *OrderRefund, message string) error {
+	refund.Status = OrderRefundStatusFailed
+	refund.Message = truncateString(message, 255)
+	refund.UpdatedAt = utils.GetTimestamp()
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&OrderRefund{}).
+			Where("id = ? AND status IN (?)", refund.Id, []string{OrderRefundStatusPending, OrderRefundStatusProcessing}).
+			Updates(map[string]any{
+				"status":     refund.Status,
+				"message":    refund.Message,
+				"updated_at": refund.UpdatedAt,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("退款已处理")
+		}
+
+		return tx.Model(&Order{}).Where("id = ?", refund.OrderId).
+			Update("refunded_amount", gorm.Expr("refunded_amount - ?", refund.Money)).Error
+	})
+}
+
+// CompleteOrderRefund 退款已完成，记录扣回的额度，全额退款后订单状态变为已退款
+func CompleteOrderRefund(refund *OrderRefund) error {
+	refund.UpdatedAt = utils.GetTimestamp()
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&OrderRefund{}).Where("id = ?", refund.Id).Updates(map[string]any{
+			"status":            refund.Status,
+			"gateway_refund_no": refund.GatewayRefundNo,
+			"quota":             refund.Quota,
+			"updated_at":        refund.UpdatedAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&Order{}).Where("id = ?", refund.OrderId).
+			Update("refunded_quota", gorm.Expr("refunded_quota + ?", refund.Quota)).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&Order{}).
+			Where("id = ? AND status = ? AND refunded_amount >= order_amount - ?", refund.OrderId, OrderStatusSuccess, refundAmountEpsilon).
+			Update("status", OrderStatusRefunded).Error
+	})
+}
+
+// ClawbackOrderQuota 退款时扣回订单发放的额度，优先扣减该订单的额度批次，不足部分按正常消耗顺序从其他批次扣减
+// allowNegative 为 false 时最多扣到余额为 0，返回实际扣回的额度
+func ClawbackOrderQuota(userId int, tradeNo string, quota int, allowNegative bool) (int, error) {
+	if quota <= 0 {
+		return 0, nil
+	}
+
+	clawed := 0
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var balance int
+		if err := tx.Model(&User{}).Where("id = ?", userId).Select("quota").Find(&balance).Error; err != nil {
+			return err
+		}
+
+		clawed = quota
+		if !allowNegative {
+			clawed = max(min(quota, balance), 0)
+		}
+		if clawed == 0 {
+			return nil
+		}
+
+		left := clawed
+		if tradeNo != "" {
+			var lot QuotaLot
+			err := tx.Where("user_id = ? AND source = ? AND source_id = ? AND status = ? AND remaining > 0", userId, QuotaLotSourceTopup, tradeNo, QuotaLotStatusActive).
+				Limit(1).Find(&lot).Error
+			if err != nil {
+				return err
+			}
+
+			if lot.Id > 0 {
+				change := min(left, lot.Remaining)
+				err = tx.Model(&QuotaLot{}).Where("id = ?", lot.Id).Updates(map[string]any{
+					"remaining":    gorm.Expr("remaining - ?", change),
+					"updated_time": utils.GetTimestamp(),
+				}).Error
+				if err != nil {
+					return err
+				}
+				left -= change
+			}
+		}
+
+		if err := adjustQuotaLots(tx, userId, -left); err != nil {
+			return err
+		}
+
+		return tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota - ?", clawed)).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	clearUserQuotaCache(userId)
+	return clawed, nil
+}
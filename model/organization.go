@@ -0,0 +1,742 @@
+package model
+
+import (
+	"done-hub/common"
+	"done-hub/common/config"
+	"done-hub/common/redis"
+	"done-hub/common/utils"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	OrganizationRoleOwner     = "owner"     // 创建者，拥有全部权限
+	OrganizationRoleAdmin     = "admin"     // 管理成员和令牌
+	OrganizationRoleDeveloper = "developer" // 创建令牌，使用组织额度
+	OrganizationRoleBilling   = "billing"   // 充值和查看账单
+)
+
+const (
+	OrganizationStatusEnabled  = 1
+	OrganizationStatusDisabled = 2
+)
+
+const QuotaLotSourceOrganization = "organization"
+
+// OrganizationInvitationExpiration 邀请的有效期（秒）
+const OrganizationInvitationExpiration = 7 * 24 * 60 * 60
+
+var (
+	ErrOrganizationNotFound       = errors.New("组织不存在")
+	ErrOrganizationPermission     = errors.New("没有权限执行该操作")
+	ErrOrganizationQuotaNotEnough = errors.New("组织额度不足")
+	ErrOrganizationMemberLimit    = errors.New("成员额度已达上限")
+
+	ErrOrganizationInvitationNotFound = errors.New("邀请不存在或已过期")
+)
+
+// Organization 组织，拥有共享的额度池，组织令牌的消费从组织额度中扣除
+type Organization struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"type:varchar(50)"`
+	OwnerId     int    `json:"owner_id" gorm:"index"`
+	Quota       int    `json:"quota" gorm:"default:0"`
+	UsedQuota   int    `json:"used_quota" gorm:"default:0"`
+	Status      int    `json:"status" gorm:"default:1"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64  `json:"updated_time" gorm:"bigint"`
+}
+
+// OrganizationMember 组织成员，QuotaLimit 为成员可使用的组织额度上限，0 表示不限
+type OrganizationMember struct {
+	Id             int    `json:"id"`
+	OrganizationId int    `json:"organization_id" gorm:"uniqueIndex:idx_organization_member"`
+	UserId         int    `json:"user_id" gorm:"uniqueIndex:idx_organization_member;index"`
+	Role           string `json:"role" gorm:"type:varchar(16)"`
+	QuotaLimit     int    `json:"quota_limit" gorm:"default:0"`
+	UsedQuota      int    `json:"used_quota" gorm:"default:0"`
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+
+	Username string `json:"username" gorm:"-:all"`
+}
+
+// OrganizationInvitation 组织邀请，被邀请的用户接受后才成为成员
+type OrganizationInvitation struct {
+	Id             int    `json:"id"`
+	OrganizationId int    `json:"organization_id" gorm:"uniqueIndex:idx_organization_invitation"`
+	UserId         int    `json:"user_id" gorm:"uniqueIndex:idx_organization_invitation;index"`
+	InviterId      int    `json:"inviter_id"`
+	Role           string `json:"role" gorm:"type:varchar(16)"`
+	QuotaLimit     int    `json:"quota_limit" gorm:"default:0"`
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+	ExpiredTime    int64  `json:"expired_time" gorm:"bigint"`
+
+	Username         string `json:"username,omitempty" gorm:"-:all"`
+	OrganizationName string `json:"organization_name,omitempty" gorm:"->;-:migration"`
+}
+
+// UserOrganization 用户所在的组织及其角色
+type UserOrganization struct {
+	Organization
+	Role       string `json:"role"`
+	QuotaLimit int    `json:"quota_limit"`
+	MemberUsed int    `json:"member_used_quota"`
+}
+
+var allowedOrganizationOrderFields = map[string]bool{
+	"id":           true,
+	"name":         true,
+	"quota":        true,
+	"used_quota":   true,
+	"created_time": true,
+}
+
+// OrganizationRoleCan 角色是否拥有指定操作的权限
+func OrganizationRoleCan(role string, action string) bool {
+	switch action {
+	case "manage":
+		return role == OrganizationRoleOwner || role == OrganizationRoleAdmin
+	case "token":
+		return role == OrganizationRoleOwner || role == OrganizationRoleAdmin || role == OrganizationRoleDeveloper
+	case "billing":
+		return role == OrganizationRoleOwner || role == OrganizationRoleAdmin || role == OrganizationRoleBilling
+	}
+	return false
+}
+
+func isValidOrganizationRole(role string) bool {
+	switch role {
+	case OrganizationRoleAdmin, OrganizationRoleDeveloper, OrganizationRoleBilling:
+		return true
+	}
+	return false
+}
+
+func GetOrganizationsList(params *GenericParams) (*DataResult[Organization], error) {
+	var organizations []*Organization
+	db := DB
+	if params.Keyword != "" {
+		db = db.Where("id = ? or name LIKE ?", utils.String2Int(params.Keyword), params.Keyword+"%")
+	}
+
+	return PaginateAndOrder(db, &params.PaginationParams, &organizations, allowedOrganizationOrderFields)
+}
+
+func GetOrganizationById(id int) (*Organization, error) {
+	var organization Organization
+	if err := DB.First(&organization, id).Error; err != nil {
+		return nil, ErrOrganizationNotFound
+	}
+	return &organization, nil
+}
+
+func GetUserOrganizations(userId int) ([]*UserOrganization, error) {
+	var organizations []*UserOrganization
+	err := DB.Table("organizations").
+		Select("organizations.*, organization_members.role, organization_members.quota_limit, organization_members.used_quota as member_used").
+		Joins("JOIN organization_members ON organization_members.organization_id = organizations.id").
+		Where("organization_members.user_id = ?", userId).
+		Order("organizations.id").
+		Scan(&organizations).Error
+	return organizations, err
+}
+
+// GetOrganizationMember 用户在组织中的成员记录，不是成员时返回错误
+func GetOrganizationMember(organizationId int, userId int) (*OrganizationMember, error) {
+	var member OrganizationMember
+	err := DB.Where("organization_id = ? AND user_id = ?", organizationId, userId).First(&member).Error
+	if err != nil {
+		return nil, ErrOrganizationNotFound
+	}
+	return &member, nil
+}
+
+func CreateOrganization(name string, ownerId int) (*Organization, error) {
+	if name == "" || len(name) > 50 {
+		return nil, errors.New("组织名称长度必须在1-50之间")
+	}
+
+	now := utils.GetTimestamp()
+	organization := &Organization{
+		Name:        name,
+		OwnerId:     ownerId,
+		Status:      OrganizationStatusEnabled,
+		CreatedTime: now,
+		UpdatedTime: now,
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(organization).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OrganizationMember{
+			OrganizationId: organization.Id,
+			UserId:         ownerId,
+			Role:           OrganizationRoleOwner,
+			CreatedTime:    now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return organization, nil
+}
+
+func UpdateOrganizationName(id int, name string) error {
+	if name == "" || len(name) > 50 {
+		return errors.New("组织名称长度必须在1-50之间")
+	}
+	return DB.Model(&Organization{}).Where("id = ?", id).Updates(map[string]any{
+		"name":         name,
+		"updated_time": utils.GetTimestamp(),
+	}).Error
+}
+
+// DeleteOrganization 删除组织，剩余额度退回给创建者，组织令牌一并删除
+func DeleteOrganization(id int) error {
+	organization, err := GetOrganizationById(id)
+	if err != nil {
+		return err
+	}
+
+	var tokens []*Token
+	if err := DB.Where("organization_id = ?", id).Find(&tokens).Error; err != nil {
+		return err
+	}
+	var memberIds []int
+	if err := DB.Model(&OrganizationMember{}).Where("organization_id = ?", id).Pluck("user_id", &memberIds).Error; err != nil {
+		return err
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		// 以删除时读到的额度为准，防止并发扣费后退回多余的额度
+		result := tx.Where("id = ? AND quota = ?", id, organization.Quota).Delete(&Organization{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("组织额度发生变化，请重试")
+		}
+		if err := tx.Where("organization_id = ?", id).Delete(&OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", id).Delete(&OrganizationInvitation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", id).Delete(&Token{}).Error; err != nil {
+			return err
+		}
+		if organization.Quota <= 0 {
+			return nil
+		}
+		return grantQuotaLot(tx, organization.OwnerId, organization.Quota, QuotaLotSourceOrganization, fmt.Sprintf("%d", id), 0, "组织解散退回额度")
+	})
+	if err != nil {
+		return err
+	}
+
+	clearOrganizationTokensCache(tokens)
+	clearOrganizationQuotaCache(id, memberIds...)
+	if organization.Quota > 0 {
+		clearUserQuotaCache(organization.OwnerId)
+		RecordLog(organization.OwnerId, LogTypeSystem, fmt.Sprintf("组织 %s 已解散，退回额度 %s", organization.Name, common.LogQuota(organization.Quota)))
+	}
+	return nil
+}
+
+// UpdateOrganizationStatus 管理员启用或停用组织，停用后组织令牌无法继续消费
+func UpdateOrganizationStatus(id int, status int) error {
+	if status != OrganizationStatusEnabled && status != OrganizationStatusDisabled {
+		return errors.New("无效的状态")
+	}
+	err := DB.Model(&Organization{}).Where("id = ?", id).Updates(map[string]any{
+		"status":       status,
+		"updated_time": utils.GetTimestamp(),
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	clearOrganizationQuotaCache(id)
+	return nil
+}
+
+func GetOrganizationMembers(organizationId int) ([]*OrganizationMember, error) {
+	var members []*OrganizationMember
+	if err := DB.Where("organization_id = ?", organizationId).Order("id").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		member.Username, _ = CacheGetUsername(member.UserId)
+	}
+	return members, nil
+}
+
+// InviteOrganizationMember 邀请用户加入组织，重复邀请时更新角色、额度上限和有效期
+func InviteOrganizationMember(organizationId int, inviterId int, username string, role string, quotaLimit int) (*OrganizationInvitation, error) {
+	if !isValidOrganizationRole(role) {
+		return nil, errors.New("无效的角色")
+	}
+	if quotaLimit < 0 {
+		return nil, errors.New("额度上限不能为负数")
+	}
+
+	var user User
+	if err := DB.Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, errors.New("用户不存在")
+	}
+	if user.Status != config.UserStatusEnabled {
+		return nil, errors.New("用户已被禁用")
+	}
+	if _, err := GetOrganizationMember(organizationId, user.Id); err == nil {
+		return nil, errors.New("该用户已是组织成员")
+	}
+
+	now := utils.GetTimestamp()
+	invitation := &OrganizationInvitation{
+		OrganizationId: organizationId,
+		UserId:         user.Id,
+		InviterId:      inviterId,
+		Role:           role,
+		QuotaLimit:     quotaLimit,
+		CreatedTime:    now,
+		ExpiredTime:    now + OrganizationInvitationExpiration,
+	}
+
+	var existing OrganizationInvitation
+	err := DB.Where("organization_id = ? AND user_id = ?", organizationId, user.Id).Limit(1).Find(&existing).Error
+	if err != nil {
+		return nil, err
+	}
+	if existing.Id > 0 {
+		invitation.Id = existing.Id
+		err = DB.Select("inviter_id", "role", "quota_limit", "created_time", "expired_time").Updates(invitation).Error
+	} else {
+		err = DB.Create(invitation).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	invitation.Username = user.Username
+	return invitation, nil
+}
+
+// GetOrganizationInvitations 组织未过期的邀请
+func GetOrganizationInvitations(organizationId int) ([]*OrganizationInvitation, error) {
+	var invitations []*OrganizationInvitation
+	err := DB.Where("organization_id = ? AND expired_time > ?", organizationId, utils.GetTimestamp()).Order("id").Find(&invitations).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, invitation := range invitations {
+		invitation.Username, _ = CacheGetUsername(invitation.UserId)
+	}
+	return invitations, nil
+}
+
+// GetUserOrganizationInvitations 用户收到的未过期邀请
+func GetUserOrganizationInvitations(userId int) ([]*OrganizationInvitation, error) {
+	var invitations []*OrganizationInvitation
+	err := DB.Table("organization_invitations").
+		Select("organization_invitations.*, organizations.name as organization_name").
+		Joins("JOIN organizations ON organizations.id = organization_invitations.organization_id").
+		Where("organization_invitations.user_id = ? AND organization_invitations.expired_time > ?", userId, utils.GetTimestamp()).
+		Order("organization_invitations.id").
+		Scan(&invitations).Error
+	return invitations, err
+}
+
+// AcceptOrganizationInvitation 用户接受邀请成为组织成员，邀请只能使用一次
+func AcceptOrganizationInvitation(id int, userId int) (*OrganizationMember, error) {
+	var invitation OrganizationInvitation
+	if err := DB.Where("id = ? AND user_id = ?", id, userId).First(&invitation).Error; err != nil {
+		return nil, ErrOrganizationInvitationNotFound
+	}
+	if invitation.ExpiredTime <= utils.GetTimestamp() {
+		return nil, ErrOrganizationInvitationNotFound
+	}
+
+	organization, err := GetOrganizationById(invitation.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+	if organization.Status != OrganizationStatusEnabled {
+		return nil, errors.New("组织已被停用")
+	}
+
+	member := &OrganizationMember{
+		OrganizationId: invitation.OrganizationId,
+		UserId:         userId,
+		Role:           invitation.Role,
+		QuotaLimit:     invitation.QuotaLimit,
+		CreatedTime:    utils.GetTimestamp(),
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&invitation)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrganizationInvitationNotFound
+		}
+		if err := tx.Create(member).Error; err != nil {
+			return errors.New("已是组织成员")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return member, nil
+}
+
+// DeleteOrganizationInvitation 撤销或拒绝邀请，organizationId 和 userId 为 0 时不作为条件
+func DeleteOrganizationInvitation(id int, organizationId int, userId int) error {
+	tx := DB.Where("id = ?", id)
+	if organizationId > 0 {
+		tx = tx.Where("organization_id = ?", organizationId)
+	}
+	if userId > 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	result := tx.Delete(&OrganizationInvitation{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrOrganizationInvitationNotFound
+	}
+	return nil
+}
+
+// UpdateOrganizationMember 修改成员角色和额度上限，创建者的角色不能修改
+func UpdateOrganizationMember(organizationId int, userId int, role string, quotaLimit int) error {
+	member, err := GetOrganizationMember(organizationId, userId)
+	if err != nil {
+		return err
+	}
+	if quotaLimit < 0 {
+		return errors.New("额度上限不能为负数")
+	}
+
+	updates := map[string]any{"quota_limit": quotaLimit}
+	if member.Role != OrganizationRoleOwner {
+		if !isValidOrganizationRole(role) {
+			return errors.New("无效的角色")
+		}
+		updates["role"] = role
+	}
+	if err := DB.Model(&OrganizationMember{}).Where("id = ?", member.Id).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	clearOrganizationQuotaCache(organizationId, userId)
+	return nil
+}
+
+// RemoveOrganizationMember 移除成员，该成员创建的组织令牌一并禁用
+func RemoveOrganizationMember(organizationId int, userId int) error {
+	member, err := GetOrganizationMember(organizationId, userId)
+	if err != nil {
+		return err
+	}
+	if member.Role == OrganizationRoleOwner {
+		return errors.New("不能移除组织创建者")
+	}
+
+	var tokens []*Token
+	if err := DB.Where("organization_id = ? AND user_id = ?", organizationId, userId).Find(&tokens).Error; err != nil {
+		return err
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(member).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Token{}).Where("organization_id = ? AND user_id = ?", organizationId, userId).
+			Update("status", config.TokenStatusDisabled).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	clearOrganizationTokensCache(tokens)
+	clearOrganizationQuotaCache(organizationId, userId)
+	return nil
+}
+
+// FundOrganization 成员将个人额度转入组织额度池
+func FundOrganization(organizationId int, userId int, quota int) error {
+	if quota <= 0 {
+		return errors.New("额度必须大于 0")
+	}
+	organization, err := GetOrganizationById(organizationId)
+	if err != nil {
+		return err
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).Where("id = ? AND quota >= ?", userId, quota).Update("quota", gorm.Expr("quota - ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("个人额度不足")
+		}
+		if err := adjustQuotaLots(tx, userId, -quota); err != nil {
+			return err
+		}
+
+		return tx.Model(&Organization{}).Where("id = ?", organizationId).Updates(map[string]any{
+			"quota":        gorm.Expr("quota + ?", quota),
+			"updated_time": utils.GetTimestamp(),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	clearUserQuotaCache(userId)
+	clearOrganizationQuotaCache(organizationId)
+	RecordLog(userId, LogTypeManage, fmt.Sprintf("向组织 %s 转入额度 %s", organization.Name, common.LogQuota(quota)))
+	return nil
+}
+
+// AdjustOrganizationQuota 管理员调整组织额度，delta 为负数时扣减
+func AdjustOrganizationQuota(organizationId int, delta int) error {
+	organization, err := GetOrganizationById(organizationId)
+	if err != nil {
+		return err
+	}
+	if delta == 0 {
+		return nil
+	}
+
+	err = DB.Model(&Organization{}).Where("id = ?", organizationId).Updates(map[string]any{
+		"quota":        gorm.Expr("quota + ?", delta),
+		"updated_time": utils.GetTimestamp(),
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	clearOrganizationQuotaCache(organizationId)
+	RecordLog(organization.OwnerId, LogTypeManage, fmt.Sprintf("管理员调整组织 %s 的额度 %s", organization.Name, common.LogQuota(delta)))
+	return nil
+}
+
+// GetOrganizationAvailableQuota 成员可使用的组织额度，取组织余额与成员剩余上限中的较小值
+func GetOrganizationAvailableQuota(organizationId int, userId int) (int, error) {
+	var result struct {
+		Quota      int
+		Status     int
+		QuotaLimit int
+		UsedQuota  int
+	}
+	err := DB.Table("organizations").
+		Select("organizations.quota, organizations.status, organization_members.quota_limit, organization_members.used_quota").
+		Joins("JOIN organization_members ON organization_members.organization_id = organizations.id").
+		Where("organizations.id = ? AND organization_members.user_id = ?", organizationId, userId).
+		Limit(1).
+		Scan(&result).Error
+	if err != nil {
+		return 0, err
+	}
+	if result.Status != OrganizationStatusEnabled {
+		return 0, nil
+	}
+	if result.QuotaLimit > 0 {
+		return min(result.Quota, result.QuotaLimit-result.UsedQuota), nil
+	}
+	return result.Quota, nil
+}
+
+// CacheGetBillingQuota 令牌计费使用的可用额度，组织令牌使用组织额度，否则使用用户额度
+func CacheGetBillingQuota(userId int, organizationId int) (int, error) {
+	if organizationId > 0 {
+		return CacheGetOrganizationAvailableQuota(organizationId, userId)
+	}
+	return CacheGetUserQuota(userId)
+}
+
+// clearOrganizationQuotaCache 组织额度、状态或成员上限变化后清除缓存，userIds 为空时清除所有成员
+func clearOrganizationQuotaCache(organizationId int, userIds ...int) {
+	if !config.RedisEnabled {
+		return
+	}
+	if len(userIds) == 0 {
+		DB.Model(&OrganizationMember{}).Where("organization_id = ?", organizationId).Pluck("user_id", &userIds)
+	}
+	for _, userId := range userIds {
+		redis.RedisDel(fmt.Sprintf(OrganizationQuotaCacheKey, organizationId, userId))
+	}
+}
+
+// consumeOrganizationQuota 组织令牌扣费，quota 为负数时退还
+// 预扣时 check 为 true，组织余额或成员上限不足时失败；结算时按实际用量扣除，允许超出
+func consumeOrganizationQuota(organizationId int, userId int, quota int, check bool) error {
+	if quota == 0 {
+		return nil
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		orgQuery := tx.Model(&Organization{}).Where("id = ?", organizationId)
+		memberQuery := tx.Model(&OrganizationMember{}).Where("organization_id = ? AND user_id = ?", organizationId, userId)
+		if check && quota > 0 {
+			orgQuery = orgQuery.Where("status = ? AND quota >= ?", OrganizationStatusEnabled, quota)
+			memberQuery = memberQuery.Where("(quota_limit = 0 OR used_quota + ? <= quota_limit)", quota)
+		}
+
+		result := orgQuery.Updates(map[string]any{
+			"quota":      gorm.Expr("quota - ?", quota),
+			"used_quota": gorm.Expr("used_quota + ?", quota),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if check && result.RowsAffected == 0 {
+			return ErrOrganizationQuotaNotEnough
+		}
+
+		result = memberQuery.Update("used_quota", gorm.Expr("used_quota + ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if check && result.RowsAffected == 0 {
+			return ErrOrganizationMemberLimit
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// 其他成员的缓存在过期前可能偏高，余额不足时由上面的条件扣减拦截
+	if config.RedisEnabled {
+		redis.RedisDecrease(fmt.Sprintf(OrganizationQuotaCacheKey, organizationId, userId), int64(quota))
+	}
+	return nil
+}
+
+func GetOrganizationTokens(organizationId int, userId int) ([]*Token, error) {
+	var tokens []*Token
+	tx := DB.Where("organization_id = ?", organizationId)
+	if userId > 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	err := tx.Order("id desc").Find(&tokens).Error
+	return tokens, err
+}
+
+func clearOrganizationTokensCache(tokens []*Token) {
+	for _, token := range tokens {
+		token.clearCache()
+	}
+}
+
+// GetOrganizationLogsList 组织令牌的消费日志，userId 不为 0 时只查询该成员的日志
+func GetOrganizationLogsList(organizationId int, userId int, params *LogsListParams) (*DataResult[Log], error) {
+	var logs []*Log
+
+	tx := DB.Where("organization_id = ?", organizationId).Omit("id")
+	if userId > 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	if params.ModelName != "" {
+		tx = tx.Where("model_name = ?", params.ModelName)
+	}
+	if params.TokenName != "" {
+		tx = tx.Where("token_name = ?", params.TokenName)
+	}
+	if params.Username != "" {
+		tx = tx.Where("username = ?", params.Username)
+	}
+	if params.StartTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", params.StartTimestamp)
+	}
+	if params.EndTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", params.EndTimestamp)
+	}
+
+	return PaginateAndOrder[Log](tx, &params.PaginationParams, &logs, allowedLogsOrderFields)
+}
+
+// GetOrganizationModelStatisticsByPeriod 按天和模型统计组织的消费，直接从日志汇总
+func GetOrganizationModelStatisticsByPeriod(organizationId int, startTimestamp, endTimestamp int64) (statistics []*LogStatisticGroupModel, err error) {
+	groupSelect := getTimestampGroupsSelect("created_at", "day", "date")
+
+	err = DB.Raw(`
+		SELECT `+groupSelect+`,
+		model_name,
+		count(*) as request_count,
+		sum(quota) as quota,
+		sum(prompt_tokens) as prompt_tokens,
+		sum(completion_tokens) as completion_tokens,
+		sum(request_time) as request_time
+		FROM logs
+		WHERE organization_id = ? AND type = ?
+		AND created_at BETWEEN ? AND ?
+		GROUP BY date, model_name
+		ORDER BY date, model_name
+	`, organizationId, LogTypeConsume, startTimestamp, endTimestamp).Scan(&statistics).Error
+	return
+}
+
+type OrganizationMemberStatistics struct {
+	UserId       int    `json:"user_id"`
+	Username     string `json:"username"`
+	RequestCount int64  `json:"request_count"`
+	Quota        int64  `json:"quota"`
+}
+
+// GetOrganizationMemberStatistics 按成员统计组织的消费
+func GetOrganizationMemberStatistics(organizationId int, startTimestamp, endTimestamp int64) (statistics []*OrganizationMemberStatistics, err error) {
+	err = DB.Model(&Log{}).
+		Select("user_id, max(username) as username, count(*) as request_count, sum(quota) as quota").
+		Where("organization_id = ? AND type = ? AND created_at BETWEEN ? AND ?", organizationId, LogTypeConsume, startTimestamp, endTimestamp).
+		Group("user_id").
+		Order("quota desc").
+		Scan(&statistics).Error
+	return
+}
+
+// GetOrganizationInvoices 按月汇总组织的消费账单
+func GetOrganizationInvoices(organizationId int) (invoices []*StatisticsMonthNoModel, err error) {
+	groupSelect := getTimestampGroupsSelect("created_at", "month", "date")
+
+	err = DB.Raw(`
+		SELECT `+groupSelect+`,
+		count(*) as request_count,
+		sum(quota) as quota,
+		sum(prompt_tokens) as prompt_tokens,
+		sum(completion_tokens) as completion_tokens,
+		sum(request_time) as request_time
+		FROM logs
+		WHERE organization_id = ? AND type = ?
+		GROUP BY date
+		ORDER BY date DESC
+	`, organizationId, LogTypeConsume).Scan(&invoices).Error
+	return
+}
+
+// GetOrganizationInvoiceDetail 组织指定月份（YYYY-MM）按模型的账单详情
+func GetOrganizationInvoiceDetail(organizationId int, month string) ([]*StatisticsMonthModel, error) {
+	start, err := time.ParseInLocation("2006-01", month, time.Local)
+	if err != nil {
+		return nil, errors.New("无效的日期格式")
+	}
+	end := start.AddDate(0, 1, 0)
+
+	var invoices []*StatisticsMonthModel
+	err = DB.Model(&Log{}).
+		Select("? as date, model_name, count(*) as request_count, sum(quota) as quota, sum(prompt_tokens) as prompt_tokens, sum(completion_tokens) as completion_tokens, sum(request_time) as request_time", month).
+		Where("organization_id = ? AND type = ? AND created_at >= ? AND created_at < ?", organizationId, LogTypeConsume, start.Unix(), end.Unix()).
+		Group("model_name").
+		Order("quota desc").
+		Scan(&invoices).Error
+	return invoices, err
+}
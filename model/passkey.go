@@ -0,0 +1,130 @@
+package model
+
+import (
+	"done-hub/common/utils"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// Passkey 用户注册的 WebAuthn 凭据，可用于免密码登录
+type Passkey struct {
+	Id           int    `json:"id"`
+	UserId       int    `json:"user_id" gorm:"index"`
+	Name         string `json:"name" gorm:"type:varchar(50);default:''"`
+	CredentialId string `json:"-" gorm:"type:varchar(255);uniqueIndex"`
+	Credential   string `json:"-" gorm:"type:text"`
+	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
+	LastUsedTime int64  `json:"last_used_time" gorm:"bigint;default:0"`
+}
+
+func GetPasskeysByUserId(userId int) ([]*Passkey, error) {
+	var passkeys []*Passkey
+	err := DB.Where("user_id = ?", userId).Order("id asc").Find(&passkeys).Error
+	return passkeys, err
+}
+
+func GetPasskeyByCredentialId(credentialId []byte) (*Passkey, error) {
+	var passkey Passkey
+	err := DB.Where("credential_id = ?", base64.RawURLEncoding.EncodeToString(credentialId)).First(&passkey).Error
+	return &passkey, err
+}
+
+func HasPasskey(userId int) bool {
+	var count int64
+	DB.Model(&Passkey{}).Where("user_id = ?", userId).Count(&count)
+	return count > 0
+}
+
+func AddPasskey(userId int, name string, credential *webauthn.Credential) error {
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+
+	if name == "" {
+		name = "Passkey"
+	}
+
+	return DB.Create(&Passkey{
+		UserId:       userId,
+		Name:         name,
+		CredentialId: base64.RawURLEncoding.EncodeToString(credential.ID),
+		Credential:   string(data),
+		CreatedTime:  utils.GetTimestamp(),
+	}).Error
+}
+
+// UpdateCredential 登录后更新签名计数等状态
+func (p *Passkey) UpdateCredential(credential *webauthn.Credential) error {
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+
+	return DB.Model(p).Updates(map[string]any{
+		"credential":     string(data),
+		"last_used_time": utils.GetTimestamp(),
+	}).Error
+}
+
+func DeletePasskey(id, userId int) error {
+	result := DB.Where("id = ? AND user_id = ?", id, userId).Delete(&Passkey{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("通行密钥不存在")
+	}
+	return nil
+}
+
+func DeletePasskeysByUserId(userId int) error {
+	return DB.Where("user_id = ?", userId).Delete(&Passkey{}).Error
+}
+
+// PasskeyUser 实现 webauthn.User
+type PasskeyUser struct {
+	User        *User
+	Credentials []webauthn.Credential
+}
+
+func NewPasskeyUser(user *User) (*PasskeyUser, error) {
+	passkeys, err := GetPasskeysByUserId(user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	passkeyUser := &PasskeyUser{User: user}
+	for _, passkey := range passkeys {
+		var credential webauthn.Credential
+		if err := json.Unmarshal([]byte(passkey.Credential), &credential); err != nil {
+			continue
+		}
+		passkeyUser.Credentials = append(passkeyUser.Credentials, credential)
+	}
+
+	return passkeyUser, nil
+}
+
+func (u *PasskeyUser) WebAuthnID() []byte {
+	return []byte(strconv.Itoa(u.User.Id))
+}
+
+func (u *PasskeyUser) WebAuthnName() string {
+	return u.User.Username
+}
+
+func (u *PasskeyUser) WebAuthnDisplayName() string {
+	if u.User.DisplayName != "" {
+		return u.User.DisplayName
+	}
+	return u.User.Username
+}
+
+func (u *PasskeyUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.Credentials
+}
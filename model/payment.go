@@ -1,6 +1,7 @@
 package model
 
 import (
+	"done-hub/common/secret"
 	"done-hub/common/utils"
 
 	"gorm.io/gorm"
@@ -88,13 +89,62 @@ func GetUserPaymentList() ([]*Payment, error) {
 	return payments, err
 }
 
+// Decrypted 返回 Config 解密后的副本
+func (p *Payment) Decrypted() (*Payment, error) {
+	if !secret.IsEncrypted(p.Config) {
+		return p, nil
+	}
+
+	decrypted := *p
+	var err error
+	if decrypted.Config, err = secret.Decrypt(p.Config); err != nil {
+		return nil, err
+	}
+
+	return &decrypted, nil
+}
+
+// MaskSecrets 管理接口返回前对 Config 中的字段脱敏
+func (p *Payment) MaskSecrets() {
+	config, _ := secret.Decrypt(p.Config)
+	p.Config = secret.MaskJSON(config)
+}
+
+// RestoreMaskedSecrets 提交的 Config 中仍为脱敏值的字段沿用库中的值
+func (p *Payment) RestoreMaskedSecrets() error {
+	if !secret.IsMasked(p.Config) {
+		return nil
+	}
+
+	stored, err := GetPaymentByID(p.ID)
+	if err != nil {
+		return err
+	}
+
+	storedConfig, err := secret.Decrypt(stored.Config)
+	if err != nil {
+		return err
+	}
+
+	p.Config = secret.MergeMaskedJSON(p.Config, storedConfig)
+	return nil
+}
+
 func (p *Payment) Insert() error {
+	var err error
+	if p.Config, err = secret.Encrypt(p.Config); err != nil {
+		return err
+	}
+
 	p.UUID = utils.GetUUID()
 	return DB.Create(p).Error
 }
 
 func (p *Payment) Update(overwrite bool) error {
 	var err error
+	if p.Config, err = secret.Encrypt(p.Config); err != nil {
+		return err
+	}
 
 	if overwrite {
 		err = DB.Model(p).Select("*").Updates(p).Error
@@ -0,0 +1,116 @@
+package model
+
+import (
+	"done-hub/common/utils"
+	"errors"
+)
+
+// 对账发现的异常类型
+const (
+	PaymentMismatchMissingCallback = "missing_callback"  // 网关已支付，本地未入账
+	PaymentMismatchUnpaid          = "unpaid"            // 本地已入账，网关未支付
+	PaymentMismatchAmount          = "amount_mismatch"   // 支付金额不一致
+	PaymentMismatchRefund          = "refund_mismatch"   // 退款金额不一致
+	PaymentMismatchDuplicate       = "duplicate_payment" // 已入账的订单收到不同网关单号的支付回调
+)
+
+// PaymentMismatch 支付对账异常，同一订单同一类型未处理的异常只记录一条
+type PaymentMismatch struct {
+	Id         int    `json:"id"`
+	OrderId    int    `json:"order_id" gorm:"index"`
+	UserId     int    `json:"user_id" gorm:"index"`
+	GatewayId  int    `json:"gateway_id" gorm:"index"`
+	TradeNo    string `json:"trade_no" gorm:"type:varchar(50);index"`
+	GatewayNo  string `json:"gateway_no" gorm:"type:varchar(100);default:''"`
+	Type       string `json:"type" gorm:"type:varchar(32);index"`
+	Detail     string `json:"detail" gorm:"type:varchar(500);default:''"`
+	Resolved   bool   `json:"resolved" gorm:"default:false;index"`
+	Resolution string `json:"resolution" gorm:"type:varchar(255);default:''"`
+	ResolvedBy int    `json:"resolved_by" gorm:"default:0"`
+	ResolvedAt int64  `json:"resolved_at" gorm:"bigint;default:0"`
+	CreatedAt  int64  `json:"created_at" gorm:"bigint"`
+}
+
+type SearchPaymentMismatchParams struct {
+	PaginationParams
+	TradeNo   string `form:"trade_no"`
+	Type      string `form:"type"`
+	GatewayId int    `form:"gateway_id"`
+	Resolved  *bool  `form:"resolved"`
+}
+
+var allowedPaymentMismatchOrderFields = map[string]bool{
+	"id":         true,
+	"order_id":   true,
+	"type":       true,
+	"created_at": true,
+}
+
+func GetPaymentMismatchList(params *SearchPaymentMismatchParams) (*DataResult[PaymentMismatch], error) {
+	var mismatches []*PaymentMismatch
+
+	tx := DB.Model(&PaymentMismatch{})
+	if params.TradeNo != "" {
+		tx = tx.Where("trade_no = ?", params.TradeNo)
+	}
+	if params.Type != "" {
+		tx = tx.Where("type = ?", params.Type)
+	}
+	if params.GatewayId != 0 {
+		tx = tx.Where("gateway_id = ?", params.GatewayId)
+	}
+	if params.Resolved != nil {
+		tx = tx.Where("resolved = ?", *params.Resolved)
+	}
+
+	return PaginateAndOrder[PaymentMismatch](tx, &params.PaginationParams, &mismatches, allowedPaymentMismatchOrderFields)
+}
+
+func GetPaymentMismatchById(id int) (*PaymentMismatch, error) {
+	var mismatch PaymentMismatch
+	err := DB.First(&mismatch, id).Error
+	return &mismatch, err
+}
+
+// RecordPaymentMismatch 记录对账异常，已存在未处理的同类异常时不重复记录，返回是否新增
+func RecordPaymentMismatch(order *Order, mismatchType string, gatewayNo string, detail string) (bool, error) {
+	var count int64
+	err := DB.Model(&PaymentMismatch{}).Where("order_id = ? AND type = ? AND resolved = ?", order.ID, mismatchType, false).Count(&count).Error
+	if err != nil || count > 0 {
+		return false, err
+	}
+
+	mismatch := &PaymentMismatch{
+		OrderId:   order.ID,
+		UserId:    order.UserId,
+		GatewayId: order.GatewayId,
+		TradeNo:   order.TradeNo,
+		GatewayNo: gatewayNo,
+		Type:      mismatchType,
+		Detail:    truncateString(detail, 500),
+		CreatedAt: utils.GetTimestamp(),
+	}
+	if err := DB.Create(mismatch).Error; err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ResolvePaymentMismatch 标记异常已处理，并发处理时只有一个请求成功
+func ResolvePaymentMismatch(mismatch *PaymentMismatch, resolution string, operatorId int) error {
+	result := DB.Model(&PaymentMismatch{}).Where("id = ? AND resolved = ?", mismatch.Id, false).Updates(map[string]any{
+		"resolved":    true,
+		"resolution":  truncateString(resolution, 255),
+		"resolved_by": operatorId,
+		"resolved_at": utils.GetTimestamp(),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("异常已处理")
+	}
+
+	return nil
+}
@@ -2,6 +2,9 @@ package model
 
 import (
 	"done-hub/common/config"
+	"errors"
+	"fmt"
+	"sort"
 
 	"github.com/shopspring/decimal"
 	"gorm.io/datatypes"
@@ -25,10 +28,25 @@ func GetIncreaseTokens(tokens int, ratio float64) int {
 	return int(float64(tokens) * (ratio - 1))
 }
 
+// extraKeyNotInPrompt 不包含在 PromptTokens 中的额外 token，如缓存存储的 token * 小时，按倍率全额计费，不参与价格档位的选择
+var extraKeyNotInPrompt = map[string]bool{
+	config.UsageExtraCachedStorage: true,
+}
+
+// GetExtraTokens 额外 token 按倍率换算后需要增加的计费 token 数
+func GetExtraTokens(key string, tokens int, ratio float64) int {
+	if extraKeyNotInPrompt[key] {
+		return int(float64(tokens) * ratio)
+	}
+
+	return GetIncreaseTokens(tokens, ratio)
+}
+
 var ExtraKeyIsPrompt = map[string]bool{
 	config.UsageExtraCache:            true,
 	config.UsageExtraCachedWrite:      true,
 	config.UsageExtraCachedRead:       true,
+	config.UsageExtraCachedStorage:    true,
 	config.UsageExtraInputAudio:       true,
 	config.UsageExtraOutputAudio:      false,
 	config.UsageExtraReasoning:        false,
@@ -45,6 +63,7 @@ var defaultExtraPrice = map[string]float64{
 	config.UsageExtraCache:            0.5,
 	config.UsageExtraCachedWrite:      1.25,
 	config.UsageExtraCachedRead:       0.1,
+	config.UsageExtraCachedStorage:    3.6,
 	config.UsageExtraInputAudio:       40,
 	config.UsageExtraOutputAudio:      40,
 	config.UsageExtraReasoning:        1,
@@ -61,6 +80,14 @@ type Price struct {
 	Locked      bool    `json:"locked" gorm:"default:false"` // 如果模型为locked 则覆盖模式不会更新locked的模型价格
 
 	ExtraRatios *datatypes.JSONType[map[string]float64] `json:"extra_ratios,omitempty" gorm:"type:json"`
+	Tiers       *datatypes.JSONType[[]PriceTier]        `json:"tiers,omitempty" gorm:"type:json"` // 按提示 token 数分档的价格，只对按 token 计费的模型生效
+}
+
+// PriceTier 提示 token 数超过阈值后使用的价格，如长上下文请求
+type PriceTier struct {
+	Threshold int     `json:"threshold"`
+	Input     float64 `json:"input"`
+	Output    float64 `json:"output"`
 }
 
 func GetAllPrices() ([]*Price, error) {
@@ -134,6 +161,58 @@ func (price *Price) GetExtraRatio(key string) float64 {
 	return ratio
 }
 
+// GetTier 返回提示 token 数适用的最高一档价格，没有命中时返回 nil，使用基础价格
+func (price *Price) GetTier(promptTokens int) *PriceTier {
+	if price.Tiers == nil || price.Type == TimesPriceType {
+		return nil
+	}
+
+	var matched *PriceTier
+	tiers := price.Tiers.Data()
+	for i := range tiers {
+		if promptTokens > tiers[i].Threshold && (matched == nil || tiers[i].Threshold > matched.Threshold) {
+			matched = &tiers[i]
+		}
+	}
+
+	return matched
+}
+
+// ValidateTiers 校验阶梯价格并按阈值排序，没有阶梯时清空字段
+func (price *Price) ValidateTiers() error {
+	if price.Tiers == nil {
+		return nil
+	}
+
+	tiers := price.Tiers.Data()
+	if len(tiers) == 0 {
+		price.Tiers = nil
+		return nil
+	}
+	if price.Type == TimesPriceType {
+		return errors.New("按次计费的模型不支持阶梯价格")
+	}
+
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].Threshold < tiers[j].Threshold
+	})
+	for i, tier := range tiers {
+		if tier.Threshold <= 0 {
+			return errors.New("阶梯价格的阈值必须大于 0")
+		}
+		if tier.Input < 0 || tier.Output < 0 {
+			return errors.New("阶梯价格不能为负数")
+		}
+		if i > 0 && tier.Threshold == tiers[i-1].Threshold {
+			return fmt.Errorf("阶梯价格的阈值 %d 重复", tier.Threshold)
+		}
+	}
+
+	jsonData := datatypes.NewJSONType(tiers)
+	price.Tiers = &jsonData
+	return nil
+}
+
 func (price *Price) FetchInputCurrencyPrice(rate float64) string {
 	r := decimal.NewFromFloat(price.GetInput()).Mul(decimal.NewFromFloat(rate))
 	return r.String()
@@ -153,6 +232,7 @@ func UpdatePrices(tx *gorm.DB, models []string, prices *Price) error {
 			Output:      prices.Output,
 			Locked:      prices.Locked,
 			ExtraRatios: prices.ExtraRatios,
+			Tiers:       prices.Tiers,
 		}).Error
 
 	return err
@@ -288,7 +368,7 @@ func GetDefaultPrice() []*Price {
 		"gemini-pro":        {[]float64{0.25, 0.75}, config.ChannelTypeGemini},
 		"gemini-pro-vision": {[]float64{0.25, 0.75}, config.ChannelTypeGemini},
 		"gemini-1.0-pro":    {[]float64{0.25, 0.75}, config.ChannelTypeGemini},
-		// $7 / 1 million tokens  $21 / 1 million tokens
+		// $3.5 / 1 million tokens  $10.5 / 1 million tokens，超过 128k 时 $7 / 1 million tokens  $21 / 1 million tokens
 		"gemini-1.5-pro":          {[]float64{1.75, 5.25}, config.ChannelTypeGemini},
 		"gemini-1.5-pro-latest":   {[]float64{1.75, 5.25}, config.ChannelTypeGemini},
 		"gemini-1.5-flash":        {[]float64{0.175, 0.265}, config.ChannelTypeGemini},
@@ -408,16 +488,27 @@ func GetDefaultPrice() []*Price {
 		"hunyuan-pro":           {[]float64{2.1429, 7.1429}, config.ChannelTypeHunyuan},
 	}
 
+	// 长上下文的阶梯价格
+	DefaultPriceTiers := map[string][]PriceTier{
+		"gemini-1.5-pro":        {{Threshold: 128000, Input: 3.5, Output: 10.5}},
+		"gemini-1.5-pro-latest": {{Threshold: 128000, Input: 3.5, Output: 10.5}},
+	}
+
 	var prices []*Price
 
 	for model, modelType := range ModelTypes {
-		prices = append(prices, &Price{
+		price := &Price{
 			Model:       model,
 			Type:        TokensPriceType,
 			ChannelType: modelType.Type,
 			Input:       modelType.Ratio[0],
 			Output:      modelType.Ratio[1],
-		})
+		}
+		if tiers, ok := DefaultPriceTiers[model]; ok {
+			jsonData := datatypes.NewJSONType(tiers)
+			price.Tiers = &jsonData
+		}
+		prices = append(prices, price)
 	}
 
 	var DefaultMJPrice = map[string]float64{
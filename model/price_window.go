@@ -0,0 +1,250 @@
+package model
+
+import (
+	"done-hub/common/utils"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PriceWindow 按时间段调整价格，如夜间错峰折扣，命中时在最终价格上再乘以倍率
+type PriceWindow struct {
+	Id          int     `json:"id"`
+	Name        string  `json:"name" gorm:"type:varchar(50)"`
+	Model       string  `json:"model" gorm:"type:varchar(100);default:''"`   // 为空时适用于所有模型，支持 * 结尾的前缀匹配
+	Group       string  `json:"group" gorm:"type:varchar(50);default:''"`    // 为空时适用于所有分组
+	Timezone    string  `json:"timezone" gorm:"type:varchar(50);default:''"` // 为空时使用 UTC
+	StartTime   string  `json:"start_time" gorm:"type:varchar(5)"`           // HH:MM，结束时间早于开始时间时表示跨天
+	EndTime     string  `json:"end_time" gorm:"type:varchar(5)"`
+	Weekdays    string  `json:"weekdays" gorm:"type:varchar(20);default:''"` // 生效的星期，0-6 逗号分隔，0 为周日，跨天时以开始当天为准，为空时每天生效
+	Ratio       float64 `json:"ratio" gorm:"default:1"`
+	Enable      *bool   `json:"enable" gorm:"default:true"`
+	CreatedTime int64   `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64   `json:"updated_time" gorm:"bigint"`
+
+	location *time.Location
+	start    int
+	end      int
+	weekdays map[time.Weekday]bool
+}
+
+var allowedPriceWindowOrderFields = map[string]bool{
+	"id":           true,
+	"name":         true,
+	"model":        true,
+	"created_time": true,
+}
+
+func GetPriceWindowsList(params *GenericParams) (*DataResult[PriceWindow], error) {
+	var windows []*PriceWindow
+	db := DB
+	if params.Keyword != "" {
+		db = db.Where("name LIKE ? or model LIKE ?", params.Keyword+"%", params.Keyword+"%")
+	}
+
+	return PaginateAndOrder(db, &params.PaginationParams, &windows, allowedPriceWindowOrderFields)
+}
+
+func GetPriceWindowById(id int) (*PriceWindow, error) {
+	var window PriceWindow
+	err := DB.First(&window, id).Error
+	return &window, err
+}
+
+// parseClock 将 HH:MM 转换为当天的分钟数
+func parseClock(clock string) (int, error) {
+	parts := strings.Split(clock, ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("时间格式错误: %s", clock)
+	}
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil || hour < 0 || hour > 23 {
+		return 0, fmt.Errorf("时间格式错误: %s", clock)
+	}
+	minute, err := strconv.Atoi(parts[1])
+	if err != nil || minute < 0 || minute > 59 {
+		return 0, fmt.Errorf("时间格式错误: %s", clock)
+	}
+	return hour*60 + minute, nil
+}
+
+// prepare 解析时区、时间段和星期，用于校验和缓存
+func (w *PriceWindow) prepare() error {
+	location, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return fmt.Errorf("时区无效: %s", w.Timezone)
+	}
+	start, err := parseClock(w.StartTime)
+	if err != nil {
+		return err
+	}
+	end, err := parseClock(w.EndTime)
+	if err != nil {
+		return err
+	}
+	if start == end {
+		return errors.New("开始时间和结束时间不能相同")
+	}
+
+	weekdays := make(map[time.Weekday]bool)
+	for _, day := range strings.Split(w.Weekdays, ",") {
+		if day = strings.TrimSpace(day); day == "" {
+			continue
+		}
+		weekday, err := strconv.Atoi(day)
+		if err != nil || weekday < 0 || weekday > 6 {
+			return fmt.Errorf("星期格式错误: %s", day)
+		}
+		weekdays[time.Weekday(weekday)] = true
+	}
+
+	w.location = location
+	w.start = start
+	w.end = end
+	w.weekdays = weekdays
+	return nil
+}
+
+func (w *PriceWindow) Validate() error {
+	if w.Name == "" || len(w.Name) > 50 {
+		return errors.New("名称长度必须在1-50之间")
+	}
+	if w.Ratio < 0 {
+		return errors.New("倍率不能为负数")
+	}
+	if w.Group != "" && GlobalUserGroupRatio.GetBySymbol(w.Group) == nil {
+		return errors.New("分组不存在")
+	}
+	if w.Enable == nil {
+		enable := true
+		w.Enable = &enable
+	}
+	return w.prepare()
+}
+
+func (w *PriceWindow) Insert() error {
+	w.CreatedTime = utils.GetTimestamp()
+	w.UpdatedTime = w.CreatedTime
+	if err := DB.Create(w).Error; err != nil {
+		return err
+	}
+	return PricingInstance.Init()
+}
+
+func (w *PriceWindow) Update() error {
+	w.UpdatedTime = utils.GetTimestamp()
+	err := DB.Select("name", "model", "group", "timezone", "start_time", "end_time", "weekdays", "ratio", "enable", "updated_time").Updates(w).Error
+	if err != nil {
+		return err
+	}
+	return PricingInstance.Init()
+}
+
+func DeletePriceWindowById(id int) error {
+	if err := DB.Delete(&PriceWindow{}, id).Error; err != nil {
+		return err
+	}
+	return PricingInstance.Init()
+}
+
+// IsActive 判断时间段在指定时间是否生效
+func (w *PriceWindow) IsActive(now time.Time) bool {
+	if w.location == nil {
+		return false
+	}
+
+	local := now.In(w.location)
+	minute := local.Hour()*60 + local.Minute()
+	day := local.Weekday()
+
+	if w.start < w.end {
+		if minute < w.start || minute >= w.end {
+			return false
+		}
+	} else {
+		switch {
+		case minute >= w.start:
+		case minute < w.end:
+			// 跨天时段的后半段属于前一天开始的时段
+			day = local.AddDate(0, 0, -1).Weekday()
+		default:
+			return false
+		}
+	}
+
+	return len(w.weekdays) == 0 || w.weekdays[day]
+}
+
+// Matches 判断时间段是否适用于分组和模型，group 为空时不按分组过滤
+func (w *PriceWindow) Matches(group string, modelName string) bool {
+	if group != "" && w.Group != "" && w.Group != group {
+		return false
+	}
+	if w.Model == "" || w.Model == modelName {
+		return true
+	}
+	return strings.HasSuffix(w.Model, "*") && strings.HasPrefix(modelName, strings.TrimSuffix(w.Model, "*"))
+}
+
+// specificity 同时命中多个时间段时，指定了模型和分组的优先
+func (w *PriceWindow) specificity() int {
+	score := 0
+	if w.Model != "" {
+		score += 2
+	}
+	if w.Group != "" {
+		score++
+	}
+	return score
+}
+
+func (p *Pricing) loadPriceWindows() error {
+	var windows []*PriceWindow
+	if err := DB.Where("enable = ?", true).Order("id").Find(&windows).Error; err != nil {
+		return err
+	}
+
+	activeWindows := make([]*PriceWindow, 0, len(windows))
+	for _, window := range windows {
+		if err := window.prepare(); err != nil {
+			continue
+		}
+		activeWindows = append(activeWindows, window)
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	p.PriceWindows = activeWindows
+	return nil
+}
+
+// GetPriceWindows 返回适用于分组和模型的时间段，group 为空时不按分组过滤
+func (p *Pricing) GetPriceWindows(group string, modelName string) []*PriceWindow {
+	p.RLock()
+	defer p.RUnlock()
+
+	windows := make([]*PriceWindow, 0)
+	for _, window := range p.PriceWindows {
+		if window.Matches(group, modelName) {
+			windows = append(windows, window)
+		}
+	}
+	return windows
+}
+
+// GetActivePriceWindow 返回当前生效的时间段，多个命中时取最具体的一个，没有时返回 nil
+func (p *Pricing) GetActivePriceWindow(group string, modelName string, now time.Time) *PriceWindow {
+	var active *PriceWindow
+	for _, window := range p.GetPriceWindows(group, modelName) {
+		if !window.IsActive(now) {
+			continue
+		}
+		if active == nil || window.specificity() > active.specificity() {
+			active = window
+		}
+	}
+	return active
+}
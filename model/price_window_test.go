@@ -0,0 +1,86 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPriceWindow(t *testing.T, window *PriceWindow) *PriceWindow {
+	if window.Name == "" {
+		window.Name = "test"
+	}
+	assert.NoError(t, window.Validate())
+	return window
+}
+
+func TestPriceWindowIsActive(t *testing.T) {
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	window := newTestPriceWindow(t, &PriceWindow{StartTime: "09:00", EndTime: "18:00", Timezone: "Asia/Shanghai", Ratio: 1.2})
+
+	assert.True(t, window.IsActive(time.Date(2026, 10, 16, 9, 0, 0, 0, shanghai)))
+	assert.True(t, window.IsActive(time.Date(2026, 10, 16, 17, 59, 0, 0, shanghai)))
+	assert.False(t, window.IsActive(time.Date(2026, 10, 16, 18, 0, 0, 0, shanghai)))
+	// 按时间段的时区判断，UTC 01:00 为上海 09:00
+	assert.True(t, window.IsActive(time.Date(2026, 10, 16, 1, 0, 0, 0, time.UTC)))
+	assert.False(t, window.IsActive(time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)))
+
+	// 未指定时区时使用 UTC
+	utc := newTestPriceWindow(t, &PriceWindow{StartTime: "09:00", EndTime: "18:00", Ratio: 1})
+	assert.True(t, utc.IsActive(time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)))
+}
+
+func TestPriceWindowOvernightWeekdays(t *testing.T) {
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	// 每周五 22:00 到次日 06:00
+	window := newTestPriceWindow(t, &PriceWindow{StartTime: "22:00", EndTime: "06:00", Timezone: "Asia/Shanghai", Weekdays: "5", Ratio: 0.5})
+
+	assert.True(t, window.IsActive(time.Date(2026, 10, 16, 23, 0, 0, 0, shanghai)))
+	// 周六凌晨属于周五开始的时段
+	assert.True(t, window.IsActive(time.Date(2026, 10, 17, 2, 0, 0, 0, shanghai)))
+	assert.False(t, window.IsActive(time.Date(2026, 10, 16, 2, 0, 0, 0, shanghai)))
+	assert.False(t, window.IsActive(time.Date(2026, 10, 17, 23, 0, 0, 0, shanghai)))
+	assert.False(t, window.IsActive(time.Date(2026, 10, 17, 6, 0, 0, 0, shanghai)))
+	assert.False(t, window.IsActive(time.Date(2026, 10, 16, 12, 0, 0, 0, shanghai)))
+}
+
+func TestPriceWindowValidate(t *testing.T) {
+	for _, window := range []*PriceWindow{
+		{StartTime: "09:00", EndTime: "18:00"},
+		{Name: "test", StartTime: "9", EndTime: "18:00"},
+		{Name: "test", StartTime: "24:00", EndTime: "18:00"},
+		{Name: "test", StartTime: "09:60", EndTime: "18:00"},
+		{Name: "test", StartTime: "09:00", EndTime: "09:00"},
+		{Name: "test", StartTime: "09:00", EndTime: "18:00", Timezone: "Mars/Base"},
+		{Name: "test", StartTime: "09:00", EndTime: "18:00", Weekdays: "1,7"},
+		{Name: "test", StartTime: "09:00", EndTime: "18:00", Ratio: -1},
+		{Name: "test", StartTime: "09:00", EndTime: "18:00", Group: "not-exist"},
+	} {
+		assert.Error(t, window.Validate(), window)
+	}
+
+	// 未解析的时间段不生效
+	assert.False(t, (&PriceWindow{StartTime: "00:00", EndTime: "23:59"}).IsActive(time.Now()))
+}
+
+func TestGetActivePriceWindow(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	all := newTestPriceWindow(t, &PriceWindow{StartTime: "00:00", EndTime: "23:59", Ratio: 0.9})
+	prefix := newTestPriceWindow(t, &PriceWindow{Model: "gpt-4o*", StartTime: "00:00", EndTime: "23:59", Ratio: 0.8})
+	expired := newTestPriceWindow(t, &PriceWindow{Model: "gpt-4o", StartTime: "00:00", EndTime: "01:00", Ratio: 0.1})
+	grouped := &PriceWindow{Model: "gpt-4o", Group: "vip", Ratio: 0.5, StartTime: "00:00", EndTime: "23:59"}
+	assert.NoError(t, grouped.prepare())
+
+	pricing := &Pricing{PriceWindows: []*PriceWindow{all, prefix, expired, grouped}}
+
+	// 多个命中时取指定了模型和分组的
+	assert.Equal(t, grouped, pricing.GetActivePriceWindow("vip", "gpt-4o", now))
+	assert.Equal(t, prefix, pricing.GetActivePriceWindow("default", "gpt-4o", now))
+	assert.Equal(t, prefix, pricing.GetActivePriceWindow("vip", "gpt-4o-mini", now))
+	assert.Equal(t, all, pricing.GetActivePriceWindow("vip", "claude-3", now))
+
+	// 不按分组过滤时返回所有适用于模型的时间段
+	assert.Len(t, pricing.GetPriceWindows("", "gpt-4o"), 4)
+	assert.Len(t, pricing.GetPriceWindows("default", "gpt-4o"), 3)
+}
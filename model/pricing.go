@@ -34,6 +34,9 @@ type Pricing struct {
 	sync.RWMutex
 	Prices map[string]*Price `json:"models"`
 	Match  []string          `json:"-"`
+
+	GroupPrices  map[string]map[string]*GroupPrice `json:"-"` // 分组 -> 模型 -> 价格覆盖
+	PriceWindows []*PriceWindow                    `json:"-"` // 已启用的分时价格
 }
 
 type BatchPrices struct {
@@ -68,6 +71,13 @@ func NewPricing() {
 
 // initializes the Pricing instance
 func (p *Pricing) Init() error {
+	if err := p.loadGroupPrices(); err != nil {
+		return err
+	}
+	if err := p.loadPriceWindows(); err != nil {
+		return err
+	}
+
 	prices, err := GetAllPrices()
 	if err != nil {
 		return err
@@ -156,6 +166,9 @@ func (p *Pricing) updateRawPrice(modelName string, price *Price) error {
 
 // UpdatePrice updates the price of a model
 func (p *Pricing) UpdatePrice(modelName string, price *Price) error {
+	if err := price.ValidateTiers(); err != nil {
+		return err
+	}
 
 	if err := p.updateRawPrice(modelName, price); err != nil {
 		return err
@@ -176,6 +189,10 @@ func (p *Pricing) addRawPrice(price *Price) error {
 
 // AddPrice adds a new price to the Pricing instance
 func (p *Pricing) AddPrice(price *Price) error {
+	if err := price.ValidateTiers(); err != nil {
+		return err
+	}
+
 	if err := p.addRawPrice(price); err != nil {
 		return err
 	}
@@ -208,6 +225,7 @@ func (p *Pricing) DeletePrice(modelName string) error {
 // SyncPricing syncs the pricing data
 func (p *Pricing) SyncPricing(pricing []*Price, mode string) error {
 	logger.SysLog("prices update mode：" + mode)
+	sanitizePriceTiers(pricing)
 	var err error
 	switch mode {
 	case string(PriceUpdateModeSystem):
@@ -228,6 +246,16 @@ func (p *Pricing) SyncPricing(pricing []*Price, mode string) error {
 	}
 }
 
+// sanitizePriceTiers 同步的价格中阶梯价格不合法时忽略阶梯，只使用基础价格
+func sanitizePriceTiers(pricing []*Price) {
+	for _, price := range pricing {
+		if err := price.ValidateTiers(); err != nil {
+			logger.SysError(fmt.Sprintf("模型 %s 的阶梯价格无效，已忽略: %s", price.Model, err.Error()))
+			price.Tiers = nil
+		}
+	}
+}
+
 func UpdatePriceByPriceService() error {
 	updatePriceMode := viper.GetString("auto_price_updates_mode")
 	if updatePriceMode == string(PriceUpdateModeSystem) {
@@ -238,6 +266,7 @@ func UpdatePriceByPriceService() error {
 	if err != nil {
 		return err
 	}
+	sanitizePriceTiers(prices)
 	if updatePriceMode == string(PriceUpdateModeAdd) {
 		// 仅仅新增
 		p := &Pricing{
@@ -453,6 +482,10 @@ func (p *Pricing) BatchDeletePrices(models []string) error {
 }
 
 func (p *Pricing) BatchSetPrices(batchPrices *BatchPrices, originalModels []string) error {
+	if err := batchPrices.Price.ValidateTiers(); err != nil {
+		return err
+	}
+
 	// 查找需要删除的model
 	var deletePrices []string
 	var addPrices []*Price
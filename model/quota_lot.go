@@ -0,0 +1,279 @@
+package model
+
+import (
+	"done-hub/common"
+	"done-hub/common/cache"
+	"done-hub/common/config"
+	"done-hub/common/logger"
+	"done-hub/common/redis"
+	"done-hub/common/utils"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+const (
+	QuotaLotSourceTopup      = "topup"
+	QuotaLotSourceRedemption = "redemption"
+	QuotaLotSourceAdmin      = "admin"
+	QuotaLotSourceInvite     = "invite"
+	QuotaLotSourceAffiliate  = "affiliate"
+)
+
+const (
+	QuotaLotStatusActive  = 1
+	QuotaLotStatusExpired = 2
+)
+
+// quotaLotConsumeOrder 先消耗最早过期的额度，永久额度最后消耗
+const quotaLotConsumeOrder = "CASE WHEN expires_at = 0 THEN 1 ELSE 0 END, expires_at, id"
+
+// quotaLotRestoreOrder 退还额度时按消耗的相反顺序补回
+const quotaLotRestoreOrder = "CASE WHEN expires_at = 0 THEN 0 ELSE 1 END, expires_at desc, id desc"
+
+// QuotaLot 额度批次，记录每笔额度的来源和有效期
+// 用户的 quota 仍是可用余额的总和，未被批次覆盖的部分（历史余额、注册赠送等）视为永久额度，在所有批次之后消耗
+type QuotaLot struct {
+	Id          int    `json:"id"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	Source      string `json:"source" gorm:"type:varchar(32);default:''"`
+	SourceId    string `json:"source_id" gorm:"type:varchar(64);default:''"`
+	Amount      int    `json:"amount" gorm:"default:0"`
+	Remaining   int    `json:"remaining" gorm:"default:0"`
+	ExpiresAt   int64  `json:"expires_at" gorm:"bigint;index;default:0"`
+	Status      int    `json:"status" gorm:"default:1;index"`
+	Remark      string `json:"remark" gorm:"type:varchar(255);default:''"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64  `json:"updated_time" gorm:"bigint"`
+}
+
+type QuotaLotsListParams struct {
+	PaginationParams
+	Source string `form:"source"`
+	Status int    `form:"status"`
+}
+
+var allowedQuotaLotsOrderFields = map[string]bool{
+	"id":           true,
+	"amount":       true,
+	"remaining":    true,
+	"expires_at":   true,
+	"created_time": true,
+}
+
+func GetUserQuotaLots(userId int, params *QuotaLotsListParams) (*DataResult[QuotaLot], error) {
+	var lots []*QuotaLot
+
+	tx := DB.Where("user_id = ?", userId)
+	if params.Source != "" {
+		tx = tx.Where("source = ?", params.Source)
+	}
+	if params.Status != 0 {
+		tx = tx.Where("status = ?", params.Status)
+	}
+
+	return PaginateAndOrder[QuotaLot](tx, &params.PaginationParams, &lots, allowedQuotaLotsOrderFields)
+}
+
+// QuotaValidDaysToExpiresAt 有效天数转换为过期时间，0 表示永久有效
+func QuotaValidDaysToExpiresAt(days int) int64 {
+	if days <= 0 {
+		return 0
+	}
+	return utils.GetTimestamp() + int64(days)*24*3600
+}
+
+// GrantUserQuota 为用户增加一笔额度批次，expiresAt 为 0 时永久有效
+func GrantUserQuota(userId int, quota int, source string, sourceId string, expiresAt int64, remark string) error {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		return grantQuotaLot(tx, userId, quota, source, sourceId, expiresAt, remark)
+	})
+	if err != nil {
+		return err
+	}
+
+	clearUserQuotaCache(userId)
+	return nil
+}
+
+// grantQuotaLot 在事务中创建额度批次并增加用户余额
+func grantQuotaLot(tx *gorm.DB, userId int, quota int, source string, sourceId string, expiresAt int64, remark string) error {
+	if quota <= 0 {
+		return errors.New("quota 必须大于 0！")
+	}
+	if expiresAt != 0 && expiresAt <= utils.GetTimestamp() {
+		return errors.New("过期时间必须晚于当前时间")
+	}
+
+	now := utils.GetTimestamp()
+	lot := &QuotaLot{
+		UserId:      userId,
+		Source:      source,
+		SourceId:    sourceId,
+		Amount:      quota,
+		Remaining:   quota,
+		ExpiresAt:   expiresAt,
+		Status:      QuotaLotStatusActive,
+		Remark:      truncateString(remark, 255),
+		CreatedTime: now,
+		UpdatedTime: now,
+	}
+	if err := tx.Create(lot).Error; err != nil {
+		return err
+	}
+	// 事务回滚时标记也无害，只是下次扣费会多查一次批次
+	markUserHasQuotaLots(userId)
+
+	return tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", quota)).Error
+}
+
+// userHasQuotaLots 用户是否有有效的额度批次，没有时扣费不需要同步批次
+// 查询失败时按有批次处理，保证批次余额不会漏扣
+func userHasQuotaLots(userId int) bool {
+	countLots := func() (bool, error) {
+		var count int64
+		err := DB.Model(&QuotaLot{}).Where("user_id = ? AND status = ?", userId, QuotaLotStatusActive).Limit(1).Count(&count).Error
+		return count > 0, err
+	}
+
+	if !config.RedisEnabled {
+		has, err := countLots()
+		return has || err != nil
+	}
+
+	has, err := cache.GetOrSetCache(fmt.Sprintf(UserQuotaLotsCacheKey, userId), UserQuotaLotsExpiration, countLots, cache.CacheTimeout)
+	return has || err != nil
+}
+
+func markUserHasQuotaLots(userId int) {
+	if config.RedisEnabled {
+		cache.SetCache(fmt.Sprintf(UserQuotaLotsCacheKey, userId), true, UserQuotaLotsExpiration)
+	}
+}
+
+// adjustQuotaLots 用户余额变化时同步额度批次，减少时按过期时间从早到晚扣减，增加（退还）时按相反顺序补回
+// 批次余额不足时，剩余部分由未被批次覆盖的永久额度承担
+func adjustQuotaLots(tx *gorm.DB, userId int, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+
+	now := utils.GetTimestamp()
+	query := tx.Where("user_id = ? AND status = ? AND (expires_at = 0 OR expires_at > ?)", userId, QuotaLotStatusActive, now)
+	if delta < 0 {
+		query = query.Where("remaining > 0").Order(quotaLotConsumeOrder)
+	} else {
+		query = query.Where("remaining < amount").Order(quotaLotRestoreOrder)
+	}
+
+	var lots []*QuotaLot
+	if err := query.Find(&lots).Error; err != nil {
+		return err
+	}
+
+	left := delta
+	if left < 0 {
+		left = -left
+	}
+	for _, lot := range lots {
+		if left == 0 {
+			break
+		}
+
+		var change int
+		if delta < 0 {
+			change = -min(left, lot.Remaining)
+		} else {
+			change = min(left, lot.Amount-lot.Remaining)
+		}
+		if change == 0 {
+			continue
+		}
+
+		err := tx.Model(&QuotaLot{}).Where("id = ?", lot.Id).Updates(map[string]any{
+			"remaining":    gorm.Expr("remaining + ?", change),
+			"updated_time": now,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		if change < 0 {
+			left += change
+		} else {
+			left -= change
+		}
+	}
+
+	return nil
+}
+
+// ExpireQuotaLots 使到期的额度批次失效，并从用户余额中扣除未用完的部分
+func ExpireQuotaLots() (int, error) {
+	now := utils.GetTimestamp()
+	expired := 0
+
+	for {
+		var lots []*QuotaLot
+		err := DB.Where("status = ? AND expires_at > 0 AND expires_at <= ?", QuotaLotStatusActive, now).
+			Order("id").Limit(100).Find(&lots).Error
+		if err != nil {
+			return expired, err
+		}
+		if len(lots) == 0 {
+			return expired, nil
+		}
+
+		for _, lot := range lots {
+			deducted, err := expireQuotaLot(lot, now)
+			if err != nil {
+				return expired, err
+			}
+			expired++
+			if config.RedisEnabled {
+				cache.DeleteCache(fmt.Sprintf(UserQuotaLotsCacheKey, lot.UserId))
+			}
+
+			if deducted > 0 {
+				clearUserQuotaCache(lot.UserId)
+				RecordLog(lot.UserId, LogTypeSystem, fmt.Sprintf("额度已过期 %s（来源：%s）", common.LogQuota(deducted), lot.Source))
+			}
+		}
+	}
+}
+
+func expireQuotaLot(lot *QuotaLot, now int64) (deducted int, err error) {
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&QuotaLot{}).Where("id = ? AND status = ?", lot.Id, QuotaLotStatusActive).Updates(map[string]any{
+			"status":       QuotaLotStatusExpired,
+			"updated_time": now,
+		})
+		if result.Error != nil || result.RowsAffected == 0 || lot.Remaining <= 0 {
+			return result.Error
+		}
+
+		// 余额已被管理员调低时，最多扣到 0
+		var quota int
+		if err := tx.Model(&User{}).Where("id = ?", lot.UserId).Select("quota").Find(&quota).Error; err != nil {
+			return err
+		}
+		deducted = max(min(lot.Remaining, quota), 0)
+		if deducted == 0 {
+			return nil
+		}
+
+		return tx.Model(&User{}).Where("id = ?", lot.UserId).Update("quota", gorm.Expr("quota - ?", deducted)).Error
+	})
+
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to expire quota lot %d: %s", lot.Id, err.Error()))
+	}
+	return deducted, err
+}
+
+func clearUserQuotaCache(userId int) {
+	if config.RedisEnabled {
+		redis.RedisDel(fmt.Sprintf(UserQuotaCacheKey, userId))
+	}
+}
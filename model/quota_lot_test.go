@@ -0,0 +1,37 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestUserQuota(t *testing.T, userId int) int {
+	user := &User{}
+	assert.NoError(t, DB.First(user, userId).Error)
+	return user.Quota
+}
+
+func TestDecreaseUserQuotaWithLots(t *testing.T) {
+	setupTestDB(t, &User{}, &QuotaLot{})
+	createTestUser(t, 1)
+	createTestUser(t, 2)
+
+	// 没有额度批次时直接扣减余额
+	assert.False(t, userHasQuotaLots(1))
+	assert.NoError(t, decreaseUserQuota(1, 100))
+	assert.Equal(t, -100, getTestUserQuota(t, 1))
+
+	assert.NoError(t, GrantUserQuota(2, 1000, QuotaLotSourceAdmin, "", QuotaValidDaysToExpiresAt(1), ""))
+	assert.True(t, userHasQuotaLots(2))
+	assert.NoError(t, decreaseUserQuota(2, 300))
+	assert.Equal(t, 700, getTestUserQuota(t, 2))
+
+	lot := &QuotaLot{}
+	assert.NoError(t, DB.Where("user_id = ?", 2).First(lot).Error)
+	assert.Equal(t, 700, lot.Remaining)
+
+	assert.NoError(t, increaseUserQuota(2, 100))
+	assert.NoError(t, DB.First(lot, lot.Id).Error)
+	assert.Equal(t, 800, lot.Remaining)
+}
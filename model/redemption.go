@@ -4,6 +4,7 @@ import (
 	"done-hub/common"
 	"done-hub/common/config"
 	"done-hub/common/logger"
+	"done-hub/common/redis"
 	"done-hub/common/utils"
 	"errors"
 	"fmt"
@@ -12,15 +13,19 @@ import (
 )
 
 type Redemption struct {
-	Id           int    `json:"id"`
-	UserId       int    `json:"user_id"`
-	Key          string `json:"key" gorm:"type:char(32);uniqueIndex"`
-	Status       int    `json:"status" gorm:"default:1"`
-	Name         string `json:"name" gorm:"index"`
-	Quota        int    `json:"quota" gorm:"default:100"`
-	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
-	RedeemedTime int64  `json:"redeemed_time" gorm:"bigint"`
-	Count        int    `json:"count" gorm:"-:all"` // only for api request
+	Id             int    `json:"id"`
+	UserId         int    `json:"user_id"`
+	CampaignId     int    `json:"campaign_id" gorm:"index;default:0"` // 所属兑换活动，0 表示普通兑换码
+	Key            string `json:"key" gorm:"type:char(32);uniqueIndex"`
+	Status         int    `json:"status" gorm:"default:1"`
+	Name           string `json:"name" gorm:"index"`
+	Quota          int    `json:"quota" gorm:"default:100"`
+	QuotaValidDays int    `json:"quota_valid_days" gorm:"default:0"` // 兑换后额度的有效天数，0 表示永久有效
+	MaxUses        int    `json:"max_uses" gorm:"default:1"`         // 可兑换的用户数，仅活动兑换码有效
+	UsedCount      int    `json:"used_count" gorm:"default:0"`
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+	RedeemedTime   int64  `json:"redeemed_time" gorm:"bigint"`
+	Count          int    `json:"count" gorm:"-:all"` // only for api request
 }
 
 var allowedRedemptionslOrderFields = map[string]bool{
@@ -66,6 +71,8 @@ func Redeem(key string, userId int, ip string) (quota int, err error) {
 		keyCol = `"key"`
 	}
 
+	var campaign *RedemptionCampaign
+	var usage *RedemptionUsage
 	err = DB.Transaction(func(tx *gorm.DB) error {
 		err := tx.Set("gorm:query_option", "FOR UPDATE").Where(keyCol+" = ?", key).First(redemption).Error
 		if err != nil {
@@ -74,11 +81,23 @@ func Redeem(key string, userId int, ip string) (quota int, err error) {
 		if redemption.Status != config.RedemptionCodeStatusEnabled {
 			return errors.New("该兑换码已被使用")
 		}
-		err = tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", redemption.Quota)).Error
-		if err != nil {
-			return err
+
+		now := utils.GetTimestamp()
+		if redemption.CampaignId > 0 {
+			campaign, usage, err = redeemCampaignCode(tx, redemption, userId, now)
+			if err != nil {
+				return err
+			}
+			return tx.Model(redemption).Select("used_count", "redeemed_time", "status").Updates(redemption).Error
+		}
+
+		if redemption.Quota > 0 {
+			err = grantQuotaLot(tx, userId, redemption.Quota, QuotaLotSourceRedemption, fmt.Sprintf("%d", redemption.Id), QuotaValidDaysToExpiresAt(redemption.QuotaValidDays), redemption.Name)
+			if err != nil {
+				return err
+			}
 		}
-		redemption.RedeemedTime = utils.GetTimestamp()
+		redemption.RedeemedTime = now
 		redemption.Status = config.RedemptionCodeStatusUsed
 		err = tx.Save(redemption).Error
 		return err
@@ -87,16 +106,26 @@ func Redeem(key string, userId int, ip string) (quota int, err error) {
 		return 0, errors.New("兑换失败，" + err.Error())
 	}
 
+	if campaign != nil {
+		return recordCampaignRedeem(campaign, usage, userId, ip), nil
+	}
+
 	// Try to upgrade user group based on cumulative recharge amount
 	err = CheckAndUpgradeUserGroup(userId, redemption.Quota)
 	if err != nil {
 		logger.SysError("failed to check and upgrade user group: " + err.Error())
 	}
 
-	RecordQuotaLog(userId, LogTypeTopup, redemption.Quota, ip, fmt.Sprintf("通过兑换码充值 %s", common.LogQuota(redemption.Quota)))
+	clearUserQuotaCache(userId)
+
+	content := fmt.Sprintf("通过兑换码充值 %s", common.LogQuota(redemption.Quota))
+	if redemption.QuotaValidDays > 0 {
+		content = fmt.Sprintf("%s，有效期 %d 天", content, redemption.QuotaValidDays)
+	}
+	RecordQuotaLog(userId, LogTypeTopup, redemption.Quota, ip, content)
 
 	// 处理邀请人充值返利
-	err = ProcessInviterReward(userId, redemption.Quota, ip)
+	err = ProcessInviterReward(userId, redemption.Quota, AffiliateSourceRedemption, fmt.Sprintf("%d", redemption.Id), ip)
 	if err != nil {
 		logger.SysError("failed to process inviter reward for redemption: " + err.Error())
 	}
@@ -104,6 +133,31 @@ func Redeem(key string, userId int, ip string) (quota int, err error) {
 	return redemption.Quota, nil
 }
 
+// recordCampaignRedeem 活动兑换码属于赠送，不计入累计充值，也不产生邀请返利
+func recordCampaignRedeem(campaign *RedemptionCampaign, usage *RedemptionUsage, userId int, ip string) int {
+	clearUserQuotaCache(userId)
+
+	content := fmt.Sprintf("参与兑换活动 %s", campaign.Name)
+	if campaign.Quota > 0 {
+		content = fmt.Sprintf("%s，获得额度 %s", content, common.LogQuota(campaign.Quota))
+		if campaign.QuotaValidDays > 0 {
+			content = fmt.Sprintf("%s，有效期 %d 天", content, campaign.QuotaValidDays)
+		}
+	}
+	if usage.Group != "" {
+		if config.RedisEnabled {
+			redis.RedisDel(fmt.Sprintf(UserGroupCacheKey, userId))
+		}
+		content = fmt.Sprintf("%s，用户分组变更为 %s", content, usage.Group)
+		if usage.GroupExpireAt > 0 {
+			content = fmt.Sprintf("%s，有效期 %d 天", content, campaign.UpgradeDays)
+		}
+	}
+	RecordQuotaLog(userId, LogTypeTopup, campaign.Quota, ip, content)
+
+	return campaign.Quota
+}
+
 func (redemption *Redemption) Insert() error {
 	var err error
 	err = DB.Create(redemption).Error
@@ -118,7 +172,7 @@ func (redemption *Redemption) SelectUpdate() error {
 // Update Make sure your token's fields is completed, because this will update non-zero values
 func (redemption *Redemption) Update() error {
 	var err error
-	err = DB.Model(redemption).Select("name", "status", "quota", "redeemed_time").Updates(redemption).Error
+	err = DB.Model(redemption).Select("name", "status", "quota", "quota_valid_days", "redeemed_time").Updates(redemption).Error
 	return err
 }
 
@@ -146,9 +200,27 @@ type RedemptionStatistics struct {
 	Status int   `json:"status"`
 }
 
+// GetStatisticsRedemption 按状态统计普通兑换码，活动兑换码的兑换记录计入已使用
 func GetStatisticsRedemption() (redemptionStatistics []*RedemptionStatistics, err error) {
-	err = DB.Model(&Redemption{}).Select("status", "count(*) as count", "sum(quota) as quota").Where("status != ?", 2).Group("status").Scan(&redemptionStatistics).Error
-	return redemptionStatistics, err
+	err = DB.Model(&Redemption{}).Select("status", "count(*) as count", "sum(quota) as quota").Where("status != ? AND campaign_id = 0", 2).Group("status").Scan(&redemptionStatistics).Error
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &RedemptionStatistics{Status: config.RedemptionCodeStatusUsed}
+	err = DB.Model(&RedemptionUsage{}).Select("count(*) as count", "COALESCE(sum(quota), 0) as quota").Scan(usage).Error
+	if err != nil || usage.Count == 0 {
+		return redemptionStatistics, err
+	}
+
+	for _, stat := range redemptionStatistics {
+		if stat.Status == config.RedemptionCodeStatusUsed {
+			stat.Count += usage.Count
+			stat.Quota += usage.Quota
+			return redemptionStatistics, nil
+		}
+	}
+	return append(redemptionStatistics, usage), nil
 }
 
 type RedemptionStatisticsGroup struct {
@@ -157,19 +229,31 @@ type RedemptionStatisticsGroup struct {
 	UserCount int64  `json:"user_count"`
 }
 
-func GetStatisticsRedemptionByPeriod(startTimestamp, endTimestamp int64) (redemptionStatistics []*RedemptionStatisticsGroup, err error) {
+// GetStatisticsRedemptionByPeriod 按天统计兑换额度和用户数，campaignId 不为 0 时只统计该活动
+func GetStatisticsRedemptionByPeriod(startTimestamp, endTimestamp int64, campaignId int) (redemptionStatistics []*RedemptionStatisticsGroup, err error) {
 	groupSelect := getTimestampGroupsSelect("redeemed_time", "day", "date")
 
+	// 普通兑换码只能兑换一次，直接按兑换码统计；活动兑换码按兑换记录统计
+	source := `
+		SELECT user_id, quota, redeemed_time FROM redemptions WHERE status = 3 AND campaign_id = 0
+		UNION ALL
+		SELECT user_id, quota, redeemed_time FROM redemption_usages`
+	args := []any{}
+	if campaignId > 0 {
+		source = `SELECT user_id, quota, redeemed_time FROM redemption_usages WHERE campaign_id = ?`
+		args = append(args, campaignId)
+	}
+	args = append(args, startTimestamp, endTimestamp)
+
 	err = DB.Raw(`
 		SELECT `+groupSelect+`,
 		sum(quota) as quota,
 		count(distinct user_id) as user_count
-		FROM redemptions
-		WHERE status=3
-		AND redeemed_time BETWEEN ? AND ?
+		FROM (`+source+`) redeemed
+		WHERE redeemed_time BETWEEN ? AND ?
 		GROUP BY date
 		ORDER BY date
-	`, startTimestamp, endTimestamp).Scan(&redemptionStatistics).Error
+	`, args...).Scan(&redemptionStatistics).Error
 
 	return redemptionStatistics, err
 }
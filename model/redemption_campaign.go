@@ -0,0 +1,362 @@
+package model
+
+import (
+	"done-hub/common/config"
+	"done-hub/common/logger"
+	"done-hub/common/redis"
+	"done-hub/common/utils"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// RedemptionCampaignMaxCodes 单次批量生成兑换码的上限
+const RedemptionCampaignMaxCodes = 1000
+
+// RedemptionCampaign 兑换活动，活动下的兑换码共用额度、有效期和兑换条件，兑换时以活动的当前设置为准
+type RedemptionCampaign struct {
+	Id             int    `json:"id"`
+	Name           string `json:"name" gorm:"type:varchar(50)"`
+	Description    string `json:"description" gorm:"type:varchar(255);default:''"`
+	Quota          int    `json:"quota" gorm:"default:0"`
+	QuotaValidDays int    `json:"quota_valid_days" gorm:"default:0"`                // 兑换后额度的有效天数，0 表示永久有效
+	UpgradeGroup   string `json:"upgrade_group" gorm:"type:varchar(50);default:''"` // 兑换后切换到的用户分组，为空时不变
+	UpgradeDays    int    `json:"upgrade_days" gorm:"default:0"`                    // 升级分组的有效天数，到期后恢复原分组，0 表示永久
+	Groups         string `json:"groups" gorm:"type:varchar(255);default:''"`       // 允许兑换的用户分组，逗号分隔，为空时不限
+	NewUserDays    int    `json:"new_user_days" gorm:"default:0"`                   // 只允许注册不超过该天数的用户兑换，0 表示不限
+	ExpiresAt      int64  `json:"expires_at" gorm:"bigint;default:0"`               // 活动截止时间，0 表示不过期
+	Enable         *bool  `json:"enable" gorm:"default:true"`
+	UserId         int    `json:"user_id"`
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime    int64  `json:"updated_time" gorm:"bigint"`
+}
+
+// RedemptionUsage 活动兑换码的兑换记录，同一活动每个用户只能兑换一次
+type RedemptionUsage struct {
+	Id            int    `json:"id"`
+	RedemptionId  int    `json:"redemption_id" gorm:"index"`
+	CampaignId    int    `json:"campaign_id" gorm:"uniqueIndex:idx_redemption_usage_user"`
+	UserId        int    `json:"user_id" gorm:"uniqueIndex:idx_redemption_usage_user"`
+	Quota         int    `json:"quota" gorm:"default:0"`
+	Group         string `json:"group" gorm:"type:varchar(50);default:''"`          // 实际升级到的分组，未升级时为空
+	PreviousGroup string `json:"previous_group" gorm:"type:varchar(50);default:''"` // 升级前的分组，到期后恢复
+	GroupExpireAt int64  `json:"group_expire_at" gorm:"bigint;default:0;index"`     // 升级分组的到期时间，0 表示永久或已恢复
+	RedeemedTime  int64  `json:"redeemed_time" gorm:"bigint;index"`
+}
+
+var allowedRedemptionCampaignOrderFields = map[string]bool{
+	"id":           true,
+	"name":         true,
+	"quota":        true,
+	"expires_at":   true,
+	"created_time": true,
+}
+
+func GetRedemptionCampaignsList(params *GenericParams) (*DataResult[RedemptionCampaign], error) {
+	var campaigns []*RedemptionCampaign
+	db := DB
+	if params.Keyword != "" {
+		db = db.Where("id = ? or name LIKE ?", utils.String2Int(params.Keyword), params.Keyword+"%")
+	}
+
+	return PaginateAndOrder(db, &params.PaginationParams, &campaigns, allowedRedemptionCampaignOrderFields)
+}
+
+func GetRedemptionCampaignById(id int) (*RedemptionCampaign, error) {
+	var campaign RedemptionCampaign
+	err := DB.First(&campaign, id).Error
+	return &campaign, err
+}
+
+func (c *RedemptionCampaign) Validate() error {
+	if c.Name == "" || len(c.Name) > 20 {
+		return errors.New("活动名称长度必须在1-20之间")
+	}
+	if c.Quota < 0 {
+		return errors.New("额度不能为负数")
+	}
+	if c.QuotaValidDays < 0 {
+		return errors.New("额度有效天数不能为负数")
+	}
+	if c.NewUserDays < 0 {
+		return errors.New("新用户天数不能为负数")
+	}
+	if c.UpgradeDays < 0 {
+		return errors.New("升级分组有效天数不能为负数")
+	}
+	if c.Quota == 0 && c.UpgradeGroup == "" {
+		return errors.New("额度和升级分组不能同时为空")
+	}
+	if c.UpgradeGroup != "" && GlobalUserGroupRatio.GetBySymbol(c.UpgradeGroup) == nil {
+		return errors.New("升级分组不存在")
+	}
+	for _, group := range c.GroupList() {
+		if GlobalUserGroupRatio.GetBySymbol(group) == nil {
+			return fmt.Errorf("分组 %s 不存在", group)
+		}
+	}
+	c.Groups = strings.Join(c.GroupList(), ",")
+	return nil
+}
+
+// GroupList 允许兑换的用户分组
+func (c *RedemptionCampaign) GroupList() []string {
+	groups := make([]string, 0)
+	for _, group := range strings.Split(c.Groups, ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+func (c *RedemptionCampaign) Insert() error {
+	c.CreatedTime = utils.GetTimestamp()
+	c.UpdatedTime = c.CreatedTime
+	return DB.Create(c).Error
+}
+
+// Update 更新活动设置，同时同步活动下兑换码的名称和额度，便于在兑换码列表中查看
+func (c *RedemptionCampaign) Update() error {
+	c.UpdatedTime = utils.GetTimestamp()
+	return DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Select("name", "description", "quota", "quota_valid_days", "upgrade_group", "upgrade_days", "groups", "new_user_days", "expires_at", "enable", "updated_time").Updates(c).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&Redemption{}).Where("campaign_id = ?", c.Id).Updates(map[string]any{
+			"name":             c.Name,
+			"quota":            c.Quota,
+			"quota_valid_days": c.QuotaValidDays,
+		}).Error
+	})
+}
+
+// DeleteRedemptionCampaignById 删除活动及其兑换码，兑换记录保留用于统计
+func DeleteRedemptionCampaignById(id int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("campaign_id = ?", id).Delete(&Redemption{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&RedemptionCampaign{}, id).Error
+	})
+}
+
+// GenerateCampaignRedemptions 为活动批量生成兑换码，每个兑换码最多可以被 maxUses 个不同用户兑换
+func GenerateCampaignRedemptions(campaign *RedemptionCampaign, count int, maxUses int, userId int) ([]*Redemption, error) {
+	if count <= 0 || count > RedemptionCampaignMaxCodes {
+		return nil, fmt.Errorf("兑换码个数必须在 1 到 %d 之间", RedemptionCampaignMaxCodes)
+	}
+	if maxUses <= 0 {
+		return nil, errors.New("兑换次数必须大于 0")
+	}
+
+	now := utils.GetTimestamp()
+	redemptions := make([]*Redemption, 0, count)
+	for i := 0; i < count; i++ {
+		redemptions = append(redemptions, &Redemption{
+			UserId:         userId,
+			CampaignId:     campaign.Id,
+			Key:            utils.GetUUID(),
+			Name:           campaign.Name,
+			Quota:          campaign.Quota,
+			QuotaValidDays: campaign.QuotaValidDays,
+			MaxUses:        maxUses,
+			CreatedTime:    now,
+		})
+	}
+
+	if err := DB.CreateInBatches(redemptions, 100).Error; err != nil {
+		return nil, err
+	}
+	return redemptions, nil
+}
+
+func GetCampaignRedemptions(campaignId int) ([]*Redemption, error) {
+	var redemptions []*Redemption
+	err := DB.Where("campaign_id = ?", campaignId).Order("id").Find(&redemptions).Error
+	return redemptions, err
+}
+
+// checkCampaignEligibility 检查活动状态和用户是否满足兑换条件
+func checkCampaignEligibility(campaign *RedemptionCampaign, user *User, now int64) error {
+	if campaign.Enable != nil && !*campaign.Enable {
+		return errors.New("兑换活动已停用")
+	}
+	if campaign.ExpiresAt > 0 && now > campaign.ExpiresAt {
+		return errors.New("兑换码已过期")
+	}
+	if campaign.NewUserDays > 0 && user.CreatedTime+int64(campaign.NewUserDays)*24*3600 < now {
+		return errors.New("该兑换码仅限新用户使用")
+	}
+	if groups := campaign.GroupList(); len(groups) > 0 && !utils.Contains(user.Group, groups) {
+		return errors.New("当前分组不能使用该兑换码")
+	}
+	return nil
+}
+
+// redeemCampaignCode 在兑换事务中处理活动兑换码，兑换码已加锁，返回对应的活动和兑换记录
+func redeemCampaignCode(tx *gorm.DB, redemption *Redemption, userId int, now int64) (*RedemptionCampaign, *RedemptionUsage, error) {
+	var campaign RedemptionCampaign
+	if err := tx.First(&campaign, redemption.CampaignId).Error; err != nil {
+		return nil, nil, errors.New("兑换活动不存在")
+	}
+
+	var user User
+	if err := tx.First(&user, userId).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := checkCampaignEligibility(&campaign, &user, now); err != nil {
+		return nil, nil, err
+	}
+
+	var count int64
+	if err := tx.Model(&RedemptionUsage{}).Where("campaign_id = ? AND user_id = ?", campaign.Id, userId).Count(&count).Error; err != nil {
+		return nil, nil, err
+	}
+	if count > 0 {
+		return nil, nil, errors.New("您已参与过该活动")
+	}
+
+	usage := &RedemptionUsage{
+		RedemptionId: redemption.Id,
+		CampaignId:   campaign.Id,
+		UserId:       userId,
+		Quota:        campaign.Quota,
+		RedeemedTime: now,
+	}
+	if err := upgradeCampaignGroup(tx, &campaign, &user, usage, now); err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Create(usage).Error; err != nil {
+		return nil, nil, errors.New("您已参与过该活动")
+	}
+
+	if campaign.Quota > 0 {
+		err := grantQuotaLot(tx, userId, campaign.Quota, QuotaLotSourceRedemption, fmt.Sprintf("%d", redemption.Id), QuotaValidDaysToExpiresAt(campaign.QuotaValidDays), campaign.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	redemption.UsedCount++
+	redemption.RedeemedTime = now
+	if redemption.UsedCount >= redemption.MaxUses {
+		redemption.Status = config.RedemptionCodeStatusUsed
+	}
+	return &campaign, usage, nil
+}
+
+// upgradeCampaignGroup 切换到活动的升级分组，用户当前分组更高时不变，结果记录在兑换记录上
+func upgradeCampaignGroup(tx *gorm.DB, campaign *RedemptionCampaign, user *User, usage *RedemptionUsage, now int64) error {
+	if campaign.UpgradeGroup == "" || campaign.UpgradeGroup == user.Group || !isGroupUpgrade(user.Group, campaign.UpgradeGroup) {
+		return nil
+	}
+
+	// 当前分组来自其他活动的限时升级时，沿用其记录的原分组，并由本次升级接管到期恢复
+	previousGroup := user.Group
+	var current RedemptionUsage
+	err := tx.Where("user_id = ? AND "+quotePostgresField("group")+" = ? AND group_expire_at > 0", user.Id, user.Group).
+		Order("id desc").First(&current).Error
+	if err == nil {
+		previousGroup = current.PreviousGroup
+		if err := tx.Model(&RedemptionUsage{}).Where("id = ?", current.Id).Update("group_expire_at", 0).Error; err != nil {
+			return err
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	usage.Group = campaign.UpgradeGroup
+	usage.PreviousGroup = previousGroup
+	if campaign.UpgradeDays > 0 {
+		usage.GroupExpireAt = now + int64(campaign.UpgradeDays)*24*3600
+	}
+
+	return tx.Model(&User{}).Where("id = ?", user.Id).Update("group", campaign.UpgradeGroup).Error
+}
+
+// isGroupUpgrade 按晋级条件最小值比较分组高低，与充值自动升级的规则一致
+func isGroupUpgrade(currentGroup, targetGroup string) bool {
+	target := GlobalUserGroupRatio.GetBySymbol(targetGroup)
+	if target == nil {
+		return false
+	}
+
+	current := GlobalUserGroupRatio.GetBySymbol(currentGroup)
+	return current == nil || current.Min <= target.Min
+}
+
+// ExpireCampaignGroupUpgrades 恢复到期的活动升级分组，用户仍在升级分组时才恢复，由定时任务调用
+func ExpireCampaignGroupUpgrades() (int, error) {
+	var usages []*RedemptionUsage
+	err := DB.Where("group_expire_at > 0 AND group_expire_at <= ?", utils.GetTimestamp()).
+		Order("id").Limit(100).Find(&usages).Error
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, usage := range usages {
+		previousGroup := usage.PreviousGroup
+		if previousGroup == "" {
+			previousGroup = "default"
+		}
+
+		reverted := false
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&RedemptionUsage{}).Where("id = ? AND group_expire_at = ?", usage.Id, usage.GroupExpireAt).Update("group_expire_at", 0)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+
+			result = tx.Model(&User{}).Where("id = ? AND "+quotePostgresField("group")+" = ?", usage.UserId, usage.Group).
+				Update("group", previousGroup)
+			reverted = result.RowsAffected > 0
+			return result.Error
+		})
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to expire campaign group upgrade %d: %s", usage.Id, err.Error()))
+			continue
+		}
+		if !reverted {
+			continue
+		}
+
+		if config.RedisEnabled {
+			redis.RedisDel(fmt.Sprintf(UserGroupCacheKey, usage.UserId))
+		}
+		RecordLog(usage.UserId, LogTypeSystem, fmt.Sprintf("活动升级分组 %s 已到期，恢复为 %s", usage.Group, previousGroup))
+		count++
+	}
+
+	return count, nil
+}
+
+type RedemptionCampaignStatistics struct {
+	CodeCount     int64 `json:"code_count"`
+	UsedCodeCount int64 `json:"used_code_count"` // 已用完次数的兑换码
+	UserCount     int64 `json:"user_count"`
+	Quota         int64 `json:"quota"`
+}
+
+func GetRedemptionCampaignStatistics(campaignId int) (*RedemptionCampaignStatistics, error) {
+	statistics := &RedemptionCampaignStatistics{}
+	err := DB.Model(&Redemption{}).Where("campaign_id = ?", campaignId).Count(&statistics.CodeCount).Error
+	if err != nil {
+		return nil, err
+	}
+	err = DB.Model(&Redemption{}).Where("campaign_id = ? AND status = ?", campaignId, config.RedemptionCodeStatusUsed).Count(&statistics.UsedCodeCount).Error
+	if err != nil {
+		return nil, err
+	}
+	err = DB.Model(&RedemptionUsage{}).Select("count(*) as user_count, COALESCE(sum(quota), 0) as quota").
+		Where("campaign_id = ?", campaignId).Scan(statistics).Error
+	if err != nil {
+		return nil, err
+	}
+	return statistics, nil
+}
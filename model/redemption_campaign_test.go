@@ -0,0 +1,69 @@
+package model
+
+import (
+	"testing"
+
+	"done-hub/common/config"
+	"done-hub/common/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupCampaignTest(t *testing.T) {
+	setupTestDB(t, &User{}, &UserGroup{}, &Redemption{}, &RedemptionCampaign{}, &RedemptionUsage{}, &QuotaLot{}, &Log{})
+	for _, group := range []*UserGroup{
+		{Symbol: "default", Name: "default", Ratio: 1},
+		{Symbol: "vip", Name: "vip", Ratio: 1, Min: 10},
+		{Symbol: "svip", Name: "svip", Ratio: 1, Min: 100},
+	} {
+		assert.NoError(t, DB.Create(group).Error)
+	}
+	GlobalUserGroupRatio.Load()
+}
+
+func createTestCampaignCode(t *testing.T, key string, upgradeDays int) {
+	campaign := &RedemptionCampaign{Name: "campaign-" + key, UpgradeGroup: "vip", UpgradeDays: upgradeDays}
+	assert.NoError(t, campaign.Insert())
+	redemption := &Redemption{Key: key, Name: campaign.Name, Status: config.RedemptionCodeStatusEnabled, CampaignId: campaign.Id, MaxUses: 10}
+	assert.NoError(t, DB.Create(redemption).Error)
+}
+
+func getTestUserGroup(t *testing.T, userId int) string {
+	user := &User{}
+	assert.NoError(t, DB.First(user, userId).Error)
+	return user.Group
+}
+
+func TestRedeemCampaignGroupUpgrade(t *testing.T) {
+	setupCampaignTest(t)
+	createTestCampaignCode(t, "upgrade", 7)
+
+	createTestUser(t, 1)
+	assert.NoError(t, DB.Model(&User{}).Where("id = ?", 1).Update("group", "default").Error)
+	createTestUser(t, 2)
+	assert.NoError(t, DB.Model(&User{}).Where("id = ?", 2).Update("group", "svip").Error)
+
+	_, err := Redeem("upgrade", 1, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "vip", getTestUserGroup(t, 1))
+
+	// 当前分组更高时不降级
+	_, err = Redeem("upgrade", 2, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "svip", getTestUserGroup(t, 2))
+
+	usage := &RedemptionUsage{}
+	assert.NoError(t, DB.Where("user_id = ?", 1).First(usage).Error)
+	assert.Equal(t, "default", usage.PreviousGroup)
+	assert.Greater(t, usage.GroupExpireAt, utils.GetTimestamp())
+
+	count, err := ExpireCampaignGroupUpgrades()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	assert.NoError(t, DB.Model(usage).Update("group_expire_at", utils.GetTimestamp()-1).Error)
+	count, err = ExpireCampaignGroupUpgrades()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, "default", getTestUserGroup(t, 1))
+}
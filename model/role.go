@@ -0,0 +1,229 @@
+package model
+
+import (
+	"done-hub/common/cache"
+	"done-hub/common/config"
+	"done-hub/common/redis"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Permissions 管理后台的权限，格式为 资源:read 或 资源:write，write 不包含 read
+var Permissions = []string{
+	"channels:read", "channels:write",
+	"users:read", "users:write",
+	"groups:read", "groups:write",
+	"prices:read", "prices:write",
+	"payments:read", "payments:write",
+	"redemptions:read", "redemptions:write",
+	"logs:read", "logs:write",
+	"audit_logs:read",
+	"tasks:read",
+	"analytics:read",
+	"webhooks:read", "webhooks:write",
+	"admin_tokens:write",
+	"roles:read", "roles:write",
+	"options:read", "options:write",
+}
+
+// 未分配角色的管理员沿用原有权限：除系统设置和角色管理外的全部权限
+var defaultAdminPermissions = slices.DeleteFunc(slices.Clone(Permissions), func(permission string) bool {
+	return strings.HasPrefix(permission, "options:") || strings.HasPrefix(permission, "roles:")
+})
+
+var UserRoleIdCacheKey = "user_role_id:%d"
+
+// Role 管理后台角色，分配给用户后替代按 User.Role 等级的权限判断，超级管理员始终拥有全部权限
+type Role struct {
+	Id          int                         `json:"id"`
+	Name        string                      `json:"name" gorm:"type:varchar(50);uniqueIndex"`
+	Description string                      `json:"description" gorm:"type:varchar(255);default:''"`
+	Permissions datatypes.JSONSlice[string] `json:"permissions" gorm:"type:json"`
+	CreatedTime int64                       `json:"created_time" gorm:"bigint"`
+}
+
+func GetRolesAll() ([]*Role, error) {
+	var roles []*Role
+	err := DB.Order("id asc").Find(&roles).Error
+	return roles, err
+}
+
+func GetRoleById(id int) (*Role, error) {
+	var role Role
+	err := DB.Where("id = ?", id).First(&role).Error
+	return &role, err
+}
+
+// Validate 校验名称和权限
+func (r *Role) Validate() error {
+	if r.Name == "" || len(r.Name) > 50 {
+		return errors.New("角色名称不能为空且不能超过50个字符")
+	}
+
+	for _, permission := range r.Permissions {
+		if !slices.Contains(Permissions, permission) {
+			return fmt.Errorf("无效的权限: %s", permission)
+		}
+	}
+
+	return nil
+}
+
+func (r *Role) Insert() error {
+	err := DB.Create(r).Error
+	if err == nil {
+		GlobalRoles.Load()
+	}
+	return err
+}
+
+func (r *Role) Update() error {
+	err := DB.Model(r).Select("name", "description", "permissions").Updates(r).Error
+	if err == nil {
+		GlobalRoles.Load()
+	}
+	return err
+}
+
+// Delete 删除角色，已分配该角色的用户恢复为按等级判断权限
+func (r *Role) Delete() error {
+	var userIds []int
+	if err := DB.Model(&User{}).Where("role_id = ?", r.Id).Pluck("id", &userIds).Error; err != nil {
+		return err
+	}
+
+	if err := DB.Model(&User{}).Where("role_id = ?", r.Id).Update("role_id", 0).Error; err != nil {
+		return err
+	}
+
+	err := DB.Delete(r).Error
+	if err == nil {
+		GlobalRoles.Load()
+	}
+
+	if config.RedisEnabled {
+		for _, userId := range userIds {
+			redis.RedisDel(fmt.Sprintf(UserRoleIdCacheKey, userId))
+		}
+	}
+
+	return err
+}
+
+type Roles struct {
+	sync.RWMutex
+	Roles map[int]*Role
+}
+
+var GlobalRoles = Roles{}
+
+func (rs *Roles) Load() {
+	roles, err := GetRolesAll()
+	if err != nil {
+		return
+	}
+
+	newRoles := make(map[int]*Role, len(roles))
+	for _, role := range roles {
+		newRoles[role.Id] = role
+	}
+
+	rs.Lock()
+	defer rs.Unlock()
+
+	rs.Roles = newRoles
+}
+
+// SyncRoles 定期从数据库重新加载角色，多节点部署时其他节点修改的角色权限也能生效
+func SyncRoles(frequency int) {
+	if frequency <= 0 {
+		return
+	}
+
+	for {
+		time.Sleep(time.Duration(frequency) * time.Second)
+		GlobalRoles.Load()
+	}
+}
+
+func (rs *Roles) Get(id int) *Role {
+	rs.RLock()
+	defer rs.RUnlock()
+
+	return rs.Roles[id]
+}
+
+func GetUserRoleId(id int) (roleId int, err error) {
+	err = DB.Model(&User{}).Where("id = ?", id).Select("role_id").Find(&roleId).Error
+	return roleId, err
+}
+
+func CacheGetUserRoleId(id int) (roleId int, err error) {
+	if !config.RedisEnabled {
+		return GetUserRoleId(id)
+	}
+
+	return cache.GetOrSetCache(
+		fmt.Sprintf(UserRoleIdCacheKey, id),
+		time.Duration(TokenCacheSeconds)*time.Second,
+		func() (int, error) {
+			return GetUserRoleId(id)
+		},
+		cache.CacheTimeout)
+}
+
+// SetUserRoleId 分配角色，0 表示取消角色
+func SetUserRoleId(userId, roleId int) error {
+	if roleId != 0 && GlobalRoles.Get(roleId) == nil {
+		return errors.New("角色不存在")
+	}
+
+	err := DB.Model(&User{}).Where("id = ?", userId).Update("role_id", roleId).Error
+	if err == nil && config.RedisEnabled {
+		redis.RedisDel(fmt.Sprintf(UserRoleIdCacheKey, userId))
+	}
+
+	return err
+}
+
+// GetUserPermissions 用户拥有的权限：超级管理员拥有全部权限，已分配角色的管理员按角色，未分配角色的管理员使用默认权限
+// 角色只细分管理员的权限，普通用户没有管理后台权限，和控制器中按 User.Role 等级的判断保持一致
+func GetUserPermissions(userId, userRole int) []string {
+	if userRole >= config.RoleRootUser {
+		return Permissions
+	}
+	if userRole < config.RoleAdminUser {
+		return nil
+	}
+
+	roleId, err := CacheGetUserRoleId(userId)
+	if err == nil && roleId > 0 {
+		if role := GlobalRoles.Get(roleId); role != nil {
+			return role.Permissions
+		}
+	}
+
+	return defaultAdminPermissions
+}
+
+func UserHasPermission(userId, userRole int, permission string) bool {
+	return slices.Contains(GetUserPermissions(userId, userRole), permission)
+}
+
+// CheckGrantPermissions 只能授予自己拥有的权限，拥有 roles:write 的用户不能借修改角色为自己或他人提升权限
+func CheckGrantPermissions(userId, userRole int, permissions []string) error {
+	held := GetUserPermissions(userId, userRole)
+	for _, permission := range permissions {
+		if !slices.Contains(held, permission) {
+			return fmt.Errorf("无权授予自己没有的权限: %s", permission)
+		}
+	}
+
+	return nil
+}
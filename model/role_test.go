@@ -0,0 +1,33 @@
+package model
+
+import (
+	"testing"
+
+	"done-hub/common/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestRole(t *testing.T, name string, permissions ...string) *Role {
+	role := &Role{Name: name, Permissions: permissions}
+	if err := role.Insert(); err != nil {
+		t.Fatal(err)
+	}
+
+	return role
+}
+
+func TestCheckGrantPermissions(t *testing.T) {
+	setupTestDB(t, &User{}, &Role{})
+
+	role := createTestRole(t, "operator", "roles:read", "roles:write", "channels:read")
+	user := createTestUser(t, 1)
+	assert.NoError(t, DB.Model(user).Updates(map[string]any{"role": config.RoleAdminUser, "role_id": role.Id}).Error)
+
+	// 拥有 roles:write 也不能授予自己没有的权限
+	assert.NoError(t, CheckGrantPermissions(user.Id, config.RoleAdminUser, []string{"channels:read", "roles:write"}))
+	assert.Error(t, CheckGrantPermissions(user.Id, config.RoleAdminUser, []string{"channels:read", "options:write"}))
+
+	// 超级管理员拥有全部权限
+	assert.NoError(t, CheckGrantPermissions(2, config.RoleRootUser, Permissions))
+}
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"done-hub/common"
+	"done-hub/common/logger"
+	"done-hub/common/secret"
+	"errors"
+	"fmt"
+)
+
+// ReEncryptSecrets 使用当前主密钥重新加密渠道密钥和支付配置，包括已删除的记录
+// 明文数据会被加密，旧主密钥加密的数据只重新加密数据密钥
+func ReEncryptSecrets() error {
+	if !secret.Enabled() {
+		return errors.New("encryption master key is not set")
+	}
+
+	var channels []*Channel
+	if err := DB.Unscoped().Select("id", "type", "key", "key_hash", "other").Find(&channels).Error; err != nil {
+		return err
+	}
+
+	channelCount := 0
+	for _, channel := range channels {
+		key, err := secret.Rotate(channel.Key)
+		if err != nil {
+			return fmt.Errorf("channel %d key: %w", channel.Id, err)
+		}
+		other := channel.Other
+		if channel.otherIsSecret() {
+			if other, err = secret.Rotate(channel.Other); err != nil {
+				return fmt.Errorf("channel %d other: %w", channel.Id, err)
+			}
+		}
+		if key == channel.Key && other == channel.Other {
+			continue
+		}
+
+		keyHash := channel.KeyHash
+		if channel.Key != "" && !secret.IsEncrypted(channel.Key) {
+			keyHash = common.HashToken(channel.Key)
+		}
+
+		err = DB.Unscoped().Model(&Channel{}).Where("id = ?", channel.Id).UpdateColumns(map[string]any{
+			"key":      key,
+			"key_hash": keyHash,
+			"other":    other,
+		}).Error
+		if err != nil {
+			return err
+		}
+		channelCount++
+	}
+
+	var payments []*Payment
+	if err := DB.Unscoped().Select("id", "config").Find(&payments).Error; err != nil {
+		return err
+	}
+
+	paymentCount := 0
+	for _, payment := range payments {
+		config, err := secret.Rotate(payment.Config)
+		if err != nil {
+			return fmt.Errorf("payment %d config: %w", payment.ID, err)
+		}
+		if config == payment.Config {
+			continue
+		}
+
+		err = DB.Unscoped().Model(&Payment{}).Where("id = ?", payment.ID).UpdateColumn("config", config).Error
+		if err != nil {
+			return err
+		}
+		paymentCount++
+	}
+
+	logger.SysLog(fmt.Sprintf("re-encrypted %d channels and %d payments", channelCount, paymentCount))
+	return nil
+}
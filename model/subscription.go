@@ -0,0 +1,576 @@
+package model
+
+import (
+	"done-hub/common"
+	"done-hub/common/cache"
+	"done-hub/common/config"
+	"done-hub/common/logger"
+	"done-hub/common/redis"
+	"done-hub/common/stmp"
+	"done-hub/common/utils"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const QuotaLotSourceSubscription = "subscription"
+
+const (
+	UserSubscriptionStatusActive    = "active"
+	UserSubscriptionStatusGrace     = "grace"
+	UserSubscriptionStatusExpired   = "expired"
+	UserSubscriptionStatusCancelled = "cancelled"
+)
+
+const SubscriptionMaxMonths = 12
+
+var UserSubscriptionCacheKey = "user_subscription:%d"
+
+// SubscriptionPlan 订阅套餐，按周期赠送额度，订阅期间可切换用户分组，并可为指定模型提供免费请求次数
+type SubscriptionPlan struct {
+	Id              int                                `json:"id"`
+	Name            string                             `json:"name" gorm:"type:varchar(50)"`
+	Description     string                             `json:"description" gorm:"type:varchar(500);default:''"`
+	Price           float64                            `json:"price" gorm:"type:decimal(10,2);default:0"` // 每周期价格（USD）
+	DurationDays    int                                `json:"duration_days" gorm:"default:30"`           // 周期天数
+	Quota           int                                `json:"quota" gorm:"default:0"`                    // 每周期赠送的额度，周期结束时未用完的部分过期
+	Group           string                             `json:"group" gorm:"type:varchar(50);default:''"`  // 订阅期间的用户分组，为空时不变
+	ModelAllowances datatypes.JSONType[map[string]int] `json:"model_allowances" gorm:"type:json"`         // 每周期各模型的免费请求次数
+	Sort            int                                `json:"sort" gorm:"default:0"`
+	Enable          *bool                              `json:"enable" gorm:"default:true"`
+	CreatedAt       int64                              `json:"created_at" gorm:"bigint"`
+	UpdatedAt       int64                              `json:"-" gorm:"bigint"`
+	DeletedAt       gorm.DeletedAt                     `json:"-" gorm:"index"`
+}
+
+// UserSubscription 用户订阅，周期和额度在开通时确定，修改套餐不影响已开通的订阅
+type UserSubscription struct {
+	Id            int    `json:"id"`
+	UserId        int    `json:"user_id" gorm:"index"`
+	PlanId        int    `json:"plan_id" gorm:"index"`
+	PlanName      string `json:"plan_name" gorm:"type:varchar(50);default:''"`
+	Status        string `json:"status" gorm:"type:varchar(16);index"`
+	DurationDays  int    `json:"duration_days"`
+	CycleQuota    int    `json:"cycle_quota"`
+	Group         string `json:"group" gorm:"type:varchar(50);default:''"`
+	PreviousGroup string `json:"previous_group" gorm:"type:varchar(50);default:''"`
+	StartTime     int64  `json:"start_time" gorm:"bigint"`
+	ExpiresAt     int64  `json:"expires_at" gorm:"bigint;index"`
+	CycleStart    int64  `json:"cycle_start" gorm:"bigint"`
+	NextGrantAt   int64  `json:"next_grant_at" gorm:"bigint"`
+	RemindedAt    int64  `json:"reminded_at" gorm:"bigint;default:0"`
+	TradeNo       string `json:"trade_no" gorm:"type:varchar(50);default:''"`
+	CreatedTime   int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime   int64  `json:"updated_time" gorm:"bigint"`
+}
+
+// SubscriptionAllowanceUsage 订阅在一个周期内各模型已使用的免费次数，多个实例共享同一计数
+type SubscriptionAllowanceUsage struct {
+	Id             int    `json:"id"`
+	SubscriptionId int    `json:"subscription_id" gorm:"uniqueIndex:idx_subscription_allowance_usage"`
+	CycleStart     int64  `json:"cycle_start" gorm:"bigint;uniqueIndex:idx_subscription_allowance_usage"`
+	ModelName      string `json:"model_name" gorm:"type:varchar(100);uniqueIndex:idx_subscription_allowance_usage"`
+	Used           int    `json:"used" gorm:"default:0"`
+}
+
+func (s *UserSubscription) cycleSeconds() int64 {
+	return int64(s.DurationDays) * 24 * 3600
+}
+
+var allowedSubscriptionPlanOrderFields = map[string]bool{
+	"id":    true,
+	"name":  true,
+	"price": true,
+	"sort":  true,
+}
+
+func GetSubscriptionPlansList(params *GenericParams) (*DataResult[SubscriptionPlan], error) {
+	var plans []*SubscriptionPlan
+	db := DB
+	if params.Keyword != "" {
+		db = db.Where("name LIKE ?", params.Keyword+"%")
+	}
+
+	return PaginateAndOrder(db, &params.PaginationParams, &plans, allowedSubscriptionPlanOrderFields)
+}
+
+func GetEnabledSubscriptionPlans() ([]*SubscriptionPlan, error) {
+	var plans []*SubscriptionPlan
+	err := DB.Where("enable = ?", true).Order("sort desc, id").Find(&plans).Error
+	return plans, err
+}
+
+func GetSubscriptionPlanById(id int) (*SubscriptionPlan, error) {
+	var plan SubscriptionPlan
+	err := DB.First(&plan, id).Error
+	return &plan, err
+}
+
+func (p *SubscriptionPlan) Validate() error {
+	if p.Name == "" || len(p.Name) > 50 {
+		return errors.New("套餐名称长度必须在1-50之间")
+	}
+	if p.Price <= 0 {
+		return errors.New("套餐价格必须大于 0")
+	}
+	if p.DurationDays <= 0 {
+		return errors.New("周期天数必须大于 0")
+	}
+	if p.Quota < 0 {
+		return errors.New("额度不能为负数")
+	}
+	if p.Group != "" && GlobalUserGroupRatio.GetBySymbol(p.Group) == nil {
+		return errors.New("分组不存在")
+	}
+	for modelName, count := range p.ModelAllowances.Data() {
+		if modelName == "" || count < 0 {
+			return errors.New("模型免费次数设置无效")
+		}
+	}
+	return nil
+}
+
+func (p *SubscriptionPlan) Insert() error {
+	p.CreatedAt = utils.GetTimestamp()
+	p.UpdatedAt = p.CreatedAt
+	return DB.Create(p).Error
+}
+
+func (p *SubscriptionPlan) Update() error {
+	p.UpdatedAt = utils.GetTimestamp()
+	return DB.Select("name", "description", "price", "duration_days", "quota", "group", "model_allowances", "sort", "enable", "updated_at").Updates(p).Error
+}
+
+func DeleteSubscriptionPlanById(id int) error {
+	return DB.Delete(&SubscriptionPlan{}, id).Error
+}
+
+type SearchUserSubscriptionParams struct {
+	UserId int    `form:"user_id"`
+	PlanId int    `form:"plan_id"`
+	Status string `form:"status"`
+	PaginationParams
+}
+
+var allowedUserSubscriptionOrderFields = map[string]bool{
+	"id":           true,
+	"user_id":      true,
+	"expires_at":   true,
+	"created_time": true,
+}
+
+func GetUserSubscriptionsList(params *SearchUserSubscriptionParams) (*DataResult[UserSubscription], error) {
+	var subscriptions []*UserSubscription
+	db := DB
+	if params.UserId != 0 {
+		db = db.Where("user_id = ?", params.UserId)
+	}
+	if params.PlanId != 0 {
+		db = db.Where("plan_id = ?", params.PlanId)
+	}
+	if params.Status != "" {
+		db = db.Where("status = ?", params.Status)
+	}
+
+	return PaginateAndOrder(db, &params.PaginationParams, &subscriptions, allowedUserSubscriptionOrderFields)
+}
+
+// GetUserCurrentSubscription 用户当前生效（含宽限期）的订阅
+func GetUserCurrentSubscription(userId int) (*UserSubscription, error) {
+	var subscription UserSubscription
+	err := DB.Where("user_id = ? AND status IN ?", userId, []string{UserSubscriptionStatusActive, UserSubscriptionStatusGrace}).
+		Order("id desc").First(&subscription).Error
+	return &subscription, err
+}
+
+// ActivateSubscription 订阅支付成功后开通或续订，同一套餐在到期后顺延，更换套餐时立即生效
+func ActivateSubscription(userId int, planId int, months int, tradeNo string) (*UserSubscription, error) {
+	if months <= 0 || months > SubscriptionMaxMonths {
+		return nil, fmt.Errorf("订阅月数必须在 1 到 %d 之间", SubscriptionMaxMonths)
+	}
+
+	plan, err := GetSubscriptionPlanById(planId)
+	if err != nil {
+		return nil, errors.New("套餐不存在")
+	}
+
+	user, err := GetUserById(userId, false)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := GetUserCurrentSubscription(userId)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	hasCurrent := err == nil
+
+	now := utils.GetTimestamp()
+	var subscription *UserSubscription
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		previousGroup := user.Group
+
+		if hasCurrent {
+			if current.PlanId == planId {
+				// 续订：从当前到期时间顺延，宽限期内续订则从现在开始新的周期
+				if current.Status == UserSubscriptionStatusGrace {
+					current.ExpiresAt = now
+					current.NextGrantAt = now
+				}
+				current.ExpiresAt += int64(months) * current.cycleSeconds()
+				current.Status = UserSubscriptionStatusActive
+				current.RemindedAt = 0
+				current.TradeNo = tradeNo
+				current.UpdatedTime = now
+				subscription = current
+				return tx.Save(current).Error
+			}
+
+			// 更换套餐，原订阅作废，沿用其记录的原分组
+			if current.Group != "" {
+				previousGroup = current.PreviousGroup
+			}
+			err := tx.Model(&UserSubscription{}).Where("id = ?", current.Id).Updates(map[string]any{
+				"status":       UserSubscriptionStatusCancelled,
+				"updated_time": now,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		subscription = &UserSubscription{
+			UserId:        userId,
+			PlanId:        plan.Id,
+			PlanName:      plan.Name,
+			Status:        UserSubscriptionStatusActive,
+			DurationDays:  plan.DurationDays,
+			CycleQuota:    plan.Quota,
+			Group:         plan.Group,
+			PreviousGroup: previousGroup,
+			StartTime:     now,
+			CycleStart:    now,
+			NextGrantAt:   now,
+			TradeNo:       tradeNo,
+			CreatedTime:   now,
+			UpdatedTime:   now,
+		}
+		subscription.ExpiresAt = now + int64(months)*subscription.cycleSeconds()
+		if err := tx.Create(subscription).Error; err != nil {
+			return err
+		}
+
+		targetGroup := plan.Group
+		if targetGroup == "" {
+			targetGroup = previousGroup
+		}
+		if targetGroup != user.Group {
+			return tx.Model(&User{}).Where("id = ?", userId).Update("group", targetGroup).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	clearUserSubscriptionCache(userId)
+	if config.RedisEnabled {
+		redis.RedisDel(fmt.Sprintf(UserGroupCacheKey, userId))
+	}
+	if err := grantSubscriptionCycles(subscription, now); err != nil {
+		logger.SysError(fmt.Sprintf("failed to grant subscription %d quota: %s", subscription.Id, err.Error()))
+	}
+
+	return subscription, nil
+}
+
+// CancelUserSubscription 管理员取消订阅，立即恢复原分组，已发放的额度不收回
+func CancelUserSubscription(id int) error {
+	var subscription UserSubscription
+	if err := DB.First(&subscription, id).Error; err != nil {
+		return errors.New("订阅不存在")
+	}
+	if subscription.Status != UserSubscriptionStatusActive && subscription.Status != UserSubscriptionStatusGrace {
+		return errors.New("订阅已失效")
+	}
+
+	return lapseSubscription(&subscription, UserSubscriptionStatusCancelled)
+}
+
+// CancelSubscriptionByTradeNo 订阅订单全额退款时取消由该订单开通或续订的订阅，没有生效中的订阅时返回 false
+func CancelSubscriptionByTradeNo(userId int, tradeNo string) (bool, error) {
+	var subscription UserSubscription
+	err := DB.Where("user_id = ? AND trade_no = ? AND status IN ?", userId, tradeNo, []string{UserSubscriptionStatusActive, UserSubscriptionStatusGrace}).
+		Limit(1).Find(&subscription).Error
+	if err != nil || subscription.Id == 0 {
+		return false, err
+	}
+
+	return true, lapseSubscription(&subscription, UserSubscriptionStatusCancelled)
+}
+
+// ProcessSubscriptions 发放到期周期的额度、发送续订提醒，并处理宽限期和过期的订阅
+func ProcessSubscriptions() {
+	now := utils.GetTimestamp()
+
+	var subscriptions []*UserSubscription
+	err := DB.Where("status = ? AND next_grant_at <= ? AND next_grant_at < expires_at", UserSubscriptionStatusActive, now).Find(&subscriptions).Error
+	if err != nil {
+		logger.SysError("failed to query subscriptions to grant: " + err.Error())
+	}
+	for _, subscription := range subscriptions {
+		if err := grantSubscriptionCycles(subscription, now); err != nil {
+			logger.SysError(fmt.Sprintf("failed to grant subscription %d quota: %s", subscription.Id, err.Error()))
+		}
+	}
+
+	if config.SubscriptionRemindDays > 0 {
+		subscriptions = nil
+		err = DB.Where("status = ? AND reminded_at = 0 AND expires_at <= ?", UserSubscriptionStatusActive, now+int64(config.SubscriptionRemindDays)*24*3600).Find(&subscriptions).Error
+		if err != nil {
+			logger.SysError("failed to query subscriptions to remind: " + err.Error())
+		}
+		for _, subscription := range subscriptions {
+			remindSubscription(subscription, now)
+		}
+	}
+
+	// 到期后进入宽限期，保留分组以便用户续订
+	err = DB.Model(&UserSubscription{}).Where("status = ? AND expires_at <= ?", UserSubscriptionStatusActive, now).Updates(map[string]any{
+		"status":       UserSubscriptionStatusGrace,
+		"updated_time": now,
+	}).Error
+	if err != nil {
+		logger.SysError("failed to update subscriptions to grace: " + err.Error())
+	}
+
+	subscriptions = nil
+	err = DB.Where("status = ? AND expires_at <= ?", UserSubscriptionStatusGrace, now-int64(config.SubscriptionGraceDays)*24*3600).Find(&subscriptions).Error
+	if err != nil {
+		logger.SysError("failed to query lapsed subscriptions: " + err.Error())
+	}
+	for _, subscription := range subscriptions {
+		if err := lapseSubscription(subscription, UserSubscriptionStatusExpired); err != nil {
+			logger.SysError(fmt.Sprintf("failed to expire subscription %d: %s", subscription.Id, err.Error()))
+		}
+	}
+}
+
+// grantSubscriptionCycles 发放所有已开始周期的额度，额度在周期结束时过期，错过的已结束周期不再补发
+func grantSubscriptionCycles(subscription *UserSubscription, now int64) error {
+	cycle := subscription.cycleSeconds()
+	if cycle <= 0 {
+		return errors.New("invalid subscription duration")
+	}
+
+	for subscription.NextGrantAt <= now && subscription.NextGrantAt < subscription.ExpiresAt {
+		cycleStart := subscription.NextGrantAt
+		cycleEnd := min(cycleStart+cycle, subscription.ExpiresAt)
+
+		granted := false
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			// 以 next_grant_at 作为条件，避免多个实例重复发放
+			result := tx.Model(&UserSubscription{}).Where("id = ? AND next_grant_at = ?", subscription.Id, cycleStart).Updates(map[string]any{
+				"cycle_start":   cycleStart,
+				"next_grant_at": cycleStart + cycle,
+				"updated_time":  now,
+			})
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+
+			// 之前周期的免费次数已不再使用
+			err := tx.Where("subscription_id = ? AND cycle_start < ?", subscription.Id, cycleStart).Delete(&SubscriptionAllowanceUsage{}).Error
+			if err != nil {
+				return err
+			}
+
+			if subscription.CycleQuota <= 0 || cycleEnd <= now {
+				return nil
+			}
+			granted = true
+			return grantQuotaLot(tx, subscription.UserId, subscription.CycleQuota, QuotaLotSourceSubscription, fmt.Sprintf("%d", subscription.Id), cycleEnd, subscription.PlanName)
+		})
+		if err != nil {
+			return err
+		}
+
+		subscription.CycleStart = cycleStart
+		subscription.NextGrantAt = cycleStart + cycle
+		clearUserSubscriptionCache(subscription.UserId)
+		if granted {
+			clearUserQuotaCache(subscription.UserId)
+			RecordLog(subscription.UserId, LogTypeTopup, fmt.Sprintf("订阅 %s 发放本周期额度 %s", subscription.PlanName, common.LogQuota(subscription.CycleQuota)))
+		}
+	}
+
+	return nil
+}
+
+func remindSubscription(subscription *UserSubscription, now int64) {
+	result := DB.Model(&UserSubscription{}).Where("id = ? AND reminded_at = 0", subscription.Id).Update("reminded_at", now)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+
+	user, err := GetUserById(subscription.UserId, false)
+	if err != nil || user.Email == "" {
+		return
+	}
+
+	if err := stmp.SendSubscriptionReminderEmail(user.Username, user.Email, subscription.PlanName, subscription.ExpiresAt, config.SubscriptionGraceDays); err != nil {
+		logger.SysError(fmt.Sprintf("failed to send subscription reminder to user %d: %s", user.Id, err.Error()))
+	}
+}
+
+// lapseSubscription 订阅失效，用户仍在套餐分组时恢复原分组
+func lapseSubscription(subscription *UserSubscription, status string) error {
+	now := utils.GetTimestamp()
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&UserSubscription{}).Where("id = ? AND status = ?", subscription.Id, subscription.Status).Updates(map[string]any{
+			"status":       status,
+			"updated_time": now,
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		if err := tx.Where("subscription_id = ?", subscription.Id).Delete(&SubscriptionAllowanceUsage{}).Error; err != nil {
+			return err
+		}
+		if subscription.Group == "" {
+			return nil
+		}
+
+		previousGroup := subscription.PreviousGroup
+		if previousGroup == "" {
+			previousGroup = "default"
+		}
+		return tx.Model(&User{}).Where("id = ? AND "+quotePostgresField("group")+" = ?", subscription.UserId, subscription.Group).
+			Update("group", previousGroup).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	clearUserSubscriptionCache(subscription.UserId)
+	if config.RedisEnabled {
+		redis.RedisDel(fmt.Sprintf(UserGroupCacheKey, subscription.UserId))
+	}
+	RecordLog(subscription.UserId, LogTypeSystem, fmt.Sprintf("订阅 %s 已失效", subscription.PlanName))
+
+	return nil
+}
+
+// subscriptionAllowance 当前周期的模型免费次数，没有订阅时为零值，同样写入缓存
+type subscriptionAllowance struct {
+	SubscriptionId int            `json:"subscription_id"`
+	CycleStart     int64          `json:"cycle_start"`
+	CycleEnd       int64          `json:"cycle_end"`
+	Allowances     map[string]int `json:"allowances"`
+}
+
+func getSubscriptionAllowance(userId int) (*subscriptionAllowance, error) {
+	allowance := &subscriptionAllowance{}
+
+	var subscription UserSubscription
+	err := DB.Where("user_id = ? AND status = ?", userId, UserSubscriptionStatusActive).Order("id desc").First(&subscription).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return allowance, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := GetSubscriptionPlanById(subscription.PlanId)
+	if err != nil {
+		return allowance, nil
+	}
+
+	allowance.SubscriptionId = subscription.Id
+	allowance.CycleStart = subscription.CycleStart
+	allowance.CycleEnd = min(subscription.CycleStart+subscription.cycleSeconds(), subscription.ExpiresAt)
+	allowance.Allowances = plan.ModelAllowances.Data()
+	return allowance, nil
+}
+
+func cacheGetSubscriptionAllowance(userId int) (*subscriptionAllowance, error) {
+	if !config.RedisEnabled {
+		return getSubscriptionAllowance(userId)
+	}
+
+	return cache.GetOrSetCache(
+		fmt.Sprintf(UserSubscriptionCacheKey, userId),
+		time.Duration(TokenCacheSeconds)*time.Second,
+		func() (*subscriptionAllowance, error) {
+			return getSubscriptionAllowance(userId)
+		},
+		cache.CacheTimeout)
+}
+
+// UseSubscriptionAllowance 使用一次订阅中该模型的免费次数，成功时本次请求不计费
+func UseSubscriptionAllowance(userId int, modelName string) bool {
+	if !config.SubscriptionEnabled {
+		return false
+	}
+
+	allowance, err := cacheGetSubscriptionAllowance(userId)
+	if err != nil || allowance.SubscriptionId == 0 {
+		return false
+	}
+
+	limit := allowance.Allowances[modelName]
+	now := utils.GetTimestamp()
+	if limit <= 0 || now < allowance.CycleStart || now >= allowance.CycleEnd {
+		return false
+	}
+
+	used, err := increaseSubscriptionAllowanceUsage(allowance.SubscriptionId, allowance.CycleStart, modelName, limit)
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to use subscription %d allowance: %s", allowance.SubscriptionId, err.Error()))
+		return false
+	}
+
+	return used
+}
+
+// increaseSubscriptionAllowanceUsage 在数据库中原子地增加本周期的使用次数，达到上限时返回 false
+func increaseSubscriptionAllowanceUsage(subscriptionId int, cycleStart int64, modelName string, limit int) (bool, error) {
+	increase := func() (bool, error) {
+		result := DB.Model(&SubscriptionAllowanceUsage{}).
+			Where("subscription_id = ? AND cycle_start = ? AND model_name = ? AND used < ?", subscriptionId, cycleStart, modelName, limit).
+			Update("used", gorm.Expr("used + 1"))
+		return result.RowsAffected > 0, result.Error
+	}
+
+	used, err := increase()
+	if err != nil || used {
+		return used, err
+	}
+
+	// 本周期首次使用时创建计数，并发创建时只有一个成功，其余重新走条件更新
+	result := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&SubscriptionAllowanceUsage{
+		SubscriptionId: subscriptionId,
+		CycleStart:     cycleStart,
+		ModelName:      modelName,
+		Used:           1,
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	return increase()
+}
+
+func clearUserSubscriptionCache(userId int) {
+	if config.RedisEnabled {
+		redis.RedisDel(fmt.Sprintf(UserSubscriptionCacheKey, userId))
+	}
+}
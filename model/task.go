@@ -44,6 +44,13 @@ type Task struct {
 	Data       datatypes.JSON `json:"data" gorm:"type:json"`
 	NotifyHook string         `json:"notify_hook"`
 	TokenID    int            `json:"token_id" gorm:"default:0"`
+	// 转存后的媒体对象，JSON 数组
+	StorageKeys string `json:"storage_keys" gorm:"type:text"`
+}
+
+// IsFinished 任务是否已处于终态
+func (status TaskStatus) IsFinished() bool {
+	return status == TaskStatusSuccess || status == TaskStatusFailure
 }
 
 func GetTaskByTaskIds(platform string, userId int, taskIds []string) (task []*Task, err error) {
@@ -79,6 +86,14 @@ func (Task *Task) Update() error {
 	return DB.Save(Task).Error
 }
 
+// UpdateTaskStorage 后台转存完成后只更新媒体相关字段，避免覆盖任务的其他状态
+func UpdateTaskStorage(id int64, data datatypes.JSON, storageKeys string) error {
+	return DB.Model(&Task{}).Where("id = ?", id).Updates(map[string]any{
+		"data":         data,
+		"storage_keys": storageKeys,
+	}).Error
+}
+
 func TaskBulkUpdate(TaskIds []string, params map[string]any) error {
 	if len(TaskIds) == 0 {
 		return nil
@@ -205,3 +220,25 @@ func GetAllUserTasks(userId int, params *TaskQueryParams) (*DataResult[Task], er
 
 	return PaginateAndOrder(tx, &params.PaginationParams, &tasks, allowedTaskOrderFields)
 }
+
+func GetUserTaskByPlatforms(platforms []string, userId int, taskId string) (task *Task, err error) {
+	task = &Task{}
+	err = DB.Where("platform in (?) and user_id = ? and task_id = ?", platforms, userId, taskId).First(task).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	return
+}
+
+// GetUserTasksByPlatforms 按 id 倒序分页，afterId 大于 0 时返回比它更早的任务
+func GetUserTasksByPlatforms(platforms []string, userId int, afterId int64, limit int) (tasks []*Task, err error) {
+	tx := DB.Where("platform in (?) and user_id = ?", platforms, userId)
+	if afterId > 0 {
+		tx = tx.Where("id < ?", afterId)
+	}
+	err = tx.Order("id desc").Limit(limit).Find(&tasks).Error
+
+	return
+}
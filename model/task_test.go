@@ -0,0 +1,46 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetUserTasksByPlatforms(t *testing.T) {
+	setupTestDB(t, &Task{})
+	for i := 1; i <= 5; i++ {
+		assert.NoError(t, DB.Create(&Task{TaskID: fmt.Sprintf("v%d", i), Platform: TaskPlatformKling, UserId: 1}).Error)
+	}
+	assert.NoError(t, DB.Create(&Task{TaskID: "s1", Platform: TaskPlatformSuno, UserId: 1}).Error)
+	assert.NoError(t, DB.Create(&Task{TaskID: "v6", Platform: TaskPlatformKling, UserId: 2}).Error)
+
+	platforms := []string{TaskPlatformKling}
+	tasks, err := GetUserTasksByPlatforms(platforms, 1, 0, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"v5", "v4"}, taskIds(tasks))
+
+	// 从上一页最后一条之后继续
+	tasks, err = GetUserTasksByPlatforms(platforms, 1, tasks[1].ID, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"v3", "v2", "v1"}, taskIds(tasks))
+
+	// 只能查询自己的任务，且只限指定平台
+	task, err := GetUserTaskByPlatforms(platforms, 1, "v6")
+	assert.NoError(t, err)
+	assert.Nil(t, task)
+	task, err = GetUserTaskByPlatforms(platforms, 1, "s1")
+	assert.NoError(t, err)
+	assert.Nil(t, task)
+	task, err = GetUserTaskByPlatforms(platforms, 2, "v6")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, task.UserId)
+}
+
+func taskIds(tasks []*Task) []string {
+	ids := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		ids = append(ids, task.TaskID)
+	}
+	return ids
+}
@@ -10,6 +10,8 @@ import (
 	"done-hub/common/utils"
 	"errors"
 	"fmt"
+
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
@@ -25,7 +27,9 @@ var (
 type Token struct {
 	Id             int            `json:"id"`
 	UserId         int            `json:"user_id"`
-	Key            string         `json:"key" gorm:"type:varchar(59);uniqueIndex"`
+	Key            string         `json:"key" gorm:"type:varchar(59);uniqueIndex"` // 旧令牌为明文，新令牌为展示前缀
+	KeyHash        string         `json:"-" gorm:"type:varchar(64);index"`
+	Hashed         bool           `json:"hashed" gorm:"-"` // 新版令牌只能在创建或轮换时获取完整令牌
 	Status         int            `json:"status" gorm:"default:1"`
 	Name           string         `json:"name" gorm:"index" `
 	CreatedTime    int64          `json:"created_time" gorm:"bigint"`
@@ -35,6 +39,7 @@ type Token struct {
 	UnlimitedQuota bool           `json:"unlimited_quota" gorm:"default:false"`
 	UsedQuota      int            `json:"used_quota" gorm:"default:0"` // used quota
 	Group          string         `json:"group" gorm:"default:''"`
+	OrganizationId int            `json:"organization_id" gorm:"index;default:0"` // 所属组织，组织令牌的消费从组织额度中扣除
 	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
 
 	Setting database.JSONType[TokenSetting] `json:"setting" form:"setting" gorm:"type:json"`
@@ -52,17 +57,91 @@ var allowedTokenOrderFields = map[string]bool{
 
 // 添加 AfterCreate 钩子方法
 func (token *Token) AfterCreate(tx *gorm.DB) (err error) {
-	tokenKey, err := common.GenerateToken(token.Id, token.UserId)
+	return token.resetKey(tx)
+}
+
+func (token *Token) AfterFind(tx *gorm.DB) (err error) {
+	token.Hashed = token.IsHashed()
+	return nil
+}
+
+// resetKey 生成新的令牌，库中只保存前缀和哈希，完整令牌仅在本次写回 token.Key
+func (token *Token) resetKey(tx *gorm.DB) error {
+	tokenKey, prefix, err := common.GenerateSecretToken(token.Id, token.UserId)
+	if err != nil {
+		return err
+	}
+
+	keyHash := common.HashToken(tokenKey)
+	err = tx.Model(token).Updates(map[string]any{"key": prefix, "key_hash": keyHash}).Error
 	if err != nil {
 		return err
 	}
 
-	// 更新 key 字段
-	return tx.Model(token).Update("key", tokenKey).Error
+	token.Key = tokenKey
+	token.KeyHash = keyHash
+	token.Hashed = true
+
+	return nil
+}
+
+// IsHashed 是否为只保存哈希的令牌，否则为旧版明文令牌
+func (token *Token) IsHashed() bool {
+	return token.KeyHash != ""
+}
+
+// ResetKey 轮换令牌，旧令牌（包括旧版明文令牌）立即失效，返回新的完整令牌
+func (token *Token) ResetKey() (string, error) {
+	oldCacheKey := token.cacheKey()
+	if err := token.resetKey(DB); err != nil {
+		return "", err
+	}
+
+	if config.RedisEnabled {
+		redis.RedisDel(fmt.Sprintf(UserTokensKey, oldCacheKey))
+		redis.RedisDel(fmt.Sprintf(TokenIdCacheKey, token.Id))
+	}
+
+	return token.Key, nil
+}
+
+func (token *Token) cacheKey() string {
+	if token.IsHashed() {
+		return token.KeyHash
+	}
+
+	return token.Key
+}
+
+// clearCache 删除按令牌和按 ID 缓存的令牌
+func (token *Token) clearCache() {
+	if !config.RedisEnabled {
+		return
+	}
+
+	redis.RedisDel(fmt.Sprintf(UserTokensKey, token.cacheKey()))
+	redis.RedisDel(fmt.Sprintf(TokenIdCacheKey, token.Id))
 }
 
 type TokenSetting struct {
 	Heartbeat HeartbeatSetting `json:"heartbeat,omitempty"`
+	Webhook   WebhookSetting   `json:"webhook,omitempty"`
+	// 是否将生成的媒体转存到存储，分组开启时令牌无需单独开启
+	MediaStorage bool `json:"media_storage,omitempty"`
+	// 按终端用户限制，终端用户取自请求中的 user 字段或 X-End-User 请求头
+	EndUser EndUserSetting `json:"end_user,omitempty"`
+}
+
+// EndUserSetting 每个终端用户的限制，0 表示不限制
+type EndUserSetting struct {
+	RPM        int `json:"rpm"`
+	DailyQuota int `json:"daily_quota"`
+}
+
+// WebhookSetting 异步任务完成回调，提交时未指定回调地址则使用 URL，Secret 用于签名
+type WebhookSetting struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
 }
 
 type HeartbeatSetting struct {
@@ -108,7 +187,7 @@ func GetTokenModel(key string) (token *Token, err error) {
 			return nil, ErrTokenInvalid
 		}
 	default:
-		return nil, ErrTokenInvalid
+		return getHashedTokenModel(key)
 	}
 
 	token, err = CacheGetTokenByKey(key)
@@ -127,25 +206,57 @@ func GetTokenModel(key string) (token *Token, err error) {
 	return token, nil
 }
 
+// getHashedTokenModel 新版令牌按哈希查找
+func getHashedTokenModel(key string) (*Token, error) {
+	tokenId, userId, err := common.ParseSecretToken(key)
+	if err != nil || userId == 0 || tokenId == 0 {
+		return nil, ErrTokenInvalid
+	}
+	if userEnabled, err := CacheIsUserEnabled(userId); err != nil || !userEnabled {
+		return nil, ErrTokenInvalid
+	}
+
+	token, err := CacheGetTokenByKeyHash(common.HashToken(key))
+	if err != nil {
+		logger.SysError(fmt.Sprintf("DB Not Found: userId=%d, tokenId=%d, err=%s", userId, tokenId, err.Error()))
+		return nil, ErrTokenInvalid
+	}
+
+	if token.Id != tokenId || token.UserId != userId {
+		return nil, ErrTokenInvalid
+	}
+
+	return token, nil
+}
+
 func ValidateUserToken(key string) (token *Token, err error) {
 	token, err = GetTokenModel(key)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := token.checkAvailable(); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// checkAvailable 检查令牌状态、有效期和额度
+func (token *Token) checkAvailable() error {
 	if token.Status != config.TokenStatusEnabled {
 		switch token.Status {
 		case config.TokenStatusExhausted:
-			return nil, ErrTokenQuotaExhausted
+			return ErrTokenQuotaExhausted
 		case config.TokenStatusExpired:
-			return nil, ErrTokenExpired
+			return ErrTokenExpired
 		default:
-			return nil, ErrTokenStatusUnavailable
+			return ErrTokenStatusUnavailable
 		}
 	}
 
 	if token.ExpiredTime != -1 && token.ExpiredTime < utils.GetTimestamp() {
-		return nil, ErrTokenExpired
+		return ErrTokenExpired
 	}
 
 	if !token.UnlimitedQuota {
@@ -158,11 +269,11 @@ func ValidateUserToken(key string) (token *Token, err error) {
 					logger.SysError("failed to update token status" + err.Error())
 				}
 			}
-			return nil, ErrTokenQuotaExhausted
+			return ErrTokenQuotaExhausted
 		}
 	}
 
-	return token, nil
+	return nil
 }
 
 func GetTokenByIds(id int, userId int) (*Token, error) {
@@ -207,6 +318,13 @@ func GetTokenByKey(key string) (*Token, error) {
 	return &token, err
 }
 
+func GetTokenByKeyHash(keyHash string) (*Token, error) {
+	var token Token
+
+	err := DB.Where("key_hash = ?", keyHash).First(&token).Error
+	return &token, err
+}
+
 func (token *Token) Insert() error {
 	err := DB.Create(token).Error
 	return err
@@ -216,13 +334,30 @@ func (token *Token) Insert() error {
 func (token *Token) Update() error {
 	err := DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota", "group", "setting").Updates(token).Error
 	// 防止Redis缓存不生效，直接删除
-	if err == nil && config.RedisEnabled {
-		redis.RedisDel(fmt.Sprintf(UserTokensKey, token.Key))
+	if err == nil {
+		token.clearCache()
 	}
 
 	return err
 }
 
+// EnsureWebhookSecret 返回回调签名密钥，早期创建的令牌没有密钥时生成并保存，保证所有回调都带签名
+func (token *Token) EnsureWebhookSecret() (string, error) {
+	setting := token.Setting.Data()
+	if setting.Webhook.Secret != "" {
+		return setting.Webhook.Secret, nil
+	}
+
+	setting.Webhook.Secret = utils.GetRandomString(32)
+	token.Setting.JSONType = datatypes.NewJSONType(setting)
+	if err := DB.Model(token).Update("setting", token.Setting).Error; err != nil {
+		return "", err
+	}
+	token.clearCache()
+
+	return setting.Webhook.Secret, nil
+}
+
 func (token *Token) SelectUpdate() error {
 	// This can update zero values
 	return DB.Model(token).Select("accessed_time", "status").Updates(token).Error
@@ -245,8 +380,8 @@ func DeleteTokenById(id int, userId int) (err error) {
 	}
 	err = token.Delete()
 
-	if err == nil && config.RedisEnabled {
-		redis.RedisDel(fmt.Sprintf(UserTokensKey, token.Key))
+	if err == nil {
+		token.clearCache()
 	}
 
 	return err
@@ -308,6 +443,15 @@ func PreConsumeTokenQuota(tokenId int, quota int) (err error) {
 	if !token.UnlimitedQuota && token.RemainQuota < quota {
 		return errors.New("令牌额度不足")
 	}
+	if token.OrganizationId > 0 {
+		if err := consumeOrganizationQuota(token.OrganizationId, token.UserId, quota, true); err != nil {
+			return err
+		}
+		if !token.UnlimitedQuota {
+			return DecreaseTokenQuota(tokenId, quota)
+		}
+		return nil
+	}
 	userQuota, err := GetUserQuota(token.UserId)
 	if err != nil {
 		return err
@@ -363,7 +507,9 @@ func PostConsumeTokenQuota(tokenId int, quota int) (err error) {
 	if err != nil {
 		return err
 	}
-	if quota > 0 {
+	if token.OrganizationId > 0 {
+		err = consumeOrganizationQuota(token.OrganizationId, token.UserId, quota, false)
+	} else if quota > 0 {
 		err = DecreaseUserQuota(token.UserId, quota)
 	} else {
 		err = IncreaseUserQuota(token.UserId, -quota)
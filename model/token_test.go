@@ -0,0 +1,149 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"done-hub/common"
+	"done-hub/common/config"
+	"done-hub/common/logger"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// setupTestDB 使用独立的内存数据库，测试之间互不影响
+func setupTestDB(t *testing.T, models ...any) {
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+
+	DB = db
+	logger.Logger = zap.NewNop()
+	common.UsingSQLite = true
+	config.RedisEnabled = false
+
+	viper.Set("user_token_secret", "test-user-token-secret")
+	if err := common.InitUserToken(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func createTestUser(t *testing.T, id int) *User {
+	user := &User{
+		Id:          id,
+		Username:    fmt.Sprintf("user%d", id),
+		Status:      config.UserStatusEnabled,
+		AccessToken: fmt.Sprintf("access%d", id),
+		AffCode:     fmt.Sprintf("aff%d", id),
+	}
+	if err := DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return user
+}
+
+func createTestToken(t *testing.T, userId int) (*Token, string) {
+	token := &Token{UserId: userId, Name: "test", ExpiredTime: -1, UnlimitedQuota: true}
+	if err := token.Insert(); err != nil {
+		t.Fatal(err)
+	}
+
+	return token, token.Key
+}
+
+func TestTokenCreateStoresHash(t *testing.T) {
+	setupTestDB(t, &User{}, &Token{})
+	user := createTestUser(t, 1)
+
+	token, key := createTestToken(t, user.Id)
+	assert.True(t, token.Hashed)
+
+	stored, err := GetTokenByIds(token.Id, user.Id)
+	assert.NoError(t, err)
+	assert.True(t, stored.Hashed)
+	assert.Equal(t, common.HashToken(key), stored.KeyHash)
+	// 库中只保存展示前缀
+	assert.NotEqual(t, key, stored.Key)
+	assert.True(t, strings.HasPrefix(key, stored.Key))
+}
+
+func TestTokenLookupByHash(t *testing.T) {
+	setupTestDB(t, &User{}, &Token{})
+	user := createTestUser(t, 1)
+	token, key := createTestToken(t, user.Id)
+
+	found, err := GetTokenModel(key)
+	assert.NoError(t, err)
+	assert.Equal(t, token.Id, found.Id)
+
+	// 前缀和篡改后的令牌都无法通过
+	stored, _ := GetTokenByIds(token.Id, user.Id)
+	for _, invalid := range []string{stored.Key, key[:len(key)-1] + "x"} {
+		_, err := GetTokenModel(invalid)
+		assert.ErrorIs(t, err, ErrTokenInvalid, invalid)
+	}
+
+	// 用户被禁用后令牌失效
+	DB.Model(user).Update("status", config.UserStatusDisabled)
+	_, err = GetTokenModel(key)
+	assert.ErrorIs(t, err, ErrTokenInvalid)
+}
+
+func TestTokenRotate(t *testing.T) {
+	setupTestDB(t, &User{}, &Token{})
+	user := createTestUser(t, 1)
+	token, oldKey := createTestToken(t, user.Id)
+
+	stored, err := GetTokenByIds(token.Id, user.Id)
+	assert.NoError(t, err)
+
+	newKey, err := stored.ResetKey()
+	assert.NoError(t, err)
+	assert.NotEqual(t, oldKey, newKey)
+
+	_, err = GetTokenModel(oldKey)
+	assert.ErrorIs(t, err, ErrTokenInvalid)
+
+	found, err := GetTokenModel(newKey)
+	assert.NoError(t, err)
+	assert.Equal(t, token.Id, found.Id)
+}
+
+func TestTokenRotateLegacy(t *testing.T) {
+	setupTestDB(t, &User{}, &Token{})
+	user := createTestUser(t, 1)
+	token, _ := createTestToken(t, user.Id)
+
+	// 旧版明文令牌轮换后变为只保存哈希的令牌
+	legacyKey, err := common.GenerateToken(token.Id, user.Id)
+	assert.NoError(t, err)
+	DB.Model(token).Updates(map[string]any{"key": legacyKey, "key_hash": ""})
+
+	stored, err := GetTokenByIds(token.Id, user.Id)
+	assert.NoError(t, err)
+	assert.False(t, stored.Hashed)
+	_, err = GetTokenModel(legacyKey)
+	assert.NoError(t, err)
+
+	newKey, err := stored.ResetKey()
+	assert.NoError(t, err)
+	assert.True(t, stored.Hashed)
+
+	_, err = GetTokenModel(legacyKey)
+	assert.ErrorIs(t, err, ErrTokenInvalid)
+	_, err = GetTokenModel(newKey)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,213 @@
+package model
+
+import (
+	"crypto/rand"
+	"done-hub/common"
+	"done-hub/common/secret"
+	"done-hub/common/totp"
+	"done-hub/common/utils"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const recoveryCodeCount = 10
+
+// TwoFactor 用户的动态口令设置，密钥加密存储，恢复码只保存哈希
+type TwoFactor struct {
+	UserId        int                         `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+	Secret        string                      `json:"-" gorm:"type:text"`
+	Enabled       bool                        `json:"enabled" gorm:"default:false"`
+	RecoveryCodes datatypes.JSONSlice[string] `json:"-" gorm:"type:json"`
+	LastStep      int64                       `json:"-" gorm:"bigint;default:0"`
+	Revision      int                         `json:"-" gorm:"default:0"` // 恢复码每次变更加一，用于条件更新
+	CreatedTime   int64                       `json:"created_time" gorm:"bigint"`
+}
+
+func GetTwoFactor(userId int) (*TwoFactor, error) {
+	var twoFactor TwoFactor
+	err := DB.Where("user_id = ?", userId).First(&twoFactor).Error
+	return &twoFactor, err
+}
+
+// IsTwoFactorEnabled 用户是否已启用动态口令
+func IsTwoFactorEnabled(userId int) bool {
+	var count int64
+	DB.Model(&TwoFactor{}).Where("user_id = ? AND enabled = ?", userId, true).Count(&count)
+	return count > 0
+}
+
+// SetupTwoFactor 生成新的密钥，需要通过 EnableTwoFactor 校验口令后才会生效
+func SetupTwoFactor(userId int) (string, error) {
+	if IsTwoFactorEnabled(userId) {
+		return "", errors.New("已启用两步验证，请先关闭后再重新绑定")
+	}
+
+	key, err := totp.GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+
+	encrypted, err := secret.Encrypt(key)
+	if err != nil {
+		return "", err
+	}
+
+	twoFactor := TwoFactor{
+		UserId:      userId,
+		Secret:      encrypted,
+		CreatedTime: utils.GetTimestamp(),
+	}
+	if err := DB.Save(&twoFactor).Error; err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+// EnableTwoFactor 校验口令后启用，返回明文恢复码，仅在此时展示一次
+func EnableTwoFactor(userId int, code string) ([]string, error) {
+	twoFactor, err := GetTwoFactor(userId)
+	if err != nil {
+		return nil, errors.New("请先生成两步验证密钥")
+	}
+	if twoFactor.Enabled {
+		return nil, errors.New("已启用两步验证")
+	}
+
+	if !twoFactor.verifyCode(code) {
+		return nil, errors.New("验证码错误")
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = DB.Model(twoFactor).Updates(map[string]any{
+		"enabled":        true,
+		"recovery_codes": datatypes.JSONSlice[string](hashes),
+		"last_step":      twoFactor.LastStep,
+	}).Error
+
+	return codes, err
+}
+
+// DisableTwoFactor 关闭两步验证，需要当前口令或恢复码
+func DisableTwoFactor(userId int, code string) error {
+	if err := VerifyTwoFactor(userId, code); err != nil {
+		return err
+	}
+
+	return DB.Where("user_id = ?", userId).Delete(&TwoFactor{}).Error
+}
+
+// ResetTwoFactor 管理员为丢失设备的用户清除两步验证
+func ResetTwoFactor(userId int) error {
+	return DB.Where("user_id = ?", userId).Delete(&TwoFactor{}).Error
+}
+
+// RegenerateRecoveryCodes 校验口令后重新生成恢复码，旧的恢复码全部失效
+func RegenerateRecoveryCodes(userId int, code string) ([]string, error) {
+	if err := VerifyTwoFactor(userId, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = DB.Model(&TwoFactor{}).Where("user_id = ?", userId).Updates(map[string]any{
+		"recovery_codes": datatypes.JSONSlice[string](hashes),
+		"revision":       gorm.Expr("revision + 1"),
+	}).Error
+	return codes, err
+}
+
+// VerifyTwoFactor 校验动态口令或恢复码，恢复码使用后失效，同一时间窗口的口令不能重复使用
+func VerifyTwoFactor(userId int, code string) error {
+	twoFactor, err := GetTwoFactor(userId)
+	if err != nil || !twoFactor.Enabled {
+		return errors.New("未启用两步验证")
+	}
+
+	code = strings.TrimSpace(code)
+	if twoFactor.verifyCode(code) {
+		// 只在 last_step 小于本次时间窗口时更新，并发请求中同一口令只有一个能通过
+		result := DB.Model(&TwoFactor{}).Where("user_id = ? AND last_step < ?", userId, twoFactor.LastStep).Update("last_step", twoFactor.LastStep)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("验证码错误")
+		}
+		return nil
+	}
+
+	hash := common.HashToken(normalizeRecoveryCode(code))
+	index := slices.Index(twoFactor.RecoveryCodes, hash)
+	if index < 0 {
+		return errors.New("验证码错误")
+	}
+
+	// 以读取时的版本作为条件更新，恢复码已被其他请求修改时本次使用失败，避免同一恢复码被使用两次
+	remain := slices.Delete(slices.Clone(twoFactor.RecoveryCodes), index, index+1)
+	result := DB.Model(&TwoFactor{}).
+		Where("user_id = ? AND revision = ?", userId, twoFactor.Revision).
+		Updates(map[string]any{
+			"recovery_codes": datatypes.JSONSlice[string](remain),
+			"revision":       gorm.Expr("revision + 1"),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("验证码错误")
+	}
+	return nil
+}
+
+// RecoveryCodesRemaining 剩余可用的恢复码数量
+func (t *TwoFactor) RecoveryCodesRemaining() int {
+	return len(t.RecoveryCodes)
+}
+
+func (t *TwoFactor) verifyCode(code string) bool {
+	key, err := secret.Decrypt(t.Secret)
+	if err != nil {
+		return false
+	}
+
+	step, ok := totp.Validate(key, code, time.Now())
+	if !ok || step <= t.LastStep {
+		return false
+	}
+
+	t.LastStep = step
+	return true
+}
+
+func generateRecoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		buf := make([]byte, 5)
+		if _, err = rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		code := hex.EncodeToString(buf)
+		code = code[:5] + "-" + code[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, common.HashToken(normalizeRecoveryCode(code)))
+	}
+
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"done-hub/common/totp"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func enableTestTwoFactor(t *testing.T, userId int) (string, []string) {
+	key, err := SetupTwoFactor(userId)
+	assert.NoError(t, err)
+
+	code, _ := totp.Generate(key, time.Now())
+	codes, err := EnableTwoFactor(userId, code)
+	assert.NoError(t, err)
+	assert.Len(t, codes, recoveryCodeCount)
+
+	return key, codes
+}
+
+func TestTwoFactorCodeReplay(t *testing.T) {
+	setupTestDB(t, &TwoFactor{})
+	key, _ := enableTestTwoFactor(t, 1)
+	assert.True(t, IsTwoFactorEnabled(1))
+
+	// 启用时使用过的口令不能再次使用
+	code, _ := totp.Generate(key, time.Now())
+	assert.Error(t, VerifyTwoFactor(1, code))
+
+	// 下一个时间窗口的口令只能使用一次
+	next, _ := totp.Generate(key, time.Now().Add(30*time.Second))
+	assert.NoError(t, VerifyTwoFactor(1, next))
+	assert.Error(t, VerifyTwoFactor(1, next))
+
+	assert.Error(t, VerifyTwoFactor(1, "000000"))
+	assert.Error(t, VerifyTwoFactor(2, next))
+}
+
+func TestTwoFactorRecoveryCodes(t *testing.T) {
+	setupTestDB(t, &TwoFactor{})
+	_, codes := enableTestTwoFactor(t, 1)
+
+	// 恢复码不区分大小写和连字符，使用后失效
+	assert.NoError(t, VerifyTwoFactor(1, " "+codes[0]+" "))
+	assert.Error(t, VerifyTwoFactor(1, codes[0]))
+	assert.NoError(t, VerifyTwoFactor(1, strings.ToUpper(strings.ReplaceAll(codes[1], "-", ""))))
+
+	twoFactor, err := GetTwoFactor(1)
+	assert.NoError(t, err)
+	assert.Equal(t, recoveryCodeCount-2, twoFactor.RecoveryCodesRemaining())
+
+	// 重新生成后旧的恢复码全部失效
+	newCodes, err := RegenerateRecoveryCodes(1, codes[2])
+	assert.NoError(t, err)
+	assert.Len(t, newCodes, recoveryCodeCount)
+	assert.Error(t, VerifyTwoFactor(1, codes[3]))
+	assert.NoError(t, VerifyTwoFactor(1, newCodes[0]))
+
+	assert.NoError(t, DisableTwoFactor(1, newCodes[1]))
+	assert.False(t, IsTwoFactorEnabled(1))
+}
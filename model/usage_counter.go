@@ -0,0 +1,82 @@
+package model
+
+import (
+	"done-hub/common/config"
+	"done-hub/common/redis"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// usageCounterStore 带过期时间的用量计数，开启 Redis 时多实例共享，否则保存在内存
+type usageCounterStore struct {
+	sync.Mutex
+	counters  map[string]*usageCounter
+	lastSweep int64
+}
+
+type usageCounter struct {
+	value     int64
+	expiresAt int64
+}
+
+var usageCounters = &usageCounterStore{counters: map[string]*usageCounter{}}
+
+// Increase 增加计数并返回增加后的值，expiresAt 为计数的过期时间戳
+func (s *usageCounterStore) Increase(key string, value int64, expiresAt int64) (int64, error) {
+	if config.RedisEnabled {
+		return redis.RedisIncrBy(key, value, time.Until(time.Unix(expiresAt, 0)))
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.sweep()
+	counter, ok := s.counters[key]
+	if !ok || counter.expiresAt < time.Now().Unix() {
+		counter = &usageCounter{expiresAt: expiresAt}
+		s.counters[key] = counter
+	}
+	counter.value += value
+
+	return counter.value, nil
+}
+
+// Get 读取当前计数，不存在时为 0
+func (s *usageCounterStore) Get(key string) (int64, error) {
+	if config.RedisEnabled {
+		value, err := redis.RedisGet(key)
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				return 0, nil
+			}
+			return 0, err
+		}
+		return strconv.ParseInt(value, 10, 64)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	counter, ok := s.counters[key]
+	if !ok || counter.expiresAt < time.Now().Unix() {
+		return 0, nil
+	}
+
+	return counter.value, nil
+}
+
+func (s *usageCounterStore) sweep() {
+	now := time.Now().Unix()
+	if now-s.lastSweep < 60 {
+		return
+	}
+	s.lastSweep = now
+
+	for key, counter := range s.counters {
+		if counter.expiresAt < now {
+			delete(s.counters, key)
+		}
+	}
+}
@@ -42,6 +42,8 @@ type User struct {
 	AffHistoryQuota  int            `json:"aff_history_quota" gorm:"type:int;default:0;column:aff_history"`
 	InviterId        int            `json:"inviter_id" gorm:"type:int;column:inviter_id;index"`
 	LastLoginTime    int64          `json:"last_login_time" gorm:"bigint;default:0"`
+	RoleId           int            `json:"role_id" gorm:"type:int;default:0;index"` // 管理后台角色，通过 SetUserRoleId 修改
+	Permissions      []string       `json:"permissions,omitempty" gorm:"-:all"`
 	CreatedTime      int64          `json:"created_time" gorm:"bigint"`
 	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`
 }
@@ -152,12 +154,12 @@ func (user *User) Insert(inviterId int) error {
 	}
 	if inviterId != 0 {
 		if config.QuotaForInvitee > 0 {
-			_ = IncreaseUserQuota(user.Id, config.QuotaForInvitee)
+			_ = GrantUserQuota(user.Id, config.QuotaForInvitee, QuotaLotSourceInvite, "", 0, "使用邀请码赠送")
 			RecordLog(user.Id, LogTypeSystem, fmt.Sprintf("使用邀请码赠送 %s", common.LogQuota(config.QuotaForInvitee)))
 		}
 		// 注册时的邀请奖励保持原有逻辑，充值时的返利使用新的配置
 		if config.QuotaForInviter > 0 {
-			_ = IncreaseUserQuota(inviterId, config.QuotaForInviter)
+			_ = GrantUserQuota(inviterId, config.QuotaForInviter, QuotaLotSourceInvite, fmt.Sprintf("%d", user.Id), 0, "邀请用户赠送")
 			RecordLog(inviterId, LogTypeSystem, fmt.Sprintf("邀请用户赠送 %s", common.LogQuota(config.QuotaForInviter)))
 		}
 	}
@@ -208,6 +210,9 @@ func (user *User) Delete() error {
 	}
 
 	err = DB.Delete(user).Error
+	if err == nil {
+		RevokeUserSessions(user.Id, 0)
+	}
 	return err
 }
 
@@ -374,6 +379,9 @@ func ResetUserPasswordByEmail(email string, password string) error {
 		return err
 	}
 	err = DB.Model(&User{}).Where("email = ?", email).Update("password", hashedPassword).Error
+	if err == nil {
+		RevokeUserSessionsByEmail(email)
+	}
 	return err
 }
 
@@ -451,8 +459,9 @@ func IncreaseUserQuota(id int, quota int) (err error) {
 	return increaseUserQuota(id, quota)
 }
 
+// increaseUserQuota 批量更新时 quota 可能为负数，用户有额度批次时同步扣减或补回
 func increaseUserQuota(id int, quota int) (err error) {
-	err = DB.Model(&User{}).Where("id = ?", id).Update("quota", gorm.Expr("quota + ?", quota)).Error
+	err = updateUserQuotaWithLots(id, quota)
 	if err != nil {
 		return err
 	}
@@ -476,7 +485,7 @@ func DecreaseUserQuota(id int, quota int) (err error) {
 }
 
 func decreaseUserQuota(id int, quota int) (err error) {
-	err = DB.Model(&User{}).Where("id = ?", id).Update("quota", gorm.Expr("quota - ?", quota)).Error
+	err = updateUserQuotaWithLots(id, -quota)
 	if err != nil {
 		return err
 	}
@@ -487,6 +496,20 @@ func decreaseUserQuota(id int, quota int) (err error) {
 	return nil
 }
 
+// updateUserQuotaWithLots 大部分用户没有额度批次，直接更新余额，避免每次扣费都开启事务
+func updateUserQuotaWithLots(id int, delta int) error {
+	if !userHasQuotaLots(id) {
+		return DB.Model(&User{}).Where("id = ?", id).Update("quota", gorm.Expr("quota + ?", delta)).Error
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("id = ?", id).Update("quota", gorm.Expr("quota + ?", delta)).Error; err != nil {
+			return err
+		}
+		return adjustQuotaLots(tx, id, delta)
+	})
+}
+
 func GetRootUserEmail() (email string) {
 	DB.Model(&User{}).Where("role = ?", config.RoleRootUser).Select("email").Find(&email)
 	return email
@@ -577,16 +600,27 @@ func GetUserStatisticsByPeriod(startTimestamp, endTimestamp int64) (statistics [
 	return statistics, err
 }
 
-func ChangeUserQuota(id int, quota int, isRecharge bool) (err error) {
-	updateMap := map[string]interface{}{
-		"quota": gorm.Expr("quota + ?", quota),
-	}
-
-	if isRecharge {
-		updateMap["recharge_count"] = gorm.Expr("recharge_count + 1")
-	}
+// ChangeUserQuota 增减用户额度，增加的额度记为一笔额度批次，expiresAt 为 0 时永久有效
+func ChangeUserQuota(id int, quota int, isRecharge bool, expiresAt int64, remark string) (err error) {
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if quota > 0 {
+			if err := grantQuotaLot(tx, id, quota, QuotaLotSourceAdmin, "", expiresAt, remark); err != nil {
+				return err
+			}
+		} else {
+			if err := tx.Model(&User{}).Where("id = ?", id).Update("quota", gorm.Expr("quota + ?", quota)).Error; err != nil {
+				return err
+			}
+			if err := adjustQuotaLots(tx, id, quota); err != nil {
+				return err
+			}
+		}
 
-	err = DB.Model(&User{}).Where("id = ?", id).Updates(updateMap).Error
+		if isRecharge {
+			return tx.Model(&User{}).Where("id = ?", id).Update("recharge_count", gorm.Expr("recharge_count + 1")).Error
+		}
+		return nil
+	})
 
 	if err != nil {
 		return err
@@ -599,8 +633,8 @@ func ChangeUserQuota(id int, quota int, isRecharge bool) (err error) {
 	return nil
 }
 
-// ProcessInviterReward 处理邀请人的充值返利
-func ProcessInviterReward(userId int, rechargeQuota int, ip string) error {
+// ProcessInviterReward 处理邀请人的充值返利，开启分销时按分销规则记录佣金
+func ProcessInviterReward(userId int, rechargeQuota int, source string, sourceId string, ip string) error {
 	// 获取用户信息，查看是否有邀请人
 	user := &User{}
 	err := DB.Where("id = ?", userId).First(user).Error
@@ -613,6 +647,10 @@ func ProcessInviterReward(userId int, rechargeQuota int, ip string) error {
 		return nil
 	}
 
+	if config.AffiliateEnabled {
+		return CreateAffiliateCommissions(user, rechargeQuota, source, sourceId)
+	}
+
 	// 如果奖励值为0或奖励类型为空，直接返回
 	if config.InviterRewardValue == 0 || config.InviterRewardType == "" {
 		return nil
@@ -640,7 +678,7 @@ func ProcessInviterReward(userId int, rechargeQuota int, ip string) error {
 	}
 
 	// 给邀请人增加额度
-	err = IncreaseUserQuota(user.InviterId, rewardQuota)
+	err = GrantUserQuota(user.InviterId, rewardQuota, QuotaLotSourceInvite, fmt.Sprintf("%d", userId), 0, "邀请用户充值返利")
 	if err != nil {
 		return err
 	}
@@ -20,6 +20,8 @@ type UserGroup struct {
 	Min       int     `json:"min" form:"min" gorm:"default:0"`                 // 晋级条件最小值
 	Max       int     `json:"max" form:"max" gorm:"default:0"`                 // 晋级条件最大值
 	Enable    *bool   `json:"enable" form:"enable" gorm:"default:true"`        // 是否启用
+
+	MediaStorage bool `json:"media_storage" form:"media_storage" gorm:"default:false"` // 是否将生成的媒体转存到存储
 }
 
 type SearchUserGroupParams struct {
@@ -75,7 +77,7 @@ func (c *UserGroup) Create() error {
 }
 
 func (c *UserGroup) Update() error {
-	err := DB.Select("name", "ratio", "public", "api_rate", "promotion", "min", "max").Updates(c).Error
+	err := DB.Select("name", "ratio", "public", "api_rate", "promotion", "min", "max", "media_storage").Updates(c).Error
 	if err == nil {
 		GlobalUserGroupRatio.Load()
 	}
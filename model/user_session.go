@@ -0,0 +1,250 @@
+package model
+
+import (
+	"crypto/rand"
+	"done-hub/common"
+	"done-hub/common/cache"
+	"done-hub/common/config"
+	"done-hub/common/redis"
+	"done-hub/common/utils"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// UserSessionMaxAge 与会话 Cookie 的有效期一致，超过该时间未活动的会话失效
+	UserSessionMaxAge = 30 * 24 * 3600
+	// 最近活动时间的更新间隔，避免每个请求都写数据库
+	userSessionTouchInterval = 60
+)
+
+var UserSessionCacheKey = "user_session:%s"
+
+// UserSession 服务端登录会话记录，Cookie 中只保存随机令牌，数据库保存其哈希，删除记录即可使会话失效
+type UserSession struct {
+	Id           int    `json:"id"`
+	UserId       int    `json:"user_id" gorm:"index"`
+	TokenHash    string `json:"-" gorm:"type:varchar(64);uniqueIndex"`
+	Device       string `json:"device" gorm:"type:varchar(100);default:''"`
+	UserAgent    string `json:"user_agent" gorm:"type:varchar(255);default:''"`
+	Ip           string `json:"ip" gorm:"type:varchar(64);default:''"`
+	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
+	LastSeenTime int64  `json:"last_seen_time" gorm:"bigint;index"`
+	Current      bool   `json:"current" gorm:"-:all"`
+}
+
+// userSessionTouched 本实例最近一次更新活动时间的记录，会话 ID => 时间戳
+var userSessionTouched sync.Map
+
+// CreateUserSession 创建会话记录，返回写入 Cookie 的令牌
+func CreateUserSession(userId int, ip, userAgent string) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	now := utils.GetTimestamp()
+	err := DB.Create(&UserSession{
+		UserId:       userId,
+		TokenHash:    common.HashToken(token),
+		Device:       describeDevice(userAgent),
+		UserAgent:    truncateString(userAgent, 255),
+		Ip:           ip,
+		CreatedTime:  now,
+		LastSeenTime: now,
+	}).Error
+
+	return token, err
+}
+
+func getUserSessionByHash(tokenHash string) (*UserSession, error) {
+	var session UserSession
+	err := DB.Where("token_hash = ?", tokenHash).First(&session).Error
+	return &session, err
+}
+
+// ValidateUserSession 校验 Cookie 中的令牌，会话被撤销或过期时返回错误
+func ValidateUserSession(token string, userId int) (*UserSession, error) {
+	if token == "" {
+		return nil, errors.New("会话无效")
+	}
+
+	tokenHash := common.HashToken(token)
+	var session *UserSession
+	var err error
+	if config.RedisEnabled {
+		session, err = cache.GetOrSetCache(
+			fmt.Sprintf(UserSessionCacheKey, tokenHash),
+			time.Duration(TokenCacheSeconds)*time.Second,
+			func() (*UserSession, error) {
+				return getUserSessionByHash(tokenHash)
+			},
+			cache.CacheTimeout)
+	} else {
+		session, err = getUserSessionByHash(tokenHash)
+	}
+
+	if err != nil || session.UserId != userId {
+		return nil, errors.New("会话已失效，请重新登录")
+	}
+
+	if session.lastSeen() < utils.GetTimestamp()-UserSessionMaxAge {
+		return nil, errors.New("会话已过期，请重新登录")
+	}
+
+	return session, nil
+}
+
+// Touch 更新最近活动时间和 IP，同一会话在间隔内只写一次
+func (s *UserSession) Touch(ip string) {
+	now := utils.GetTimestamp()
+	if now-s.lastSeen() < userSessionTouchInterval {
+		return
+	}
+	userSessionTouched.Store(s.Id, now)
+
+	DB.Model(&UserSession{}).Where("id = ?", s.Id).Updates(map[string]any{
+		"last_seen_time": now,
+		"ip":             ip,
+	})
+}
+
+// lastSeen 缓存中的记录可能较旧，取本实例记录的最近活动时间
+func (s *UserSession) lastSeen() int64 {
+	if touched, ok := userSessionTouched.Load(s.Id); ok && touched.(int64) > s.LastSeenTime {
+		return touched.(int64)
+	}
+	return s.LastSeenTime
+}
+
+func GetUserSessions(userId int) ([]*UserSession, error) {
+	var sessions []*UserSession
+	err := DB.Where("user_id = ? AND last_seen_time >= ?", userId, utils.GetTimestamp()-UserSessionMaxAge).
+		Order("last_seen_time desc").Find(&sessions).Error
+
+	for _, session := range sessions {
+		session.LastSeenTime = session.lastSeen()
+	}
+
+	return sessions, err
+}
+
+// DeleteUserSessionByToken 退出登录时删除当前会话
+func DeleteUserSessionByToken(token string) error {
+	if token == "" {
+		return nil
+	}
+	return deleteUserSessions(DB.Where("token_hash = ?", common.HashToken(token)))
+}
+
+// RevokeUserSession 撤销用户的指定会话
+func RevokeUserSession(userId, sessionId int) error {
+	var count int64
+	if err := DB.Model(&UserSession{}).Where("id = ? AND user_id = ?", sessionId, userId).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("会话不存在")
+	}
+
+	return deleteUserSessions(DB.Where("id = ? AND user_id = ?", sessionId, userId))
+}
+
+// RevokeUserSessions 撤销用户的全部会话，exceptSessionId 不为 0 时保留该会话
+func RevokeUserSessions(userId, exceptSessionId int) error {
+	query := DB.Where("user_id = ?", userId)
+	if exceptSessionId != 0 {
+		query = query.Where("id <> ?", exceptSessionId)
+	}
+	return deleteUserSessions(query)
+}
+
+// RevokeUserSessionsByEmail 通过邮箱重置密码后撤销该账号的全部会话
+func RevokeUserSessionsByEmail(email string) error {
+	var userIds []int
+	if err := DB.Model(&User{}).Where("email = ?", email).Pluck("id", &userIds).Error; err != nil {
+		return err
+	}
+	if len(userIds) == 0 {
+		return nil
+	}
+	return deleteUserSessions(DB.Where("user_id IN ?", userIds))
+}
+
+// DeleteExpiredUserSessions 清理长时间未活动的会话
+func DeleteExpiredUserSessions() (int64, error) {
+	result := DB.Where("last_seen_time < ?", utils.GetTimestamp()-UserSessionMaxAge).Delete(&UserSession{})
+	return result.RowsAffected, result.Error
+}
+
+func deleteUserSessions(query *gorm.DB) error {
+	var sessions []*UserSession
+	if err := query.Model(&UserSession{}).Select("id", "token_hash").Find(&sessions).Error; err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		return nil
+	}
+
+	ids := make([]int, 0, len(sessions))
+	for _, session := range sessions {
+		ids = append(ids, session.Id)
+		userSessionTouched.Delete(session.Id)
+		if config.RedisEnabled {
+			redis.RedisDel(fmt.Sprintf(UserSessionCacheKey, session.TokenHash))
+		}
+	}
+
+	return DB.Where("id IN ?", ids).Delete(&UserSession{}).Error
+}
+
+// describeDevice 从 User-Agent 中提取浏览器和系统，便于用户辨认会话
+func describeDevice(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	browser := "Unknown"
+	for _, item := range []struct{ key, name string }{
+		{"edg/", "Edge"},
+		{"opr/", "Opera"},
+		{"firefox/", "Firefox"},
+		{"chrome/", "Chrome"},
+		{"safari/", "Safari"},
+		{"curl/", "curl"},
+	} {
+		if strings.Contains(ua, item.key) {
+			browser = item.name
+			break
+		}
+	}
+
+	os := "Unknown"
+	for _, item := range []struct{ key, name string }{
+		{"windows", "Windows"},
+		{"iphone", "iOS"},
+		{"ipad", "iPadOS"},
+		{"android", "Android"},
+		{"mac os", "macOS"},
+		{"linux", "Linux"},
+	} {
+		if strings.Contains(ua, item.key) {
+			os = item.name
+			break
+		}
+	}
+
+	return browser + " on " + os
+}
+
+func truncateString(s string, length int) string {
+	if len(s) <= length {
+		return s
+	}
+	return s[:length]
+}
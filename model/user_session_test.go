@@ -0,0 +1,79 @@
+package model
+
+import (
+	"testing"
+
+	"done-hub/common/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSessionUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120.0 Safari/537.36"
+
+func createTestSession(t *testing.T, userId int) (*UserSession, string) {
+	token, err := CreateUserSession(userId, "1.1.1.1", testSessionUA)
+	assert.NoError(t, err)
+
+	session, err := ValidateUserSession(token, userId)
+	assert.NoError(t, err)
+
+	return session, token
+}
+
+func TestUserSessionValidate(t *testing.T) {
+	setupTestDB(t, &UserSession{})
+	session, token := createTestSession(t, 1)
+	assert.Equal(t, "Chrome on Windows", session.Device)
+
+	// 令牌属于其他用户或为空时无效
+	_, err := ValidateUserSession(token, 2)
+	assert.Error(t, err)
+	_, err = ValidateUserSession("", 1)
+	assert.Error(t, err)
+
+	// 超过有效期未活动的会话失效，也不出现在会话列表中
+	assert.NoError(t, DB.Model(session).Update("last_seen_time", utils.GetTimestamp()-UserSessionMaxAge-1).Error)
+	_, err = ValidateUserSession(token, 1)
+	assert.Error(t, err)
+	sessions, err := GetUserSessions(1)
+	assert.NoError(t, err)
+	assert.Empty(t, sessions)
+
+	deleted, err := DeleteExpiredUserSessions()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, deleted)
+}
+
+func TestUserSessionRevoke(t *testing.T) {
+	setupTestDB(t, &User{}, &UserSession{})
+	current, currentToken := createTestSession(t, 1)
+	other, otherToken := createTestSession(t, 1)
+	_, thirdToken := createTestSession(t, 1)
+	_, foreignToken := createTestSession(t, 2)
+
+	// 不能撤销其他用户的会话
+	assert.Error(t, RevokeUserSession(2, other.Id))
+	assert.NoError(t, RevokeUserSession(1, other.Id))
+	_, err := ValidateUserSession(otherToken, 1)
+	assert.Error(t, err)
+
+	// 撤销其他会话时保留当前会话
+	assert.NoError(t, RevokeUserSessions(1, current.Id))
+	_, err = ValidateUserSession(thirdToken, 1)
+	assert.Error(t, err)
+	_, err = ValidateUserSession(currentToken, 1)
+	assert.NoError(t, err)
+	_, err = ValidateUserSession(foreignToken, 2)
+	assert.NoError(t, err)
+
+	assert.NoError(t, DeleteUserSessionByToken(currentToken))
+	_, err = ValidateUserSession(currentToken, 1)
+	assert.Error(t, err)
+
+	// 重置密码后撤销该邮箱账号的全部会话
+	user := createTestUser(t, 2)
+	assert.NoError(t, DB.Model(user).Update("email", "user2@example.com").Error)
+	assert.NoError(t, RevokeUserSessionsByEmail("user2@example.com"))
+	_, err = ValidateUserSession(foreignToken, 2)
+	assert.Error(t, err)
+}
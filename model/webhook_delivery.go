@@ -0,0 +1,100 @@
+package model
+
+import (
+	"done-hub/common/utils"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+const (
+	WebhookDeliveryStatusPending = 1
+	WebhookDeliveryStatusSuccess = 2
+	WebhookDeliveryStatusFailed  = 3
+)
+
+// WebhookDelivery 异步任务完成回调的投递记录
+type WebhookDelivery struct {
+	Id           int    `json:"id"`
+	UserId       int    `json:"user_id" gorm:"index"`
+	TokenId      int    `json:"token_id" gorm:"default:0"`
+	Platform     string `json:"platform" gorm:"type:varchar(30);index"`
+	TaskId       string `json:"task_id" gorm:"type:varchar(100);index"`
+	Event        string `json:"event" gorm:"type:varchar(50)"`
+	URL          string `json:"url" gorm:"type:varchar(1024)"`
+	Payload      string `json:"payload" gorm:"type:text"`
+	Status       int    `json:"status" gorm:"default:1;index"`
+	Attempts     int    `json:"attempts" gorm:"default:0"`
+	NextRetryAt  int64  `json:"next_retry_at" gorm:"bigint;index"`
+	ResponseCode int    `json:"response_code" gorm:"default:0"`
+	LastError    string `json:"last_error" gorm:"type:text"`
+	CreatedAt    int64  `json:"created_at" gorm:"bigint"`
+	UpdatedAt    int64  `json:"updated_at" gorm:"bigint"`
+}
+
+type WebhookDeliveryQueryParams struct {
+	PaginationParams
+	UserId   int    `form:"user_id"`
+	Platform string `form:"platform"`
+	TaskId   string `form:"task_id"`
+	Status   int    `form:"status"`
+}
+
+var allowedWebhookDeliveryOrderFields = map[string]bool{
+	"id":            true,
+	"created_at":    true,
+	"next_retry_at": true,
+	"attempts":      true,
+}
+
+func GetAllWebhookDeliveries(params *WebhookDeliveryQueryParams) (*DataResult[WebhookDelivery], error) {
+	var deliveries []*WebhookDelivery
+	tx := DB
+
+	if params.UserId != 0 {
+		tx = tx.Where("user_id = ?", params.UserId)
+	}
+	if params.Platform != "" {
+		tx = tx.Where("platform = ?", params.Platform)
+	}
+	if params.TaskId != "" {
+		tx = tx.Where("task_id = ?", params.TaskId)
+	}
+	if params.Status != 0 {
+		tx = tx.Where("status = ?", params.Status)
+	}
+
+	return PaginateAndOrder(tx, &params.PaginationParams, &deliveries, allowedWebhookDeliveryOrderFields)
+}
+
+func GetWebhookDeliveryById(id int) (*WebhookDelivery, error) {
+	delivery := &WebhookDelivery{}
+	err := DB.First(delivery, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("投递记录不存在")
+	}
+
+	return delivery, err
+}
+
+// GetPendingWebhookDeliveries 获取到达重试时间的待投递记录
+func GetPendingWebhookDeliveries(limit int) ([]*WebhookDelivery, error) {
+	var deliveries []*WebhookDelivery
+	err := DB.Where("status = ? and next_retry_at <= ?", WebhookDeliveryStatusPending, utils.GetTimestamp()).
+		Order("id").Limit(limit).Find(&deliveries).Error
+
+	return deliveries, err
+}
+
+func (delivery *WebhookDelivery) Insert() error {
+	delivery.CreatedAt = utils.GetTimestamp()
+	delivery.UpdatedAt = delivery.CreatedAt
+
+	return DB.Create(delivery).Error
+}
+
+func (delivery *WebhookDelivery) Update() error {
+	delivery.UpdatedAt = utils.GetTimestamp()
+
+	return DB.Save(delivery).Error
+}
@@ -1,11 +1,13 @@
 package alipay
 
 import (
+	"context"
 	"done-hub/model"
 	"done-hub/payment/types"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/smartwalle/alipay/v3"
@@ -118,3 +120,71 @@ func getAlipayConfig(gatewayConfig string) (*AlipayConfig, error) {
 func (a *Alipay) CreatedPay(_ string, _ *model.Payment) error {
 	return nil
 }
+
+func (a *Alipay) Refund(req *types.RefundRequest, gatewayConfig string) (*types.RefundResult, error) {
+	alipayConfig, err := getAlipayConfig(gatewayConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := a.createClient(alipayConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := client.TradeRefund(context.Background(), alipay.TradeRefund{
+		OutTradeNo:   req.TradeNo,
+		RefundAmount: strconv.FormatFloat(req.Money, 'f', 2, 64),
+		RefundReason: req.Reason,
+		OutRequestNo: req.RefundNo,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("alipay trade refund failed: %s", err.Error())
+	}
+	if !result.IsSuccess() {
+		return nil, fmt.Errorf("alipay trade refund failed: %s %s", result.Msg, result.SubMsg)
+	}
+
+	return &types.RefundResult{GatewayRefundNo: result.TradeNo}, nil
+}
+
+func (a *Alipay) QueryTrade(order *model.Order, gatewayConfig string) (*types.TradeQueryResult, error) {
+	alipayConfig, err := getAlipayConfig(gatewayConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := a.createClient(alipayConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := client.TradeQuery(context.Background(), alipay.TradeQuery{OutTradeNo: order.TradeNo})
+	if err != nil {
+		return nil, fmt.Errorf("alipay trade query failed: %s", err.Error())
+	}
+
+	queryResult := &types.TradeQueryResult{TradeNo: order.TradeNo}
+	// 未扫码的交易在支付宝侧不存在
+	if !result.IsSuccess() {
+		if result.SubCode == "ACQ.TRADE_NOT_EXIST" {
+			return queryResult, nil
+		}
+		return nil, fmt.Errorf("alipay trade query failed: %s %s", result.Msg, result.SubMsg)
+	}
+
+	queryResult.GatewayNo = result.TradeNo
+	queryResult.Money, _ = strconv.ParseFloat(result.TotalAmount, 64)
+	switch result.TradeStatus {
+	case alipay.TradeStatusSuccess, alipay.TradeStatusFinished:
+		queryResult.Paid = true
+	case alipay.TradeStatusClosed:
+		// 支付后全额退款的交易也会关闭
+		queryResult.Paid = order.Status != model.OrderStatusPending && order.Status != model.OrderStatusClosed
+		if queryResult.Paid {
+			queryResult.RefundMoney = queryResult.Money
+		}
+	}
+
+	return queryResult, nil
+}
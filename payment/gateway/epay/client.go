@@ -3,8 +3,14 @@ package epay
 import (
 	"crypto/md5"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/mitchellh/mapstructure"
 )
@@ -78,3 +84,59 @@ func (c *Client) Sign(args map[string]string) string {
 
 	return hex.EncodeToString(h.Sum(nil))
 }
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// QueryOrder 查询订单，对应 api.php?act=order
+func (c *Client) QueryOrder(outTradeNo string) (*OrderQueryResult, error) {
+	params := url.Values{}
+	params.Set("act", "order")
+	params.Set("pid", c.PartnerID)
+	params.Set("key", c.Key)
+	params.Set("out_trade_no", outTradeNo)
+
+	resp, err := httpClient.Get(c.apiURL() + "?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result OrderQueryResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode epay order response failed: %v", err)
+	}
+
+	return &result, nil
+}
+
+// Refund 订单退款，对应 api.php?act=refund，需要商户开通 API 退款权限
+func (c *Client) Refund(outTradeNo, money string) error {
+	form := url.Values{}
+	form.Set("pid", c.PartnerID)
+	form.Set("key", c.Key)
+	form.Set("out_trade_no", outTradeNo)
+	form.Set("money", money)
+
+	resp, err := httpClient.PostForm(c.apiURL()+"?act=refund", form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result APIResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("decode epay refund response failed: %v", err)
+	}
+	if result.Code.String() != APICodeSuccess {
+		if result.Msg == "" {
+			return errors.New("epay refund failed")
+		}
+		return errors.New(result.Msg)
+	}
+
+	return nil
+}
+
+func (c *Client) apiURL() string {
+	return strings.TrimSuffix(c.PayDomain, "/") + APIUrl
+}
@@ -93,3 +93,44 @@ func getEpayConfig(gatewayConfig string) (*EpayConfig, error) {
 func (e *Epay) CreatedPay(_ string, _ *model.Payment) error {
 	return nil
 }
+
+func (e *Epay) Refund(req *types.RefundRequest, gatewayConfig string) (*types.RefundResult, error) {
+	epayConfig, err := getEpayConfig(gatewayConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// 易支付不返回退款单号
+	err = epayConfig.Refund(req.TradeNo, strconv.FormatFloat(req.Money, 'f', 2, 64))
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.RefundResult{}, nil
+}
+
+func (e *Epay) QueryTrade(order *model.Order, gatewayConfig string) (*types.TradeQueryResult, error) {
+	epayConfig, err := getEpayConfig(gatewayConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	orderResult, err := epayConfig.QueryOrder(order.TradeNo)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &types.TradeQueryResult{TradeNo: order.TradeNo}
+	// 订单不存在时同样返回错误码，视为未支付
+	if orderResult.Code.String() != APICodeSuccess {
+		return result, nil
+	}
+
+	result.GatewayNo = orderResult.TradeNo
+	result.Paid = orderResult.Status.String() == OrderStatusPaid
+	result.Money, _ = orderResult.Money.Float64()
+	// 易支付查询接口不返回退款金额，沿用本地记录
+	result.RefundMoney = order.RefundedAmount
+
+	return result, nil
+}
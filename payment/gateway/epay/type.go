@@ -1,5 +1,7 @@
 package epay
 
+import "encoding/json"
+
 type PayType string
 
 var (
@@ -24,6 +26,9 @@ const (
 	FormArgsSignType   = "MD5"
 	FormSubmitUrl      = "/submit.php"
 	TradeStatusSuccess = "TRADE_SUCCESS"
+	APIUrl             = "/api.php"
+	APICodeSuccess     = "1"
+	OrderStatusPaid    = "1"
 )
 
 type PayArgs struct {
@@ -43,3 +48,17 @@ type PaymentResult struct {
 	Money       string  `mapstructure:"money"`
 	TradeStatus string  `mapstructure:"trade_status"`
 }
+
+// 不同版本的易支付返回的数字字段可能是字符串，统一使用 json.Number 接收
+type APIResult struct {
+	Code json.Number `json:"code"`
+	Msg  string      `json:"msg"`
+}
+
+type OrderQueryResult struct {
+	APIResult
+	TradeNo    string      `json:"trade_no"`
+	OutTradeNo string      `json:"out_trade_no"`
+	Money      json.Number `json:"money"`
+	Status     json.Number `json:"status"`
+}
@@ -0,0 +1,204 @@
+package paypal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// apiBaseURL 接口地址，测试时替换为本地模拟服务
+var apiBaseURL = func(mode Mode) string {
+	if mode == Sandbox {
+		return SandboxAPIBase
+	}
+	return LiveAPIBase
+}
+
+type cachedToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// access token 有效期通常为 9 小时，按 client_id 缓存，提前 5 分钟刷新
+var (
+	tokenCache   = make(map[string]*cachedToken)
+	tokenCacheMu sync.Mutex
+)
+
+type Client struct {
+	baseURL      string
+	clientID     string
+	clientSecret string
+}
+
+func NewClient(config *PaypalConfig) *Client {
+	return &Client{
+		baseURL:      strings.TrimSuffix(apiBaseURL(config.Mode), "/"),
+		clientID:     config.ClientID,
+		clientSecret: config.ClientSecret,
+	}
+}
+
+func (c *Client) getAccessToken() (string, error) {
+	cacheKey := c.baseURL + "|" + c.clientID
+	tokenCacheMu.Lock()
+	defer tokenCacheMu.Unlock()
+
+	if cached, ok := tokenCache[cacheKey]; ok && time.Now().Before(cached.expiresAt) {
+		return cached.token, nil
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/v1/oauth2/token", strings.NewReader(url.Values{"grant_type": {"client_credentials"}}.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(c.clientID, c.clientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var token TokenResponse
+	if err := doRequest(req, &token); err != nil {
+		return "", fmt.Errorf("paypal get access token failed: %v", err)
+	}
+
+	tokenCache[cacheKey] = &cachedToken{
+		token:     token.AccessToken,
+		expiresAt: time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - 5*time.Minute),
+	}
+	return token.AccessToken, nil
+}
+
+// request 调用需要授权的接口，requestId 不为空时作为幂等键
+func (c *Client) request(method, path string, body any, requestId string, result any) error {
+	token, err := c.getAccessToken()
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	if requestId != "" {
+		req.Header.Set("PayPal-Request-Id", requestId)
+	}
+
+	return doRequest(req, result)
+}
+
+func doRequest(req *http.Request, result any) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var errResp ErrorResponse
+		if json.Unmarshal(data, &errResp) == nil {
+			return errResp.toError(resp.StatusCode)
+		}
+		return fmt.Errorf("status code %d", resp.StatusCode)
+	}
+
+	if result == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, result)
+}
+
+func (e *ErrorResponse) toError(statusCode int) error {
+	message := e.Message
+	if message == "" {
+		message = e.ErrorDescription
+	}
+	if len(e.Details) > 0 {
+		message = fmt.Sprintf("%s (%s: %s)", message, e.Details[0].Issue, e.Details[0].Description)
+	}
+
+	name := e.Name
+	if name == "" {
+		name = e.Error
+	}
+	return fmt.Errorf("status code %d, %s: %s", statusCode, name, message)
+}
+
+func (c *Client) CreateOrder(orderReq *CreateOrderRequest, requestId string) (*Order, error) {
+	var order Order
+	err := c.request(http.MethodPost, "/v2/checkout/orders", orderReq, requestId, &order)
+	return &order, err
+}
+
+func (c *Client) GetOrder(orderId string) (*Order, error) {
+	var order Order
+	err := c.request(http.MethodGet, "/v2/checkout/orders/"+url.PathEscape(orderId), nil, "", &order)
+	return &order, err
+}
+
+// CaptureOrder 扣款，以订单 ID 作为幂等键，重复回调不会重复扣款
+func (c *Client) CaptureOrder(orderId string) (*Order, error) {
+	var order Order
+	err := c.request(http.MethodPost, "/v2/checkout/orders/"+url.PathEscape(orderId)+"/capture", struct{}{}, "capture-"+orderId, &order)
+	return &order, err
+}
+
+func (c *Client) GetCapture(captureId string) (*Capture, error) {
+	var capture Capture
+	err := c.request(http.MethodGet, "/v2/payments/captures/"+url.PathEscape(captureId), nil, "", &capture)
+	return &capture, err
+}
+
+func (c *Client) RefundCapture(captureId string, refundReq *RefundRequest, requestId string) (*Refund, error) {
+	var refund Refund
+	err := c.request(http.MethodPost, "/v2/payments/captures/"+url.PathEscape(captureId)+"/refund", refundReq, requestId, &refund)
+	return &refund, err
+}
+
+func (c *Client) GetRefund(refundId string) (*Refund, error) {
+	var refund Refund
+	err := c.request(http.MethodGet, "/v2/payments/refunds/"+url.PathEscape(refundId), nil, "", &refund)
+	return &refund, err
+}
+
+func (c *Client) VerifyWebhookSignature(verifyReq *VerifyWebhookSignatureRequest) (bool, error) {
+	var resp VerifyWebhookSignatureResponse
+	if err := c.request(http.MethodPost, "/v1/notifications/verify-webhook-signature", verifyReq, "", &resp); err != nil {
+		return false, err
+	}
+	return resp.VerificationStatus == VerificationStatusSuccess, nil
+}
+
+func (c *Client) ListWebhooks() ([]*Webhook, error) {
+	var list WebhookList
+	err := c.request(http.MethodGet, "/v1/notifications/webhooks", nil, "", &list)
+	return list.Webhooks, err
+}
+
+func (c *Client) CreateWebhook(webhook *Webhook) (*Webhook, error) {
+	var created Webhook
+	err := c.request(http.MethodPost, "/v1/notifications/webhooks", webhook, "", &created)
+	return &created, err
+}
@@ -0,0 +1,410 @@
+package paypal
+
+import (
+	"done-hub/model"
+	"done-hub/payment/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	sysconfig "done-hub/common/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PayPal 使用 Orders v2 接口，用户在 PayPal 确认付款后通过 webhook 通知扣款
+type PayPal struct{}
+
+// webhookEvents 需要订阅的事件，扣款可能在回调中完成，也可能由 PayPal 异步完成
+var webhookEvents = []string{EventOrderApproved, EventCaptureComplete}
+
+func (p *PayPal) Name() string {
+	return "PayPal"
+}
+
+func (p *PayPal) Pay(config *types.PayConfig, gatewayConfig string) (*types.PayRequest, error) {
+	paypalConfig, err := getPaypalConfig(gatewayConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	currency, err := currencyCode(config.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	amount := strconv.FormatFloat(config.Money, 'f', 2, 64)
+	orderReq := &CreateOrderRequest{
+		Intent: "CAPTURE",
+		PurchaseUnits: []*PurchaseUnitRequest{
+			{
+				ReferenceID: config.TradeNo,
+				CustomID:    config.TradeNo,
+				InvoiceID:   config.TradeNo,
+				Description: sysconfig.SystemName + "-Token充值:" + amount + " " + currency,
+				Amount: &Money{
+					CurrencyCode: currency,
+					Value:        amount,
+				},
+			},
+		},
+		ApplicationContext: &ApplicationContext{
+			BrandName:          sysconfig.SystemName,
+			ReturnURL:          config.ReturnURL,
+			CancelURL:          config.ReturnURL,
+			ShippingPreference: "NO_SHIPPING",
+			UserAction:         "PAY_NOW",
+		},
+	}
+
+	order, err := NewClient(paypalConfig).CreateOrder(orderReq, config.TradeNo)
+	if err != nil {
+		return nil, fmt.Errorf("paypal create order failed: %v", err)
+	}
+
+	approveURL := ""
+	for _, link := range order.Links {
+		if link.Rel == "approve" || link.Rel == "payer-action" {
+			approveURL = link.Href
+			break
+		}
+	}
+	if approveURL == "" {
+		return nil, fmt.Errorf("paypal create order failed: approve link not found, order: %s", order.ID)
+	}
+
+	// 前端以表单提交的方式跳转，查询参数需要拆分到 Params 中
+	payURL, params, err := splitURLParams(approveURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.PayRequest{
+		Type: 1,
+		Data: types.PayRequestData{
+			URL:    payURL,
+			Params: params,
+			Method: http.MethodGet,
+		},
+	}, nil
+}
+
+// CreatedPay 创建网关时在 PayPal 后台注册 webhook，并保存 webhook_id 用于校验回调签名
+func (p *PayPal) CreatedPay(notifyURL string, gatewayConfig *model.Payment) error {
+	paypalConfig, err := getPaypalConfig(gatewayConfig.Config)
+	if err != nil {
+		return err
+	}
+
+	client := NewClient(paypalConfig)
+	webhooks, err := client.ListWebhooks()
+	if err != nil {
+		return fmt.Errorf("error listing webhooks: %v", err)
+	}
+
+	var webhook *Webhook
+	for _, item := range webhooks {
+		if item.URL == notifyURL {
+			webhook = item
+			break
+		}
+	}
+
+	if webhook == nil {
+		eventTypes := make([]*WebhookEventType, 0, len(webhookEvents))
+		for _, event := range webhookEvents {
+			eventTypes = append(eventTypes, &WebhookEventType{Name: event})
+		}
+
+		webhook, err = client.CreateWebhook(&Webhook{URL: notifyURL, EventTypes: eventTypes})
+		if err != nil {
+			return fmt.Errorf("error creating webhook: %v", err)
+		}
+	}
+
+	paypalConfig.WebhookID = webhook.ID
+	config, err := json.Marshal(paypalConfig)
+	if err != nil {
+		return fmt.Errorf("error creating webhook: %v", err)
+	}
+
+	gatewayConfig.Config = string(config)
+	if err := gatewayConfig.Update(true); err != nil {
+		return fmt.Errorf("error creating webhook: %v", err)
+	}
+	return nil
+}
+
+// HandleCallback 校验签名后处理 webhook，用户确认付款时发起扣款，扣款完成后返回支付结果
+// 返回非 2xx 状态码时 PayPal 会重试
+func (p *PayPal) HandleCallback(c *gin.Context, gatewayConfig string) (*types.PayNotify, error) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return nil, fmt.Errorf("failed to read request body: %v", err)
+	}
+
+	paypalConfig, err := getPaypalConfig(gatewayConfig)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return nil, err
+	}
+	if paypalConfig.WebhookID == "" {
+		c.Status(http.StatusInternalServerError)
+		return nil, errors.New("webhook id not configured")
+	}
+
+	client := NewClient(paypalConfig)
+	verified, err := client.VerifyWebhookSignature(&VerifyWebhookSignatureRequest{
+		AuthAlgo:         c.GetHeader("PAYPAL-AUTH-ALGO"),
+		CertURL:          c.GetHeader("PAYPAL-CERT-URL"),
+		TransmissionID:   c.GetHeader("PAYPAL-TRANSMISSION-ID"),
+		TransmissionSig:  c.GetHeader("PAYPAL-TRANSMISSION-SIG"),
+		TransmissionTime: c.GetHeader("PAYPAL-TRANSMISSION-TIME"),
+		WebhookID:        paypalConfig.WebhookID,
+		WebhookEvent:     body,
+	})
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return nil, fmt.Errorf("failed to verify webhook: %v", err)
+	}
+	if !verified {
+		c.Status(http.StatusBadRequest)
+		return nil, errors.New("failed to verify webhook: invalid signature")
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		c.Status(http.StatusBadRequest)
+		return nil, fmt.Errorf("failed to parse webhook event: %v", err)
+	}
+
+	switch event.EventType {
+	case EventOrderApproved:
+		var order Order
+		if err := json.Unmarshal(event.Resource, &order); err != nil {
+			c.Status(http.StatusBadRequest)
+			return nil, fmt.Errorf("failed to parse order data: %v", err)
+		}
+
+		captured, err := client.CaptureOrder(order.ID)
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return nil, fmt.Errorf("failed to capture order %s: %v", order.ID, err)
+		}
+
+		unit, capture := firstCapture(captured)
+		// 扣款处理中时等待 PAYMENT.CAPTURE.COMPLETED 通知
+		if capture == nil || capture.Status != CaptureStatusCompleted {
+			return nil, nil
+		}
+
+		tradeNo := capture.CustomID
+		if tradeNo == "" {
+			tradeNo = unit.CustomID
+		}
+		if tradeNo == "" {
+			tradeNo = unit.ReferenceID
+		}
+
+		return &types.PayNotify{
+			TradeNo:   tradeNo,
+			GatewayNo: capture.ID,
+		}, nil
+	case EventCaptureComplete:
+		var capture Capture
+		if err := json.Unmarshal(event.Resource, &capture); err != nil {
+			c.Status(http.StatusBadRequest)
+			return nil, fmt.Errorf("failed to parse capture data: %v", err)
+		}
+
+		return &types.PayNotify{
+			TradeNo:   capture.CustomID,
+			GatewayNo: capture.ID,
+		}, nil
+	default:
+		return nil, nil
+	}
+}
+
+func (p *PayPal) Refund(req *types.RefundRequest, gatewayConfig string) (*types.RefundResult, error) {
+	paypalConfig, err := getPaypalConfig(gatewayConfig)
+	if err != nil {
+		return nil, err
+	}
+	if req.GatewayNo == "" {
+		return nil, fmt.Errorf("capture not found, trade_no: %s", req.TradeNo)
+	}
+
+	currency, err := currencyCode(req.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	refundReq := &RefundRequest{
+		Amount: &Money{
+			CurrencyCode: currency,
+			Value:        strconv.FormatFloat(req.Money, 'f', 2, 64),
+		},
+		InvoiceID:   req.RefundNo,
+		NoteToPayer: req.Reason,
+	}
+
+	// 使用退款单号作为幂等键，重试时不会重复退款
+	refund, err := NewClient(paypalConfig).RefundCapture(req.GatewayNo, refundReq, req.RefundNo)
+	if err != nil {
+		return nil, fmt.Errorf("paypal refund failed: %v", err)
+	}
+
+	result := &types.RefundResult{GatewayRefundNo: refund.ID}
+	switch refund.Status {
+	case RefundStatusCompleted:
+	case RefundStatusPending:
+		result.Pending = true
+	default:
+		return nil, fmt.Errorf("paypal refund failed: %s", refund.Status)
+	}
+
+	return result, nil
+}
+
+func (p *PayPal) QueryRefund(refund *model.OrderRefund, gatewayConfig string) (*types.RefundQueryResult, error) {
+	paypalConfig, err := getPaypalConfig(gatewayConfig)
+	if err != nil {
+		return nil, err
+	}
+	if refund.GatewayRefundNo == "" {
+		return nil, fmt.Errorf("gateway refund no not found, refund_no: %s", refund.RefundNo)
+	}
+
+	paypalRefund, err := NewClient(paypalConfig).GetRefund(refund.GatewayRefundNo)
+	if err != nil {
+		return nil, fmt.Errorf("paypal query refund failed: %v", err)
+	}
+
+	result := &types.RefundQueryResult{}
+	switch paypalRefund.Status {
+	case RefundStatusCompleted:
+	case RefundStatusPending:
+		result.Pending = true
+	default:
+		result.Failed = true
+		result.Message = "paypal refund " + paypalRefund.Status
+	}
+
+	return result, nil
+}
+
+func (p *PayPal) QueryTrade(order *model.Order, gatewayConfig string) (*types.TradeQueryResult, error) {
+	// 扣款单号只在回调中返回，未回调的订单无法查询
+	if order.GatewayNo == "" {
+		return nil, types.ErrTradeQueryUnsupported
+	}
+
+	paypalConfig, err := getPaypalConfig(gatewayConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	client := NewClient(paypalConfig)
+	capture, err := client.GetCapture(order.GatewayNo)
+	if err != nil {
+		return nil, fmt.Errorf("paypal query capture failed: %v", err)
+	}
+
+	result := &types.TradeQueryResult{
+		TradeNo:   order.TradeNo,
+		GatewayNo: capture.ID,
+	}
+	if capture.Amount != nil {
+		result.Money, _ = strconv.ParseFloat(capture.Amount.Value, 64)
+	}
+
+	switch capture.Status {
+	case CaptureStatusCompleted:
+		result.Paid = true
+	case CaptureStatusRefunded:
+		result.Paid = true
+		result.RefundMoney = result.Money
+	case CaptureStatusPartiallyRefunded:
+		result.Paid = true
+		// 扣款信息不包含退款金额，从订单的退款记录中汇总
+		result.RefundMoney = order.RefundedAmount
+		if capture.SupplementaryData != nil && capture.SupplementaryData.RelatedIDs.OrderID != "" {
+			paypalOrder, err := client.GetOrder(capture.SupplementaryData.RelatedIDs.OrderID)
+			if err != nil {
+				return nil, fmt.Errorf("paypal query order failed: %v", err)
+			}
+			result.RefundMoney = sumRefunds(paypalOrder)
+		}
+	}
+
+	return result, nil
+}
+
+func getPaypalConfig(gatewayConfig string) (*PaypalConfig, error) {
+	var paypalConfig PaypalConfig
+	if err := json.Unmarshal([]byte(gatewayConfig), &paypalConfig); err != nil {
+		return nil, errors.New("config error")
+	}
+
+	return &paypalConfig, nil
+}
+
+// currencyCode 网关币种转换为 PayPal 的币种代码，只支持系统中定义的币种
+func currencyCode(currency model.CurrencyType) (string, error) {
+	switch currency {
+	case model.CurrencyTypeUSD, model.CurrencyTypeCNY:
+		return string(currency), nil
+	case "":
+		return string(model.CurrencyTypeUSD), nil
+	default:
+		return "", fmt.Errorf("unsupported currency: %s", currency)
+	}
+}
+
+func firstCapture(order *Order) (*PurchaseUnit, *Capture) {
+	for _, unit := range order.PurchaseUnits {
+		if unit.Payments != nil && len(unit.Payments.Captures) > 0 {
+			return unit, unit.Payments.Captures[0]
+		}
+	}
+	return nil, nil
+}
+
+func sumRefunds(order *Order) float64 {
+	total := 0.0
+	for _, unit := range order.PurchaseUnits {
+		if unit.Payments == nil {
+			continue
+		}
+		for _, refund := range unit.Payments.Refunds {
+			if refund.Status != RefundStatusCompleted || refund.Amount == nil {
+				continue
+			}
+			value, _ := strconv.ParseFloat(refund.Amount.Value, 64)
+			total += value
+		}
+	}
+	return total
+}
+
+func splitURLParams(rawURL string) (string, map[string]string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", nil, err
+	}
+
+	params := make(map[string]string)
+	for key, values := range parsed.Query() {
+		if len(values) > 0 {
+			params[key] = values[0]
+		}
+	}
+
+	parsed.RawQuery = ""
+	return parsed.String(), params, nil
+}
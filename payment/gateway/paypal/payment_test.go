@@ -0,0 +1,295 @@
+package paypal
+
+import (
+	"done-hub/model"
+	"done-hub/payment/types"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+const testGatewayConfig = `{"client_id":"client","client_secret":"secret","webhook_id":"WH-1","mode":"sandbox"}`
+
+// mockPaypal 本地模拟的 PayPal 接口，记录收到的请求用于断言
+type mockPaypal struct {
+	server       *httptest.Server
+	requests     map[string]*http.Request
+	bodies       map[string]string
+	verification string
+	captureState string
+	refundState  string
+}
+
+func setupMockPaypal(t *testing.T) *mockPaypal {
+	mock := &mockPaypal{
+		requests:     make(map[string]*http.Request),
+		bodies:       make(map[string]string),
+		verification: VerificationStatusSuccess,
+		captureState: CaptureStatusCompleted,
+		refundState:  RefundStatusCompleted,
+	}
+
+	mux := http.NewServeMux()
+	handle := func(pattern string, handler func(w http.ResponseWriter, r *http.Request)) {
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			mock.requests[pattern] = r
+			mock.bodies[pattern] = string(body)
+
+			if pattern != "POST /v1/oauth2/token" && r.Header.Get("Authorization") != "Bearer test-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"name":"AUTHENTICATION_FAILURE","message":"invalid token"}`))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			handler(w, r)
+		})
+	}
+
+	handle("POST /v1/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "client" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client","error_description":"Client Authentication failed"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token":"test-token","token_type":"Bearer","expires_in":32400}`))
+	})
+	handle("POST /v2/checkout/orders", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"ORDER-1","status":"CREATED","links":[
+			{"href":"https://api.sandbox.paypal.com/v2/checkout/orders/ORDER-1","rel":"self","method":"GET"},
+			{"href":"https://www.sandbox.paypal.com/checkoutnow?token=ORDER-1","rel":"approve","method":"GET"}]}`))
+	})
+	handle("POST /v2/checkout/orders/{id}/capture", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"` + r.PathValue("id") + `","status":"COMPLETED","purchase_units":[{"reference_id":"T100","payments":{"captures":[
+			{"id":"CAPTURE-1","status":"` + mock.captureState + `","custom_id":"T100","amount":{"currency_code":"USD","value":"10.00"}}]}}]}`))
+	})
+	handle("GET /v2/checkout/orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"` + r.PathValue("id") + `","status":"COMPLETED","purchase_units":[{"payments":{"refunds":[
+			{"id":"R-1","status":"COMPLETED","amount":{"currency_code":"USD","value":"2.50"}},
+			{"id":"R-2","status":"COMPLETED","amount":{"currency_code":"USD","value":"1.00"}},
+			{"id":"R-3","status":"CANCELLED","amount":{"currency_code":"USD","value":"5.00"}}]}}]}`))
+	})
+	handle("GET /v2/payments/captures/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"` + r.PathValue("id") + `","status":"` + mock.captureState + `","custom_id":"T100",
+			"amount":{"currency_code":"USD","value":"10.00"},"supplementary_data":{"related_ids":{"order_id":"ORDER-1"}}}`))
+	})
+	handle("POST /v2/payments/captures/{id}/refund", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"REFUND-1","status":"` + mock.refundState + `"}`))
+	})
+	handle("POST /v1/notifications/verify-webhook-signature", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"verification_status":"` + mock.verification + `"}`))
+	})
+
+	mock.server = httptest.NewServer(mux)
+
+	originalBaseURL := apiBaseURL
+	apiBaseURL = func(Mode) string { return mock.server.URL }
+	tokenCacheMu.Lock()
+	tokenCache = make(map[string]*cachedToken)
+	tokenCacheMu.Unlock()
+
+	t.Cleanup(func() {
+		mock.server.Close()
+		apiBaseURL = originalBaseURL
+	})
+
+	return mock
+}
+
+func newCallbackContext(body string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/payment/notify/uuid", strings.NewReader(body))
+	c.Request.Header.Set("PAYPAL-AUTH-ALGO", "SHA256withRSA")
+	c.Request.Header.Set("PAYPAL-CERT-URL", "https://api.sandbox.paypal.com/v1/notifications/certs/CERT")
+	c.Request.Header.Set("PAYPAL-TRANSMISSION-ID", "TRANSMISSION-1")
+	c.Request.Header.Set("PAYPAL-TRANSMISSION-SIG", "signature")
+	c.Request.Header.Set("PAYPAL-TRANSMISSION-TIME", "2024-01-01T00:00:00Z")
+	return c, w
+}
+
+func TestPay(t *testing.T) {
+	mock := setupMockPaypal(t)
+
+	payRequest, err := (&PayPal{}).Pay(&types.PayConfig{
+		Money:     10,
+		TradeNo:   "T100",
+		ReturnURL: "https://example.com/panel/log",
+		Currency:  model.CurrencyTypeUSD,
+	}, testGatewayConfig)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, payRequest.Type)
+	assert.Equal(t, "https://www.sandbox.paypal.com/checkoutnow", payRequest.Data.URL)
+	assert.Equal(t, map[string]string{"token": "ORDER-1"}, payRequest.Data.Params)
+	assert.Equal(t, http.MethodGet, payRequest.Data.Method)
+
+	request := mock.requests["POST /v2/checkout/orders"]
+	assert.Equal(t, "T100", request.Header.Get("PayPal-Request-Id"))
+
+	var orderReq CreateOrderRequest
+	assert.Nil(t, json.Unmarshal([]byte(mock.bodies["POST /v2/checkout/orders"]), &orderReq))
+	assert.Equal(t, "CAPTURE", orderReq.Intent)
+	assert.Equal(t, "T100", orderReq.PurchaseUnits[0].CustomID)
+	assert.Equal(t, "USD", orderReq.PurchaseUnits[0].Amount.CurrencyCode)
+	assert.Equal(t, "10.00", orderReq.PurchaseUnits[0].Amount.Value)
+}
+
+func TestPayCurrency(t *testing.T) {
+	mock := setupMockPaypal(t)
+
+	_, err := (&PayPal{}).Pay(&types.PayConfig{Money: 73.5, TradeNo: "T101", Currency: model.CurrencyTypeCNY}, testGatewayConfig)
+	assert.Nil(t, err)
+
+	var orderReq CreateOrderRequest
+	assert.Nil(t, json.Unmarshal([]byte(mock.bodies["POST /v2/checkout/orders"]), &orderReq))
+	assert.Equal(t, "CNY", orderReq.PurchaseUnits[0].Amount.CurrencyCode)
+	assert.Equal(t, "73.50", orderReq.PurchaseUnits[0].Amount.Value)
+
+	_, err = (&PayPal{}).Pay(&types.PayConfig{Money: 1, TradeNo: "T102", Currency: "EUR"}, testGatewayConfig)
+	assert.NotNil(t, err)
+}
+
+func TestPayInvalidCredentials(t *testing.T) {
+	setupMockPaypal(t)
+
+	_, err := (&PayPal{}).Pay(&types.PayConfig{Money: 1, TradeNo: "T103", Currency: model.CurrencyTypeUSD},
+		`{"client_id":"client","client_secret":"wrong","mode":"sandbox"}`)
+	assert.ErrorContains(t, err, "invalid_client")
+}
+
+func TestHandleCallbackOrderApproved(t *testing.T) {
+	mock := setupMockPaypal(t)
+
+	body := `{"id":"WH-EVENT-1","event_type":"CHECKOUT.ORDER.APPROVED","resource":{"id":"ORDER-1","status":"APPROVED","purchase_units":[{"reference_id":"T100","custom_id":"T100"}]}}`
+	c, w := newCallbackContext(body)
+	payNotify, err := (&PayPal{}).HandleCallback(c, testGatewayConfig)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "T100", payNotify.TradeNo)
+	assert.Equal(t, "CAPTURE-1", payNotify.GatewayNo)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "capture-ORDER-1", mock.requests["POST /v2/checkout/orders/{id}/capture"].Header.Get("PayPal-Request-Id"))
+
+	var verifyReq VerifyWebhookSignatureRequest
+	assert.Nil(t, json.Unmarshal([]byte(mock.bodies["POST /v1/notifications/verify-webhook-signature"]), &verifyReq))
+	assert.Equal(t, "WH-1", verifyReq.WebhookID)
+	assert.Equal(t, "TRANSMISSION-1", verifyReq.TransmissionID)
+	assert.Equal(t, "signature", verifyReq.TransmissionSig)
+	assert.JSONEq(t, body, string(verifyReq.WebhookEvent))
+}
+
+func TestHandleCallbackCapturePending(t *testing.T) {
+	mock := setupMockPaypal(t)
+	mock.captureState = "PENDING"
+
+	c, _ := newCallbackContext(`{"event_type":"CHECKOUT.ORDER.APPROVED","resource":{"id":"ORDER-1"}}`)
+	payNotify, err := (&PayPal{}).HandleCallback(c, testGatewayConfig)
+
+	assert.Nil(t, err)
+	assert.Nil(t, payNotify)
+}
+
+func TestHandleCallbackCaptureCompleted(t *testing.T) {
+	mock := setupMockPaypal(t)
+
+	c, _ := newCallbackContext(`{"event_type":"PAYMENT.CAPTURE.COMPLETED","resource":{"id":"CAPTURE-2","status":"COMPLETED","custom_id":"T200"}}`)
+	payNotify, err := (&PayPal{}).HandleCallback(c, testGatewayConfig)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "T200", payNotify.TradeNo)
+	assert.Equal(t, "CAPTURE-2", payNotify.GatewayNo)
+	assert.Nil(t, mock.requests["POST /v2/checkout/orders/{id}/capture"])
+}
+
+func TestHandleCallbackInvalidSignature(t *testing.T) {
+	mock := setupMockPaypal(t)
+	mock.verification = "FAILURE"
+
+	c, w := newCallbackContext(`{"event_type":"PAYMENT.CAPTURE.COMPLETED","resource":{"id":"CAPTURE-2","custom_id":"T200"}}`)
+	payNotify, err := (&PayPal{}).HandleCallback(c, testGatewayConfig)
+	c.Writer.WriteHeaderNow()
+
+	assert.NotNil(t, err)
+	assert.Nil(t, payNotify)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandleCallbackIgnoredEvent(t *testing.T) {
+	setupMockPaypal(t)
+
+	c, _ := newCallbackContext(`{"event_type":"PAYMENT.CAPTURE.DENIED","resource":{"id":"CAPTURE-3"}}`)
+	payNotify, err := (&PayPal{}).HandleCallback(c, testGatewayConfig)
+
+	assert.Nil(t, err)
+	assert.Nil(t, payNotify)
+}
+
+func TestRefund(t *testing.T) {
+	mock := setupMockPaypal(t)
+
+	result, err := (&PayPal{}).Refund(&types.RefundRequest{
+		TradeNo:    "T100",
+		GatewayNo:  "CAPTURE-1",
+		RefundNo:   "RF1",
+		Money:      2.5,
+		TotalMoney: 10,
+		Currency:   model.CurrencyTypeUSD,
+		Reason:     "duplicate",
+	}, testGatewayConfig)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "REFUND-1", result.GatewayRefundNo)
+	assert.False(t, result.Pending)
+	assert.Equal(t, "RF1", mock.requests["POST /v2/payments/captures/{id}/refund"].Header.Get("PayPal-Request-Id"))
+
+	var refundReq RefundRequest
+	assert.Nil(t, json.Unmarshal([]byte(mock.bodies["POST /v2/payments/captures/{id}/refund"]), &refundReq))
+	assert.Equal(t, "2.50", refundReq.Amount.Value)
+	assert.Equal(t, "USD", refundReq.Amount.CurrencyCode)
+
+	mock.refundState = RefundStatusPending
+	result, err = (&PayPal{}).Refund(&types.RefundRequest{GatewayNo: "CAPTURE-1", RefundNo: "RF2", Money: 1, Currency: model.CurrencyTypeUSD}, testGatewayConfig)
+	assert.Nil(t, err)
+	assert.True(t, result.Pending)
+
+	mock.refundState = "FAILED"
+	_, err = (&PayPal{}).Refund(&types.RefundRequest{GatewayNo: "CAPTURE-1", RefundNo: "RF3", Money: 1, Currency: model.CurrencyTypeUSD}, testGatewayConfig)
+	assert.NotNil(t, err)
+}
+
+func TestQueryTrade(t *testing.T) {
+	mock := setupMockPaypal(t)
+	order := &model.Order{TradeNo: "T100", GatewayNo: "CAPTURE-1", OrderAmount: 10, Status: model.OrderStatusSuccess}
+
+	result, err := (&PayPal{}).QueryTrade(order, testGatewayConfig)
+	assert.Nil(t, err)
+	assert.True(t, result.Paid)
+	assert.Equal(t, 10.0, result.Money)
+	assert.Equal(t, 0.0, result.RefundMoney)
+
+	mock.captureState = CaptureStatusPartiallyRefunded
+	result, err = (&PayPal{}).QueryTrade(order, testGatewayConfig)
+	assert.Nil(t, err)
+	assert.True(t, result.Paid)
+	assert.Equal(t, 3.5, result.RefundMoney)
+
+	mock.captureState = CaptureStatusRefunded
+	result, err = (&PayPal{}).QueryTrade(order, testGatewayConfig)
+	assert.Nil(t, err)
+	assert.Equal(t, 10.0, result.RefundMoney)
+
+	_, err = (&PayPal{}).QueryTrade(&model.Order{TradeNo: "T101"}, testGatewayConfig)
+	assert.ErrorIs(t, err, types.ErrTradeQueryUnsupported)
+}
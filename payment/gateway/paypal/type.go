@@ -0,0 +1,171 @@
+package paypal
+
+import "encoding/json"
+
+type Mode string
+
+var (
+	Live    Mode = "live"    // 正式环境
+	Sandbox Mode = "sandbox" // 沙箱环境
+)
+
+const (
+	LiveAPIBase    = "https://api-m.paypal.com"
+	SandboxAPIBase = "https://api-m.sandbox.paypal.com"
+)
+
+const (
+	EventOrderApproved   = "CHECKOUT.ORDER.APPROVED"
+	EventCaptureComplete = "PAYMENT.CAPTURE.COMPLETED"
+)
+
+const (
+	OrderStatusApproved  = "APPROVED"
+	OrderStatusCompleted = "COMPLETED"
+
+	CaptureStatusCompleted         = "COMPLETED"
+	CaptureStatusPartiallyRefunded = "PARTIALLY_REFUNDED"
+	CaptureStatusRefunded          = "REFUNDED"
+
+	RefundStatusCompleted = "COMPLETED"
+	RefundStatusPending   = "PENDING"
+
+	VerificationStatusSuccess = "SUCCESS"
+)
+
+type PaypalConfig struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	WebhookID    string `json:"webhook_id"`
+	Mode         Mode   `json:"mode"`
+}
+
+type Money struct {
+	CurrencyCode string `json:"currency_code"`
+	Value        string `json:"value"`
+}
+
+type Link struct {
+	Href   string `json:"href"`
+	Rel    string `json:"rel"`
+	Method string `json:"method"`
+}
+
+type PurchaseUnitRequest struct {
+	ReferenceID string `json:"reference_id,omitempty"`
+	CustomID    string `json:"custom_id,omitempty"`
+	InvoiceID   string `json:"invoice_id,omitempty"`
+	Description string `json:"description,omitempty"`
+	Amount      *Money `json:"amount"`
+}
+
+type ApplicationContext struct {
+	BrandName          string `json:"brand_name,omitempty"`
+	ReturnURL          string `json:"return_url,omitempty"`
+	CancelURL          string `json:"cancel_url,omitempty"`
+	ShippingPreference string `json:"shipping_preference,omitempty"`
+	UserAction         string `json:"user_action,omitempty"`
+}
+
+type CreateOrderRequest struct {
+	Intent             string                 `json:"intent"`
+	PurchaseUnits      []*PurchaseUnitRequest `json:"purchase_units"`
+	ApplicationContext *ApplicationContext    `json:"application_context,omitempty"`
+}
+
+type Capture struct {
+	ID                string `json:"id"`
+	Status            string `json:"status"`
+	Amount            *Money `json:"amount"`
+	CustomID          string `json:"custom_id"`
+	InvoiceID         string `json:"invoice_id"`
+	SupplementaryData *struct {
+		RelatedIDs struct {
+			OrderID string `json:"order_id"`
+		} `json:"related_ids"`
+	} `json:"supplementary_data,omitempty"`
+}
+
+type Refund struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Amount *Money `json:"amount"`
+}
+
+type PurchaseUnit struct {
+	ReferenceID string `json:"reference_id"`
+	CustomID    string `json:"custom_id"`
+	InvoiceID   string `json:"invoice_id"`
+	Amount      *Money `json:"amount"`
+	Payments    *struct {
+		Captures []*Capture `json:"captures"`
+		Refunds  []*Refund  `json:"refunds"`
+	} `json:"payments,omitempty"`
+}
+
+type Order struct {
+	ID            string          `json:"id"`
+	Status        string          `json:"status"`
+	PurchaseUnits []*PurchaseUnit `json:"purchase_units"`
+	Links         []*Link         `json:"links"`
+}
+
+type RefundRequest struct {
+	Amount      *Money `json:"amount,omitempty"`
+	InvoiceID   string `json:"invoice_id,omitempty"`
+	NoteToPayer string `json:"note_to_payer,omitempty"`
+}
+
+type WebhookEventType struct {
+	Name string `json:"name"`
+}
+
+type Webhook struct {
+	ID         string              `json:"id,omitempty"`
+	URL        string              `json:"url"`
+	EventTypes []*WebhookEventType `json:"event_types"`
+}
+
+type WebhookList struct {
+	Webhooks []*Webhook `json:"webhooks"`
+}
+
+type WebhookEvent struct {
+	ID         string          `json:"id"`
+	EventType  string          `json:"event_type"`
+	Resource   json.RawMessage `json:"resource"`
+	CreateTime string          `json:"create_time"`
+}
+
+// VerifyWebhookSignatureRequest WebhookEvent 使用回调的原始报文，避免按本地结构体重新序列化时丢失字段导致校验失败
+type VerifyWebhookSignatureRequest struct {
+	AuthAlgo         string          `json:"auth_algo"`
+	CertURL          string          `json:"cert_url"`
+	TransmissionID   string          `json:"transmission_id"`
+	TransmissionSig  string          `json:"transmission_sig"`
+	TransmissionTime string          `json:"transmission_time"`
+	WebhookID        string          `json:"webhook_id"`
+	WebhookEvent     json.RawMessage `json:"webhook_event"`
+}
+
+type VerifyWebhookSignatureResponse struct {
+	VerificationStatus string `json:"verification_status"`
+}
+
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+type ErrorResponse struct {
+	Name             string `json:"name"`
+	Message          string `json:"message"`
+	DebugID          string `json:"debug_id"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+	Details          []struct {
+		Issue       string `json:"issue"`
+		Description string `json:"description"`
+	} `json:"details"`
+}
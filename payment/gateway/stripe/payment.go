@@ -203,3 +203,111 @@ func (e *Stripe) HandleCallback(c *gin.Context, gatewayConfig string) (*types.Pa
 		return nil, nil
 	}
 }
+
+func (e *Stripe) Refund(req *types.RefundRequest, gatewayConfig string) (*types.RefundResult, error) {
+	sc, err := getStripeClient(gatewayConfig)
+	if err != nil {
+		return nil, err
+	}
+	if req.GatewayNo == "" {
+		return nil, fmt.Errorf("payment intent not found, trade_no: %s", req.TradeNo)
+	}
+
+	params := &stripe.RefundParams{
+		PaymentIntent: stripe.String(req.GatewayNo),
+		Amount:        stripe.Int64(int64(math.Round(req.Money * 100))),
+	}
+	params.AddMetadata("trade_no", req.TradeNo)
+	params.AddMetadata("refund_no", req.RefundNo)
+	params.AddMetadata("reason", req.Reason)
+	// 使用退款单号作为幂等键，重试时不会重复退款
+	params.SetIdempotencyKey(req.RefundNo)
+
+	refund, err := sc.Refunds.New(params)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &types.RefundResult{GatewayRefundNo: refund.ID}
+	switch refund.Status {
+	case stripe.RefundStatusSucceeded:
+	case stripe.RefundStatusPending, stripe.RefundStatusRequiresAction:
+		result.Pending = true
+	default:
+		return nil, fmt.Errorf("stripe refund failed: %s", refund.Status)
+	}
+
+	return result, nil
+}
+
+func (e *Stripe) QueryRefund(refund *model.OrderRefund, gatewayConfig string) (*types.RefundQueryResult, error) {
+	sc, err := getStripeClient(gatewayConfig)
+	if err != nil {
+		return nil, err
+	}
+	if refund.GatewayRefundNo == "" {
+		return nil, fmt.Errorf("gateway refund no not found, refund_no: %s", refund.RefundNo)
+	}
+
+	stripeRefund, err := sc.Refunds.Get(refund.GatewayRefundNo, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &types.RefundQueryResult{}
+	switch stripeRefund.Status {
+	case stripe.RefundStatusSucceeded:
+	case stripe.RefundStatusPending, stripe.RefundStatusRequiresAction:
+		result.Pending = true
+	default:
+		result.Failed = true
+		result.Message = "stripe refund " + string(stripeRefund.Status)
+		if stripeRefund.FailureReason != "" {
+			result.Message += ": " + string(stripeRefund.FailureReason)
+		}
+	}
+
+	return result, nil
+}
+
+func (e *Stripe) QueryTrade(order *model.Order, gatewayConfig string) (*types.TradeQueryResult, error) {
+	// Stripe 的订单号只在回调中返回，未回调的订单无法查询
+	if order.GatewayNo == "" {
+		return nil, types.ErrTradeQueryUnsupported
+	}
+
+	sc, err := getStripeClient(gatewayConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &stripe.PaymentIntentParams{}
+	params.AddExpand("latest_charge")
+	intent, err := sc.PaymentIntents.Get(order.GatewayNo, params)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &types.TradeQueryResult{
+		TradeNo:   order.TradeNo,
+		GatewayNo: intent.ID,
+		Paid:      intent.Status == stripe.PaymentIntentStatusSucceeded,
+		Money:     float64(intent.AmountReceived) / 100,
+	}
+	if intent.LatestCharge != nil {
+		result.RefundMoney = float64(intent.LatestCharge.AmountRefunded) / 100
+	}
+
+	return result, nil
+}
+
+func getStripeClient(gatewayConfig string) (*client.API, error) {
+	var stripeConfig StripeConfig
+	if err := json.Unmarshal([]byte(gatewayConfig), &stripeConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse gateway config: %v", err)
+	}
+
+	sc := &client.API{}
+	sc.Init(stripeConfig.SecretKey, nil)
+	return sc, nil
+}
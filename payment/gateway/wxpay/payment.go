@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -17,6 +18,8 @@ import (
 	"github.com/wechatpay-apiv3/wechatpay-go/core/notify"
 	"github.com/wechatpay-apiv3/wechatpay-go/core/option"
 	"github.com/wechatpay-apiv3/wechatpay-go/services/payments"
+	"github.com/wechatpay-apiv3/wechatpay-go/services/payments/native"
+	"github.com/wechatpay-apiv3/wechatpay-go/services/refunddomestic"
 	"github.com/wechatpay-apiv3/wechatpay-go/utils"
 )
 
@@ -128,3 +131,129 @@ func getWeChatConfig(gatewayConfig string) (*WeChatConfig, error) {
 func (w *WeChatPay) CreatedPay(_ string, _ *model.Payment) error {
 	return nil
 }
+
+func (w *WeChatPay) Refund(req *types.RefundRequest, gatewayConfig string) (*types.RefundResult, error) {
+	wechatConfig, err := getWeChatConfig(gatewayConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		if err := w.InitClient(wechatConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	service := refunddomestic.RefundsApiService{Client: client}
+	resp, _, err := service.Create(context.Background(), refunddomestic.CreateRequest{
+		OutTradeNo:  core.String(req.TradeNo),
+		OutRefundNo: core.String(req.RefundNo),
+		Reason:      core.String(req.Reason),
+		Amount: &refunddomestic.AmountReq{
+			Refund:   core.Int64(int64(math.Round(req.Money * 100))), // 转换为分
+			Total:    core.Int64(int64(math.Round(req.TotalMoney * 100))),
+			Currency: core.String("CNY"),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("wechat refund failed: %s", err.Error())
+	}
+
+	result := &types.RefundResult{}
+	if resp.RefundId != nil {
+		result.GatewayRefundNo = *resp.RefundId
+	}
+	if resp.Status != nil {
+		switch *resp.Status {
+		case refunddomestic.STATUS_SUCCESS:
+		case refunddomestic.STATUS_PROCESSING:
+			result.Pending = true
+		default:
+			return nil, fmt.Errorf("wechat refund failed: %s", *resp.Status)
+		}
+	}
+
+	return result, nil
+}
+
+func (w *WeChatPay) QueryRefund(refund *model.OrderRefund, gatewayConfig string) (*types.RefundQueryResult, error) {
+	wechatConfig, err := getWeChatConfig(gatewayConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		if err := w.InitClient(wechatConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	service := refunddomestic.RefundsApiService{Client: client}
+	resp, _, err := service.QueryByOutRefundNo(context.Background(), refunddomestic.QueryByOutRefundNoRequest{
+		OutRefundNo: core.String(refund.RefundNo),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("wechat query refund failed: %s", err.Error())
+	}
+
+	result := &types.RefundQueryResult{Pending: true}
+	if resp.Status != nil {
+		switch *resp.Status {
+		case refunddomestic.STATUS_SUCCESS:
+			result.Pending = false
+		case refunddomestic.STATUS_PROCESSING:
+		default:
+			result.Pending = false
+			result.Failed = true
+			result.Message = "wechat refund " + string(*resp.Status)
+		}
+	}
+
+	return result, nil
+}
+
+func (w *WeChatPay) QueryTrade(order *model.Order, gatewayConfig string) (*types.TradeQueryResult, error) {
+	wechatConfig, err := getWeChatConfig(gatewayConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		if err := w.InitClient(wechatConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	service := native.NativeApiService{Client: client}
+	transaction, result, err := service.QueryOrderByOutTradeNo(context.Background(), native.QueryOrderByOutTradeNoRequest{
+		OutTradeNo: core.String(order.TradeNo),
+		Mchid:      core.String(wechatConfig.MchID),
+	})
+	queryResult := &types.TradeQueryResult{TradeNo: order.TradeNo}
+	if err != nil {
+		// 未扫码的订单在微信侧不存在
+		if result != nil && result.Response.StatusCode == http.StatusNotFound {
+			return queryResult, nil
+		}
+		return nil, fmt.Errorf("wechat query order failed: %s", err.Error())
+	}
+
+	if transaction.TransactionId != nil {
+		queryResult.GatewayNo = *transaction.TransactionId
+	}
+	if transaction.Amount != nil && transaction.Amount.Total != nil {
+		queryResult.Money = float64(*transaction.Amount.Total) / 100
+	}
+	if transaction.TradeState != nil {
+		switch *transaction.TradeState {
+		case "SUCCESS":
+			queryResult.Paid = true
+		case "REFUND":
+			// 微信只返回是否转入退款，不返回退款金额，按本地已退款金额计算
+			queryResult.Paid = true
+			queryResult.RefundMoney = max(order.RefundedAmount, 0.01)
+		}
+	}
+
+	return queryResult, nil
+}
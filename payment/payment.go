@@ -4,6 +4,7 @@ import (
 	"done-hub/model"
 	"done-hub/payment/gateway/alipay"
 	"done-hub/payment/gateway/epay"
+	"done-hub/payment/gateway/paypal"
 	"done-hub/payment/gateway/stripe"
 	"done-hub/payment/gateway/wxpay"
 	"done-hub/payment/types"
@@ -16,8 +17,23 @@ type PaymentProcessor interface {
 	Pay(config *types.PayConfig, gatewayConfig string) (*types.PayRequest, error)
 	CreatedPay(notifyURL string, gatewayConfig *model.Payment) error
 	HandleCallback(c *gin.Context, gatewayConfig string) (*types.PayNotify, error)
+	// Refund 原路退款，支持部分退款
+	Refund(req *types.RefundRequest, gatewayConfig string) (*types.RefundResult, error)
+	// QueryTrade 查询网关侧的交易状态，网关不支持查询时返回 types.ErrTradeQueryUnsupported
+	QueryTrade(order *model.Order, gatewayConfig string) (*types.TradeQueryResult, error)
 }
 
+// RefundQuerier 退款可能返回处理中的网关实现该接口，由对账任务查询处理中退款的最终结果
+type RefundQuerier interface {
+	QueryRefund(refund *model.OrderRefund, gatewayConfig string) (*types.RefundQueryResult, error)
+}
+
+var (
+	_ RefundQuerier = (*wxpay.WeChatPay)(nil)
+	_ RefundQuerier = (*stripe.Stripe)(nil)
+	_ RefundQuerier = (*paypal.PayPal)(nil)
+)
+
 var Gateways = make(map[string]PaymentProcessor)
 
 func init() {
@@ -25,4 +41,5 @@ func init() {
 	Gateways["alipay"] = &alipay.Alipay{}
 	Gateways["wxpay"] = &wxpay.WeChatPay{}
 	Gateways["stripe"] = &stripe.Stripe{}
+	Gateways["paypal"] = &paypal.PayPal{}
 }
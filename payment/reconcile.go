@@ -0,0 +1,159 @@
+package payment
+
+import (
+	"done-hub/common/logger"
+	"done-hub/model"
+	"done-hub/payment/types"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// reconcileWindow 每次对账检查的订单范围，覆盖前两天的订单，避免遗漏延迟到达的回调
+	reconcileWindow = 48 * time.Hour
+	// reconcileDelay 未满 3 小时的订单可能仍在支付中，留到下次对账
+	reconcileDelay = 3 * time.Hour
+	// reconcileMoneyEpsilon 金额比较的误差
+	reconcileMoneyEpsilon = 0.01
+)
+
+type ReconcileResult struct {
+	Checked int `json:"checked"`
+	Skipped int `json:"skipped"`
+	Failed  int `json:"failed"`
+	Flagged int `json:"flagged"`
+	// 处理中的退款在本次对账中确认完成或失败的数量
+	RefundsResolved int `json:"refunds_resolved"`
+}
+
+// Reconcile 对比最近的订单与网关侧的交易记录，不一致的订单记录为对账异常，由管理员处理
+func Reconcile() (*ReconcileResult, error) {
+	now := time.Now()
+	orders, err := model.GetReconcileOrders(now.Add(-reconcileWindow).Unix(), now.Add(-reconcileDelay).Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReconcileResult{}
+	services := make(map[int]*PaymentService)
+	getService := func(gatewayId int) *PaymentService {
+		paymentService, ok := services[gatewayId]
+		if !ok {
+			paymentService, err = NewPaymentServiceByID(gatewayId)
+			if err != nil {
+				logger.SysError(fmt.Sprintf("reconcile: payment %d unavailable: %s", gatewayId, err.Error()))
+			}
+			services[gatewayId] = paymentService
+		}
+		return paymentService
+	}
+
+	// 先确认处理中的退款，仍在处理中的金额不参与退款金额的比较
+	processingMoney := resolveProcessingRefunds(result, getService)
+
+	for _, order := range orders {
+		paymentService := getService(order.GatewayId)
+		if paymentService == nil {
+			result.Skipped++
+			continue
+		}
+
+		trade, err := paymentService.gateway.QueryTrade(order, paymentService.Payment.Config)
+		if err != nil {
+			if errors.Is(err, types.ErrTradeQueryUnsupported) {
+				result.Skipped++
+				continue
+			}
+			result.Failed++
+			logger.SysError(fmt.Sprintf("reconcile: %s query trade failed, trade_no: %s, error: %s", paymentService.gateway.Name(), order.TradeNo, err.Error()))
+			continue
+		}
+		result.Checked++
+
+		mismatchType, detail := compareTrade(order, trade, processingMoney[order.ID])
+		if mismatchType == "" {
+			continue
+		}
+
+		created, err := model.RecordPaymentMismatch(order, mismatchType, trade.GatewayNo, detail)
+		if err != nil {
+			logger.SysError(fmt.Sprintf("reconcile: failed to record mismatch, trade_no: %s, error: %s", order.TradeNo, err.Error()))
+			continue
+		}
+		if created {
+			result.Flagged++
+		}
+	}
+
+	return result, nil
+}
+
+// resolveProcessingRefunds 查询处理中的退款在网关侧的结果并确认，返回仍在处理中的退款金额（按订单汇总）
+func resolveProcessingRefunds(result *ReconcileResult, getService func(int) *PaymentService) map[int]float64 {
+	processingMoney := make(map[int]float64)
+	refunds, err := model.GetProcessingOrderRefunds()
+	if err != nil {
+		logger.SysError("reconcile: get processing refunds failed: " + err.Error())
+		return processingMoney
+	}
+
+	for _, refund := range refunds {
+		processingMoney[refund.OrderId] += refund.Money
+
+		order, err := model.GetOrderById(refund.OrderId)
+		if err != nil {
+			continue
+		}
+		paymentService := getService(order.GatewayId)
+		if paymentService == nil {
+			continue
+		}
+		querier, ok := paymentService.gateway.(RefundQuerier)
+		if !ok {
+			continue
+		}
+
+		query, err := querier.QueryRefund(refund, paymentService.Payment.Config)
+		if err != nil {
+			logger.SysError(fmt.Sprintf("reconcile: %s query refund failed, refund_no: %s, error: %s", paymentService.gateway.Name(), refund.RefundNo, err.Error()))
+			continue
+		}
+		if query.Pending {
+			continue
+		}
+
+		if err := ConfirmRefund(refund, !query.Failed, query.Message); err != nil {
+			logger.SysError(fmt.Sprintf("reconcile: confirm refund failed, refund_no: %s, error: %s", refund.RefundNo, err.Error()))
+			continue
+		}
+		// 本轮已加载的订单仍计入了该退款，失败的退款在网关侧没有金额，继续从比较中扣除
+		if !query.Failed {
+			processingMoney[refund.OrderId] -= refund.Money
+		}
+		result.RefundsResolved++
+	}
+
+	return processingMoney
+}
+
+// compareTrade 比较本地订单和网关记录，一致时返回空类型，processingMoney 为网关尚未完成的退款金额
+func compareTrade(order *model.Order, trade *types.TradeQueryResult, processingMoney float64) (string, string) {
+	localPaid := order.Status == model.OrderStatusSuccess || order.Status == model.OrderStatusRefunded
+
+	switch {
+	case !localPaid && trade.Paid:
+		return model.PaymentMismatchMissingCallback, fmt.Sprintf("网关已支付 %.2f，本地订单状态为 %s", trade.Money, order.Status)
+	case localPaid && !trade.Paid:
+		return model.PaymentMismatchUnpaid, fmt.Sprintf("本地订单状态为 %s，网关未支付", order.Status)
+	case !localPaid:
+		return "", ""
+	case math.Abs(trade.Money-order.OrderAmount) > reconcileMoneyEpsilon:
+		return model.PaymentMismatchAmount, fmt.Sprintf("网关支付金额 %.2f，订单金额 %.2f %s", trade.Money, order.OrderAmount, order.OrderCurrency)
+	case math.Abs(trade.RefundMoney-(order.RefundedAmount-processingMoney)) > reconcileMoneyEpsilon:
+		return model.PaymentMismatchRefund, fmt.Sprintf("网关退款金额 %.2f，本地退款金额 %.2f %s", trade.RefundMoney, order.RefundedAmount, order.OrderCurrency)
+	}
+
+	return "", ""
+}
@@ -0,0 +1,167 @@
+package payment
+
+import (
+	"done-hub/common"
+	"done-hub/common/config"
+	"done-hub/common/logger"
+	"done-hub/common/utils"
+	"done-hub/model"
+	"done-hub/payment/types"
+	"errors"
+	"fmt"
+	"math"
+)
+
+type RefundOrderRequest struct {
+	Money      float64 // 退款金额，订单支付币种
+	Quota      *int    // 指定扣回的额度，为空时按 PaymentRefundClawback 配置和退款比例计算
+	Reason     string
+	OperatorId int
+}
+
+// RefundOrder 原路退款并扣回额度，订阅订单全额退款后取消对应的订阅，充值订单全额退款后撤销未结算的佣金
+// 网关退款失败时释放预占的退款金额；网关返回处理中时只记录待扣回的额度，由 ConfirmRefund 或对账任务确认后再扣回
+func RefundOrder(order *model.Order, req *RefundOrderRequest) (*model.OrderRefund, error) {
+	money := utils.Decimal(req.Money, 2)
+	if money <= 0 {
+		return nil, errors.New("退款金额必须大于 0")
+	}
+	if req.Quota != nil && *req.Quota < 0 {
+		return nil, errors.New("扣回额度不能为负数")
+	}
+	if order.Status != model.OrderStatusSuccess {
+		return nil, errors.New("只有支付成功的订单可以退款")
+	}
+
+	paymentService, err := NewPaymentServiceByID(order.GatewayId)
+	if err != nil {
+		return nil, err
+	}
+
+	refund := &model.OrderRefund{
+		RefundNo:   utils.GenerateTradeNo(),
+		Money:      money,
+		Reason:     req.Reason,
+		OperatorId: req.OperatorId,
+	}
+	fullyRefunded := order.RefundedAmount+money >= order.OrderAmount-0.001
+	refund.ClawbackQuota, refund.ClawbackNegative = refundClawbackQuota(order, money, fullyRefunded, req.Quota)
+	if err := model.CreateOrderRefund(order, refund); err != nil {
+		return nil, err
+	}
+
+	result, err := paymentService.gateway.Refund(&types.RefundRequest{
+		TradeNo:    order.TradeNo,
+		GatewayNo:  order.GatewayNo,
+		RefundNo:   refund.RefundNo,
+		Money:      money,
+		TotalMoney: order.OrderAmount,
+		Currency:   order.OrderCurrency,
+		Reason:     req.Reason,
+	}, paymentService.Payment.Config)
+	if err != nil {
+		logger.SysError(fmt.Sprintf("%s refund failed, trade_no: %s, refund_no: %s, error: %s", paymentService.gateway.Name(), order.TradeNo, refund.RefundNo, err.Error()))
+		if failErr := model.FailOrderRefund(refund, err.Error()); failErr != nil {
+			logger.SysError(fmt.Sprintf("failed to release refund %s: %s", refund.RefundNo, failErr.Error()))
+		}
+		return refund, fmt.Errorf("网关退款失败：%s", err.Error())
+	}
+
+	refund.GatewayRefundNo = result.GatewayRefundNo
+	if result.Pending {
+		if err := model.ProcessOrderRefund(refund); err != nil {
+			logger.SysError(fmt.Sprintf("failed to mark refund %s processing: %s", refund.RefundNo, err.Error()))
+		}
+		model.RecordLog(order.UserId, model.LogTypeManage, fmt.Sprintf("订单 %s 退款 %.2f %s 处理中，完成后扣回额度", order.TradeNo, money, order.OrderCurrency))
+		return refund, nil
+	}
+
+	if err := finishRefund(refund, model.OrderRefundStatusPending); err != nil {
+		logger.SysError(fmt.Sprintf("failed to complete refund %s: %s", refund.RefundNo, err.Error()))
+	}
+
+	return refund, nil
+}
+
+// ConfirmRefund 确认处理中的退款结果，成功时扣回额度并完成退款，失败时释放预占的退款金额
+func ConfirmRefund(refund *model.OrderRefund, succeeded bool, message string) error {
+	if refund.Status != model.OrderRefundStatusProcessing {
+		return errors.New("退款不在处理中")
+	}
+	if !succeeded {
+		return model.FailOrderRefund(refund, message)
+	}
+
+	return finishRefund(refund, model.OrderRefundStatusProcessing)
+}
+
+// finishRefund 网关退款完成后扣回额度，并处理订阅和佣金，from 为退款当前的状态，用于防止重复处理
+func finishRefund(refund *model.OrderRefund, from string) error {
+	claimed, err := model.ClaimOrderRefund(refund, from)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return errors.New("退款已处理")
+	}
+
+	order, err := model.GetOrderById(refund.OrderId)
+	if err != nil {
+		return err
+	}
+
+	if refund.ClawbackQuota > 0 {
+		sourceTradeNo := order.TradeNo
+		if order.PlanId > 0 {
+			sourceTradeNo = ""
+		}
+		refund.Quota, err = model.ClawbackOrderQuota(order.UserId, sourceTradeNo, refund.ClawbackQuota, refund.ClawbackNegative)
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to claw back quota, trade_no: %s, refund_no: %s, error: %s", order.TradeNo, refund.RefundNo, err.Error()))
+		}
+	}
+
+	if err := model.CompleteOrderRefund(refund); err != nil {
+		return err
+	}
+
+	// 全额退款后取消订阅，充值订单撤销尚未结算的分销佣金，部分退款按退款比例扣减
+	fullyRefunded := order.RefundedAmount >= order.OrderAmount-0.001
+	if fullyRefunded && order.PlanId > 0 {
+		if _, err := model.CancelSubscriptionByTradeNo(order.UserId, order.TradeNo); err != nil {
+			logger.SysError(fmt.Sprintf("failed to cancel subscription, trade_no: %s, error: %s", order.TradeNo, err.Error()))
+		}
+	} else if fullyRefunded {
+		if _, err := model.RevokeAffiliateCommissions(model.AffiliateSourceTopup, order.TradeNo, "充值订单已退款"); err != nil {
+			logger.SysError(fmt.Sprintf("failed to revoke affiliate commissions, trade_no: %s, error: %s", order.TradeNo, err.Error()))
+		}
+	} else if order.PlanId == 0 && order.OrderAmount > 0 {
+		reason := fmt.Sprintf("充值订单部分退款 %.2f %s", refund.Money, order.OrderCurrency)
+		if _, err := model.ReduceAffiliateCommissions(model.AffiliateSourceTopup, order.TradeNo, refund.Money/order.OrderAmount, reason); err != nil {
+			logger.SysError(fmt.Sprintf("failed to reduce affiliate commissions, trade_no: %s, error: %s", order.TradeNo, err.Error()))
+		}
+	}
+
+	model.RecordLog(order.UserId, model.LogTypeManage, fmt.Sprintf("订单 %s 退款 %.2f %s，扣回额度 %s", order.TradeNo, refund.Money, order.OrderCurrency, common.LogQuota(refund.Quota)))
+	return nil
+}
+
+// refundClawbackQuota 计算需要扣回的额度，按退款金额占订单金额的比例扣回，全额退款时扣回剩余部分
+// 订阅订单的额度按周期发放，默认不扣回，需要时由管理员指定
+func refundClawbackQuota(order *model.Order, money float64, fullyRefunded bool, quota *int) (clawback int, allowNegative bool) {
+	allowNegative = config.PaymentRefundClawback == model.RefundClawbackNegative
+	if quota != nil {
+		return *quota, allowNegative
+	}
+	if config.PaymentRefundClawback == model.RefundClawbackNone || order.Quota <= 0 || order.OrderAmount <= 0 {
+		return 0, false
+	}
+
+	left := order.Quota - order.RefundedQuota
+	if fullyRefunded {
+		return max(left, 0), allowNegative
+	}
+
+	clawback = int(math.Round(float64(order.Quota) * money / order.OrderAmount))
+	return max(min(clawback, left), 0), allowNegative
+}
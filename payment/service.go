@@ -28,6 +28,27 @@ func NewPaymentService(uuid string) (*PaymentService, error) {
 		return nil, errors.New("payment not found")
 	}
 
+	return newPaymentService(payment)
+}
+
+// NewPaymentServiceByID 按网关 ID 创建，用于退款和对账等不经过前端的场景
+func NewPaymentServiceByID(id int) (*PaymentService, error) {
+	payment, err := model.GetPaymentByID(id)
+	if err != nil {
+		return nil, errors.New("payment not found")
+	}
+
+	return newPaymentService(payment)
+}
+
+func newPaymentService(payment *model.Payment) (*PaymentService, error) {
+	decrypted, err := payment.Decrypted()
+	if err != nil {
+		logger.SysError(fmt.Sprintf("decrypt payment %d config failed: %v", payment.ID, err))
+		return nil, errors.New("payment config decrypt failed")
+	}
+	payment = decrypted
+
 	gateway, ok := Gateways[payment.Type]
 	if !ok {
 		return nil, errors.New("payment gateway not found")
@@ -1,6 +1,11 @@
 package types
 
-import "done-hub/model"
+import (
+	"done-hub/model"
+	"errors"
+)
+
+var ErrTradeQueryUnsupported = errors.New("payment gateway does not support trade query")
 
 // 支付网关的通用配置
 type PayConfig struct {
@@ -29,3 +34,36 @@ type PayNotify struct {
 	TradeNo   string `json:"trade_no"`
 	GatewayNo string `json:"gateway_no"`
 }
+
+// 退款请求，金额均为订单支付币种
+type RefundRequest struct {
+	TradeNo    string             `json:"trade_no"`
+	GatewayNo  string             `json:"gateway_no"`
+	RefundNo   string             `json:"refund_no"`
+	Money      float64            `json:"money"`
+	TotalMoney float64            `json:"total_money"`
+	Currency   model.CurrencyType `json:"currency"`
+	Reason     string             `json:"reason"`
+}
+
+// 退款结果，Pending 表示网关已受理但尚未完成
+type RefundResult struct {
+	GatewayRefundNo string `json:"gateway_refund_no"`
+	Pending         bool   `json:"pending"`
+}
+
+// 网关侧的退款状态，用于确认处理中的退款，Pending 和 Failed 都为 false 时表示退款已完成
+type RefundQueryResult struct {
+	Pending bool   `json:"pending"`
+	Failed  bool   `json:"failed"`
+	Message string `json:"message"`
+}
+
+// 网关侧的交易记录，用于对账
+type TradeQueryResult struct {
+	TradeNo     string  `json:"trade_no"`
+	GatewayNo   string  `json:"gateway_no"`
+	Paid        bool    `json:"paid"`
+	Money       float64 `json:"money"`
+	RefundMoney float64 `json:"refund_money"`
+}
@@ -104,6 +104,11 @@ func getKeyConfig(bedrock *BedrockProvider) {
 }
 
 func (p *BedrockProvider) Sign(req *http.Request) error {
+	return p.signWithService(req, awsService)
+}
+
+// signWithService 按服务签名，S3 还需要在请求头中携带请求体的哈希
+func (p *BedrockProvider) signWithService(req *http.Request, service string) error {
 	var body []byte
 	if req.Body == nil {
 		body = []byte("")
@@ -115,12 +120,15 @@ func (p *BedrockProvider) Sign(req *http.Request) error {
 		}
 		req.Body = io.NopCloser(bytes.NewReader(body))
 	}
-	sig, err := sigv4.New(sigv4.WithCredential(p.AccessKeyID, p.SecretAccessKey, p.SessionToken), sigv4.WithRegionService(p.Region, awsService))
+	sig, err := sigv4.New(sigv4.WithCredential(p.AccessKeyID, p.SecretAccessKey, p.SessionToken), sigv4.WithRegionService(p.Region, service))
 	if err != nil {
 		return err
 	}
 
 	reqBodyHashHex := fmt.Sprintf("%x", sha256.Sum256(body))
+	if service == s3Service {
+		req.Header.Set(sigv4.ContentSHAKey, reqBodyHashHex)
+	}
 	sig.Sign(req, reqBodyHashHex, sigv4.NewTime(time.Now()))
 
 	return nil
@@ -0,0 +1,192 @@
+package bedrock
+
+import (
+	"bytes"
+	"done-hub/common"
+	"done-hub/common/utils"
+	"done-hub/providers/bedrock/category"
+	"done-hub/providers/claude"
+	"done-hub/types"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	s3Service          = "s3"
+	batchJobBaseURL    = "https://bedrock.%s.amazonaws.com"
+	batchInputFileName = "input.jsonl"
+)
+
+// getBatchConfig 批量推理需要在渠道插件中配置 S3 存储桶和 Bedrock 可以读写该存储桶的服务角色
+func (p *BedrockProvider) getBatchConfig() (bucket, roleArn string, errWithCode *types.OpenAIErrorWithStatusCode) {
+	if p.Channel.Plugin != nil {
+		batch := p.Channel.Plugin.Data()["batch"]
+		bucket, _ = batch["bucket"].(string)
+		roleArn, _ = batch["role_arn"].(string)
+	}
+
+	if bucket == "" || roleArn == "" || p.Region == "" {
+		return "", "", common.StringErrorWrapperLocal("bedrock batch config error, bucket and role_arn are required", "invalid_bedrock_config", http.StatusInternalServerError)
+	}
+
+	return bucket, roleArn, nil
+}
+
+func (p *BedrockProvider) CreateClaudeBatchJob(modelName string, requests []*claude.ClaudeBatchRequest) (*claude.ClaudeBatchJob, *types.OpenAIErrorWithStatusCode) {
+	bucket, roleArn, errWithCode := p.getBatchConfig()
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, request := range requests {
+		record := &BatchRecord{
+			RecordId:   request.CustomId,
+			ModelInput: claude.BatchJobModelInput(request.Params, category.AnthropicVersion),
+		}
+		if err := encoder.Encode(record); err != nil {
+			return nil, common.ErrorWrapper(err, "marshal_batch_input_failed", http.StatusInternalServerError)
+		}
+	}
+
+	// 每个任务使用单独的目录，结果写入该目录下以任务 ID 命名的子目录
+	jobName := "done-hub-batch-" + utils.GetRandomString(16)
+	dir := "done-hub/batches/" + jobName
+	if errWithCode := p.sendS3Request(http.MethodPut, bucket, dir+"/"+batchInputFileName, body.Bytes()); errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	jobRequest := &BatchJobRequest{
+		JobName: jobName,
+		ModelId: category.GetModelName(modelName),
+		RoleArn: roleArn,
+		InputDataConfig: BatchInputDataConfig{
+			S3InputDataConfig: BatchS3Config{S3Uri: fmt.Sprintf("s3://%s/%s/%s", bucket, dir, batchInputFileName), S3InputFormat: "JSONL"},
+		},
+		OutputDataConfig: BatchOutputDataConfig{
+			S3OutputDataConfig: BatchS3Config{S3Uri: fmt.Sprintf("s3://%s/%s/", bucket, dir)},
+		},
+	}
+
+	jobResponse := &BatchJobResponse{}
+	if errWithCode := p.sendBatchJobRequest(http.MethodPost, "/model-invocation-job", jobRequest, jobResponse); errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	return &claude.ClaudeBatchJob{
+		Id:               jobResponse.JobArn,
+		ProcessingStatus: claude.BatchProcessingStatusInProgress,
+		RequestCounts:    claude.ClaudeBatchRequestCounts{Processing: len(requests)},
+		CreatedAt:        time.Now(),
+	}, nil
+}
+
+// GetClaudeBatchJob Bedrock 的任务信息不包含请求数量，只返回处理状态
+func (p *BedrockProvider) GetClaudeBatchJob(jobId string) (*claude.ClaudeBatchJob, *types.OpenAIErrorWithStatusCode) {
+	job := &BatchJob{}
+	if errWithCode := p.sendBatchJobRequest(http.MethodGet, "/model-invocation-job/"+url.PathEscape(jobId), nil, job); errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	batchJob := &claude.ClaudeBatchJob{
+		Id:               job.JobArn,
+		ProcessingStatus: getBatchProcessingStatus(job.Status),
+		CreatedAt:        job.SubmitTime,
+		EndedAt:          job.EndTime,
+	}
+
+	// 结果文件位于 输出目录/任务 ID/输入文件名.out
+	if batchJob.ProcessingStatus == claude.BatchProcessingStatusEnded {
+		jobArnParts := strings.Split(job.JobArn, "/")
+		batchJob.OutputLocation = strings.TrimSuffix(job.OutputDataConfig.S3OutputDataConfig.S3Uri, "/") + "/" +
+			jobArnParts[len(jobArnParts)-1] + "/" + path.Base(job.InputDataConfig.S3InputDataConfig.S3Uri) + ".out"
+	}
+
+	return batchJob, nil
+}
+
+func (p *BedrockProvider) CancelClaudeBatchJob(jobId string) *types.OpenAIErrorWithStatusCode {
+	return p.sendBatchJobRequest(http.MethodPost, "/model-invocation-job/"+url.PathEscape(jobId)+"/stop", nil, nil)
+}
+
+func (p *BedrockProvider) GetClaudeBatchJobResults(job *claude.ClaudeBatchJob) (*http.Response, *types.OpenAIErrorWithStatusCode) {
+	bucket, key, ok := strings.Cut(strings.TrimPrefix(job.OutputLocation, "s3://"), "/")
+	if !ok {
+		return nil, common.StringErrorWrapperLocal("batch results not found", "not_found_error", http.StatusNotFound)
+	}
+
+	req, err := p.Requester.NewRequest(http.MethodGet, p.getS3ObjectURL(bucket, key))
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+	if err := p.signWithService(req, s3Service); err != nil {
+		return nil, common.ErrorWrapper(err, "sign_request_failed", http.StatusInternalServerError)
+	}
+
+	return p.Requester.SendRequestRaw(req)
+}
+
+func (p *BedrockProvider) ConvertClaudeBatchResult(line []byte) *claude.ClaudeBatchResult {
+	record := &BatchRecordResult{}
+	if err := json.Unmarshal(line, record); err != nil || record.RecordId == "" {
+		return nil
+	}
+
+	if record.Error != nil || len(record.ModelOutput) == 0 {
+		message := "bedrock batch record failed"
+		if record.Error != nil && record.Error.ErrorMessage != "" {
+			message = record.Error.ErrorMessage
+		}
+		return claude.NewClaudeBatchErroredResult(record.RecordId, message)
+	}
+
+	return claude.NewClaudeBatchSucceededResult(record.RecordId, record.ModelOutput)
+}
+
+func getBatchProcessingStatus(status string) string {
+	switch status {
+	case "Completed", "PartiallyCompleted", "Failed", "Stopped", "Expired":
+		return claude.BatchProcessingStatusEnded
+	case "Stopping":
+		return claude.BatchProcessingStatusCanceling
+	default:
+		return claude.BatchProcessingStatusInProgress
+	}
+}
+
+func (p *BedrockProvider) sendBatchJobRequest(method, requestPath string, body any, response any) *types.OpenAIErrorWithStatusCode {
+	fullRequestURL := fmt.Sprintf(batchJobBaseURL, p.Region) + requestPath
+	req, err := p.Requester.NewRequest(method, fullRequestURL, p.Requester.WithBody(body), p.Requester.WithHeader(p.GetRequestHeaders()))
+	if err != nil {
+		return common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+	if err := p.Sign(req); err != nil {
+		return common.ErrorWrapper(err, "sign_request_failed", http.StatusInternalServerError)
+	}
+
+	_, errWithCode := p.Requester.SendRequest(req, response, false)
+	return errWithCode
+}
+
+func (p *BedrockProvider) sendS3Request(method, bucket, key string, body []byte) *types.OpenAIErrorWithStatusCode {
+	req, err := p.Requester.NewRequest(method, p.getS3ObjectURL(bucket, key), p.Requester.WithBody(body), p.Requester.WithContentType("application/jsonl"))
+	if err != nil {
+		return common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+	if err := p.signWithService(req, s3Service); err != nil {
+		return common.ErrorWrapper(err, "sign_request_failed", http.StatusInternalServerError)
+	}
+
+	_, errWithCode := p.Requester.SendRequest(req, nil, false)
+	return errWithCode
+}
+
+func (p *BedrockProvider) getS3ObjectURL(bucket, key string) string {
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, p.Region, key)
+}
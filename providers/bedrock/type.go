@@ -1,5 +1,10 @@
 package bedrock
 
+import (
+	"encoding/json"
+	"time"
+)
+
 const awsService = "bedrock"
 
 type BedrockError struct {
@@ -9,3 +14,54 @@ type BedrockError struct {
 type BedrockResponseStream struct {
 	Bytes string `json:"bytes"`
 }
+
+type BatchRecord struct {
+	RecordId   string         `json:"recordId"`
+	ModelInput map[string]any `json:"modelInput"`
+}
+
+type BatchRecordResult struct {
+	RecordId    string          `json:"recordId"`
+	ModelOutput json.RawMessage `json:"modelOutput,omitempty"`
+	Error       *BatchError     `json:"error,omitempty"`
+}
+
+type BatchError struct {
+	ErrorCode    int    `json:"errorCode"`
+	ErrorMessage string `json:"errorMessage"`
+}
+
+type BatchS3Config struct {
+	S3Uri         string `json:"s3Uri"`
+	S3InputFormat string `json:"s3InputFormat,omitempty"`
+}
+
+type BatchInputDataConfig struct {
+	S3InputDataConfig BatchS3Config `json:"s3InputDataConfig"`
+}
+
+type BatchOutputDataConfig struct {
+	S3OutputDataConfig BatchS3Config `json:"s3OutputDataConfig"`
+}
+
+type BatchJobRequest struct {
+	JobName          string                `json:"jobName"`
+	ModelId          string                `json:"modelId"`
+	RoleArn          string                `json:"roleArn"`
+	InputDataConfig  BatchInputDataConfig  `json:"inputDataConfig"`
+	OutputDataConfig BatchOutputDataConfig `json:"outputDataConfig"`
+}
+
+type BatchJobResponse struct {
+	JobArn string `json:"jobArn"`
+}
+
+type BatchJob struct {
+	JobArn           string                `json:"jobArn"`
+	Status           string                `json:"status"`
+	Message          string                `json:"message"`
+	SubmitTime       time.Time             `json:"submitTime"`
+	EndTime          time.Time             `json:"endTime"`
+	InputDataConfig  BatchInputDataConfig  `json:"inputDataConfig"`
+	OutputDataConfig BatchOutputDataConfig `json:"outputDataConfig"`
+}
@@ -0,0 +1,90 @@
+package claude
+
+import (
+	"done-hub/common"
+	"done-hub/types"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"time"
+)
+
+func (p *ClaudeProvider) RelayClaudeRequest(method, path string, body any, contentType string) (*http.Response, *types.OpenAIErrorWithStatusCode) {
+	fullRequestURL := p.GetFullRequestURL(path)
+	if rawQuery := p.Context.Request.URL.RawQuery; rawQuery != "" {
+		fullRequestURL += "?" + rawQuery
+	}
+
+	headers := p.GetRequestHeaders()
+	// Files API 等功能需要客户端传入 beta 标识
+	if beta := p.Context.Request.Header.Get("anthropic-beta"); beta != "" {
+		headers["anthropic-beta"] = beta
+	}
+
+	req, err := p.Requester.NewRequest(method, fullRequestURL, p.Requester.WithBody(body), p.Requester.WithHeader(headers))
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+
+	// contentType 不为空时表示透传原始请求体，如文件上传
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+		req.ContentLength = p.Context.Request.ContentLength
+	}
+
+	return p.Requester.SendRequestRaw(req)
+}
+
+// BatchJobModelInput 批量推理任务的单个请求，模型由任务指定，并补充平台要求的 anthropic_version
+func BatchJobModelInput(params map[string]any, anthropicVersion string) map[string]any {
+	input := maps.Clone(params)
+	delete(input, "model")
+	delete(input, "stream")
+	input["anthropic_version"] = anthropicVersion
+
+	return input
+}
+
+// ToMessageBatch 转换为 Message Batches 的批量对象，与 Anthropic 一致，未结束的批量 24 小时后过期
+func (job *ClaudeBatchJob) ToMessageBatch(id, resultsUrl string) *ClaudeMessageBatch {
+	batch := &ClaudeMessageBatch{
+		Id:               id,
+		Type:             "message_batch",
+		ProcessingStatus: job.ProcessingStatus,
+		RequestCounts:    job.RequestCounts,
+		CreatedAt:        job.CreatedAt.UTC().Format(time.RFC3339),
+		ExpiresAt:        job.CreatedAt.Add(24 * time.Hour).UTC().Format(time.RFC3339),
+	}
+
+	if job.ProcessingStatus == BatchProcessingStatusEnded {
+		// 上游没有返回结束时间时使用创建时间
+		endedAt := job.CreatedAt.UTC().Format(time.RFC3339)
+		if !job.EndedAt.IsZero() {
+			endedAt = job.EndedAt.UTC().Format(time.RFC3339)
+		}
+		batch.EndedAt = &endedAt
+		batch.ResultsUrl = &resultsUrl
+	}
+
+	return batch
+}
+
+func NewClaudeBatchSucceededResult(customId string, message json.RawMessage) *ClaudeBatchResult {
+	return &ClaudeBatchResult{
+		CustomId: customId,
+		Result:   ClaudeBatchResultItem{Type: "succeeded", Message: message},
+	}
+}
+
+func NewClaudeBatchErroredResult(customId, message string) *ClaudeBatchResult {
+	return &ClaudeBatchResult{
+		CustomId: customId,
+		Result: ClaudeBatchResultItem{
+			Type: "errored",
+			Error: &ClaudeError{
+				Type:      "error",
+				ErrorInfo: ClaudeErrorInfo{Type: "api_error", Message: message},
+			},
+		},
+	}
+}
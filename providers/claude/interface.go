@@ -4,6 +4,7 @@ import (
 	"done-hub/common/requester"
 	"done-hub/providers/base"
 	"done-hub/types"
+	"net/http"
 )
 
 type ClaudeChatInterface interface {
@@ -11,3 +12,22 @@ type ClaudeChatInterface interface {
 	CreateClaudeChat(request *ClaudeRequest) (*ClaudeResponse, *types.OpenAIErrorWithStatusCode)
 	CreateClaudeChatStream(request *ClaudeRequest) (requester.StreamReaderInterface[string], *types.OpenAIErrorWithStatusCode)
 }
+
+// ClaudeBatchInterface 用于透传 Message Batches 和 Files API
+type ClaudeBatchInterface interface {
+	base.ProviderInterface
+	RelayClaudeRequest(method, path string, body any, contentType string) (*http.Response, *types.OpenAIErrorWithStatusCode)
+}
+
+// ClaudeBatchJobInterface Bedrock 和 Vertex AI 没有 Message Batches 接口，通过各自的批量推理任务实现
+// 请求和结果经渠道插件中配置的存储桶中转，一个任务只能使用一个模型，不支持 Files API
+type ClaudeBatchJobInterface interface {
+	base.ProviderInterface
+	CreateClaudeBatchJob(modelName string, requests []*ClaudeBatchRequest) (*ClaudeBatchJob, *types.OpenAIErrorWithStatusCode)
+	GetClaudeBatchJob(jobId string) (*ClaudeBatchJob, *types.OpenAIErrorWithStatusCode)
+	CancelClaudeBatchJob(jobId string) *types.OpenAIErrorWithStatusCode
+	// GetClaudeBatchJobResults 读取已结束任务的结果文件
+	GetClaudeBatchJobResults(job *ClaudeBatchJob) (*http.Response, *types.OpenAIErrorWithStatusCode)
+	// ConvertClaudeBatchResult 将结果文件的一行转换为 Message Batches 的结果，无法解析时返回 nil
+	ConvertClaudeBatchResult(line []byte) *ClaudeBatchResult
+}
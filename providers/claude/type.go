@@ -3,6 +3,7 @@ package claude
 import (
 	"done-hub/types"
 	"encoding/json"
+	"time"
 )
 
 const (
@@ -218,3 +219,58 @@ type Model struct {
 	Type string `json:"type"`
 	ID   string `json:"id"`
 }
+
+const (
+	BatchProcessingStatusInProgress = "in_progress"
+	BatchProcessingStatusCanceling  = "canceling"
+	BatchProcessingStatusEnded      = "ended"
+)
+
+type ClaudeBatchRequest struct {
+	CustomId string         `json:"custom_id"`
+	Params   map[string]any `json:"params"`
+}
+
+type ClaudeBatchRequestCounts struct {
+	Processing int `json:"processing"`
+	Succeeded  int `json:"succeeded"`
+	Errored    int `json:"errored"`
+	Canceled   int `json:"canceled"`
+	Expired    int `json:"expired"`
+}
+
+// ClaudeMessageBatch Message Batches 接口返回的批量对象
+type ClaudeMessageBatch struct {
+	Id                string                   `json:"id"`
+	Type              string                   `json:"type"`
+	ProcessingStatus  string                   `json:"processing_status"`
+	RequestCounts     ClaudeBatchRequestCounts `json:"request_counts"`
+	EndedAt           *string                  `json:"ended_at"`
+	CreatedAt         string                   `json:"created_at"`
+	ExpiresAt         string                   `json:"expires_at"`
+	ArchivedAt        *string                  `json:"archived_at"`
+	CancelInitiatedAt *string                  `json:"cancel_initiated_at"`
+	ResultsUrl        *string                  `json:"results_url"`
+}
+
+// ClaudeBatchJob Bedrock、Vertex AI 批量推理任务的状态
+type ClaudeBatchJob struct {
+	Id               string
+	ProcessingStatus string
+	RequestCounts    ClaudeBatchRequestCounts
+	CreatedAt        time.Time
+	EndedAt          time.Time
+	// 结果文件在存储桶中的位置，任务结束后才有
+	OutputLocation string
+}
+
+type ClaudeBatchResult struct {
+	CustomId string                `json:"custom_id"`
+	Result   ClaudeBatchResultItem `json:"result"`
+}
+
+type ClaudeBatchResultItem struct {
+	Type    string          `json:"type"`
+	Message json.RawMessage `json:"message,omitempty"`
+	Error   *ClaudeError    `json:"error,omitempty"`
+}
@@ -41,6 +41,6 @@ func (p *DeepseekProvider) Balance() (float64, error) {
 		return 0, err
 	}
 
-	p.Channel.UpdateBalance(balance)
+	p.Channel.UpdateBalanceWithCurrency(balance, info.BalanceInfo[0].Currency)
 	return balance, nil
 }
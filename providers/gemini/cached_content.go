@@ -0,0 +1,121 @@
+package gemini
+
+import (
+	"done-hub/common"
+	"done-hub/common/requester"
+	"done-hub/types"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type GeminiCachedContent struct {
+	Name          string                    `json:"name,omitempty"`
+	Model         string                    `json:"model,omitempty"`
+	DisplayName   string                    `json:"displayName,omitempty"`
+	CreateTime    string                    `json:"createTime,omitempty"`
+	UpdateTime    string                    `json:"updateTime,omitempty"`
+	ExpireTime    string                    `json:"expireTime,omitempty"`
+	UsageMetadata *GeminiCachedContentUsage `json:"usageMetadata,omitempty"`
+}
+
+type GeminiCachedContentUsage struct {
+	TotalTokenCount int `json:"totalTokenCount"`
+}
+
+type GeminiCachedContentList struct {
+	CachedContents []*GeminiCachedContent `json:"cachedContents"`
+}
+
+func (c *GeminiCachedContent) GetTokenCount() int {
+	if c.UsageMetadata == nil {
+		return 0
+	}
+
+	return c.UsageMetadata.TotalTokenCount
+}
+
+// GetExpireTime 返回过期时间的时间戳，解析失败返回 0
+func (c *GeminiCachedContent) GetExpireTime() int64 {
+	expireTime, err := time.Parse(time.RFC3339Nano, c.ExpireTime)
+	if err != nil {
+		return 0
+	}
+
+	return expireTime.Unix()
+}
+
+func (p *GeminiProvider) getCachedContentURL(name string) string {
+	baseURL := strings.TrimSuffix(p.GetBaseURL(), "/")
+	version := "v1beta"
+
+	if p.Channel.Other != "" {
+		version = p.Channel.Other
+	}
+
+	inputVersion := p.Context.Param("version")
+	if inputVersion != "" {
+		version = inputVersion
+	}
+
+	return fmt.Sprintf("%s/%s/%s", baseURL, version, name)
+}
+
+func (p *GeminiProvider) sendCachedContentRequest(method, url string, body any) (*GeminiCachedContent, *types.OpenAIErrorWithStatusCode) {
+	headers := p.GetRequestHeaders()
+	req, err := p.Requester.NewRequest(method, url, p.Requester.WithBody(body), p.Requester.WithHeader(headers))
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+
+	return SendCachedContentRequest(p.Requester, req)
+}
+
+// SendCachedContentRequest 发送 cachedContents 请求，DELETE 请求不解析响应
+func SendCachedContentRequest(r *requester.HTTPRequester, req *http.Request) (*GeminiCachedContent, *types.OpenAIErrorWithStatusCode) {
+	if req.Method == http.MethodDelete {
+		_, errWithCode := r.SendRequest(req, nil, false)
+		return nil, errWithCode
+	}
+
+	response := &GeminiCachedContent{}
+	_, errWithCode := r.SendRequest(req, response, false)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	return response, nil
+}
+
+func (p *GeminiProvider) CreateCachedContent(request map[string]any) (*GeminiCachedContent, *types.OpenAIErrorWithStatusCode) {
+	modelName, _ := request["model"].(string)
+	request["model"] = "models/" + strings.TrimPrefix(modelName, "models/")
+
+	return p.sendCachedContentRequest(http.MethodPost, p.getCachedContentURL("cachedContents"), request)
+}
+
+func (p *GeminiProvider) GetCachedContent(name string) (*GeminiCachedContent, *types.OpenAIErrorWithStatusCode) {
+	return p.sendCachedContentRequest(http.MethodGet, p.getCachedContentURL(name), nil)
+}
+
+func (p *GeminiProvider) UpdateCachedContent(name string, request map[string]any) (*GeminiCachedContent, *types.OpenAIErrorWithStatusCode) {
+	url := p.getCachedContentURL(name) + "?updateMask=" + GetCachedContentUpdateMask(request)
+
+	return p.sendCachedContentRequest(http.MethodPatch, url, request)
+}
+
+func (p *GeminiProvider) DeleteCachedContent(name string) *types.OpenAIErrorWithStatusCode {
+	_, errWithCode := p.sendCachedContentRequest(http.MethodDelete, p.getCachedContentURL(name), nil)
+
+	return errWithCode
+}
+
+// GetCachedContentUpdateMask 缓存只允许更新过期时间
+func GetCachedContentUpdateMask(request map[string]any) string {
+	if _, ok := request["expireTime"]; ok {
+		return "expireTime"
+	}
+
+	return "ttl"
+}
@@ -643,6 +643,9 @@ func ConvertOpenAIUsage(geminiUsage *GeminiUsageMetadata) types.Usage {
 		CompletionTokens: completionTokens,
 		TotalTokens:      totalTokens,
 
+		PromptTokensDetails: types.PromptTokensDetails{
+			CachedTokens: geminiUsage.CachedContentTokenCount,
+		},
 		CompletionTokensDetails: types.CompletionTokensDetails{
 			ReasoningTokens: geminiUsage.ThoughtsTokenCount,
 		},
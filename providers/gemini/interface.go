@@ -11,3 +11,11 @@ type GeminiChatInterface interface {
 	CreateGeminiChat(request *GeminiChatRequest) (*GeminiChatResponse, *types.OpenAIErrorWithStatusCode)
 	CreateGeminiChatStream(request *GeminiChatRequest) (requester.StreamReaderInterface[string], *types.OpenAIErrorWithStatusCode)
 }
+
+type GeminiCachedContentInterface interface {
+	base.ProviderInterface
+	CreateCachedContent(request map[string]any) (*GeminiCachedContent, *types.OpenAIErrorWithStatusCode)
+	GetCachedContent(name string) (*GeminiCachedContent, *types.OpenAIErrorWithStatusCode)
+	UpdateCachedContent(name string, request map[string]any) (*GeminiCachedContent, *types.OpenAIErrorWithStatusCode)
+	DeleteCachedContent(name string) *types.OpenAIErrorWithStatusCode
+}
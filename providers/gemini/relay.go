@@ -111,6 +111,7 @@ func (h *GeminiRelayStreamHandler) HandlerStream(rawLine *[]byte, dataChan chan
 	}
 	h.Usage.CompletionTokens = completionTokens
 	h.Usage.CompletionTokensDetails.ReasoningTokens = geminiResponse.UsageMetadata.ThoughtsTokenCount
+	h.Usage.PromptTokensDetails.CachedTokens = geminiResponse.UsageMetadata.CachedContentTokenCount
 
 	// 如果 TotalTokenCount 为 0 但有 PromptTokenCount，则计算总数
 	totalTokens := geminiResponse.UsageMetadata.TotalTokenCount
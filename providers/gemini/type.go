@@ -142,6 +142,7 @@ type GeminiChatRequest struct {
 	Tools             []GeminiChatTools          `json:"tools,omitempty"`
 	ToolConfig        *GeminiToolConfig          `json:"toolConfig,omitempty"`
 	SystemInstruction any                        `json:"systemInstruction,omitempty"`
+	CachedContent     string                     `json:"cachedContent,omitempty"`
 
 	JsonRaw []byte `json:"-"`
 }
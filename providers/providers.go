@@ -2,6 +2,7 @@ package providers
 
 import (
 	"done-hub/common/config"
+	"done-hub/common/logger"
 	"done-hub/model"
 	"done-hub/providers/ali"
 	"done-hub/providers/azure"
@@ -41,6 +42,7 @@ import (
 	"done-hub/providers/xAI"
 	"done-hub/providers/xunfei"
 	"done-hub/providers/zhipu"
+	"fmt"
 
 	"github.com/gin-gonic/gin"
 )
@@ -98,6 +100,13 @@ func init() {
 
 // 获取供应商
 func GetProvider(channel *model.Channel, c *gin.Context) base.ProviderInterface {
+	// 密钥只在构建供应商时解密，供应商持有的是解密后的副本
+	channel, err := channel.Decrypted()
+	if err != nil {
+		logger.SysError(fmt.Sprintf("decrypt channel secrets failed: %s", err.Error()))
+		return nil
+	}
+
 	factory, ok := providerFactories[channel.Type]
 	var provider base.ProviderInterface
 	if !ok {
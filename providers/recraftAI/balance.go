@@ -1,6 +1,7 @@
 package recraftAI
 
 import (
+	"done-hub/model"
 	"errors"
 )
 
@@ -29,6 +30,6 @@ func (p *RecraftProvider) Balance() (float64, error) {
 	if info.Credits > 0 {
 		balance = float64(info.Credits) / 1000
 	}
-	p.Channel.UpdateBalance(balance)
+	p.Channel.UpdateBalanceWithCurrency(balance, model.ChannelBalanceCurrencyCredits)
 	return balance, nil
 }
@@ -1,6 +1,7 @@
 package siliconflow
 
 import (
+	"done-hub/model"
 	"errors"
 	"strconv"
 )
@@ -43,6 +44,6 @@ func (p *SiliconflowProvider) Balance() (float64, error) {
 		return 0, err
 	}
 
-	p.Channel.UpdateBalance(balance)
+	p.Channel.UpdateBalanceWithCurrency(balance, model.ChannelBalanceCurrencyCNY)
 	return balance, nil
 }
@@ -0,0 +1,197 @@
+package vertexai
+
+import (
+	"bytes"
+	"done-hub/common"
+	"done-hub/common/utils"
+	"done-hub/providers/claude"
+	"done-hub/providers/vertexai/category"
+	"done-hub/types"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	storageBaseURL           = "https://storage.googleapis.com"
+	batchPredictionsFileName = "predictions.jsonl"
+)
+
+// getBatchBucket 批量推理需要在渠道插件中配置 GCS 存储桶，服务账号需要有该存储桶的读写权限
+func (p *VertexAIProvider) getBatchBucket() (string, *types.OpenAIErrorWithStatusCode) {
+	bucket := ""
+	if p.Channel.Plugin != nil {
+		bucket, _ = p.Channel.Plugin.Data()["batch"]["bucket"].(string)
+	}
+
+	if bucket == "" || p.ProjectID == "" || p.Region == "" {
+		return "", common.StringErrorWrapperLocal("vertexAI batch config error, bucket is required", "invalid_vertexai_config", http.StatusInternalServerError)
+	}
+
+	return bucket, nil
+}
+
+func (p *VertexAIProvider) CreateClaudeBatchJob(modelName string, requests []*claude.ClaudeBatchRequest) (*claude.ClaudeBatchJob, *types.OpenAIErrorWithStatusCode) {
+	bucket, errWithCode := p.getBatchBucket()
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, request := range requests {
+		batchRequest := &BatchRequest{
+			CustomId: request.CustomId,
+			Request:  claude.BatchJobModelInput(request.Params, category.AnthropicVersion),
+		}
+		if err := encoder.Encode(batchRequest); err != nil {
+			return nil, common.ErrorWrapper(err, "marshal_batch_input_failed", http.StatusInternalServerError)
+		}
+	}
+
+	// 每个任务使用单独的目录，结果写入该目录下由 Vertex AI 创建的子目录
+	jobName := "done-hub-batch-" + utils.GetRandomString(16)
+	dir := "done-hub/batches/" + jobName
+	uploadURL := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s", storageBaseURL, bucket, url.QueryEscape(dir+"/input.jsonl"))
+	if errWithCode := p.sendBatchRequest(http.MethodPost, uploadURL, body.Bytes(), nil); errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	jobRequest := &BatchPredictionJobRequest{
+		DisplayName: jobName,
+		Model:       "publishers/anthropic/models/" + category.GetClaudeModelName(modelName),
+		InputConfig: BatchInputConfig{
+			InstancesFormat: "jsonl",
+			GcsSource:       BatchGcsSource{Uris: []string{fmt.Sprintf("gs://%s/%s/input.jsonl", bucket, dir)}},
+		},
+		OutputConfig: BatchOutputConfig{
+			PredictionsFormat: "jsonl",
+			GcsDestination:    BatchGcsDestination{OutputUriPrefix: fmt.Sprintf("gs://%s/%s/output", bucket, dir)},
+		},
+	}
+
+	job := &BatchPredictionJob{}
+	jobURL := fmt.Sprintf("https://%s/v1/projects/%s/locations/%s/batchPredictionJobs", p.getAPIHost(), p.ProjectID, p.Region)
+	if errWithCode := p.sendBatchRequest(http.MethodPost, jobURL, jobRequest, job); errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	batchJob := job.toClaudeBatchJob()
+	batchJob.RequestCounts.Processing = len(requests)
+	if batchJob.CreatedAt.IsZero() {
+		batchJob.CreatedAt = time.Now()
+	}
+
+	return batchJob, nil
+}
+
+func (p *VertexAIProvider) GetClaudeBatchJob(jobId string) (*claude.ClaudeBatchJob, *types.OpenAIErrorWithStatusCode) {
+	p.pinRegionByResourceName(jobId)
+
+	job := &BatchPredictionJob{}
+	if errWithCode := p.sendBatchRequest(http.MethodGet, fmt.Sprintf("https://%s/v1/%s", p.getAPIHost(), jobId), nil, job); errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	return job.toClaudeBatchJob(), nil
+}
+
+func (p *VertexAIProvider) CancelClaudeBatchJob(jobId string) *types.OpenAIErrorWithStatusCode {
+	p.pinRegionByResourceName(jobId)
+
+	return p.sendBatchRequest(http.MethodPost, fmt.Sprintf("https://%s/v1/%s:cancel", p.getAPIHost(), jobId), nil, nil)
+}
+
+func (p *VertexAIProvider) GetClaudeBatchJobResults(job *claude.ClaudeBatchJob) (*http.Response, *types.OpenAIErrorWithStatusCode) {
+	bucket, object, ok := strings.Cut(strings.TrimPrefix(job.OutputLocation, "gs://"), "/")
+	if !ok {
+		return nil, common.StringErrorWrapperLocal("batch results not found", "not_found_error", http.StatusNotFound)
+	}
+
+	headers := p.GetRequestHeaders()
+	if headers == nil {
+		return nil, common.StringErrorWrapperLocal("vertexAI config error", "invalid_vertexai_config", http.StatusInternalServerError)
+	}
+
+	p.Requester.ErrorHandler = RequestErrorHandle(nil)
+	downloadURL := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", storageBaseURL, bucket, url.PathEscape(object))
+	req, err := p.Requester.NewRequest(http.MethodGet, downloadURL, p.Requester.WithHeader(headers))
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+
+	return p.Requester.SendRequestRaw(req)
+}
+
+func (p *VertexAIProvider) ConvertClaudeBatchResult(line []byte) *claude.ClaudeBatchResult {
+	result := &BatchPredictionResult{}
+	if err := json.Unmarshal(line, result); err != nil || result.CustomId == "" {
+		return nil
+	}
+
+	if result.Status != "" || len(result.Response) == 0 {
+		message := result.Status
+		if message == "" {
+			message = "vertexAI batch request failed"
+		}
+		return claude.NewClaudeBatchErroredResult(result.CustomId, message)
+	}
+
+	return claude.NewClaudeBatchSucceededResult(result.CustomId, result.Response)
+}
+
+func (job *BatchPredictionJob) toClaudeBatchJob() *claude.ClaudeBatchJob {
+	batchJob := &claude.ClaudeBatchJob{
+		Id:        job.Name,
+		CreatedAt: job.CreateTime,
+		EndedAt:   job.EndTime,
+		RequestCounts: claude.ClaudeBatchRequestCounts{
+			Succeeded: int(job.CompletionStats.SuccessfulCount),
+			Errored:   int(job.CompletionStats.FailedCount),
+		},
+	}
+
+	incomplete := int(job.CompletionStats.IncompleteCount)
+	switch job.State {
+	case "JOB_STATE_SUCCEEDED", "JOB_STATE_PARTIALLY_SUCCEEDED", "JOB_STATE_FAILED":
+		batchJob.ProcessingStatus = claude.BatchProcessingStatusEnded
+		batchJob.RequestCounts.Errored += incomplete
+	case "JOB_STATE_CANCELLED":
+		batchJob.ProcessingStatus = claude.BatchProcessingStatusEnded
+		batchJob.RequestCounts.Canceled = incomplete
+	case "JOB_STATE_EXPIRED":
+		batchJob.ProcessingStatus = claude.BatchProcessingStatusEnded
+		batchJob.RequestCounts.Expired = incomplete
+	case "JOB_STATE_CANCELLING":
+		batchJob.ProcessingStatus = claude.BatchProcessingStatusCanceling
+		batchJob.RequestCounts.Processing = incomplete
+	default:
+		batchJob.ProcessingStatus = claude.BatchProcessingStatusInProgress
+		batchJob.RequestCounts.Processing = incomplete
+	}
+
+	if batchJob.ProcessingStatus == claude.BatchProcessingStatusEnded && job.OutputInfo.GcsOutputDirectory != "" {
+		batchJob.OutputLocation = strings.TrimSuffix(job.OutputInfo.GcsOutputDirectory, "/") + "/" + batchPredictionsFileName
+	}
+
+	return batchJob
+}
+
+func (p *VertexAIProvider) sendBatchRequest(method, fullRequestURL string, body any, response any) *types.OpenAIErrorWithStatusCode {
+	headers := p.GetRequestHeaders()
+	if headers == nil {
+		return common.StringErrorWrapperLocal("vertexAI config error", "invalid_vertexai_config", http.StatusInternalServerError)
+	}
+
+	p.Requester.ErrorHandler = RequestErrorHandle(nil)
+	req, err := p.Requester.NewRequest(method, fullRequestURL, p.Requester.WithBody(body), p.Requester.WithHeader(headers))
+	if err != nil {
+		return common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+
+	_, errWithCode := p.Requester.SendRequest(req, response, false)
+	return errWithCode
+}
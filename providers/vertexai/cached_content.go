@@ -0,0 +1,87 @@
+package vertexai
+
+import (
+	"done-hub/common"
+	"done-hub/providers/gemini"
+	"done-hub/types"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var resourceRegionRegex = regexp.MustCompile(`locations/([^/]+)/`)
+
+// 缓存和批量任务只存在于创建时的区域，后续请求需要固定到该区域
+func (p *VertexAIProvider) pinRegionByResourceName(name string) {
+	matches := resourceRegionRegex.FindStringSubmatch(name)
+	if len(matches) == 2 {
+		p.Region = matches[1]
+	}
+}
+
+func (p *VertexAIProvider) getAPIHost() string {
+	if p.Region == "global" {
+		return "aiplatform.googleapis.com"
+	}
+
+	return p.Region + "-aiplatform.googleapis.com"
+}
+
+func (p *VertexAIProvider) getCachedContentURL(name string) string {
+	host := p.getAPIHost()
+	if name == "" {
+		return fmt.Sprintf("https://%s/v1/projects/%s/locations/%s/cachedContents", host, p.ProjectID, p.Region)
+	}
+
+	return fmt.Sprintf("https://%s/v1/%s", host, name)
+}
+
+func (p *VertexAIProvider) sendCachedContentRequest(method, name string, body any) (*gemini.GeminiCachedContent, *types.OpenAIErrorWithStatusCode) {
+	if p.ProjectID == "" || p.Region == "" {
+		return nil, common.StringErrorWrapperLocal("vertexAI config error", "invalid_vertexai_config", http.StatusInternalServerError)
+	}
+
+	url := p.getCachedContentURL(name)
+	if method == http.MethodPatch {
+		url += "?updateMask=" + gemini.GetCachedContentUpdateMask(body.(map[string]any))
+	}
+
+	headers := p.GetRequestHeaders()
+	if headers == nil {
+		return nil, common.StringErrorWrapperLocal("vertexAI config error", "invalid_vertexai_config", http.StatusInternalServerError)
+	}
+
+	p.Requester.ErrorHandler = RequestErrorHandle(nil)
+	req, err := p.Requester.NewRequest(method, url, p.Requester.WithBody(body), p.Requester.WithHeader(headers))
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+
+	return gemini.SendCachedContentRequest(p.Requester, req)
+}
+
+func (p *VertexAIProvider) CreateCachedContent(request map[string]any) (*gemini.GeminiCachedContent, *types.OpenAIErrorWithStatusCode) {
+	modelName, _ := request["model"].(string)
+	modelName = strings.TrimPrefix(modelName, "models/")
+	request["model"] = fmt.Sprintf("projects/%s/locations/%s/publishers/google/models/%s", p.ProjectID, p.Region, modelName)
+
+	return p.sendCachedContentRequest(http.MethodPost, "", request)
+}
+
+func (p *VertexAIProvider) GetCachedContent(name string) (*gemini.GeminiCachedContent, *types.OpenAIErrorWithStatusCode) {
+	p.pinRegionByResourceName(name)
+	return p.sendCachedContentRequest(http.MethodGet, name, nil)
+}
+
+func (p *VertexAIProvider) UpdateCachedContent(name string, request map[string]any) (*gemini.GeminiCachedContent, *types.OpenAIErrorWithStatusCode) {
+	p.pinRegionByResourceName(name)
+	return p.sendCachedContentRequest(http.MethodPatch, name, request)
+}
+
+func (p *VertexAIProvider) DeleteCachedContent(name string) *types.OpenAIErrorWithStatusCode {
+	p.pinRegionByResourceName(name)
+	_, errWithCode := p.sendCachedContentRequest(http.MethodDelete, name, nil)
+
+	return errWithCode
+}
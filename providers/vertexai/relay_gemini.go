@@ -71,6 +71,10 @@ func (p *VertexAIProvider) getGeminiRequest(request *gemini.GeminiChatRequest) (
 		return nil, common.StringErrorWrapperLocal("vertexAI gemini provider not found", "vertexAI_err", http.StatusInternalServerError)
 	}
 
+	if request.CachedContent != "" {
+		p.pinRegionByResourceName(request.CachedContent)
+	}
+
 	otherUrl := p.Category.GetOtherUrl(request.Stream)
 	modelName := p.Category.GetModelName(request.Model)
 
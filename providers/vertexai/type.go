@@ -1,5 +1,10 @@
 package vertexai
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type Credentials struct {
 	Type                    string `json:"type"`
 	ProjectID               string `json:"project_id"`
@@ -27,3 +32,56 @@ type VertexaiError struct {
 func (e *VertexaiErrors) Error() *VertexaiError {
 	return (*e)[0]
 }
+
+type BatchGcsSource struct {
+	Uris []string `json:"uris"`
+}
+
+type BatchGcsDestination struct {
+	OutputUriPrefix string `json:"outputUriPrefix"`
+}
+
+type BatchInputConfig struct {
+	InstancesFormat string         `json:"instancesFormat"`
+	GcsSource       BatchGcsSource `json:"gcsSource"`
+}
+
+type BatchOutputConfig struct {
+	PredictionsFormat string              `json:"predictionsFormat"`
+	GcsDestination    BatchGcsDestination `json:"gcsDestination"`
+}
+
+type BatchPredictionJobRequest struct {
+	DisplayName  string            `json:"displayName"`
+	Model        string            `json:"model"`
+	InputConfig  BatchInputConfig  `json:"inputConfig"`
+	OutputConfig BatchOutputConfig `json:"outputConfig"`
+}
+
+// BatchPredictionJob int64 字段按 proto3 JSON 格式以字符串返回
+type BatchPredictionJob struct {
+	Name       string    `json:"name"`
+	State      string    `json:"state"`
+	CreateTime time.Time `json:"createTime"`
+	EndTime    time.Time `json:"endTime"`
+	OutputInfo struct {
+		GcsOutputDirectory string `json:"gcsOutputDirectory"`
+	} `json:"outputInfo"`
+	CompletionStats struct {
+		SuccessfulCount int64 `json:"successfulCount,string"`
+		FailedCount     int64 `json:"failedCount,string"`
+		IncompleteCount int64 `json:"incompleteCount,string"`
+	} `json:"completionStats"`
+}
+
+type BatchRequest struct {
+	CustomId string         `json:"custom_id"`
+	Request  map[string]any `json:"request"`
+}
+
+// BatchPredictionResult 请求失败时 status 为错误信息，没有 response
+type BatchPredictionResult struct {
+	CustomId string          `json:"custom_id"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Status   string          `json:"status,omitempty"`
+}
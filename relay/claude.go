@@ -54,6 +54,12 @@ func (r *relayClaudeOnly) setRequest() error {
 	// 设置原始模型到 Context，用于统一请求响应模型功能
 	r.c.Set("original_model", r.claudeRequest.Model)
 
+	if rawBody, ok := r.c.Get(config.GinRequestBodyKey); ok {
+		if err := bindClaudeFileChannel(r.c, rawBody.([]byte)); err != nil {
+			return err
+		}
+	}
+
 	// 检测背景任务（参考demo逻辑）
 	if r.isBackgroundTask() {
 
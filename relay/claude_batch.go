@@ -0,0 +1,758 @@
+package relay
+
+import (
+	"bufio"
+	"bytes"
+	"done-hub/common"
+	"done-hub/common/config"
+	"done-hub/common/logger"
+	"done-hub/common/utils"
+	"done-hub/model"
+	providersBase "done-hub/providers/base"
+	"done-hub/providers/claude"
+	"done-hub/relay/relay_util"
+	"done-hub/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Message Batches 支持 Anthropic 官方接口，Bedrock 和 Vertex AI 通过各自的批量推理任务实现
+var AllowClaudeBatchChannelType = []int{config.ChannelTypeAnthropic, config.ChannelTypeBedrock, config.ChannelTypeVertexAI}
+
+// Files API 只有 Anthropic 官方接口支持，Bedrock 和 Vertex AI 没有对应的接口
+var AllowClaudeFileChannelType = []int{config.ChannelTypeAnthropic}
+
+var unsupportedClaudeFileChannelType = []int{config.ChannelTypeBedrock, config.ChannelTypeVertexAI}
+
+var errClaudeFileChannelUnsupported = errors.New("files API is only supported on Anthropic channels, Bedrock and Vertex AI channels are not supported")
+
+type claudeObjectResponse struct {
+	Id string `json:"id"`
+}
+
+type claudeObjectList struct {
+	Data    []json.RawMessage `json:"data"`
+	HasMore bool              `json:"has_more"`
+	FirstId string            `json:"first_id,omitempty"`
+	LastId  string            `json:"last_id,omitempty"`
+}
+
+type claudeBatchResult struct {
+	CustomId string `json:"custom_id"`
+	Result   struct {
+		Type    string `json:"type"`
+		Message *struct {
+			Model string       `json:"model"`
+			Usage claude.Usage `json:"usage"`
+		} `json:"message,omitempty"`
+	} `json:"result"`
+}
+
+func CreateClaudeBatch(c *gin.Context) {
+	var request map[string]any
+	if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+		responseClaudeObjectError(c, common.StringErrorWrapperLocal(err.Error(), "invalid_request_error", http.StatusBadRequest))
+		return
+	}
+
+	requests, _ := request["requests"].([]any)
+	if len(requests) == 0 {
+		responseClaudeObjectError(c, common.StringErrorWrapperLocal("requests is required", "invalid_request_error", http.StatusBadRequest))
+		return
+	}
+
+	groupModels, err := model.ChannelGroup.GetGroupModels(c.GetString("token_group"))
+	if err != nil {
+		responseClaudeObjectError(c, common.StringErrorWrapperLocal(err.Error(), "one_hub_error", http.StatusServiceUnavailable))
+		return
+	}
+
+	// 批量请求在读取结果时才计费，提交时逐个校验模型并预估额度
+	modelName := ""
+	estimatedQuota := 0
+	requestModels := make(map[string]string, len(requests))
+	for i, item := range requests {
+		params := getClaudeBatchParams(item)
+		itemModel, _ := params["model"].(string)
+		if itemModel == "" {
+			responseClaudeObjectError(c, common.StringErrorWrapperLocal(fmt.Sprintf("requests.%d.params.model is required", i), "invalid_request_error", http.StatusBadRequest))
+			return
+		}
+		if !slices.Contains(groupModels, itemModel) {
+			responseClaudeObjectError(c, common.StringErrorWrapperLocal(fmt.Sprintf("当前分组 %s 下对于模型 %s 无可用渠道", c.GetString("token_group"), itemModel), "one_hub_error", http.StatusServiceUnavailable))
+			return
+		}
+		if err := checkEphemeralModel(c, itemModel); err != nil {
+			responseClaudeObjectError(c, common.StringErrorWrapperLocal(err.Error(), "permission_error", http.StatusForbidden))
+			return
+		}
+
+		if modelName == "" {
+			modelName = itemModel
+		}
+		customId, _ := item.(map[string]any)["custom_id"].(string)
+		requestModels[customId] = itemModel
+		estimatedQuota += estimateClaudeBatchQuota(c, itemModel, params)
+	}
+
+	if errWithCode := checkClaudeBatchQuota(c, estimatedQuota); errWithCode != nil {
+		responseClaudeObjectError(c, errWithCode)
+		return
+	}
+
+	batchProvider, err := getClaudeBatchProvider(c, modelName, model.ClaudeObjectTypeBatch)
+	if err != nil {
+		responseClaudeObjectError(c, claudeBatchProviderError(err))
+		return
+	}
+
+	// 所有请求提交到同一个渠道，渠道需要支持批量中的每个模型
+	channelModels := strings.Split(batchProvider.GetChannel().Models, ",")
+	for _, itemModel := range requestModels {
+		if !slices.Contains(channelModels, itemModel) {
+			responseClaudeObjectError(c, common.StringErrorWrapperLocal("models in one batch must be available on the same channel", "invalid_request_error", http.StatusBadRequest))
+			return
+		}
+	}
+
+	// 按渠道的模型映射替换每个请求的模型
+	for _, item := range requests {
+		params := getClaudeBatchParams(item)
+		if params == nil {
+			continue
+		}
+		if itemModel, ok := params["model"].(string); ok {
+			if newModel, err := batchProvider.ModelMappingHandler(itemModel); err == nil {
+				params["model"] = strings.TrimPrefix(newModel, "+")
+			}
+		}
+	}
+
+	object := &model.ClaudeObject{Type: model.ClaudeObjectTypeBatch}
+	object.SetRequestModels(requestModels)
+
+	if jobProvider, ok := batchProvider.(claude.ClaudeBatchJobInterface); ok {
+		createClaudeBatchJob(c, jobProvider, requests, object)
+		return
+	}
+
+	response, errWithCode := batchProvider.(claude.ClaudeBatchInterface).RelayClaudeRequest(http.MethodPost, "/v1/messages/batches", request, "")
+	if errWithCode != nil {
+		responseClaudeObjectError(c, errWithCode)
+		return
+	}
+
+	responseCreatedClaudeObject(c, response, object)
+}
+
+// createClaudeBatchJob 将批量请求提交为 Bedrock、Vertex AI 的批量推理任务，一个任务只能使用一个模型
+func createClaudeBatchJob(c *gin.Context, jobProvider claude.ClaudeBatchJobInterface, requests []any, object *model.ClaudeObject) {
+	jobModel := ""
+	batchRequests := make([]*claude.ClaudeBatchRequest, 0, len(requests))
+	for _, item := range requests {
+		params := getClaudeBatchParams(item)
+		itemModel, _ := params["model"].(string)
+		if jobModel != "" && jobModel != itemModel {
+			responseClaudeObjectError(c, common.StringErrorWrapperLocal("requests in one batch must use the same model on Bedrock and Vertex AI channels", "invalid_request_error", http.StatusBadRequest))
+			return
+		}
+		jobModel = itemModel
+
+		customId, _ := item.(map[string]any)["custom_id"].(string)
+		batchRequests = append(batchRequests, &claude.ClaudeBatchRequest{CustomId: customId, Params: params})
+	}
+
+	job, errWithCode := jobProvider.CreateClaudeBatchJob(jobModel, batchRequests)
+	if errWithCode != nil {
+		responseClaudeObjectError(c, errWithCode)
+		return
+	}
+
+	// 记录保存失败时无法再查询和计费，取消已提交的任务
+	object.ObjectId = "msgbatch_" + utils.GetRandomString(24)
+	object.UpstreamId = job.Id
+	if err := saveClaudeObject(c, object); err != nil {
+		logger.LogError(c.Request.Context(), "insert claude object failed: "+err.Error())
+		if errWithCode := jobProvider.CancelClaudeBatchJob(job.Id); errWithCode != nil {
+			logger.LogError(c.Request.Context(), "cancel claude batch job failed: "+errWithCode.Message)
+		}
+		responseClaudeObjectError(c, common.ErrorWrapper(err, "insert_claude_object_failed", http.StatusInternalServerError))
+		return
+	}
+
+	c.JSON(http.StatusOK, job.ToMessageBatch(object.ObjectId, getClaudeBatchResultsUrl(object.ObjectId)))
+}
+
+func ListClaudeBatches(c *gin.Context) {
+	listClaudeObjects(c, model.ClaudeObjectTypeBatch, "/v1/messages/batches/")
+}
+
+func RetrieveClaudeBatch(c *gin.Context) {
+	relayClaudeBatch(c, http.MethodGet, "")
+}
+
+func CancelClaudeBatch(c *gin.Context) {
+	relayClaudeBatch(c, http.MethodPost, "/cancel")
+}
+
+func DeleteClaudeBatch(c *gin.Context) {
+	relayClaudeBatch(c, http.MethodDelete, "")
+}
+
+// relayClaudeBatch Anthropic 渠道直接透传，Bedrock、Vertex AI 渠道转换为对批量推理任务的操作
+func relayClaudeBatch(c *gin.Context, method, action string) {
+	object, batchProvider, errWithCode := getBoundClaudeObject(c, model.ClaudeObjectTypeBatch, c.Param("id"))
+	if errWithCode != nil {
+		responseClaudeObjectError(c, errWithCode)
+		return
+	}
+
+	jobProvider, ok := batchProvider.(claude.ClaudeBatchJobInterface)
+	if !ok {
+		relayBoundClaudeObject(c, object, batchProvider, method, "/v1/messages/batches/"+object.ObjectId+action)
+		return
+	}
+
+	job, errWithCode := jobProvider.GetClaudeBatchJob(object.UpstreamId)
+	if errWithCode != nil {
+		responseClaudeObjectError(c, errWithCode)
+		return
+	}
+
+	switch {
+	case method == http.MethodDelete:
+		// 与 Anthropic 一致，只能删除已结束的批量
+		if job.ProcessingStatus != claude.BatchProcessingStatusEnded {
+			responseClaudeObjectError(c, common.StringErrorWrapperLocal("batch is still in progress, cancel it and wait for it to end before deleting", "invalid_request_error", http.StatusBadRequest))
+			return
+		}
+		if err := object.Delete(); err != nil {
+			responseClaudeObjectError(c, common.ErrorWrapper(err, "delete_claude_object_failed", http.StatusInternalServerError))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"id": object.ObjectId, "type": "message_batch_deleted"})
+		return
+	case action == "/cancel" && job.ProcessingStatus == claude.BatchProcessingStatusInProgress:
+		if errWithCode := jobProvider.CancelClaudeBatchJob(object.UpstreamId); errWithCode != nil {
+			responseClaudeObjectError(c, errWithCode)
+			return
+		}
+		job.ProcessingStatus = claude.BatchProcessingStatusCanceling
+	}
+
+	c.JSON(http.StatusOK, job.ToMessageBatch(object.ObjectId, getClaudeBatchResultsUrl(object.ObjectId)))
+}
+
+// GetClaudeBatchResults 透传批量结果，并在首次完整读取结果时按折扣计费
+func GetClaudeBatchResults(c *gin.Context) {
+	object, batchProvider, errWithCode := getBoundClaudeObject(c, model.ClaudeObjectTypeBatch, c.Param("id"))
+	if errWithCode != nil {
+		responseClaudeObjectError(c, errWithCode)
+		return
+	}
+
+	var response *http.Response
+	// Bedrock、Vertex AI 的结果文件需要逐行转换为 Message Batches 的结果格式
+	var convertResult func(line []byte) *claude.ClaudeBatchResult
+	if jobProvider, ok := batchProvider.(claude.ClaudeBatchJobInterface); ok {
+		response, errWithCode = getClaudeBatchJobResults(jobProvider, object)
+		convertResult = jobProvider.ConvertClaudeBatchResult
+	} else {
+		response, errWithCode = batchProvider.(claude.ClaudeBatchInterface).RelayClaudeRequest(http.MethodGet, "/v1/messages/batches/"+object.ObjectId+"/results", nil, "")
+	}
+	if errWithCode != nil {
+		responseClaudeObjectError(c, errWithCode)
+		return
+	}
+	defer response.Body.Close()
+
+	if convertResult != nil {
+		c.Writer.Header().Set("Content-Type", "application/x-jsonl")
+	} else {
+		for k, v := range response.Header {
+			c.Writer.Header().Set(k, v[0])
+		}
+	}
+	c.Writer.WriteHeader(response.StatusCode)
+
+	usages := make(map[string]*claude.Usage)
+	requestModel := object.RequestModelResolver()
+	reader := bufio.NewReader(response.Body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && convertResult != nil {
+			line = convertClaudeBatchResultLine(line, convertResult)
+		}
+		if len(line) > 0 {
+			// 客户端断开时仍然读完结果，保证计费完整
+			c.Writer.Write(line)
+			collectClaudeBatchUsage(line, usages, requestModel)
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				logger.LogError(c.Request.Context(), "read claude batch results failed: "+err.Error())
+				return
+			}
+			break
+		}
+	}
+	c.Writer.Flush()
+
+	billingClaudeBatch(c, object, usages)
+}
+
+// getClaudeBatchJobResults 批量推理任务结束后才能读取结果
+func getClaudeBatchJobResults(jobProvider claude.ClaudeBatchJobInterface, object *model.ClaudeObject) (*http.Response, *types.OpenAIErrorWithStatusCode) {
+	job, errWithCode := jobProvider.GetClaudeBatchJob(object.UpstreamId)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+	if job.ProcessingStatus != claude.BatchProcessingStatusEnded || job.OutputLocation == "" {
+		return nil, common.StringErrorWrapperLocal("batch results are not available until the batch has ended", "invalid_request_error", http.StatusBadRequest)
+	}
+
+	return jobProvider.GetClaudeBatchJobResults(job)
+}
+
+func convertClaudeBatchResultLine(line []byte, convertResult func(line []byte) *claude.ClaudeBatchResult) []byte {
+	result := convertResult(bytes.TrimSpace(line))
+	if result == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil
+	}
+
+	return append(data, '\n')
+}
+
+func UploadClaudeFile(c *gin.Context) {
+	fileProvider, err := getClaudeBatchProvider(c, c.Query("model"), model.ClaudeObjectTypeFile)
+	if err != nil {
+		responseClaudeObjectError(c, claudeBatchProviderError(err))
+		return
+	}
+
+	response, errWithCode := fileProvider.(claude.ClaudeBatchInterface).RelayClaudeRequest(http.MethodPost, "/v1/files", c.Request.Body, c.Request.Header.Get("Content-Type"))
+	if errWithCode != nil {
+		responseClaudeObjectError(c, errWithCode)
+		return
+	}
+
+	responseCreatedClaudeObject(c, response, &model.ClaudeObject{Type: model.ClaudeObjectTypeFile})
+}
+
+func ListClaudeFiles(c *gin.Context) {
+	listClaudeObjects(c, model.ClaudeObjectTypeFile, "/v1/files/")
+}
+
+func RetrieveClaudeFile(c *gin.Context) {
+	relayClaudeObject(c, model.ClaudeObjectTypeFile, http.MethodGet, "/v1/files/"+c.Param("id"))
+}
+
+func DownloadClaudeFile(c *gin.Context) {
+	relayClaudeObject(c, model.ClaudeObjectTypeFile, http.MethodGet, "/v1/files/"+c.Param("id")+"/content")
+}
+
+func DeleteClaudeFile(c *gin.Context) {
+	relayClaudeObject(c, model.ClaudeObjectTypeFile, http.MethodDelete, "/v1/files/"+c.Param("id"))
+}
+
+// 将引用了文件的消息请求固定到上传该文件的渠道
+func bindClaudeFileChannel(c *gin.Context, rawBody []byte) error {
+	fileIds := findClaudeFileIds(rawBody)
+	if len(fileIds) == 0 {
+		return nil
+	}
+
+	channelId := 0
+	for _, fileId := range fileIds {
+		object, err := model.GetClaudeObject(c.GetInt("id"), model.ClaudeObjectTypeFile, fileId)
+		if err != nil {
+			return fmt.Errorf("file %s not found", fileId)
+		}
+		if channelId != 0 && channelId != object.ChannelId {
+			return errors.New("files in one request must be uploaded with the same channel")
+		}
+		channelId = object.ChannelId
+	}
+
+	c.Set("specific_channel_id", channelId)
+	c.Set("specific_channel_id_ignore", false)
+
+	return nil
+}
+
+func findClaudeFileIds(rawBody []byte) []string {
+	if !bytes.Contains(rawBody, []byte(`"file_id"`)) {
+		return nil
+	}
+
+	var body any
+	if err := json.Unmarshal(rawBody, &body); err != nil {
+		return nil
+	}
+
+	fileIds := make([]string, 0)
+	var walk func(value any)
+	walk = func(value any) {
+		switch v := value.(type) {
+		case map[string]any:
+			if fileId, ok := v["file_id"].(string); ok && v["type"] == "file" {
+				fileIds = append(fileIds, fileId)
+			}
+			for _, item := range v {
+				walk(item)
+			}
+		case []any:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	walk(body)
+
+	return fileIds
+}
+
+func relayClaudeObject(c *gin.Context, objectType, method, path string) {
+	object, objectProvider, errWithCode := getBoundClaudeObject(c, objectType, c.Param("id"))
+	if errWithCode != nil {
+		responseClaudeObjectError(c, errWithCode)
+		return
+	}
+
+	relayBoundClaudeObject(c, object, objectProvider, method, path)
+}
+
+func relayBoundClaudeObject(c *gin.Context, object *model.ClaudeObject, objectProvider providersBase.ProviderInterface, method, path string) {
+	response, errWithCode := objectProvider.(claude.ClaudeBatchInterface).RelayClaudeRequest(method, path, nil, "")
+	if errWithCode != nil {
+		responseClaudeObjectError(c, errWithCode)
+		return
+	}
+
+	if method == http.MethodDelete {
+		if err := object.Delete(); err != nil {
+			logger.LogError(c.Request.Context(), "delete claude object failed: "+err.Error())
+		}
+	}
+
+	if errWithCode = responseMultipart(c, response); errWithCode != nil {
+		logger.LogError(c.Request.Context(), "write claude object response failed: "+errWithCode.Message)
+	}
+}
+
+// 上游的列表接口会返回渠道下所有用户的数据，这里只列出当前用户的对象
+func listClaudeObjects(c *gin.Context, objectType, path string) {
+	limit := 20
+	if queryLimit := c.Query("limit"); queryLimit != "" {
+		fmt.Sscanf(queryLimit, "%d", &limit)
+	}
+	limit = min(max(limit, 1), 100)
+
+	afterId := 0
+	if after := c.Query("after_id"); after != "" {
+		if object, err := model.GetClaudeObject(c.GetInt("id"), objectType, after); err == nil {
+			afterId = object.Id
+		}
+	}
+
+	objects, err := model.GetUserClaudeObjects(c.GetInt("id"), objectType, afterId, limit+1)
+	if err != nil {
+		responseClaudeObjectError(c, common.ErrorWrapperLocal(err, "list_objects_failed", http.StatusInternalServerError))
+		return
+	}
+
+	list := &claudeObjectList{
+		Data:    make([]json.RawMessage, 0, len(objects)),
+		HasMore: len(objects) > limit,
+	}
+	if list.HasMore {
+		objects = objects[:limit]
+	}
+
+	// 列表请求不携带查询参数透传给上游
+	c.Request.URL.RawQuery = ""
+	for _, object := range objects {
+		c.Set("specific_channel_id", object.ChannelId)
+		objectProvider, err := getClaudeBatchProvider(c, "", objectType)
+		if err != nil {
+			continue
+		}
+
+		body, err := getClaudeObjectBody(objectProvider, object, path)
+		if err != nil {
+			continue
+		}
+
+		list.Data = append(list.Data, body)
+		if list.FirstId == "" {
+			list.FirstId = object.ObjectId
+		}
+		list.LastId = object.ObjectId
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// getClaudeObjectBody 查询对象的最新状态，Bedrock、Vertex AI 的批量推理任务转换为批量对象
+func getClaudeObjectBody(objectProvider providersBase.ProviderInterface, object *model.ClaudeObject, path string) ([]byte, error) {
+	if jobProvider, ok := objectProvider.(claude.ClaudeBatchJobInterface); ok {
+		job, errWithCode := jobProvider.GetClaudeBatchJob(object.UpstreamId)
+		if errWithCode != nil {
+			return nil, errors.New(errWithCode.Message)
+		}
+		return json.Marshal(job.ToMessageBatch(object.ObjectId, getClaudeBatchResultsUrl(object.ObjectId)))
+	}
+
+	response, errWithCode := objectProvider.(claude.ClaudeBatchInterface).RelayClaudeRequest(http.MethodGet, path+object.ObjectId, nil, "")
+	if errWithCode != nil {
+		return nil, errors.New(errWithCode.Message)
+	}
+	defer response.Body.Close()
+
+	return io.ReadAll(response.Body)
+}
+
+func getClaudeBatchResultsUrl(batchId string) string {
+	return fmt.Sprintf("%s/claude/v1/messages/batches/%s/results", strings.TrimSuffix(config.ServerAddress, "/"), batchId)
+}
+
+func saveClaudeObject(c *gin.Context, object *model.ClaudeObject) error {
+	object.UserId = c.GetInt("id")
+	object.TokenId = c.GetInt("token_id")
+	object.ChannelId = c.GetInt("channel_id")
+
+	return object.Insert()
+}
+
+func responseCreatedClaudeObject(c *gin.Context, response *http.Response, object *model.ClaudeObject) {
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		responseClaudeObjectError(c, common.ErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError))
+		return
+	}
+
+	created := &claudeObjectResponse{}
+	if err := json.Unmarshal(body, created); err == nil && created.Id != "" {
+		object.ObjectId = created.Id
+		if err := saveClaudeObject(c, object); err != nil {
+			logger.LogError(c.Request.Context(), "insert claude object failed: "+err.Error())
+		}
+	}
+
+	c.Data(response.StatusCode, "application/json", body)
+}
+
+func getBoundClaudeObject(c *gin.Context, objectType, objectId string) (*model.ClaudeObject, providersBase.ProviderInterface, *types.OpenAIErrorWithStatusCode) {
+	object, err := model.GetClaudeObject(c.GetInt("id"), objectType, objectId)
+	if err != nil {
+		return nil, nil, common.StringErrorWrapperLocal(err.Error(), "not_found_error", http.StatusNotFound)
+	}
+
+	c.Set("specific_channel_id", object.ChannelId)
+	c.Set("specific_channel_id_ignore", false)
+
+	objectProvider, err := getClaudeBatchProvider(c, "", objectType)
+	if err != nil {
+		return nil, nil, claudeBatchProviderError(err)
+	}
+
+	return object, objectProvider, nil
+}
+
+// getClaudeBatchProvider 批量请求可以使用 Anthropic、Bedrock、Vertex AI 渠道，文件只能使用 Anthropic 渠道
+// 未指定模型时（如上传文件），从当前分组可用的 claude 模型中选择渠道
+func getClaudeBatchProvider(c *gin.Context, modelName, objectType string) (providersBase.ProviderInterface, error) {
+	if objectType == model.ClaudeObjectTypeFile {
+		c.Set("allow_channel_type", AllowClaudeFileChannelType)
+	} else {
+		c.Set("allow_channel_type", AllowClaudeBatchChannelType)
+	}
+
+	modelNames := []string{modelName}
+	if modelName == "" && c.GetInt("specific_channel_id") == 0 {
+		groupModels, err := model.ChannelGroup.GetGroupModels(c.GetString("token_group"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(groupModels)
+
+		modelNames = modelNames[:0]
+		for _, groupModel := range groupModels {
+			if strings.HasPrefix(groupModel, "claude") {
+				modelNames = append(modelNames, groupModel)
+			}
+		}
+	}
+
+	err := errors.New("no available channel")
+	for _, name := range modelNames {
+		provider, _, fail := GetProvider(c, name)
+		if fail != nil {
+			err = fail
+			continue
+		}
+
+		if _, ok := provider.(claude.ClaudeBatchInterface); ok {
+			return provider, nil
+		}
+		if _, ok := provider.(claude.ClaudeBatchJobInterface); ok && objectType == model.ClaudeObjectTypeBatch {
+			return provider, nil
+		}
+
+		return nil, errors.New("channel not supported message batches")
+	}
+
+	// 文件的模型只有 Bedrock 或 Vertex AI 渠道时明确提示不支持
+	if objectType == model.ClaudeObjectTypeFile {
+		for _, name := range modelNames {
+			if name == "" {
+				continue
+			}
+			if _, fail := model.ChannelGroup.Next(c.GetString("token_group"), name, model.FilterChannelTypes(unsupportedClaudeFileChannelType)); fail == nil {
+				return nil, errClaudeFileChannelUnsupported
+			}
+		}
+	}
+
+	return nil, err
+}
+
+func claudeBatchProviderError(err error) *types.OpenAIErrorWithStatusCode {
+	if errors.Is(err, errClaudeFileChannelUnsupported) {
+		return common.StringErrorWrapperLocal(err.Error(), "invalid_request_error", http.StatusBadRequest)
+	}
+
+	return common.StringErrorWrapperLocal(err.Error(), "one_hub_error", http.StatusServiceUnavailable)
+}
+
+// estimateClaudeBatchQuota 按提示 token 数和批量折扣预估单个请求的额度
+func estimateClaudeBatchQuota(c *gin.Context, modelName string, params map[string]any) int {
+	claudeRequest := &claude.ClaudeRequest{}
+	if data, err := json.Marshal(params); err == nil {
+		_ = json.Unmarshal(data, claudeRequest)
+	}
+
+	promptTokens, _ := CountTokenMessages(claudeRequest, config.PreCostDefault)
+	quota := relay_util.NewQuota(c, modelName, promptTokens)
+	quota.SetDiscount(config.ClaudeBatchDiscount)
+
+	return quota.EstimateQuota()
+}
+
+// checkClaudeBatchQuota 检查用户、令牌和临时令牌的余额是否足够支付批量请求的预估额度
+func checkClaudeBatchQuota(c *gin.Context, quota int) *types.OpenAIErrorWithStatusCode {
+	userQuota, err := model.CacheGetBillingQuota(c.GetInt("id"), c.GetInt("organization_id"))
+	if err != nil {
+		return common.ErrorWrapper(err, "get_user_quota_failed", http.StatusInternalServerError)
+	}
+	if userQuota <= 0 || userQuota < quota {
+		return common.StringErrorWrapperLocal("user quota is not enough", "insufficient_user_quota", http.StatusPaymentRequired)
+	}
+
+	token, err := model.CacheGetTokenById(c.GetInt("token_id"))
+	if err != nil {
+		return common.ErrorWrapper(err, "get_token_failed", http.StatusInternalServerError)
+	}
+	if !token.UnlimitedQuota && token.RemainQuota < quota {
+		return common.StringErrorWrapperLocal("token quota is not enough", "insufficient_token_quota", http.StatusPaymentRequired)
+	}
+
+	if err := model.CheckEphemeralTokenQuota(getEphemeralClaims(c), quota); err != nil {
+		return common.StringErrorWrapperLocal(err.Error(), "insufficient_ephemeral_token_quota", http.StatusPaymentRequired)
+	}
+
+	return nil
+}
+
+func getClaudeBatchParams(item any) map[string]any {
+	request, ok := item.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	params, _ := request["params"].(map[string]any)
+	return params
+}
+
+// 按提交时请求的模型汇总用量，旧记录没有保存请求模型时使用上游返回的模型
+func collectClaudeBatchUsage(line []byte, usages map[string]*claude.Usage, requestModel func(customId string) string) {
+	result := &claudeBatchResult{}
+	if err := json.Unmarshal(line, result); err != nil {
+		return
+	}
+
+	if result.Result.Type != "succeeded" || result.Result.Message == nil {
+		return
+	}
+
+	message := result.Result.Message
+	modelName := requestModel(result.CustomId)
+	if modelName == "" {
+		modelName = message.Model
+	}
+
+	usage, ok := usages[modelName]
+	if !ok {
+		usage = &claude.Usage{}
+		usages[modelName] = usage
+	}
+
+	usage.InputTokens += message.Usage.InputTokens
+	usage.OutputTokens += message.Usage.OutputTokens
+	usage.CacheCreationInputTokens += message.Usage.CacheCreationInputTokens
+	usage.CacheReadInputTokens += message.Usage.CacheReadInputTokens
+}
+
+func billingClaudeBatch(c *gin.Context, object *model.ClaudeObject, usages map[string]*claude.Usage) {
+	if len(usages) == 0 {
+		return
+	}
+
+	billed, err := object.MarkBilled()
+	if err != nil {
+		logger.LogError(c.Request.Context(), "mark claude batch billed failed: "+err.Error())
+		return
+	}
+	if !billed {
+		return
+	}
+
+	totalQuota := 0
+	for modelName, claudeUsage := range usages {
+		usage := &types.Usage{}
+		if !claude.ClaudeUsageToOpenaiUsage(claudeUsage, usage) {
+			continue
+		}
+
+		quota := relay_util.NewQuota(c, modelName, usage.PromptTokens)
+		quota.SetDiscount(config.ClaudeBatchDiscount)
+		quota.AddLogMeta("batch_id", object.ObjectId)
+		totalQuota += quota.GetTotalQuotaByUsage(usage)
+		quota.Consume(c, usage, false)
+	}
+
+	if err := object.UpdateQuota(totalQuota); err != nil {
+		logger.LogError(c.Request.Context(), "update claude batch quota failed: "+err.Error())
+	}
+}
+
+func responseClaudeObjectError(c *gin.Context, err *types.OpenAIErrorWithStatusCode) {
+	newErr := FilterOpenAIErr(c, err)
+	claudeErr := claude.OpenaiErrToClaudeErr(&newErr)
+
+	c.JSON(newErr.StatusCode, claudeErr.ClaudeError)
+}
@@ -64,6 +64,10 @@ func Path2Relay(c *gin.Context, path string) RelayBaseInterface {
 }
 
 func GetProvider(c *gin.Context, modelName string) (provider providersBase.ProviderInterface, newModelName string, fail error) {
+	if fail = checkEphemeralModel(c, modelName); fail != nil {
+		return
+	}
+
 	channel, fail := fetchChannel(c, modelName)
 	if fail != nil {
 		return
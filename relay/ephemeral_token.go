@@ -0,0 +1,81 @@
+package relay
+
+import (
+	"done-hub/common"
+	"done-hub/model"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateEphemeralToken 使用普通令牌签发短期有效的临时令牌，供浏览器和移动端直接调用
+func CreateEphemeralToken(c *gin.Context) {
+	if getEphemeralClaims(c) != nil {
+		common.AbortWithMessage(c, http.StatusForbidden, "临时令牌不能签发新的临时令牌")
+		return
+	}
+
+	var req model.EphemeralTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.AbortWithMessage(c, http.StatusBadRequest, "无效的参数")
+		return
+	}
+
+	token, err := model.GetTokenById(c.GetInt("token_id"))
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusUnauthorized, "令牌不存在")
+		return
+	}
+
+	key, claims, err := model.MintEphemeralToken(token, &req)
+	if err != nil {
+		common.AbortWithMessage(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"object":       "ephemeral_token",
+		"id":           claims.Id,
+		"token":        key,
+		"expires_at":   claims.ExpiresAt,
+		"models":       claims.Models,
+		"max_requests": claims.MaxRequests,
+		"max_quota":    claims.MaxQuota,
+	})
+}
+
+func getEphemeralClaims(c *gin.Context) *common.EphemeralClaims {
+	value, ok := c.Get("ephemeral_token")
+	if !ok {
+		return nil
+	}
+	claims, _ := value.(*common.EphemeralClaims)
+	return claims
+}
+
+// checkEphemeralModel 临时令牌限制了模型时，只能使用列出的模型
+func checkEphemeralModel(c *gin.Context, modelName string) error {
+	claims := getEphemeralClaims(c)
+	if claims == nil || len(claims.Models) == 0 {
+		return nil
+	}
+
+	if !slices.Contains(claims.Models, modelName) {
+		return fmt.Errorf("该令牌无权使用模型 %s", modelName)
+	}
+
+	return nil
+}
+
+func filterEphemeralModels(c *gin.Context, models []string) []string {
+	claims := getEphemeralClaims(c)
+	if claims == nil || len(claims.Models) == 0 {
+		return models
+	}
+
+	return slices.DeleteFunc(slices.Clone(models), func(modelName string) bool {
+		return !slices.Contains(claims.Models, modelName)
+	})
+}
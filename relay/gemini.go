@@ -65,6 +65,12 @@ func (r *relayGeminiOnly) setRequest() error {
 	}
 	r.geminiRequest.Model = modelList[0]
 	r.geminiRequest.Stream = isStream
+
+	if r.geminiRequest.CachedContent != "" {
+		if _, err := bindGeminiCachedContentChannel(r.c, r.geminiRequest.CachedContent); err != nil {
+			return err
+		}
+	}
 	r.setOriginalModel(r.geminiRequest.Model)
 	// 设置原始模型到 Context，用于统一请求响应模型功能
 	r.c.Set("original_model", r.geminiRequest.Model)
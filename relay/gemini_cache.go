@@ -0,0 +1,270 @@
+package relay
+
+import (
+	"done-hub/common"
+	"done-hub/common/config"
+	"done-hub/common/logger"
+	"done-hub/model"
+	"done-hub/providers/gemini"
+	"done-hub/relay/relay_util"
+	"done-hub/types"
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func CreateGeminiCachedContent(c *gin.Context) {
+	var request map[string]any
+	if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+		responseGeminiCacheError(c, common.StringErrorWrapperLocal(err.Error(), "invalid_request", http.StatusBadRequest))
+		return
+	}
+
+	modelName, _ := request["model"].(string)
+	modelName = strings.TrimPrefix(modelName, "models/")
+	if modelName == "" {
+		responseGeminiCacheError(c, common.StringErrorWrapperLocal("model is required", "invalid_request", http.StatusBadRequest))
+		return
+	}
+
+	c.Set("allow_channel_type", AllowGeminiChannelType)
+	cacheProvider, newModelName, err := getGeminiCachedContentProvider(c, modelName)
+	if err != nil {
+		responseGeminiCacheError(c, common.StringErrorWrapperLocal(err.Error(), "one_hub_error", http.StatusServiceUnavailable))
+		return
+	}
+	request["model"] = newModelName
+
+	billingModel := newModelName
+	if c.GetBool("billing_original_model") {
+		billingModel = modelName
+	}
+
+	quota := relay_util.NewQuota(c, billingModel, 0)
+	quota.SetNoCompletion()
+	if errWithCode := quota.PreQuotaConsumption(); errWithCode != nil {
+		responseGeminiCacheError(c, errWithCode)
+		return
+	}
+
+	response, errWithCode := cacheProvider.CreateCachedContent(request)
+	if errWithCode != nil {
+		quota.Undo(c)
+		responseGeminiCacheError(c, errWithCode)
+		return
+	}
+
+	cache := &model.GeminiCachedContent{
+		Name:       response.Name,
+		UserId:     c.GetInt("id"),
+		TokenId:    c.GetInt("token_id"),
+		ChannelId:  c.GetInt("channel_id"),
+		Model:      billingModel,
+		TokenCount: response.GetTokenCount(),
+		ExpireTime: response.GetExpireTime(),
+	}
+	if err := cache.Insert(); err != nil {
+		// 未记录的缓存无法固定到渠道，删除上游缓存并退还预扣的额度
+		logger.LogError(c.Request.Context(), "insert gemini cached content failed: "+err.Error())
+		if errWithCode := cacheProvider.DeleteCachedContent(cache.Name); errWithCode != nil {
+			logger.LogError(c.Request.Context(), "delete gemini cached content failed: "+errWithCode.Message)
+		}
+		quota.Undo(c)
+		responseGeminiCacheError(c, common.ErrorWrapperLocal(err, "insert_cached_content_failed", http.StatusInternalServerError))
+		return
+	}
+
+	// 创建时按输入价格计算缓存内容，同时预付 TTL 内的存储费用
+	quota.Consume(c, getCachedContentUsage(cache.TokenCount, cache.TokenCount, time.Now().Unix(), cache.ExpireTime), false)
+
+	c.JSON(http.StatusOK, response)
+}
+
+func ListGeminiCachedContents(c *gin.Context) {
+	caches, err := model.GetUserGeminiCachedContents(c.GetInt("id"))
+	if err != nil {
+		responseGeminiCacheError(c, common.ErrorWrapperLocal(err, "list_cached_contents_failed", http.StatusInternalServerError))
+		return
+	}
+
+	response := &gemini.GeminiCachedContentList{
+		CachedContents: make([]*gemini.GeminiCachedContent, 0, len(caches)),
+	}
+	for _, cache := range caches {
+		response.CachedContents = append(response.CachedContents, &gemini.GeminiCachedContent{
+			Name:       cache.Name,
+			Model:      "models/" + cache.Model,
+			CreateTime: time.Unix(cache.CreatedAt, 0).UTC().Format(time.RFC3339),
+			UpdateTime: time.Unix(cache.UpdatedAt, 0).UTC().Format(time.RFC3339),
+			ExpireTime: time.Unix(cache.ExpireTime, 0).UTC().Format(time.RFC3339),
+			UsageMetadata: &gemini.GeminiCachedContentUsage{
+				TotalTokenCount: cache.TokenCount,
+			},
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func GetGeminiCachedContent(c *gin.Context) {
+	cache, cacheProvider, errWithCode := getBoundGeminiCachedContent(c)
+	if errWithCode != nil {
+		responseGeminiCacheError(c, errWithCode)
+		return
+	}
+
+	response, errWithCode := cacheProvider.GetCachedContent(cache.Name)
+	if errWithCode != nil {
+		responseGeminiCacheError(c, errWithCode)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func UpdateGeminiCachedContent(c *gin.Context) {
+	var body map[string]any
+	if err := common.UnmarshalBodyReusable(c, &body); err != nil {
+		responseGeminiCacheError(c, common.StringErrorWrapperLocal(err.Error(), "invalid_request", http.StatusBadRequest))
+		return
+	}
+
+	// 缓存内容创建后不可修改，只允许更新过期时间
+	request := make(map[string]any)
+	for _, key := range []string{"ttl", "expireTime"} {
+		if value, ok := body[key]; ok {
+			request[key] = value
+		}
+	}
+	if len(request) == 0 {
+		responseGeminiCacheError(c, common.StringErrorWrapperLocal("ttl or expireTime is required", "invalid_request", http.StatusBadRequest))
+		return
+	}
+
+	cache, cacheProvider, errWithCode := getBoundGeminiCachedContent(c)
+	if errWithCode != nil {
+		responseGeminiCacheError(c, errWithCode)
+		return
+	}
+
+	quota := relay_util.NewQuota(c, cache.Model, 0)
+	quota.SetNoCompletion()
+	if errWithCode := quota.PreQuotaConsumption(); errWithCode != nil {
+		responseGeminiCacheError(c, errWithCode)
+		return
+	}
+
+	response, errWithCode := cacheProvider.UpdateCachedContent(cache.Name, request)
+	if errWithCode != nil {
+		quota.Undo(c)
+		responseGeminiCacheError(c, errWithCode)
+		return
+	}
+
+	// 只对延长的部分收取存储费用
+	paidUntil := max(cache.ExpireTime, time.Now().Unix())
+	expireTime := response.GetExpireTime()
+	if expireTime > paidUntil {
+		quota.Consume(c, getCachedContentUsage(0, cache.TokenCount, paidUntil, expireTime), false)
+	} else {
+		quota.Undo(c)
+	}
+
+	if expireTime > 0 {
+		if err := cache.UpdateExpireTime(expireTime); err != nil {
+			logger.LogError(c.Request.Context(), "update gemini cached content failed: "+err.Error())
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func DeleteGeminiCachedContent(c *gin.Context) {
+	cache, cacheProvider, errWithCode := getBoundGeminiCachedContent(c)
+	if errWithCode != nil {
+		responseGeminiCacheError(c, errWithCode)
+		return
+	}
+
+	// 已预付的存储费用不退还
+	if errWithCode = cacheProvider.DeleteCachedContent(cache.Name); errWithCode != nil {
+		responseGeminiCacheError(c, errWithCode)
+		return
+	}
+
+	if err := cache.Delete(); err != nil {
+		logger.LogError(c.Request.Context(), "delete gemini cached content failed: "+err.Error())
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// 将引用了缓存的请求固定到创建该缓存的渠道
+func bindGeminiCachedContentChannel(c *gin.Context, name string) (*model.GeminiCachedContent, error) {
+	cache, err := model.GetGeminiCachedContent(c.GetInt("id"), name)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Set("specific_channel_id", cache.ChannelId)
+	c.Set("specific_channel_id_ignore", false)
+
+	return cache, nil
+}
+
+func getBoundGeminiCachedContent(c *gin.Context) (*model.GeminiCachedContent, gemini.GeminiCachedContentInterface, *types.OpenAIErrorWithStatusCode) {
+	cache, err := bindGeminiCachedContentChannel(c, c.Param("id"))
+	if err != nil {
+		return nil, nil, common.StringErrorWrapperLocal(err.Error(), "cached_content_not_found", http.StatusNotFound)
+	}
+
+	cacheProvider, _, err := getGeminiCachedContentProvider(c, cache.Model)
+	if err != nil {
+		return nil, nil, common.StringErrorWrapperLocal(err.Error(), "one_hub_error", http.StatusServiceUnavailable)
+	}
+
+	return cache, cacheProvider, nil
+}
+
+func getGeminiCachedContentProvider(c *gin.Context, modelName string) (gemini.GeminiCachedContentInterface, string, error) {
+	provider, newModelName, err := GetProvider(c, modelName)
+	if err != nil {
+		return nil, "", err
+	}
+
+	cacheProvider, ok := provider.(gemini.GeminiCachedContentInterface)
+	if !ok {
+		return nil, "", errors.New("channel not supported cached contents")
+	}
+
+	return cacheProvider, newModelName, nil
+}
+
+// 存储费用按 token * 小时 计算，只通过 cached_storage_tokens 的倍率计费，不计入 PromptTokens，避免影响价格档位
+func getCachedContentUsage(promptTokens, cacheTokens int, startTime, expireTime int64) *types.Usage {
+	storageTokens := 0
+	if expireTime > startTime {
+		storageTokens = int(math.Ceil(float64(cacheTokens) * float64(expireTime-startTime) / 3600))
+	}
+
+	usage := &types.Usage{
+		PromptTokens: promptTokens,
+		TotalTokens:  promptTokens,
+		ExtraTokens: map[string]int{
+			config.UsageExtraCachedStorage: storageTokens,
+		},
+	}
+
+	return usage
+}
+
+func responseGeminiCacheError(c *gin.Context, err *types.OpenAIErrorWithStatusCode) {
+	newErr := FilterOpenAIErr(c, err)
+	geminiErr := gemini.OpenaiErrToGeminiErr(&newErr)
+
+	c.JSON(newErr.StatusCode, geminiErr.GeminiErrorResponse)
+}
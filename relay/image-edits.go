@@ -2,6 +2,7 @@ package relay
 
 import (
 	"done-hub/common"
+	"done-hub/common/media"
 	providersBase "done-hub/providers/base"
 	"done-hub/types"
 	"errors"
@@ -62,6 +63,7 @@ func (r *relayImageEdits) send() (err *types.OpenAIErrorWithStatusCode, done boo
 	if err != nil {
 		return
 	}
+	media.PersistImageResponse(r.c, response)
 	err = responseJsonClient(r.c, response)
 
 	if err != nil {
@@ -2,6 +2,7 @@ package relay
 
 import (
 	"done-hub/common"
+	"done-hub/common/media"
 	providersBase "done-hub/providers/base"
 	"done-hub/types"
 	"errors"
@@ -137,6 +138,7 @@ func (r *relayImageGenerations) send() (err *types.OpenAIErrorWithStatusCode, do
 	if err != nil {
 		return
 	}
+	media.PersistImageResponse(r.c, response)
 	err = responseJsonClient(r.c, response)
 
 	if err != nil {
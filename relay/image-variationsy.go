@@ -2,6 +2,7 @@ package relay
 
 import (
 	"done-hub/common"
+	"done-hub/common/media"
 	providersBase "done-hub/providers/base"
 	"done-hub/types"
 	"net/http"
@@ -60,6 +61,7 @@ func (r *relayImageVariations) send() (err *types.OpenAIErrorWithStatusCode, don
 	if err != nil {
 		return
 	}
+	media.PersistImageResponse(r.c, response)
 	err = responseJsonClient(r.c, response)
 
 	if err != nil {
@@ -7,7 +7,10 @@ import (
 	"bytes"
 	"context"
 	"done-hub/common"
+	"done-hub/common/config"
 	"done-hub/common/logger"
+	"done-hub/common/media"
+	"done-hub/common/webhook"
 	"done-hub/controller"
 	"done-hub/model"
 	provider "done-hub/providers/midjourney"
@@ -83,6 +86,7 @@ func RelayMidjourneyNotify(c *gin.Context) *provider.MidjourneyResponse {
 			Result:      "",
 		}
 	}
+	finished := midjourneyTask.IsFinished()
 	midjourneyTask.Progress = midjRequest.Progress
 	midjourneyTask.PromptEn = midjRequest.PromptEn
 	midjourneyTask.State = midjRequest.State
@@ -100,6 +104,11 @@ func RelayMidjourneyNotify(c *gin.Context) *provider.MidjourneyResponse {
 		}
 	}
 
+	if !finished && midjourneyTask.IsFinished() {
+		media.PersistMidjourney(midjourneyTask)
+		webhook.MidjourneyFinished(midjourneyTask)
+	}
+
 	return nil
 }
 
@@ -353,6 +362,22 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *provider.MidjourneyRe
 		return provider.MidjourneyErrorWrapper(provider.MjRequestError, "bind_request_body_failed")
 	}
 
+	// 开启上游回调时由上游直接通知，否则由网关投递
+	notifyHook := ""
+	if !config.MjNotifyEnabled {
+		notifyHook = midjRequest.NotifyHook
+		if notifyHook == "" {
+			if setting, ok := c.Get("token_setting"); ok {
+				if tokenSetting, ok := setting.(*model.TokenSetting); ok {
+					notifyHook = tokenSetting.Webhook.URL
+				}
+			}
+		}
+		if notifyHook != "" && webhook.ValidateURL(notifyHook) != nil {
+			return provider.MidjourneyErrorWrapper(provider.MjRequestError, "invalid_notify_hook")
+		}
+	}
+
 	mjProvider, errWithMJ := getMJProviderWithRequest(c, relayMode, &midjRequest)
 	if errWithMJ != nil {
 		return errWithMJ
@@ -523,6 +548,7 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *provider.MidjourneyRe
 		ChannelId:   c.GetInt("channel_id"),
 		Quota:       quota,
 		Mode:        mjModelType,
+		NotifyHook:  notifyHook,
 	}
 
 	if midjResponse.Code != 1 && midjResponse.Code != 21 && midjResponse.Code != 22 {
@@ -46,6 +46,7 @@ func ListModelsByToken(c *gin.Context) {
 		})
 		return
 	}
+	models = filterEphemeralModels(c, models)
 	sort.Strings(models)
 
 	var groupOpenAIModels []*OpenAIModels
@@ -89,6 +90,7 @@ func ListGeminiModelsByToken(c *gin.Context) {
 		})
 		return
 	}
+	models = filterEphemeralModels(c, models)
 	sort.Strings(models)
 
 	var geminiModels []gemini.ModelDetails
@@ -129,6 +131,7 @@ func ListClaudeModelsByToken(c *gin.Context) {
 		})
 		return
 	}
+	models = filterEphemeralModels(c, models)
 	sort.Strings(models)
 
 	var claudeModelsData []claude.Model
@@ -229,9 +232,11 @@ type ModelPrice struct {
 }
 
 type AvailableModelResponse struct {
-	Groups  []string     `json:"groups"`
-	OwnedBy string       `json:"owned_by"`
-	Price   *model.Price `json:"price"`
+	Groups       []string                     `json:"groups"`
+	OwnedBy      string                       `json:"owned_by"`
+	Price        *model.Price                 `json:"price"`
+	GroupPrices  map[string]*model.GroupPrice `json:"group_prices,omitempty"`  // 分组下单独设置的价格
+	PriceWindows []*model.PriceWindow         `json:"price_windows,omitempty"` // 适用的分时价格
 }
 
 func AvailableModel(c *gin.Context) {
@@ -276,6 +281,21 @@ func getAvailableModels(groupName string) map[string]*AvailableModelResponse {
 				OwnedBy: *getModelOwnedBy(price.ChannelType),
 				Price:   price,
 			}
+
+			for _, window := range model.PricingInstance.GetPriceWindows("", modelName) {
+				if window.Group == "" || utils.Contains(window.Group, groups) {
+					availableModels[modelName].PriceWindows = append(availableModels[modelName].PriceWindows, window)
+				}
+			}
+
+			for _, group := range groups {
+				if groupPrice := model.PricingInstance.GetGroupPrice(group, modelName); groupPrice != nil {
+					if availableModels[modelName].GroupPrices == nil {
+						availableModels[modelName].GroupPrices = make(map[string]*model.GroupPrice)
+					}
+					availableModels[modelName].GroupPrices[group] = groupPrice
+				}
+			}
 		}
 	}
 
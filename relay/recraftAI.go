@@ -1,15 +1,18 @@
 package relay
 
 import (
+	"bytes"
 	"done-hub/common"
 	"done-hub/common/config"
 	"done-hub/common/logger"
+	"done-hub/common/media"
 	"done-hub/metrics"
 	"done-hub/providers/recraftAI"
 	"done-hub/relay/relay_util"
 	"done-hub/types"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 
@@ -43,7 +46,7 @@ func RelayRecraftAI(c *gin.Context) {
 		quota.Consume(c, usage, false)
 
 		metrics.RecordProvider(c, 200)
-		errWithCode := responseMultipart(c, response)
+		errWithCode := responseRecraftAI(c, response)
 		logger.LogError(c.Request.Context(), fmt.Sprintf("relay error happen %v, won't retry in this case", errWithCode))
 		return
 	}
@@ -71,7 +74,7 @@ func RelayRecraftAI(c *gin.Context) {
 			quota.Consume(c, usage, false)
 
 			metrics.RecordProvider(c, 200)
-			errWithCode := responseMultipart(c, response)
+			errWithCode := responseRecraftAI(c, response)
 			logger.LogError(c.Request.Context(), fmt.Sprintf("relay error happen %v, won't retry in this case", errWithCode))
 			return
 		}
@@ -87,6 +90,27 @@ func RelayRecraftAI(c *gin.Context) {
 	common.AbortWithErr(c, newErrWithCode.StatusCode, &newErrWithCode.OpenAIError)
 }
 
+// responseRecraftAI 开启媒体转存时先转存响应中的图片再返回
+func responseRecraftAI(c *gin.Context, resp *http.Response) *types.OpenAIErrorWithStatusCode {
+	if !strings.Contains(resp.Header.Get("Content-Type"), "application/json") || !media.Enabled(c) {
+		return responseMultipart(c, resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return common.ErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError)
+	}
+
+	persister := media.NewPersister(c.Request.Context(), c.GetInt("id"), c.GetInt("token_id"), media.PlatformRecraft, "")
+	body = persister.PersistJSON(body)
+
+	resp.Header.Del("Content-Length")
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	return responseMultipart(c, resp)
+}
+
 func Path2RecraftAIModel(path string) string {
 	parts := strings.Split(path, "/")
 	lastPart := parts[len(parts)-1]
@@ -0,0 +1,60 @@
+package relay_util
+
+import (
+	"bytes"
+	"done-hub/common/config"
+	"encoding/json"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const endUserMaxLength = 64
+
+// GetEndUser 获取发起请求的终端用户，优先使用 X-End-User 请求头，其次是请求体中的 user 或 metadata.user_id
+func GetEndUser(c *gin.Context) string {
+	if endUser, ok := c.Get("end_user"); ok {
+		return endUser.(string)
+	}
+
+	endUser := strings.TrimSpace(c.GetHeader("X-End-User"))
+	if endUser == "" {
+		endUser = getEndUserFromBody(c)
+	}
+	if len(endUser) > endUserMaxLength {
+		endUser = endUser[:endUserMaxLength]
+	}
+
+	c.Set("end_user", endUser)
+	return endUser
+}
+
+func getEndUserFromBody(c *gin.Context) string {
+	rawBody, ok := c.Get(config.GinRequestBodyKey)
+	if !ok {
+		return ""
+	}
+	body, ok := rawBody.([]byte)
+	if !ok || !bytes.Contains(body, []byte(`"user`)) {
+		return ""
+	}
+
+	var request struct {
+		User     any `json:"user"`
+		Metadata struct {
+			UserId any `json:"user_id"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return ""
+	}
+
+	if user, ok := request.User.(string); ok && user != "" {
+		return strings.TrimSpace(user)
+	}
+	if userId, ok := request.Metadata.UserId.(string); ok {
+		return strings.TrimSpace(userId)
+	}
+
+	return ""
+}
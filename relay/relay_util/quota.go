@@ -19,6 +19,9 @@ type Quota struct {
 	modelName        string
 	promptTokens     int
 	price            model.Price
+	priceTier        *model.PriceTier
+	groupPrice       *model.GroupPrice
+	priceWindow      *model.PriceWindow
 	groupName        string
 	groupRatio       float64
 	inputRatio       float64
@@ -28,7 +31,18 @@ type Quota struct {
 	userId           int
 	channelId        int
 	tokenId          int
-	HandelStatus     bool
+	organizationId   int
+	ephemeral        *common.EphemeralClaims
+	// 预扣时占用的临时令牌额度
+	ephemeralReserved int
+	endUser           string
+	endUserSetting    *model.EndUserSetting
+	HandelStatus      bool
+	// 缓存存储等本身没有输出的请求，不受空回复计费开关影响
+	noCompletion bool
+	discount     float64
+	times        float64
+	logMeta      map[string]any
 
 	startTime         time.Time
 	firstResponseTime time.Time
@@ -37,51 +51,119 @@ type Quota struct {
 
 func NewQuota(c *gin.Context, modelName string, promptTokens int) *Quota {
 	quota := &Quota{
-		modelName:    modelName,
-		promptTokens: promptTokens,
-		userId:       c.GetInt("id"),
-		channelId:    c.GetInt("channel_id"),
-		tokenId:      c.GetInt("token_id"),
-		HandelStatus: false,
+		modelName:      modelName,
+		promptTokens:   promptTokens,
+		userId:         c.GetInt("id"),
+		channelId:      c.GetInt("channel_id"),
+		tokenId:        c.GetInt("token_id"),
+		organizationId: c.GetInt("organization_id"),
+		HandelStatus:   false,
+	}
+
+	if claims, ok := c.Get("ephemeral_token"); ok {
+		quota.ephemeral, _ = claims.(*common.EphemeralClaims)
+	}
+
+	quota.endUser = GetEndUser(c)
+	if setting, ok := c.Get("token_setting"); ok {
+		if tokenSetting, ok := setting.(*model.TokenSetting); ok && tokenSetting != nil {
+			quota.endUserSetting = &tokenSetting.EndUser
+		}
 	}
 
-	quota.price = *model.PricingInstance.GetPrice(quota.modelName)
-	quota.groupRatio = c.GetFloat64("group_ratio")
 	quota.groupName = c.GetString("token_group")
-	quota.inputRatio = quota.price.GetInput() * quota.groupRatio
-	quota.outputRatio = quota.price.GetOutput() * quota.groupRatio
+	price, groupRatio, groupPrice := model.PricingInstance.ResolveGroupPrice(quota.groupName, quota.modelName, c.GetFloat64("group_ratio"))
+	quota.price = *price
+	quota.groupRatio = groupRatio
+	quota.groupPrice = groupPrice
+	quota.priceWindow = model.PricingInstance.GetActivePriceWindow(quota.groupName, quota.modelName, time.Now())
+	quota.priceTier = quota.price.GetTier(promptTokens)
+	quota.updateRatios()
 
 	return quota
 }
 
-func (q *Quota) PreQuotaConsumption() *types.OpenAIErrorWithStatusCode {
+// updateRatios 按当前价格档位、分组倍率、折扣、计费次数和分时倍率计算输入输出倍率
+func (q *Quota) updateRatios() {
+	input, output := q.price.GetInput(), q.price.GetOutput()
+	if q.priceTier != nil {
+		input, output = q.priceTier.Input, q.priceTier.Output
+	}
+
+	scale := q.groupRatio
+	if q.discount > 0 {
+		scale *= q.discount
+	}
+	if q.times > 0 {
+		scale *= q.times
+	}
+	if q.priceWindow != nil {
+		scale *= q.priceWindow.Ratio
+	}
+
+	q.inputRatio = input * scale
+	q.outputRatio = output * scale
+}
+
+// setPriceTier 按实际的提示 token 数重新选择阶梯价格
+func (q *Quota) setPriceTier(promptTokens int) {
+	tier := q.price.GetTier(promptTokens)
+	if tier == q.priceTier {
+		return
+	}
+
+	q.priceTier = tier
+	q.updateRatios()
+}
+
+// EstimateQuota 按提示 token 数预估请求的额度，按次计费时为单次价格
+func (q *Quota) EstimateQuota() int {
 	if q.price.Type == model.TimesPriceType {
-		q.preConsumedQuota = int(1000 * q.inputRatio)
-	} else if q.price.Input != 0 || q.price.Output != 0 {
-		q.preConsumedQuota = int(float64(q.promptTokens)*q.inputRatio) + config.PreConsumedQuota
+		return int(1000 * q.inputRatio)
+	}
+	if q.price.Input != 0 || q.price.Output != 0 {
+		return int(float64(q.promptTokens)*q.inputRatio) + config.PreConsumedQuota
+	}
+
+	return 0
+}
+
+func (q *Quota) PreQuotaConsumption() *types.OpenAIErrorWithStatusCode {
+	if err := model.CheckEndUserLimit(q.tokenId, q.endUser, q.endUserSetting); err != nil {
+		return common.ErrorWrapper(err, "end_user_limit_exceeded", http.StatusTooManyRequests)
 	}
 
+	q.preConsumedQuota = q.EstimateQuota()
 	if q.preConsumedQuota == 0 {
 		return nil
 	}
 
-	userQuota, err := model.CacheGetUserQuota(q.userId)
+	userQuota, err := model.CacheGetBillingQuota(q.userId, q.organizationId)
 	if err != nil {
 		return common.ErrorWrapper(err, "get_user_quota_failed", http.StatusInternalServerError)
 	}
 
+	if userQuota < q.preConsumedQuota {
+		return common.ErrorWrapper(errors.New("user quota is not enough"), "insufficient_user_quota", http.StatusPaymentRequired)
+	}
+
+	// 临时令牌按预估额度占用剩余额度，余额充足免预扣时同样占用
+	if err := model.ReserveEphemeralTokenQuota(q.ephemeral, q.preConsumedQuota); err != nil {
+		return common.ErrorWrapper(err, "insufficient_ephemeral_token_quota", http.StatusPaymentRequired)
+	}
+	if q.ephemeral != nil && q.ephemeral.MaxQuota > 0 {
+		q.ephemeralReserved = q.preConsumedQuota
+	}
+
 	if userQuota > 100*q.preConsumedQuota {
 		q.preConsumedQuota = 0
 		return nil
 	}
 
-	if userQuota < q.preConsumedQuota {
-		return common.ErrorWrapper(errors.New("user quota is not enough"), "insufficient_user_quota", http.StatusPaymentRequired)
-	}
-
 	if q.preConsumedQuota > 0 {
 		err := model.PreConsumeTokenQuota(q.tokenId, q.preConsumedQuota)
 		if err != nil {
+			q.releaseEphemeralQuota()
 			return common.ErrorWrapper(err, "pre_consume_token_quota_failed", http.StatusForbidden)
 		}
 		_ = model.CacheUpdateUserQuota(q.userId)
@@ -100,6 +182,7 @@ func (q *Quota) UpdateUserRealtimeQuota(usage *types.UsageEvent, nowUsage *types
 		return nil
 	}
 
+	q.setPriceTier(usage.InputTokens)
 	promptTokens, completionTokens := q.getComputeTokensByUsageEvent(nowUsage)
 	increaseQuota := q.GetTotalQuota(promptTokens, completionTokens, nil)
 
@@ -109,7 +192,7 @@ func (q *Quota) UpdateUserRealtimeQuota(usage *types.UsageEvent, nowUsage *types
 	}
 
 	q.cacheQuota += increaseQuota
-	userQuota, err := model.CacheGetUserQuota(q.userId)
+	userQuota, err := model.CacheGetBillingQuota(q.userId, q.organizationId)
 	if err != nil {
 		return errors.New("error get user quota cache: " + err.Error())
 	}
@@ -129,6 +212,23 @@ func (q *Quota) completedQuotaConsumption(usage *types.Usage, tokenName string,
 	}()
 
 	quota := q.GetTotalQuotaByUsage(usage)
+	// 上游成本与是否向用户收费无关，订阅抵扣等情况仍然记录
+	costQuota := q.GetCostQuotaByUsage(usage)
+
+	// 订阅套餐包含该模型的免费次数时本次不计费，退还预扣的额度
+	if quota > 0 && model.UseSubscriptionAllowance(q.userId, q.modelName) {
+		quota = 0
+		q.AddLogMeta("subscription_allowance", true)
+		if q.preConsumedQuota > 0 {
+			if err := model.PostConsumeTokenQuota(q.tokenId, -q.preConsumedQuota); err != nil {
+				return errors.New("error return pre-consumed quota: " + err.Error())
+			}
+			_ = model.CacheUpdateUserQuota(q.userId)
+		}
+	}
+
+	// 按实际消费修正临时令牌预扣时占用的额度
+	model.RecordEphemeralTokenQuota(q.ephemeral, quota-q.ephemeralReserved)
 
 	if quota > 0 {
 		quotaDelta := quota - q.preConsumedQuota
@@ -141,6 +241,7 @@ func (q *Quota) completedQuotaConsumption(usage *types.Usage, tokenName string,
 			return errors.New("error consuming token remain quota: " + err.Error())
 		}
 		model.UpdateChannelUsedQuota(q.channelId, quota)
+		model.RecordEndUserQuota(q.tokenId, q.endUser, q.endUserSetting, quota)
 	}
 
 	model.RecordConsumeLog(
@@ -152,11 +253,15 @@ func (q *Quota) completedQuotaConsumption(usage *types.Usage, tokenName string,
 		q.modelName,
 		tokenName,
 		quota,
+		costQuota,
 		"",
 		q.getRequestTime(),
 		isStream,
 		q.GetLogMeta(usage),
 		sourceIp,
+		q.endUser,
+		q.organizationId,
+		q.groupName,
 	)
 	model.UpdateUserUsedQuotaAndRequestCount(q.userId, quota)
 
@@ -164,6 +269,7 @@ func (q *Quota) completedQuotaConsumption(usage *types.Usage, tokenName string,
 }
 
 func (q *Quota) Undo(c *gin.Context) {
+	q.releaseEphemeralQuota()
 	tokenId := c.GetInt("token_id")
 	if q.HandelStatus {
 		go func(ctx context.Context) {
@@ -177,6 +283,12 @@ func (q *Quota) Undo(c *gin.Context) {
 	}
 }
 
+// releaseEphemeralQuota 请求失败时释放临时令牌占用的额度
+func (q *Quota) releaseEphemeralQuota() {
+	model.RecordEphemeralTokenQuota(q.ephemeral, -q.ephemeralReserved)
+	q.ephemeralReserved = 0
+}
+
 func (q *Quota) Consume(c *gin.Context, usage *types.Usage, isStream bool) {
 	tokenName := c.GetString("token_name")
 	q.startTime = c.GetTime("requestStartTime")
@@ -189,6 +301,39 @@ func (q *Quota) Consume(c *gin.Context, usage *types.Usage, isStream bool) {
 	}(c.Request.Context())
 }
 
+func (q *Quota) SetNoCompletion() {
+	q.noCompletion = true
+}
+
+// SetDiscount 在分组倍率之外再对输入输出价格打折，如批量请求
+func (q *Quota) SetDiscount(discount float64) {
+	if discount <= 0 {
+		return
+	}
+
+	q.discount = discount
+	q.updateRatios()
+}
+
+// SetTimes 按次计费时的计费次数，如视频按秒计费时为 秒数 * 分辨率倍率
+func (q *Quota) SetTimes(times float64) {
+	if times <= 0 {
+		return
+	}
+
+	q.times = times
+	q.updateRatios()
+}
+
+// AddLogMeta 附加写入消费日志的元数据
+func (q *Quota) AddLogMeta(key string, value any) {
+	if q.logMeta == nil {
+		q.logMeta = make(map[string]any)
+	}
+
+	q.logMeta[key] = value
+}
+
 func (q *Quota) GetInputRatio() float64 {
 	return q.inputRatio
 }
@@ -202,6 +347,22 @@ func (q *Quota) GetLogMeta(usage *types.Usage) map[string]any {
 		"output_ratio": q.price.GetOutput(),
 	}
 
+	if q.groupPrice != nil {
+		meta["group_price_type"] = q.groupPrice.Type
+	}
+
+	if q.priceWindow != nil {
+		meta["price_window"] = q.priceWindow.Id
+		meta["price_window_name"] = q.priceWindow.Name
+		meta["price_window_ratio"] = q.priceWindow.Ratio
+	}
+
+	if q.priceTier != nil {
+		meta["price_tier"] = q.priceTier.Threshold
+		meta["input_ratio"] = q.priceTier.Input
+		meta["output_ratio"] = q.priceTier.Output
+	}
+
 	firstResponseTime := q.GetFirstResponseTime()
 	if firstResponseTime > 0 {
 		meta["first_response"] = firstResponseTime
@@ -221,6 +382,26 @@ func (q *Quota) GetLogMeta(usage *types.Usage) map[string]any {
 		meta["extra_billing"] = q.extraBillingData
 	}
 
+	if q.discount > 0 {
+		meta["discount"] = q.discount
+	}
+
+	if q.times > 0 {
+		meta["times"] = q.times
+	}
+
+	// 临时令牌的消费记在父令牌下，记录临时令牌以便区分来源
+	if q.ephemeral != nil {
+		meta["ephemeral_token"] = q.ephemeral.Id
+		if q.ephemeral.Name != "" {
+			meta["ephemeral_token_name"] = q.ephemeral.Name
+		}
+	}
+
+	for key, value := range q.logMeta {
+		meta[key] = value
+	}
+
 	return meta
 }
 
@@ -263,7 +444,7 @@ func (q *Quota) GetTotalQuota(promptTokens, completionTokens int, extraBilling m
 	}
 
 	// 如果禁用了空回复计费且没有输出token，则不计费
-	if !config.EmptyResponseBillingEnabled && completionTokens == 0 {
+	if !config.EmptyResponseBillingEnabled && completionTokens == 0 && !q.noCompletion {
 		quota = 0
 	}
 
@@ -280,9 +461,9 @@ func (q *Quota) getComputeTokensByUsage(usage *types.Usage) (promptTokens, compl
 	for key, value := range extraTokens {
 		extraRatio := q.price.GetExtraRatio(key)
 		if model.GetExtraPriceIsPrompt(key) {
-			promptTokens += model.GetIncreaseTokens(value, extraRatio)
+			promptTokens += model.GetExtraTokens(key, value, extraRatio)
 		} else {
-			completionTokens += model.GetIncreaseTokens(value, extraRatio)
+			completionTokens += model.GetExtraTokens(key, value, extraRatio)
 		}
 	}
 
@@ -297,9 +478,9 @@ func (q *Quota) getComputeTokensByUsageEvent(usage *types.UsageEvent) (promptTok
 	for key, value := range extraTokens {
 		extraRatio := q.price.GetExtraRatio(key)
 		if model.GetExtraPriceIsPrompt(key) {
-			promptTokens += model.GetIncreaseTokens(value, extraRatio)
+			promptTokens += model.GetExtraTokens(key, value, extraRatio)
 		} else {
-			completionTokens += model.GetIncreaseTokens(value, extraRatio)
+			completionTokens += model.GetExtraTokens(key, value, extraRatio)
 		}
 	}
 
@@ -308,10 +489,56 @@ func (q *Quota) getComputeTokensByUsageEvent(usage *types.UsageEvent) (promptTok
 
 // 通过 usage 获取消费配额
 func (q *Quota) GetTotalQuotaByUsage(usage *types.Usage) (quota int) {
+	q.setPriceTier(usage.PromptTokens)
 	promptTokens, completionTokens := q.getComputeTokensByUsage(usage)
 	return q.GetTotalQuota(promptTokens, completionTokens, usage.ExtraBilling)
 }
 
+// GetCostQuotaByUsage 按渠道的上游价格计算本次请求的成本，不受分组倍率和分时价格影响，渠道未设置成本时返回 0
+func (q *Quota) GetCostQuotaByUsage(usage *types.Usage) int {
+	if usage == nil || (usage.PromptTokens+usage.CompletionTokens == 0 && len(usage.ExtraTokens) == 0 && len(usage.ExtraBilling) == 0) {
+		return 0
+	}
+
+	channel := model.ChannelGroup.GetChannel(q.channelId)
+	if channel == nil {
+		return 0
+	}
+
+	basePrice := model.PricingInstance.GetPrice(q.modelName)
+	input, output, ok := channel.GetCostPrice(q.modelName, basePrice, usage.PromptTokens)
+	if !ok {
+		return 0
+	}
+
+	scale := 1.0
+	if q.discount > 0 {
+		scale *= q.discount
+	}
+	if q.times > 0 {
+		scale *= q.times
+	}
+
+	extraCost := q.getExtraBillingCost(usage.ExtraBilling, channel.GetExtraCostRatio())
+
+	if basePrice.Type == model.TimesPriceType {
+		return int(math.Ceil(1000*input*scale)) + extraCost
+	}
+
+	promptTokens, completionTokens := q.getComputeTokensByUsage(usage)
+	return int(math.Ceil((float64(promptTokens)*input+float64(completionTokens)*output)*scale)) + extraCost
+}
+
+// getExtraBillingCost 额外服务（如联网搜索）按默认价格乘以渠道成本倍率计入成本
+func (q *Quota) getExtraBillingCost(extraBilling map[string]types.ExtraBilling, costRatio float64) int {
+	cost := 0
+	for serviceType, value := range extraBilling {
+		price := getDefaultExtraServicePrice(serviceType, q.modelName, value.Type)
+		cost += int(math.Ceil(price*float64(config.QuotaPerUnit)*costRatio)) * value.CallCount
+	}
+	return cost
+}
+
 func (q *Quota) GetFirstResponseTime() int64 {
 	// 先判断 firstResponseTime 是否为0
 	if q.firstResponseTime.IsZero() {
@@ -0,0 +1,165 @@
+package relay_util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"done-hub/common/config"
+	"done-hub/model"
+	"done-hub/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/datatypes"
+)
+
+func newTestQuota(price model.Price) *Quota {
+	quota := &Quota{price: price, groupRatio: 1, noCompletion: true}
+	quota.updateRatios()
+	return quota
+}
+
+func TestCachedStorageTokensOutOfPriceTier(t *testing.T) {
+	tiers := datatypes.NewJSONType([]model.PriceTier{{Threshold: 200000, Input: 2, Output: 2}})
+	quota := newTestQuota(model.Price{Type: model.TokensPriceType, Input: 1, Output: 1, Tiers: &tiers})
+
+	// 10 万 token 的缓存保存 24 小时，存储 token * 小时 不计入提示 token，不会进入长上下文档位
+	usage := &types.Usage{
+		PromptTokens: 100000,
+		TotalTokens:  100000,
+		ExtraTokens:  map[string]int{config.UsageExtraCachedStorage: 2400000},
+	}
+	assert.Equal(t, 100000+int(2400000*3.6), quota.GetTotalQuotaByUsage(usage))
+	assert.Nil(t, quota.priceTier)
+
+	// 只延长过期时间时只收取存储费用
+	usage = &types.Usage{ExtraTokens: map[string]int{config.UsageExtraCachedStorage: 1000}}
+	assert.Equal(t, 3600, quota.GetTotalQuotaByUsage(usage))
+}
+
+func TestEstimateQuotaWithDiscount(t *testing.T) {
+	quota := newTestQuota(model.Price{Type: model.TokensPriceType, Input: 2, Output: 4})
+	quota.promptTokens = 1000
+	assert.Equal(t, 2000+config.PreConsumedQuota, quota.EstimateQuota())
+
+	// 批量请求按折扣预估
+	quota.SetDiscount(0.5)
+	assert.Equal(t, 1000+config.PreConsumedQuota, quota.EstimateQuota())
+
+	times := newTestQuota(model.Price{Type: model.TimesPriceType, Input: 0.5})
+	assert.Equal(t, 500, times.EstimateQuota())
+
+	free := newTestQuota(model.Price{Type: model.TokensPriceType})
+	assert.Zero(t, free.EstimateQuota())
+}
+
+func TestSetTimes(t *testing.T) {
+	// 视频按秒计费：10 秒 * 1080p 倍率 1.5
+	quota := newTestQuota(model.Price{Type: model.TimesPriceType, Input: 0.5})
+	quota.SetTimes(10 * 1.5)
+	assert.Equal(t, 7500, quota.EstimateQuota())
+	assert.Equal(t, 15.0, quota.GetLogMeta(&types.Usage{})["times"])
+
+	// 无效的次数不改变计费
+	quota = newTestQuota(model.Price{Type: model.TimesPriceType, Input: 0.5})
+	quota.SetTimes(0)
+	assert.Equal(t, 500, quota.EstimateQuota())
+	assert.NotContains(t, quota.GetLogMeta(&types.Usage{}), "times")
+}
+
+func newTestQuotaContext(group string, groupRatio float64) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	c.Set("token_group", group)
+	c.Set("group_ratio", groupRatio)
+	return c
+}
+
+func usePricing(t *testing.T, pricing *model.Pricing) {
+	original := model.PricingInstance
+	model.PricingInstance = pricing
+	t.Cleanup(func() {
+		model.PricingInstance = original
+	})
+}
+
+func TestNewQuotaGroupPrice(t *testing.T) {
+	usePricing(t, &model.Pricing{
+		Prices: map[string]*model.Price{
+			"gpt-4o": {Model: "gpt-4o", Type: model.TokensPriceType, Input: 2, Output: 4},
+		},
+		GroupPrices: map[string]map[string]*model.GroupPrice{
+			"vip":  {"gpt-4o": {Group: "vip", Model: "gpt-4o", Type: model.GroupPriceTypeRatio, Ratio: 0.5}},
+			"svip": {"gpt-4o": {Group: "svip", Model: "gpt-4o", Type: model.GroupPriceTypeFixed, Input: 1, Output: 3}},
+		},
+	})
+	usage := &types.Usage{PromptTokens: 1000, CompletionTokens: 1000, TotalTokens: 2000}
+
+	// 没有覆盖时按分组倍率计费
+	quota := NewQuota(newTestQuotaContext("default", 1.5), "gpt-4o", 1000)
+	assert.Equal(t, (2000+4000)*3/2, quota.GetTotalQuotaByUsage(usage))
+	assert.NotContains(t, quota.GetLogMeta(usage), "group_price_type")
+
+	// 倍率覆盖代替分组倍率
+	quota = NewQuota(newTestQuotaContext("vip", 1.5), "gpt-4o", 1000)
+	assert.Equal(t, (2000+4000)/2, quota.GetTotalQuotaByUsage(usage))
+	assert.Equal(t, model.GroupPriceTypeRatio, quota.GetLogMeta(usage)["group_price_type"])
+
+	// 固定价格不再乘分组倍率
+	quota = NewQuota(newTestQuotaContext("svip", 1.5), "gpt-4o", 1000)
+	assert.Equal(t, 1000+3000, quota.GetTotalQuotaByUsage(usage))
+	assert.Equal(t, model.GroupPriceTypeFixed, quota.GetLogMeta(usage)["group_price_type"])
+}
+
+func newTestPriceWindow(t *testing.T, modelName string, from, to time.Duration, ratio float64) *model.PriceWindow {
+	now := time.Now().UTC()
+	window := &model.PriceWindow{
+		Name:      "test",
+		Model:     modelName,
+		StartTime: now.Add(from).Format("15:04"),
+		EndTime:   now.Add(to).Format("15:04"),
+		Ratio:     ratio,
+	}
+	assert.NoError(t, window.Validate())
+	return window
+}
+
+func TestNewQuotaPriceWindow(t *testing.T) {
+	active := newTestPriceWindow(t, "gpt-4o", -time.Hour, time.Hour, 0.5)
+	active.Id = 1
+	usePricing(t, &model.Pricing{
+		Prices: map[string]*model.Price{
+			"gpt-4o": {Model: "gpt-4o", Type: model.TokensPriceType, Input: 2, Output: 4},
+		},
+		GroupPrices: map[string]map[string]*model.GroupPrice{
+			"svip": {"gpt-4o": {Group: "svip", Model: "gpt-4o", Type: model.GroupPriceTypeFixed, Input: 1, Output: 3}},
+		},
+		PriceWindows: []*model.PriceWindow{
+			active,
+			newTestPriceWindow(t, "", time.Hour, 2*time.Hour, 2),
+		},
+	})
+	usage := &types.Usage{PromptTokens: 1000, CompletionTokens: 1000, TotalTokens: 2000}
+
+	// 时间段倍率在分组倍率之后再乘
+	quota := NewQuota(newTestQuotaContext("default", 1.5), "gpt-4o", 1000)
+	assert.Equal(t, (2000+4000)*3/4, quota.GetTotalQuotaByUsage(usage))
+	meta := quota.GetLogMeta(usage)
+	assert.Equal(t, 1, meta["price_window"])
+	assert.Equal(t, 0.5, meta["price_window_ratio"])
+
+	// 固定价格同样适用时间段倍率
+	quota = NewQuota(newTestQuotaContext("svip", 1.5), "gpt-4o", 1000)
+	assert.Equal(t, (1000+3000)/2, quota.GetTotalQuotaByUsage(usage))
+
+	// 未到生效时间的时间段不影响计费
+	usePricing(t, &model.Pricing{
+		Prices:       map[string]*model.Price{"claude-3": {Model: "claude-3", Type: model.TokensPriceType, Input: 2, Output: 4}},
+		PriceWindows: []*model.PriceWindow{newTestPriceWindow(t, "", time.Hour, 2*time.Hour, 2)},
+	})
+	quota = NewQuota(newTestQuotaContext("default", 1), "claude-3", 1000)
+	assert.Equal(t, 2000+4000, quota.GetTotalQuotaByUsage(usage))
+	assert.NotContains(t, quota.GetLogMeta(usage), "price_window")
+}
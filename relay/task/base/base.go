@@ -2,6 +2,7 @@ package base
 
 import (
 	"context"
+	"done-hub/common/webhook"
 	"done-hub/model"
 	"done-hub/providers/base"
 	"done-hub/relay"
@@ -21,6 +22,7 @@ type TaskBase struct {
 	OriginTaskID  string
 	BaseProvider  base.ProviderInterface
 	Response      any
+	NotifyHook    string
 }
 
 type TaskInterface interface {
@@ -47,9 +49,32 @@ func (t *TaskBase) InitTask() {
 		SubmitTime: time.Now().Unix(),
 		Status:     model.TaskStatusNotStart,
 		Progress:   0,
+		NotifyHook: t.NotifyHook,
 	}
 }
 
+// SetNotifyHook 设置任务完成后的回调地址，未指定时使用令牌设置的默认地址
+func (t *TaskBase) SetNotifyHook(hook string) error {
+	if hook == "" {
+		if setting, ok := t.C.Get("token_setting"); ok {
+			if tokenSetting, ok := setting.(*model.TokenSetting); ok {
+				hook = tokenSetting.Webhook.URL
+			}
+		}
+	}
+
+	if hook == "" {
+		return nil
+	}
+
+	if err := webhook.ValidateURL(hook); err != nil {
+		return err
+	}
+	t.NotifyHook = hook
+
+	return nil
+}
+
 func (t *TaskBase) GetModelName() string {
 	billingOriginalModel := t.C.GetBool("billing_original_model")
 	if billingOriginalModel {
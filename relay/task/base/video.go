@@ -0,0 +1,16 @@
+package base
+
+import (
+	"done-hub/model"
+	"done-hub/types"
+)
+
+// VideoTaskInterface 统一视频接口 /v1/videos 的扩展点
+// 新的视频平台实现该接口，并在 task.VideoChannelPlatforms 中登记渠道类型即可
+type VideoTaskInterface interface {
+	TaskInterface
+	// InitVideo 将统一请求转换为平台请求，并补全默认的时长和尺寸
+	InitVideo(request *types.VideoRequest) *TaskError
+	// GetVideoURL 返回已完成任务的视频地址
+	GetVideoURL(task *model.Task) (string, error)
+}
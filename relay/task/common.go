@@ -39,6 +39,32 @@ func GetTaskAdaptorByPlatform(platform string) (base.TaskInterface, error) {
 	return GetTaskAdaptor(relayType, nil)
 }
 
+// VideoChannelPlatforms 支持统一视频接口的渠道类型及其任务平台
+var VideoChannelPlatforms = map[int]string{
+	config.ChannelTypeKling: model.TaskPlatformKling,
+}
+
+func GetVideoTaskAdaptorByPlatform(platform string, c *gin.Context) (base.VideoTaskInterface, error) {
+	relayType := config.RelayModeUnknown
+
+	switch platform {
+	case model.TaskPlatformKling:
+		relayType = config.RelayModeKling
+	}
+
+	taskAdaptor, err := GetTaskAdaptor(relayType, c)
+	if err != nil {
+		return nil, err
+	}
+
+	videoAdaptor, ok := taskAdaptor.(base.VideoTaskInterface)
+	if !ok {
+		return nil, errors.New("adaptor not supported videos")
+	}
+
+	return videoAdaptor, nil
+}
+
 func getTaskBase(c *gin.Context, platform string) base.TaskBase {
 	return base.TaskBase{
 		Platform: platform,
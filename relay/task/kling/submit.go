@@ -4,6 +4,8 @@ import (
 	"context"
 	"done-hub/common"
 	"done-hub/common/logger"
+	"done-hub/common/media"
+	"done-hub/common/webhook"
 	"done-hub/model"
 	"done-hub/providers"
 	KlingProvider "done-hub/providers/kling"
@@ -26,6 +28,8 @@ type KlingTask struct {
 
 	Class  string
 	Action string
+	// 通过统一视频接口提交，上游模型名取自渠道映射后的模型
+	Video bool
 }
 
 func (t *KlingTask) HandleError(err *base.TaskError) {
@@ -43,6 +47,14 @@ func (t *KlingTask) Init() *base.TaskError {
 		return base.StringTaskError(http.StatusBadRequest, "invalid_request", err.Error(), true)
 	}
 
+	// 回调由网关投递，不传给上游
+	callbackURL, _ := t.Request.CallbackURL.(string)
+	err = t.SetNotifyHook(callbackURL)
+	if err != nil {
+		return base.StringTaskError(http.StatusBadRequest, "invalid_request", err.Error(), true)
+	}
+	t.Request.CallbackURL = nil
+
 	err = t.HandleOriginTaskID()
 	if err != nil {
 		return base.StringTaskError(http.StatusInternalServerError, "get_origin_task_failed", err.Error(), true)
@@ -66,6 +78,10 @@ func (t *KlingTask) SetProvider() *base.TaskError {
 	t.Provider = KlingProvider
 	t.BaseProvider = provider
 
+	if t.Video {
+		t.Request.ModelName = t.ModelName
+	}
+
 	return nil
 }
 
@@ -194,6 +210,7 @@ func updateKlingTaskAll(ctx context.Context, channelId int, taskIds []string, ta
 		if !checkTaskNeedUpdate(task, responseItem) {
 			continue
 		}
+		oldStatus := task.Status
 
 		task.Status = lo.If(model.TaskStatus(responseItem.Status) != "", model.TaskStatus(responseItem.Status)).Else(task.Status)
 		task.FailReason = lo.If(responseItem.FailReason != "", responseItem.FailReason).Else(task.FailReason)
@@ -223,6 +240,12 @@ func updateKlingTaskAll(ctx context.Context, channelId int, taskIds []string, ta
 		err := task.Update()
 		if err != nil {
 			logger.SysError("UpdateTask task error: " + err.Error())
+			continue
+		}
+
+		if !oldStatus.IsFinished() && task.Status.IsFinished() {
+			media.PersistTask(task)
+			webhook.TaskFinished(task)
 		}
 	}
 
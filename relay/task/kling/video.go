@@ -0,0 +1,82 @@
+package kling
+
+import (
+	"done-hub/model"
+	KlingProvider "done-hub/providers/kling"
+	"done-hub/relay/task/base"
+	"done-hub/types"
+	"errors"
+	"net/http"
+)
+
+var klingVideoModes = map[string]string{
+	"720p":  "std",
+	"1080p": "pro",
+}
+
+func (t *KlingTask) InitVideo(request *types.VideoRequest) *base.TaskError {
+	if request.Seconds == "" {
+		request.Seconds = "5"
+	}
+	if request.Seconds != "5" && request.Seconds != "10" {
+		return base.StringTaskError(http.StatusBadRequest, "invalid_request", "seconds is not 5 or 10", true)
+	}
+
+	if request.Size == "" {
+		request.Size = "1280x720"
+	}
+	mode, ok := klingVideoModes[request.GetResolution()]
+	if !ok {
+		return base.StringTaskError(http.StatusBadRequest, "invalid_request", "size resolution must be 720p or 1080p", true)
+	}
+	width, height, _ := request.GetWidthAndHeight()
+	aspectRatio := getKlingAspectRatio(width, height)
+	if aspectRatio == "" {
+		return base.StringTaskError(http.StatusBadRequest, "invalid_request", "size aspect ratio must be 16:9, 9:16 or 1:1", true)
+	}
+
+	if err := t.SetNotifyHook(request.CallbackURL); err != nil {
+		return base.StringTaskError(http.StatusBadRequest, "invalid_request", err.Error(), true)
+	}
+
+	t.Request = &KlingProvider.KlingTask{
+		Prompt:         request.Prompt,
+		NegativePrompt: request.NegativePrompt,
+		Image:          request.InputReference,
+		Mode:           mode,
+		Duration:       request.Seconds,
+		AspectRatio:    aspectRatio,
+	}
+
+	t.Class = "videos"
+	t.Action = "text2video"
+	if request.InputReference != "" {
+		t.Action = "image2video"
+	}
+	t.OriginalModel = request.Model
+	t.Video = true
+
+	return nil
+}
+
+func (t *KlingTask) GetVideoURL(task *model.Task) (string, error) {
+	data := TaskModel2Dto(task)
+	if data.Data == nil || data.Data.TaskResult == nil || len(data.Data.TaskResult.Videos) == 0 {
+		return "", errors.New("video not found")
+	}
+
+	return data.Data.TaskResult.Videos[0].URL, nil
+}
+
+func getKlingAspectRatio(width, height int) string {
+	switch {
+	case width*9 == height*16:
+		return "16:9"
+	case width*16 == height*9:
+		return "9:16"
+	case width == height:
+		return "1:1"
+	}
+
+	return ""
+}
@@ -0,0 +1,55 @@
+package kling
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"done-hub/relay/task/base"
+	"done-hub/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestKlingTask() *KlingTask {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	return &KlingTask{TaskBase: base.TaskBase{C: c}}
+}
+
+func TestInitVideo(t *testing.T) {
+	task := newTestKlingTask()
+	request := &types.VideoRequest{Model: "kling-v1", Prompt: "a cat"}
+	assert.Nil(t, task.InitVideo(request))
+
+	// 未指定时默认 5 秒 720p 横屏
+	assert.Equal(t, "5", request.Seconds)
+	assert.Equal(t, "1280x720", request.Size)
+	assert.Equal(t, "std", task.Request.Mode)
+	assert.Equal(t, "16:9", task.Request.AspectRatio)
+	assert.Equal(t, "text2video", task.Action)
+	assert.Equal(t, "videos", task.Class)
+	assert.True(t, task.Video)
+
+	task = newTestKlingTask()
+	request = &types.VideoRequest{Model: "kling-v1", Seconds: "10", Size: "1080x1920", InputReference: "https://example.com/a.png"}
+	assert.Nil(t, task.InitVideo(request))
+	assert.Equal(t, "pro", task.Request.Mode)
+	assert.Equal(t, "9:16", task.Request.AspectRatio)
+	assert.Equal(t, "10", task.Request.Duration)
+	assert.Equal(t, "image2video", task.Action)
+}
+
+func TestInitVideoInvalid(t *testing.T) {
+	for _, request := range []*types.VideoRequest{
+		{Model: "kling-v1", Seconds: "8"},
+		{Model: "kling-v1", Size: "640x480"},
+		{Model: "kling-v1", Size: "1280x960"},
+		{Model: "kling-v1", Size: "1280"},
+		{Model: "kling-v1", CallbackURL: "http://127.0.0.1/hook"},
+	} {
+		taskErr := newTestKlingTask().InitVideo(request)
+		if assert.NotNil(t, taskErr, request) {
+			assert.Equal(t, 400, taskErr.StatusCode)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"done-hub/common"
 	"done-hub/common/logger"
+	"done-hub/common/media"
+	"done-hub/common/webhook"
 	"done-hub/metrics"
 	"done-hub/model"
 	"done-hub/providers"
@@ -43,6 +45,13 @@ func (t *SunoTask) Init() *base.TaskError {
 		return base.StringTaskError(http.StatusBadRequest, "invalid_request", err.Error(), true)
 	}
 
+	// 回调由网关投递，不传给上游
+	err = t.SetNotifyHook(t.Request.NotifyHook)
+	if err != nil {
+		return base.StringTaskError(http.StatusBadRequest, "invalid_request", err.Error(), true)
+	}
+	t.Request.NotifyHook = ""
+
 	err = t.HandleOriginTaskID()
 	if err != nil {
 		return base.StringTaskError(http.StatusInternalServerError, "get_origin_task_failed", err.Error(), true)
@@ -211,6 +220,7 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 		if !checkTaskNeedUpdate(task, responseItem) {
 			continue
 		}
+		oldStatus := task.Status
 
 		task.Status = lo.If(model.TaskStatus(responseItem.Status) != "", model.TaskStatus(responseItem.Status)).Else(task.Status)
 		task.FailReason = lo.If(responseItem.FailReason != "", responseItem.FailReason).Else(task.FailReason)
@@ -240,6 +250,12 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 		err := task.Update()
 		if err != nil {
 			logger.SysError("UpdateTask task error: " + err.Error())
+			continue
+		}
+
+		if !oldStatus.IsFinished() && task.Status.IsFinished() {
+			media.PersistTask(task)
+			webhook.TaskFinished(task)
 		}
 	}
 	return nil
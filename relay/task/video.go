@@ -0,0 +1,268 @@
+package task
+
+import (
+	"done-hub/common"
+	"done-hub/common/logger"
+	"done-hub/common/requester"
+	"done-hub/common/utils"
+	"done-hub/metrics"
+	"done-hub/model"
+	"done-hub/relay"
+	"done-hub/relay/relay_util"
+	"done-hub/relay/task/base"
+	"done-hub/types"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+)
+
+func RelayVideoSubmit(c *gin.Context) {
+	request := &types.VideoRequest{}
+	if err := common.UnmarshalBodyReusable(c, request); err != nil {
+		responseVideoError(c, base.StringTaskError(http.StatusBadRequest, "invalid_request", err.Error(), true))
+		return
+	}
+
+	videoAdaptor, taskErr := getVideoTaskAdaptor(c, request.Model)
+	if taskErr != nil {
+		responseVideoError(c, taskErr)
+		return
+	}
+
+	if taskErr = videoAdaptor.InitVideo(request); taskErr != nil {
+		responseVideoError(c, taskErr)
+		return
+	}
+
+	if taskErr = videoAdaptor.SetProvider(); taskErr != nil {
+		responseVideoError(c, taskErr)
+		return
+	}
+
+	// 视频按秒计费，不同分辨率的倍率在价格的 extra_ratios 中配置，如 {"1080p": 1.5}
+	modelName := videoAdaptor.GetModelName()
+	resolution := request.GetResolution()
+	resolutionRatio := model.PricingInstance.GetPrice(modelName).GetExtraRatio(resolution)
+
+	quotaInstance := relay_util.NewQuota(c, modelName, 1000)
+	quotaInstance.SetNoCompletion()
+	quotaInstance.SetTimes(float64(request.GetSeconds()) * resolutionRatio)
+	quotaInstance.AddLogMeta("seconds", request.GetSeconds())
+	quotaInstance.AddLogMeta("resolution", resolution)
+	if errWithOA := quotaInstance.PreQuotaConsumption(); errWithOA != nil {
+		responseVideoError(c, base.OpenAIErrToTaskErr(errWithOA))
+		return
+	}
+
+	if taskErr = videoAdaptor.Relay(); taskErr != nil {
+		quotaInstance.Undo(c)
+		responseVideoError(c, taskErr)
+		return
+	}
+
+	task := videoAdaptor.GetTask()
+	task.Properties, _ = json.Marshal(&types.VideoProperties{
+		Model:   request.Model,
+		Seconds: request.Seconds,
+		Size:    request.Size,
+	})
+	CompletedTask(quotaInstance, videoAdaptor, c)
+	metrics.RecordProvider(c, 200)
+
+	c.JSON(http.StatusOK, TaskModel2Video(task))
+}
+
+func GetVideo(c *gin.Context) {
+	task, taskErr := getUserVideoTask(c)
+	if taskErr != nil {
+		responseVideoError(c, taskErr)
+		return
+	}
+
+	c.JSON(http.StatusOK, TaskModel2Video(task))
+}
+
+func ListVideos(c *gin.Context) {
+	limit := min(max(utils.String2Int(c.DefaultQuery("limit", "20")), 1), 100)
+	userId := c.GetInt("id")
+
+	var afterId int64
+	if after := c.Query("after"); after != "" {
+		task, err := model.GetUserTaskByPlatforms(getVideoPlatforms(), userId, after)
+		if err == nil && task != nil {
+			afterId = task.ID
+		}
+	}
+
+	tasks, err := model.GetUserTasksByPlatforms(getVideoPlatforms(), userId, afterId, limit+1)
+	if err != nil {
+		responseVideoError(c, base.StringTaskError(http.StatusInternalServerError, "get_task_failed", err.Error(), true))
+		return
+	}
+
+	list := &types.VideoList{
+		Object:  "list",
+		Data:    make([]*types.VideoObject, 0, len(tasks)),
+		HasMore: len(tasks) > limit,
+	}
+	if list.HasMore {
+		tasks = tasks[:limit]
+	}
+
+	for _, task := range tasks {
+		list.Data = append(list.Data, TaskModel2Video(task))
+	}
+	if len(list.Data) > 0 {
+		list.FirstId = list.Data[0].Id
+		list.LastId = list.Data[len(list.Data)-1].Id
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// GetVideoContent 代理下载视频内容，不向用户暴露上游地址
+func GetVideoContent(c *gin.Context) {
+	task, taskErr := getUserVideoTask(c)
+	if taskErr != nil {
+		responseVideoError(c, taskErr)
+		return
+	}
+
+	if task.Status != model.TaskStatusSuccess {
+		responseVideoError(c, base.StringTaskError(http.StatusBadRequest, "video_not_ready", "video is not completed", true))
+		return
+	}
+
+	videoAdaptor, err := GetVideoTaskAdaptorByPlatform(task.Platform, c)
+	if err != nil {
+		responseVideoError(c, base.StringTaskError(http.StatusInternalServerError, "adaptor_not_found", err.Error(), true))
+		return
+	}
+
+	videoURL, err := videoAdaptor.GetVideoURL(task)
+	if err != nil {
+		responseVideoError(c, base.StringTaskError(http.StatusNotFound, "video_not_found", err.Error(), true))
+		return
+	}
+
+	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, videoURL, nil)
+	if err != nil {
+		responseVideoError(c, base.StringTaskError(http.StatusInternalServerError, "new_request_failed", err.Error(), true))
+		return
+	}
+
+	resp, err := requester.HTTPClient.Do(req)
+	if err != nil {
+		responseVideoError(c, base.StringTaskError(http.StatusBadGateway, "download_video_failed", err.Error(), true))
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		responseVideoError(c, base.StringTaskError(http.StatusBadGateway, "download_video_failed", fmt.Sprintf("status code: %d", resp.StatusCode), true))
+		return
+	}
+
+	c.Writer.Header().Set("Content-Type", lo.If(resp.Header.Get("Content-Type") != "", resp.Header.Get("Content-Type")).Else("video/mp4"))
+	if resp.ContentLength > 0 {
+		c.Writer.Header().Set("Content-Length", fmt.Sprintf("%d", resp.ContentLength))
+	}
+	c.Writer.WriteHeader(http.StatusOK)
+
+	if _, err = io.Copy(c.Writer, resp.Body); err != nil {
+		logger.LogError(c.Request.Context(), "write video content failed: "+err.Error())
+	}
+}
+
+func TaskModel2Video(task *model.Task) *types.VideoObject {
+	properties := &types.VideoProperties{}
+	json.Unmarshal(task.Properties, properties)
+
+	video := &types.VideoObject{
+		Id:        task.TaskID,
+		Object:    "video",
+		Model:     properties.Model,
+		Progress:  task.Progress,
+		CreatedAt: task.CreatedAt,
+		Seconds:   properties.Seconds,
+		Size:      properties.Size,
+	}
+
+	switch task.Status {
+	case model.TaskStatusSuccess:
+		video.Status = types.VideoStatusCompleted
+		video.CompletedAt = task.FinishTime
+	case model.TaskStatusFailure:
+		video.Status = types.VideoStatusFailed
+		video.Error = &types.VideoError{
+			Code:    "video_generation_failed",
+			Message: task.FailReason,
+		}
+	case model.TaskStatusInProgress:
+		video.Status = types.VideoStatusInProgress
+	default:
+		video.Status = types.VideoStatusQueued
+	}
+
+	return video
+}
+
+// 先按模型选出渠道，再根据渠道类型确定对应平台的适配器
+func getVideoTaskAdaptor(c *gin.Context, modelName string) (base.VideoTaskInterface, *base.TaskError) {
+	c.Set("allow_channel_type", lo.Keys(VideoChannelPlatforms))
+
+	provider, _, err := relay.GetProvider(c, modelName)
+	if err != nil {
+		return nil, base.StringTaskError(http.StatusServiceUnavailable, "provider_not_found", err.Error(), true)
+	}
+
+	channel := provider.GetChannel()
+	videoAdaptor, err := GetVideoTaskAdaptorByPlatform(VideoChannelPlatforms[channel.Type], c)
+	if err != nil {
+		return nil, base.StringTaskError(http.StatusServiceUnavailable, "adaptor_not_found", err.Error(), true)
+	}
+
+	// 适配器初始化后按同一渠道重新获取供应商
+	c.Set("specific_channel_id", channel.Id)
+	c.Set("specific_channel_id_ignore", false)
+
+	return videoAdaptor, nil
+}
+
+func getUserVideoTask(c *gin.Context) (*model.Task, *base.TaskError) {
+	task, err := model.GetUserTaskByPlatforms(getVideoPlatforms(), c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		return nil, base.StringTaskError(http.StatusInternalServerError, "get_task_failed", err.Error(), true)
+	}
+
+	if task == nil {
+		return nil, base.StringTaskError(http.StatusNotFound, "video_not_found", "video not found", true)
+	}
+
+	return task, nil
+}
+
+func getVideoPlatforms() []string {
+	return lo.Uniq(lo.Values(VideoChannelPlatforms))
+}
+
+func responseVideoError(c *gin.Context, taskErr *base.TaskError) {
+	errWithCode := &types.OpenAIErrorWithStatusCode{
+		OpenAIError: types.OpenAIError{
+			Code:    taskErr.Code,
+			Message: taskErr.Message,
+			Type:    "one_hub_error",
+		},
+		StatusCode: taskErr.StatusCode,
+		LocalError: taskErr.LocalError,
+	}
+
+	newErr := relay.FilterOpenAIErr(c, errWithCode)
+	c.JSON(newErr.StatusCode, types.OpenAIErrorResponse{
+		Error: newErr.OpenAIError,
+	})
+}
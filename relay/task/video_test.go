@@ -0,0 +1,45 @@
+package task
+
+import (
+	"encoding/json"
+	"testing"
+
+	"done-hub/model"
+	"done-hub/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskModel2Video(t *testing.T) {
+	properties, _ := json.Marshal(&types.VideoProperties{Model: "kling-v1", Seconds: "5", Size: "1280x720"})
+	task := &model.Task{
+		TaskID:     "task-1",
+		Status:     model.TaskStatusSuccess,
+		Progress:   100,
+		CreatedAt:  100,
+		FinishTime: 200,
+		Properties: properties,
+	}
+
+	video := TaskModel2Video(task)
+	assert.Equal(t, "task-1", video.Id)
+	assert.Equal(t, "video", video.Object)
+	assert.Equal(t, "kling-v1", video.Model)
+	assert.Equal(t, types.VideoStatusCompleted, video.Status)
+	assert.EqualValues(t, 200, video.CompletedAt)
+	assert.Equal(t, "5", video.Seconds)
+	assert.Equal(t, "1280x720", video.Size)
+	assert.Nil(t, video.Error)
+
+	task.Status = model.TaskStatusFailure
+	task.FailReason = "content rejected"
+	video = TaskModel2Video(task)
+	assert.Equal(t, types.VideoStatusFailed, video.Status)
+	assert.Zero(t, video.CompletedAt)
+	assert.Equal(t, "content rejected", video.Error.Message)
+
+	task.Status = model.TaskStatusInProgress
+	assert.Equal(t, types.VideoStatusInProgress, TaskModel2Video(task).Status)
+	task.Status = model.TaskStatusSubmitted
+	assert.Equal(t, types.VideoStatusQueued, TaskModel2Video(task).Status)
+}
@@ -21,9 +21,8 @@ func SetApiRouter(router *gin.Engine) {
 	apiRouter.GET("/metrics", middleware.MetricsWithBasicAuth(), gin.WrapH(promhttp.Handler()))
 
 	systemInfo := apiRouter.Group("/system_info")
-	systemInfo.Use(middleware.RootAuth())
 	{
-		systemInfo.POST("/log", controller.SystemLog)
+		systemInfo.POST("/log", middleware.PermissionAuth("options:write"), controller.SystemLog)
 	}
 
 	apiRouter.POST("/telegram/:token", middleware.Telegram(), controller.TelegramBotWebHook)
@@ -58,6 +57,9 @@ func SetApiRouter(router *gin.Engine) {
 			userRoute.POST("/register", middleware.CriticalRateLimit(), middleware.TurnstileCheck(), controller.Register)
 			userRoute.POST("/login", middleware.CriticalRateLimit(), middleware.SessionSecurity(), controller.Login)
 			userRoute.GET("/logout", middleware.SessionSecurity(), controller.Logout)
+			userRoute.POST("/login/2fa", middleware.CriticalRateLimit(), middleware.SessionSecurity(), controller.LoginTwoFactor)
+			userRoute.POST("/login/passkey/begin", middleware.CriticalRateLimit(), middleware.SessionSecurity(), controller.BeginPasskeyLogin)
+			userRoute.POST("/login/passkey/finish", middleware.CriticalRateLimit(), middleware.SessionSecurity(), controller.FinishPasskeyLogin)
 
 			selfRoute := userRoute.Group("/")
 			selfRoute.Use(middleware.UserAuth())
@@ -65,6 +67,7 @@ func SetApiRouter(router *gin.Engine) {
 			{
 				selfRoute.GET("/dashboard", controller.GetUserDashboard)
 				selfRoute.GET("/dashboard/rate", controller.GetRateRealtime)
+				selfRoute.GET("/dashboard/end_users", controller.GetUserEndUserStatistics)
 				selfRoute.GET("/dashboard/uptimekuma/status-page", controller.UptimeKumaStatusPage)
 				selfRoute.GET("/dashboard/uptimekuma/status-page/heartbeat", controller.UptimeKumaStatusPageHeartbeat)
 				selfRoute.GET("/invoice", controller.GetUserInvoice)
@@ -78,90 +81,129 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.GET("/payment", controller.GetUserPaymentList)
 				selfRoute.POST("/order", controller.CreateOrder)
 				selfRoute.GET("/order/status", controller.CheckOrderStatus)
+				selfRoute.GET("/subscription/plans", controller.GetSubscriptionPlans)
+				selfRoute.GET("/subscription", controller.GetSelfSubscription)
+				selfRoute.POST("/subscription/order", controller.CreateSubscriptionOrder)
+				selfRoute.GET("/2fa", controller.GetTwoFactorStatus)
+				selfRoute.POST("/2fa/setup", controller.SetupTwoFactor)
+				selfRoute.POST("/2fa/enable", controller.EnableTwoFactor)
+				selfRoute.POST("/2fa/disable", controller.DisableTwoFactor)
+				selfRoute.POST("/2fa/recovery_codes", controller.RegenerateRecoveryCodes)
+				selfRoute.POST("/passkey/register/begin", controller.BeginPasskeyRegistration)
+				selfRoute.POST("/passkey/register/finish", controller.FinishPasskeyRegistration)
+				selfRoute.DELETE("/passkey/:id", controller.DeletePasskey)
+				selfRoute.GET("/sessions", controller.GetSelfSessions)
+				selfRoute.DELETE("/sessions", controller.RevokeOtherSelfSessions)
+				selfRoute.DELETE("/sessions/:id", controller.RevokeSelfSession)
+				selfRoute.GET("/quota_lots", controller.GetSelfQuotaLots)
+				selfRoute.GET("/affiliate", controller.GetSelfAffiliate)
+				selfRoute.GET("/affiliate/commissions", controller.GetSelfAffiliateCommissions)
+				selfRoute.GET("/affiliate/withdrawals", controller.GetSelfAffiliateWithdrawals)
+				selfRoute.POST("/affiliate/withdrawals", controller.CreateAffiliateWithdrawal)
+				selfRoute.GET("/organization", controller.GetSelfOrganizations)
+				selfRoute.POST("/organization", controller.CreateOrganization)
+				selfRoute.GET("/organization/invitations", controller.GetSelfOrganizationInvitations)
+				selfRoute.POST("/organization/invitations/:invitation_id/accept", controller.AcceptOrganizationInvitation)
+				selfRoute.DELETE("/organization/invitations/:invitation_id", controller.DeclineOrganizationInvitation)
+				selfRoute.GET("/organization/:id", controller.GetSelfOrganization)
+				selfRoute.PUT("/organization/:id", controller.UpdateOrganization)
+				selfRoute.DELETE("/organization/:id", controller.DeleteOrganization)
+				selfRoute.GET("/organization/:id/members", controller.GetOrganizationMembers)
+				selfRoute.GET("/organization/:id/invitations", controller.GetOrganizationInvitations)
+				selfRoute.POST("/organization/:id/invitations", controller.InviteOrganizationMember)
+				selfRoute.DELETE("/organization/:id/invitations/:invitation_id", controller.RevokeOrganizationInvitation)
+				selfRoute.PUT("/organization/:id/members/:user_id", controller.UpdateOrganizationMember)
+				selfRoute.DELETE("/organization/:id/members/:user_id", controller.RemoveOrganizationMember)
+				selfRoute.POST("/organization/:id/fund", controller.FundOrganization)
+				selfRoute.GET("/organization/:id/tokens", controller.GetOrganizationTokens)
+				selfRoute.DELETE("/organization/:id/tokens/:token_id", controller.DeleteOrganizationToken)
+				selfRoute.GET("/organization/:id/logs", controller.GetOrganizationLogs)
+				selfRoute.GET("/organization/:id/statistics", controller.GetOrganizationStatistics)
+				selfRoute.GET("/organization/:id/invoice", controller.GetOrganizationInvoice)
+				selfRoute.GET("/organization/:id/invoice/detail", controller.GetOrganizationInvoiceDetail)
 			}
 
 			adminRoute := userRoute.Group("/")
-			adminRoute.Use(middleware.AdminAuth())
 			{
-				adminRoute.GET("/", controller.GetUsersList)
-				adminRoute.GET("/:id", controller.GetUser)
-				adminRoute.POST("/", controller.CreateUser)
-				adminRoute.POST("/manage", controller.ManageUser)
-				adminRoute.POST("/quota/:id", controller.ChangeUserQuota)
-				adminRoute.PUT("/", controller.UpdateUser)
-				adminRoute.DELETE("/:id", controller.DeleteUser)
+				adminRoute.GET("/", middleware.PermissionAuth("users:read"), controller.GetUsersList)
+				adminRoute.GET("/:id", middleware.PermissionAuth("users:read"), controller.GetUser)
+				adminRoute.POST("/", middleware.PermissionAuth("users:write"), controller.CreateUser)
+				adminRoute.POST("/manage", middleware.PermissionAuth("users:write"), controller.ManageUser)
+				adminRoute.POST("/quota/:id", middleware.PermissionAuth("users:write"), controller.ChangeUserQuota)
+				adminRoute.PUT("/", middleware.PermissionAuth("users:write"), controller.UpdateUser)
+				adminRoute.DELETE("/:id", middleware.PermissionAuth("users:write"), controller.DeleteUser)
+				adminRoute.PUT("/role", middleware.PermissionAuth("roles:write"), controller.SetUserRole)
+				adminRoute.DELETE("/2fa/:id", middleware.PermissionAuth("users:write"), controller.ResetUserTwoFactor)
+				adminRoute.GET("/:id/sessions", middleware.PermissionAuth("users:read"), controller.GetUserSessions)
+				adminRoute.DELETE("/:id/sessions", middleware.PermissionAuth("users:write"), controller.RevokeUserSessions)
+				adminRoute.GET("/:id/quota_lots", middleware.PermissionAuth("users:read"), controller.GetUserQuotaLots)
 			}
 		}
 		optionRoute := apiRouter.Group("/option")
-		optionRoute.Use(middleware.RootAuth())
-		{
-			optionRoute.GET("/", controller.GetOptions)
-			optionRoute.PUT("/", controller.UpdateOption)
-			optionRoute.GET("/telegram", controller.GetTelegramMenuList)
-			optionRoute.POST("/telegram", controller.AddOrUpdateTelegramMenu)
-			optionRoute.GET("/telegram/status", controller.GetTelegramBotStatus)
-			optionRoute.PUT("/telegram/reload", controller.ReloadTelegramBot)
-			optionRoute.GET("/telegram/:id", controller.GetTelegramMenu)
-			optionRoute.DELETE("/telegram/:id", controller.DeleteTelegramMenu)
-			optionRoute.GET("/safe_tools", controller.GetSafeTools)
-			optionRoute.POST("/invoice/gen/:time", controller.GenInvoice)
-			optionRoute.POST("/invoice/update/:time", controller.UpdateInvoice)
-			optionRoute.POST("/system_info/log", controller.SystemLog)
+		{
+			optionRoute.GET("/", middleware.PermissionAuth("options:read"), controller.GetOptions)
+			optionRoute.PUT("/", middleware.PermissionAuth("options:write"), controller.UpdateOption)
+			optionRoute.GET("/telegram", middleware.PermissionAuth("options:read"), controller.GetTelegramMenuList)
+			optionRoute.POST("/telegram", middleware.PermissionAuth("options:write"), controller.AddOrUpdateTelegramMenu)
+			optionRoute.GET("/telegram/status", middleware.PermissionAuth("options:read"), controller.GetTelegramBotStatus)
+			optionRoute.PUT("/telegram/reload", middleware.PermissionAuth("options:write"), controller.ReloadTelegramBot)
+			optionRoute.GET("/telegram/:id", middleware.PermissionAuth("options:read"), controller.GetTelegramMenu)
+			optionRoute.DELETE("/telegram/:id", middleware.PermissionAuth("options:write"), controller.DeleteTelegramMenu)
+			optionRoute.GET("/safe_tools", middleware.PermissionAuth("options:read"), controller.GetSafeTools)
+			optionRoute.POST("/invoice/gen/:time", middleware.PermissionAuth("options:write"), controller.GenInvoice)
+			optionRoute.POST("/invoice/update/:time", middleware.PermissionAuth("options:write"), controller.UpdateInvoice)
+			optionRoute.POST("/system_info/log", middleware.PermissionAuth("options:write"), controller.SystemLog)
 		}
 
 		modelOwnedByRoute := apiRouter.Group("/model_ownedby")
 		modelOwnedByRoute.GET("/", controller.GetAllModelOwnedBy)
-		modelOwnedByRoute.Use(middleware.AdminAuth())
 		{
-			modelOwnedByRoute.GET("/:id", controller.GetModelOwnedBy)
-			modelOwnedByRoute.POST("/", controller.CreateModelOwnedBy)
-			modelOwnedByRoute.PUT("/", controller.UpdateModelOwnedBy)
-			modelOwnedByRoute.DELETE("/:id", controller.DeleteModelOwnedBy)
+			modelOwnedByRoute.GET("/:id", middleware.PermissionAuth("prices:read"), controller.GetModelOwnedBy)
+			modelOwnedByRoute.POST("/", middleware.PermissionAuth("prices:write"), controller.CreateModelOwnedBy)
+			modelOwnedByRoute.PUT("/", middleware.PermissionAuth("prices:write"), controller.UpdateModelOwnedBy)
+			modelOwnedByRoute.DELETE("/:id", middleware.PermissionAuth("prices:write"), controller.DeleteModelOwnedBy)
 		}
 
 		userGroup := apiRouter.Group("/user_group")
-		userGroup.Use(middleware.AdminAuth())
 		{
-			userGroup.GET("/", controller.GetUserGroups)
-			userGroup.GET("/:id", controller.GetUserGroupById)
-			userGroup.POST("/", controller.AddUserGroup)
-			userGroup.PUT("/enable/:id", controller.ChangeUserGroupEnable)
-			userGroup.PUT("/", controller.UpdateUserGroup)
-			userGroup.DELETE("/:id", controller.DeleteUserGroup)
+			userGroup.GET("/", middleware.PermissionAuth("groups:read"), controller.GetUserGroups)
+			userGroup.GET("/:id", middleware.PermissionAuth("groups:read"), controller.GetUserGroupById)
+			userGroup.POST("/", middleware.PermissionAuth("groups:write"), controller.AddUserGroup)
+			userGroup.PUT("/enable/:id", middleware.PermissionAuth("groups:write"), controller.ChangeUserGroupEnable)
+			userGroup.PUT("/", middleware.PermissionAuth("groups:write"), controller.UpdateUserGroup)
+			userGroup.DELETE("/:id", middleware.PermissionAuth("groups:write"), controller.DeleteUserGroup)
 
 		}
 		channelRoute := apiRouter.Group("/channel")
-		channelRoute.Use(middleware.AdminAuth())
-		{
-			channelRoute.GET("/", controller.GetChannelsList)
-			channelRoute.GET("/models", relay.ListModelsForAdmin)
-			channelRoute.POST("/provider_models_list", controller.GetModelList)
-			channelRoute.GET("/:id", controller.GetChannel)
-			channelRoute.GET("/test", controller.TestAllChannels)
-			channelRoute.GET("/test/:id", controller.TestChannel)
-			channelRoute.GET("/update_balance", controller.UpdateAllChannelsBalance)
-			channelRoute.GET("/update_balance/:id", controller.UpdateChannelBalance)
-			channelRoute.POST("/", controller.AddChannel)
-			channelRoute.PUT("/", controller.UpdateChannel)
-			channelRoute.PUT("/batch/azure_api", controller.BatchUpdateChannelsAzureApi)
-			channelRoute.PUT("/batch/del_model", controller.BatchDelModelChannels)
-			channelRoute.PUT("/batch/add_user_group", controller.BatchAddUserGroupToChannels)
-			channelRoute.DELETE("/disabled", controller.DeleteDisabledChannel)
-			channelRoute.DELETE("/:id/tag", controller.DeleteChannelTag)
-			channelRoute.DELETE("/:id", controller.DeleteChannel)
-			channelRoute.DELETE("/batch", controller.BatchDeleteChannel)
+		{
+			channelRoute.GET("/", middleware.PermissionAuth("channels:read"), controller.GetChannelsList)
+			channelRoute.GET("/models", middleware.PermissionAuth("channels:read"), relay.ListModelsForAdmin)
+			channelRoute.POST("/provider_models_list", middleware.PermissionAuth("channels:write"), controller.GetModelList)
+			channelRoute.GET("/:id", middleware.PermissionAuth("channels:read"), controller.GetChannel)
+			channelRoute.GET("/test", middleware.PermissionAuth("channels:write"), controller.TestAllChannels)
+			channelRoute.GET("/test/:id", middleware.PermissionAuth("channels:write"), controller.TestChannel)
+			channelRoute.GET("/update_balance", middleware.PermissionAuth("channels:write"), controller.UpdateAllChannelsBalance)
+			channelRoute.GET("/update_balance/:id", middleware.PermissionAuth("channels:write"), controller.UpdateChannelBalance)
+			channelRoute.POST("/", middleware.PermissionAuth("channels:write"), controller.AddChannel)
+			channelRoute.PUT("/", middleware.PermissionAuth("channels:write"), controller.UpdateChannel)
+			channelRoute.PUT("/batch/azure_api", middleware.PermissionAuth("channels:write"), controller.BatchUpdateChannelsAzureApi)
+			channelRoute.PUT("/batch/del_model", middleware.PermissionAuth("channels:write"), controller.BatchDelModelChannels)
+			channelRoute.PUT("/batch/add_user_group", middleware.PermissionAuth("channels:write"), controller.BatchAddUserGroupToChannels)
+			channelRoute.DELETE("/disabled", middleware.PermissionAuth("channels:write"), controller.DeleteDisabledChannel)
+			channelRoute.DELETE("/:id/tag", middleware.PermissionAuth("channels:write"), controller.DeleteChannelTag)
+			channelRoute.DELETE("/:id", middleware.PermissionAuth("channels:write"), controller.DeleteChannel)
+			channelRoute.DELETE("/batch", middleware.PermissionAuth("channels:write"), controller.BatchDeleteChannel)
 		}
 		channelTagRoute := apiRouter.Group("/channel_tag")
-		channelTagRoute.Use(middleware.AdminAuth())
 		{
-			channelTagRoute.GET("/_all", controller.GetChannelsTagAllList)
-			channelTagRoute.GET("/:tag/list", controller.GetChannelsTagList)
-			channelTagRoute.GET("/:tag", controller.GetChannelsTag)
-			channelTagRoute.PUT("/:tag", controller.UpdateChannelsTag)
-			channelTagRoute.DELETE("/:tag", controller.DeleteChannelsTag)
-			channelTagRoute.DELETE("/:tag/disabled", controller.DeleteDisabledChannelsTag)
-			channelTagRoute.PUT("/:tag/priority", controller.UpdateChannelsTagPriority)
-			channelTagRoute.PUT("/:tag/status/:status", controller.ChangeChannelsTagStatus)
+			channelTagRoute.GET("/_all", middleware.PermissionAuth("channels:read"), controller.GetChannelsTagAllList)
+			channelTagRoute.GET("/:tag/list", middleware.PermissionAuth("channels:read"), controller.GetChannelsTagList)
+			channelTagRoute.GET("/:tag", middleware.PermissionAuth("channels:read"), controller.GetChannelsTag)
+			channelTagRoute.PUT("/:tag", middleware.PermissionAuth("channels:write"), controller.UpdateChannelsTag)
+			channelTagRoute.DELETE("/:tag", middleware.PermissionAuth("channels:write"), controller.DeleteChannelsTag)
+			channelTagRoute.DELETE("/:tag/disabled", middleware.PermissionAuth("channels:write"), controller.DeleteDisabledChannelsTag)
+			channelTagRoute.PUT("/:tag/priority", middleware.PermissionAuth("channels:write"), controller.UpdateChannelsTagPriority)
+			channelTagRoute.PUT("/:tag/status/:status", middleware.PermissionAuth("channels:write"), controller.ChangeChannelsTagStatus)
 
 		}
 
@@ -174,76 +216,144 @@ func SetApiRouter(router *gin.Engine) {
 			tokenRoute.POST("/", controller.AddToken)
 			tokenRoute.PUT("/", controller.UpdateToken)
 			tokenRoute.DELETE("/:id", controller.DeleteToken)
+			tokenRoute.POST("/:id/rotate", controller.RotateToken)
+		}
+		// 管理令牌只能在登录后管理，不能使用管理令牌调用
+		adminTokenRoute := apiRouter.Group("/admin_token")
+		{
+			adminTokenRoute.GET("/", middleware.PermissionAuth("admin_tokens:write"), controller.GetAdminTokensList)
+			adminTokenRoute.GET("/scopes", middleware.PermissionAuth("admin_tokens:write"), controller.GetAdminTokenScopes)
+			adminTokenRoute.POST("/", middleware.PermissionAuth("admin_tokens:write"), controller.AddAdminToken)
+			adminTokenRoute.PUT("/", middleware.PermissionAuth("admin_tokens:write"), controller.UpdateAdminToken)
+			adminTokenRoute.DELETE("/:id", middleware.PermissionAuth("admin_tokens:write"), controller.DeleteAdminToken)
+		}
+		roleRoute := apiRouter.Group("/role")
+		{
+			roleRoute.GET("/", middleware.PermissionAuth("roles:read"), controller.GetRoles)
+			roleRoute.GET("/permissions", middleware.PermissionAuth("roles:read"), controller.GetPermissions)
+			roleRoute.POST("/", middleware.PermissionAuth("roles:write"), controller.AddRole)
+			roleRoute.PUT("/", middleware.PermissionAuth("roles:write"), controller.UpdateRole)
+			roleRoute.DELETE("/:id", middleware.PermissionAuth("roles:write"), controller.DeleteRole)
 		}
 		redemptionRoute := apiRouter.Group("/redemption")
-		redemptionRoute.Use(middleware.AdminAuth())
 		{
-			redemptionRoute.GET("/", controller.GetRedemptionsList)
-			redemptionRoute.GET("/:id", controller.GetRedemption)
-			redemptionRoute.POST("/", controller.AddRedemption)
-			redemptionRoute.PUT("/", controller.UpdateRedemption)
-			redemptionRoute.DELETE("/:id", controller.DeleteRedemption)
+			redemptionRoute.GET("/", middleware.PermissionAuth("redemptions:read"), controller.GetRedemptionsList)
+			redemptionRoute.GET("/:id", middleware.PermissionAuth("redemptions:read"), controller.GetRedemption)
+			redemptionRoute.POST("/", middleware.PermissionAuth("redemptions:write"), controller.AddRedemption)
+			redemptionRoute.PUT("/", middleware.PermissionAuth("redemptions:write"), controller.UpdateRedemption)
+			redemptionRoute.DELETE("/:id", middleware.PermissionAuth("redemptions:write"), controller.DeleteRedemption)
+			redemptionRoute.GET("/campaign", middleware.PermissionAuth("redemptions:read"), controller.GetRedemptionCampaignList)
+			redemptionRoute.GET("/campaign/:id", middleware.PermissionAuth("redemptions:read"), controller.GetRedemptionCampaign)
+			redemptionRoute.GET("/campaign/:id/statistics", middleware.PermissionAuth("redemptions:read"), controller.GetRedemptionCampaignStatistics)
+			redemptionRoute.GET("/campaign/:id/export", middleware.PermissionAuth("redemptions:read"), controller.ExportCampaignRedemptions)
+			redemptionRoute.POST("/campaign", middleware.PermissionAuth("redemptions:write"), controller.AddRedemptionCampaign)
+			redemptionRoute.PUT("/campaign", middleware.PermissionAuth("redemptions:write"), controller.UpdateRedemptionCampaign)
+			redemptionRoute.DELETE("/campaign/:id", middleware.PermissionAuth("redemptions:write"), controller.DeleteRedemptionCampaign)
+			redemptionRoute.POST("/campaign/:id/codes", middleware.PermissionAuth("redemptions:write"), controller.GenerateCampaignRedemptions)
 		}
 		logRoute := apiRouter.Group("/log")
-		logRoute.GET("/", middleware.AdminAuth(), controller.GetLogsList)
-		logRoute.DELETE("/", middleware.AdminAuth(), controller.DeleteHistoryLogs)
-		logRoute.GET("/stat", middleware.AdminAuth(), controller.GetLogsStat)
+		logRoute.GET("/", middleware.PermissionAuth("logs:read"), controller.GetLogsList)
+		logRoute.DELETE("/", middleware.PermissionAuth("logs:write"), controller.DeleteHistoryLogs)
+		logRoute.GET("/stat", middleware.PermissionAuth("logs:read"), controller.GetLogsStat)
 		logRoute.GET("/self/stat", middleware.UserAuth(), controller.GetLogsSelfStat)
 		// logRoute.GET("/search", middleware.AdminAuth(), controller.SearchAllLogs)
 		logRoute.GET("/self", middleware.UserAuth(), controller.GetUserLogsList)
 		// logRoute.GET("/self/search", middleware.UserAuth(), controller.SearchUserLogs)
+		auditLogRoute := apiRouter.Group("/audit_log")
+		{
+			auditLogRoute.GET("/", middleware.PermissionAuth("audit_logs:read"), controller.GetAuditLogsList)
+			auditLogRoute.GET("/verify", middleware.PermissionAuth("audit_logs:read"), controller.VerifyAuditLogs)
+			auditLogRoute.GET("/export", middleware.PermissionAuth("audit_logs:read"), controller.ExportAuditLogs)
+		}
 		groupRoute := apiRouter.Group("/group")
-		groupRoute.Use(middleware.AdminAuth())
 		{
-			groupRoute.GET("/", controller.GetGroups)
+			groupRoute.GET("/", middleware.PermissionAuth("groups:read"), controller.GetGroups)
 		}
 
 		analyticsRoute := apiRouter.Group("/analytics")
-		analyticsRoute.Use(middleware.AdminAuth())
 		{
-			analyticsRoute.GET("/statistics", controller.GetStatisticsDetail)
-			analyticsRoute.GET("/period", controller.GetStatisticsByPeriod)
-			analyticsRoute.GET("/recharge", controller.GetRechargeStatisticsByTimeRange)
+			analyticsRoute.GET("/statistics", middleware.PermissionAuth("analytics:read"), controller.GetStatisticsDetail)
+			analyticsRoute.GET("/period", middleware.PermissionAuth("analytics:read"), controller.GetStatisticsByPeriod)
+			analyticsRoute.GET("/recharge", middleware.PermissionAuth("analytics:read"), controller.GetRechargeStatisticsByTimeRange)
+			analyticsRoute.GET("/margin", middleware.PermissionAuth("analytics:read"), controller.GetMarginStatistics)
+			analyticsRoute.GET("/margin/balance", middleware.PermissionAuth("analytics:read"), controller.GetChannelBalanceReconciliation)
 		}
 
 		pricesRoute := apiRouter.Group("/prices")
-		pricesRoute.Use(middleware.AdminAuth())
 		{
-			pricesRoute.GET("/model_list", controller.GetAllModelList)
-			pricesRoute.POST("/single", controller.AddPrice)
-			pricesRoute.PUT("/single/*model", controller.UpdatePrice)
-			pricesRoute.DELETE("/single/*model", controller.DeletePrice)
-			pricesRoute.POST("/multiple", controller.BatchSetPrices)
-			pricesRoute.PUT("/multiple/delete", controller.BatchDeletePrices)
-			pricesRoute.POST("/sync", controller.SyncPricing)
-			pricesRoute.GET("/updateService", controller.GetUpdatePriceService)
+			pricesRoute.GET("/model_list", middleware.PermissionAuth("prices:read"), controller.GetAllModelList)
+			pricesRoute.POST("/single", middleware.PermissionAuth("prices:write"), controller.AddPrice)
+			pricesRoute.PUT("/single/*model", middleware.PermissionAuth("prices:write"), controller.UpdatePrice)
+			pricesRoute.DELETE("/single/*model", middleware.PermissionAuth("prices:write"), controller.DeletePrice)
+			pricesRoute.POST("/multiple", middleware.PermissionAuth("prices:write"), controller.BatchSetPrices)
+			pricesRoute.PUT("/multiple/delete", middleware.PermissionAuth("prices:write"), controller.BatchDeletePrices)
+			pricesRoute.GET("/group", middleware.PermissionAuth("prices:read"), controller.GetGroupPrices)
+			pricesRoute.POST("/group/multiple", middleware.PermissionAuth("prices:write"), controller.BatchSetGroupPrices)
+			pricesRoute.PUT("/group/multiple/delete", middleware.PermissionAuth("prices:write"), controller.BatchDeleteGroupPrices)
+			pricesRoute.GET("/window", middleware.PermissionAuth("prices:read"), controller.GetPriceWindowList)
+			pricesRoute.GET("/window/:id", middleware.PermissionAuth("prices:read"), controller.GetPriceWindow)
+			pricesRoute.POST("/window", middleware.PermissionAuth("prices:write"), controller.AddPriceWindow)
+			pricesRoute.PUT("/window", middleware.PermissionAuth("prices:write"), controller.UpdatePriceWindow)
+			pricesRoute.DELETE("/window/:id", middleware.PermissionAuth("prices:write"), controller.DeletePriceWindow)
+			pricesRoute.POST("/sync", middleware.PermissionAuth("prices:write"), controller.SyncPricing)
+			pricesRoute.GET("/updateService", middleware.PermissionAuth("prices:read"), controller.GetUpdatePriceService)
 
 		}
 
 		paymentRoute := apiRouter.Group("/payment")
-		paymentRoute.Use(middleware.AdminAuth())
 		{
-			paymentRoute.GET("/order", controller.GetOrderList)
-			paymentRoute.GET("/", controller.GetPaymentList)
-			paymentRoute.GET("/:id", controller.GetPayment)
-			paymentRoute.POST("/", controller.AddPayment)
-			paymentRoute.PUT("/", controller.UpdatePayment)
-			paymentRoute.DELETE("/:id", controller.DeletePayment)
+			paymentRoute.GET("/order", middleware.PermissionAuth("payments:read"), controller.GetOrderList)
+			paymentRoute.GET("/order/:id/refunds", middleware.PermissionAuth("payments:read"), controller.GetOrderRefunds)
+			paymentRoute.POST("/order/:id/refund", middleware.PermissionAuth("payments:write"), controller.RefundOrder)
+			paymentRoute.PUT("/refund/:id/confirm", middleware.PermissionAuth("payments:write"), controller.ConfirmOrderRefund)
+			paymentRoute.GET("/mismatch", middleware.PermissionAuth("payments:read"), controller.GetPaymentMismatchList)
+			paymentRoute.PUT("/mismatch/:id/resolve", middleware.PermissionAuth("payments:write"), controller.ResolvePaymentMismatch)
+			paymentRoute.POST("/reconcile", middleware.PermissionAuth("payments:write"), controller.ReconcilePayments)
+			paymentRoute.GET("/affiliate/commission", middleware.PermissionAuth("payments:read"), controller.GetAffiliateCommissionList)
+			paymentRoute.PUT("/affiliate/commission/:id/review", middleware.PermissionAuth("payments:write"), controller.ReviewAffiliateCommission)
+			paymentRoute.GET("/affiliate/withdrawal", middleware.PermissionAuth("payments:read"), controller.GetAffiliateWithdrawalList)
+			paymentRoute.PUT("/affiliate/withdrawal/:id/approve", middleware.PermissionAuth("payments:write"), controller.ApproveAffiliateWithdrawal)
+			paymentRoute.PUT("/affiliate/withdrawal/:id/reject", middleware.PermissionAuth("payments:write"), controller.RejectAffiliateWithdrawal)
+			paymentRoute.GET("/plan", middleware.PermissionAuth("payments:read"), controller.GetSubscriptionPlanList)
+			paymentRoute.GET("/plan/:id", middleware.PermissionAuth("payments:read"), controller.GetSubscriptionPlan)
+			paymentRoute.POST("/plan", middleware.PermissionAuth("payments:write"), controller.AddSubscriptionPlan)
+			paymentRoute.PUT("/plan", middleware.PermissionAuth("payments:write"), controller.UpdateSubscriptionPlan)
+			paymentRoute.DELETE("/plan/:id", middleware.PermissionAuth("payments:write"), controller.DeleteSubscriptionPlan)
+			paymentRoute.GET("/subscription", middleware.PermissionAuth("payments:read"), controller.GetUserSubscriptionList)
+			paymentRoute.DELETE("/subscription/:id", middleware.PermissionAuth("payments:write"), controller.CancelUserSubscription)
+			paymentRoute.GET("/", middleware.PermissionAuth("payments:read"), controller.GetPaymentList)
+			paymentRoute.GET("/:id", middleware.PermissionAuth("payments:read"), controller.GetPayment)
+			paymentRoute.POST("/", middleware.PermissionAuth("payments:write"), controller.AddPayment)
+			paymentRoute.PUT("/", middleware.PermissionAuth("payments:write"), controller.UpdatePayment)
+			paymentRoute.DELETE("/:id", middleware.PermissionAuth("payments:write"), controller.DeletePayment)
+		}
+
+		organizationRoute := apiRouter.Group("/organization")
+		{
+			organizationRoute.GET("/", middleware.PermissionAuth("users:read"), controller.GetOrganizationList)
+			organizationRoute.PUT("/:id/quota", middleware.PermissionAuth("users:write"), controller.AdjustOrganizationQuota)
+			organizationRoute.PUT("/:id/status", middleware.PermissionAuth("users:write"), controller.UpdateOrganizationStatus)
 		}
 
 		mjRoute := apiRouter.Group("/mj")
 		mjRoute.GET("/self", middleware.UserAuth(), controller.GetUserMidjourney)
-		mjRoute.GET("/", middleware.AdminAuth(), controller.GetAllMidjourney)
+		mjRoute.GET("/", middleware.PermissionAuth("tasks:read"), controller.GetAllMidjourney)
 
 		taskRoute := apiRouter.Group("/task")
 		taskRoute.GET("/self", middleware.UserAuth(), controller.GetUserAllTask)
-		taskRoute.GET("/", middleware.AdminAuth(), controller.GetAllTask)
+		taskRoute.GET("/", middleware.PermissionAuth("tasks:read"), controller.GetAllTask)
+
+		webhookRoute := apiRouter.Group("/webhook")
+		{
+			webhookRoute.GET("/deliveries", middleware.PermissionAuth("webhooks:read"), controller.GetWebhookDeliveries)
+			webhookRoute.POST("/deliveries/:id/redeliver", middleware.PermissionAuth("webhooks:write"), controller.RedeliverWebhook)
+		}
 	}
 
 	sseRouter := router.Group("/api/sse")
 	sseRouter.Use(middleware.GlobalAPIRateLimit())
 	{
-		sseRouter.POST("/channel/check", middleware.AdminAuth(), controller.CheckChannel)
+		sseRouter.POST("/channel/check", middleware.PermissionAuth("channels:write"), controller.CheckChannel)
 	}
 
 }
@@ -30,6 +30,9 @@ func setOpenAIRouter(router *gin.Engine) {
 		modelsRouter.GET("", relay.ListModelsByToken)
 		modelsRouter.GET("/:model", relay.RetrieveModel)
 	}
+	// 使用普通令牌签发临时令牌
+	router.POST("/v1/ephemeral_tokens", middleware.CriticalRateLimit(), middleware.OpenaiAuth(), relay.CreateEphemeralToken)
+
 	relayV1Router := router.Group("/v1")
 	relayV1Router.Use(middleware.RelayPanicRecover(), middleware.OpenaiAuth(), middleware.Distribute(), middleware.DynamicRedisRateLimiter())
 	{
@@ -48,6 +51,10 @@ func setOpenAIRouter(router *gin.Engine) {
 		relayV1Router.POST("/moderations", relay.Relay)
 		relayV1Router.POST("/rerank", relay.RelayRerank)
 		relayV1Router.GET("/realtime", relay.ChatRealtime)
+		relayV1Router.POST("/videos", task.RelayVideoSubmit)
+		relayV1Router.GET("/videos", task.ListVideos)
+		relayV1Router.GET("/videos/:id", task.GetVideo)
+		relayV1Router.GET("/videos/:id/content", task.GetVideoContent)
 
 		relayV1Router.Use(middleware.SpecifiedChannel())
 		{
@@ -114,6 +121,17 @@ func setClaudeRouter(router *gin.Engine) {
 	{
 		relayV1Router.POST("/messages", relay.Relay)
 		relayV1Router.GET("/models", relay.ListClaudeModelsByToken)
+		relayV1Router.POST("/messages/batches", relay.CreateClaudeBatch)
+		relayV1Router.GET("/messages/batches", relay.ListClaudeBatches)
+		relayV1Router.GET("/messages/batches/:id", relay.RetrieveClaudeBatch)
+		relayV1Router.DELETE("/messages/batches/:id", relay.DeleteClaudeBatch)
+		relayV1Router.POST("/messages/batches/:id/cancel", relay.CancelClaudeBatch)
+		relayV1Router.GET("/messages/batches/:id/results", relay.GetClaudeBatchResults)
+		relayV1Router.POST("/files", relay.UploadClaudeFile)
+		relayV1Router.GET("/files", relay.ListClaudeFiles)
+		relayV1Router.GET("/files/:id", relay.RetrieveClaudeFile)
+		relayV1Router.DELETE("/files/:id", relay.DeleteClaudeFile)
+		relayV1Router.GET("/files/:id/content", relay.DownloadClaudeFile)
 	}
 }
 
@@ -123,6 +141,11 @@ func setGeminiRouter(router *gin.Engine) {
 	{
 		relayGeminiRouter.POST("/:version/models/:model", relay.Relay)
 		relayGeminiRouter.GET("/:version/models", relay.ListGeminiModelsByToken)
+		relayGeminiRouter.POST("/:version/cachedContents", relay.CreateGeminiCachedContent)
+		relayGeminiRouter.GET("/:version/cachedContents", relay.ListGeminiCachedContents)
+		relayGeminiRouter.GET("/:version/cachedContents/:id", relay.GetGeminiCachedContent)
+		relayGeminiRouter.PATCH("/:version/cachedContents/:id", relay.UpdateGeminiCachedContent)
+		relayGeminiRouter.DELETE("/:version/cachedContents/:id", relay.DeleteGeminiCachedContent)
 	}
 }
 
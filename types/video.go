@@ -0,0 +1,86 @@
+package types
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	VideoStatusQueued     = "queued"
+	VideoStatusInProgress = "in_progress"
+	VideoStatusCompleted  = "completed"
+	VideoStatusFailed     = "failed"
+)
+
+type VideoRequest struct {
+	Model          string `json:"model" binding:"required"`
+	Prompt         string `json:"prompt,omitempty"`
+	InputReference string `json:"input_reference,omitempty"` // 参考图片，url 或 base64
+	NegativePrompt string `json:"negative_prompt,omitempty"`
+	Seconds        string `json:"seconds,omitempty"`
+	Size           string `json:"size,omitempty"` // 宽x高，如 1280x720
+	CallbackURL    string `json:"callback_url,omitempty"`
+}
+
+func (r *VideoRequest) GetSeconds() int {
+	seconds, _ := strconv.Atoi(r.Seconds)
+	return seconds
+}
+
+// GetResolution 按短边返回分辨率，如 1280x720 => 720p
+func (r *VideoRequest) GetResolution() string {
+	width, height, ok := r.GetWidthAndHeight()
+	if !ok {
+		return ""
+	}
+
+	return strconv.Itoa(min(width, height)) + "p"
+}
+
+func (r *VideoRequest) GetWidthAndHeight() (width, height int, ok bool) {
+	sizes := strings.Split(strings.ToLower(r.Size), "x")
+	if len(sizes) != 2 {
+		return
+	}
+
+	width, errWidth := strconv.Atoi(sizes[0])
+	height, errHeight := strconv.Atoi(sizes[1])
+	if errWidth != nil || errHeight != nil || width <= 0 || height <= 0 {
+		return 0, 0, false
+	}
+
+	return width, height, true
+}
+
+// VideoProperties 保存在 Task.Properties 中的统一视频参数
+type VideoProperties struct {
+	Model   string `json:"model"`
+	Seconds string `json:"seconds"`
+	Size    string `json:"size"`
+}
+
+type VideoObject struct {
+	Id          string      `json:"id"`
+	Object      string      `json:"object"`
+	Model       string      `json:"model"`
+	Status      string      `json:"status"`
+	Progress    int         `json:"progress"`
+	CreatedAt   int64       `json:"created_at"`
+	CompletedAt int64       `json:"completed_at,omitempty"`
+	Seconds     string      `json:"seconds,omitempty"`
+	Size        string      `json:"size,omitempty"`
+	Error       *VideoError `json:"error,omitempty"`
+}
+
+type VideoError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type VideoList struct {
+	Object  string         `json:"object"`
+	Data    []*VideoObject `json:"data"`
+	HasMore bool           `json:"has_more"`
+	FirstId string         `json:"first_id,omitempty"`
+	LastId  string         `json:"last_id,omitempty"`
+}
@@ -0,0 +1,28 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVideoRequestSize(t *testing.T) {
+	request := &VideoRequest{Size: "1280x720", Seconds: "10"}
+	assert.Equal(t, "720p", request.GetResolution())
+	assert.Equal(t, 10, request.GetSeconds())
+
+	// 竖屏按短边计算分辨率
+	request.Size = "1080X1920"
+	width, height, ok := request.GetWidthAndHeight()
+	assert.True(t, ok)
+	assert.Equal(t, 1080, width)
+	assert.Equal(t, 1920, height)
+	assert.Equal(t, "1080p", request.GetResolution())
+
+	for _, size := range []string{"", "1280", "1280x", "0x720", "-1x720", "axb"} {
+		request.Size = size
+		_, _, ok = request.GetWidthAndHeight()
+		assert.False(t, ok, size)
+		assert.Empty(t, request.GetResolution(), size)
+	}
+}
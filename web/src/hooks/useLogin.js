@@ -4,6 +4,7 @@ import { useDispatch } from 'react-redux';
 import { LOGIN, SET_USER_GROUP } from 'store/actions';
 import { useNavigate } from 'react-router';
 import { showSuccess } from 'utils/common';
+import { getPasskey } from 'utils/webauthn';
 import { useTranslation } from 'react-i18next';
 
 const useLogin = () => {
@@ -16,8 +17,12 @@ const useLogin = () => {
         username,
         password
       });
-      const { success, message } = res.data;
+      const { success, message, data } = res.data;
       if (success) {
+        // 启用了两步验证时需要继续输入验证码
+        if (data?.require_2fa) {
+          return { success, message, require2fa: true };
+        }
         loadUser();
         loadUserGroup();
         navigate('/panel');
@@ -29,11 +34,60 @@ const useLogin = () => {
     }
   };
 
+  const loginTwoFactor = async (code) => {
+    try {
+      const res = await API.post(`/api/user/login/2fa`, { code });
+      const { success, message } = res.data;
+      if (success) {
+        loadUser();
+        loadUserGroup();
+        navigate('/panel');
+      }
+      return { success, message };
+    } catch (err) {
+      return { success: false, message: '' };
+    }
+  };
+
+  const passkeyLogin = async () => {
+    try {
+      let res = await API.post(`/api/user/login/passkey/begin`);
+      if (!res.data.success) {
+        return res.data;
+      }
+      const assertion = await getPasskey(res.data.data);
+      res = await API.post(`/api/user/login/passkey/finish`, assertion);
+      const { success, message } = res.data;
+      if (success) {
+        loadUser();
+        loadUserGroup();
+        showSuccess(t('common.loginOk'));
+        navigate('/panel');
+      }
+      return { success, message };
+    } catch (err) {
+      // 用户取消或浏览器不支持
+      return { success: false, message: t('login.passkeyError') };
+    }
+  };
+
+  // 第三方登录后需要两步验证时回到登录页输入验证码
+  const requireTwoFactor = (data) => {
+    if (data?.require_2fa) {
+      navigate('/login', { state: { require2fa: true } });
+      return true;
+    }
+    return false;
+  };
+
   const githubLogin = async (code, state) => {
     try {
       const affCode = localStorage.getItem('aff');
       const res = await API.get(`/api/oauth/github?code=${code}&state=${state}&aff=${affCode}`);
-      const { success, message } = res.data;
+      const { success, message, data } = res.data;
+      if (success && requireTwoFactor(data)) {
+        return { success, message };
+      }
       if (success) {
         if (message === 'bind') {
           showSuccess(t('common.bindOk'));
@@ -56,7 +110,10 @@ const useLogin = () => {
     try {
       const affCode = localStorage.getItem('aff');
       const res = await API.get(`/api/oauth/oidc?code=${code}&state=${state}&aff=${affCode}`);
-      const { success, message } = res.data;
+      const { success, message, data } = res.data;
+      if (success && requireTwoFactor(data)) {
+        return { success, message };
+      }
       if (success) {
         if (message === 'bind') {
           showSuccess(t('common.bindOk'));
@@ -79,7 +136,10 @@ const useLogin = () => {
     try {
       const affCode = localStorage.getItem('aff');
       const res = await API.get(`/api/oauth/lark?code=${code}&state=${state}&aff=${affCode}`);
-      const { success, message } = res.data;
+      const { success, message, data } = res.data;
+      if (success && requireTwoFactor(data)) {
+        return { success, message };
+      }
       if (success) {
         if (message === 'bind') {
           showSuccess(t('common.bindOk'));
@@ -101,7 +161,10 @@ const useLogin = () => {
     try {
       const affCode = localStorage.getItem('aff');
       const res = await API.get(`/api/oauth/wechat?code=${code}&aff=${affCode}`);
-      const { success, message } = res.data;
+      const { success, message, data } = res.data;
+      if (success && requireTwoFactor(data)) {
+        return { success, message };
+      }
       if (success) {
         loadUser();
         loadUserGroup();
@@ -151,7 +214,7 @@ const useLogin = () => {
     return [];
   }, []);
 
-  return { login, logout, githubLogin, wechatLogin, larkLogin, oidcLogin, loadUser, loadUserGroup };
+  return { login, loginTwoFactor, passkeyLogin, logout, githubLogin, wechatLogin, larkLogin, oidcLogin, loadUser, loadUserGroup };
 };
 
 export default useLogin;
@@ -0,0 +1,69 @@
+// 服务端（go-webauthn）使用 base64url 编码二进制字段，浏览器接口需要 ArrayBuffer
+
+export const isWebAuthnSupported = () => typeof window !== 'undefined' && !!window.PublicKeyCredential;
+
+const base64urlToBuffer = (value) => {
+  const base64 = value.replace(/-/g, '+').replace(/_/g, '/');
+  const padded = base64 + '='.repeat((4 - (base64.length % 4)) % 4);
+  const binary = atob(padded);
+  const bytes = new Uint8Array(binary.length);
+  for (let i = 0; i < binary.length; i++) {
+    bytes[i] = binary.charCodeAt(i);
+  }
+  return bytes.buffer;
+};
+
+const bufferToBase64url = (buffer) => {
+  const bytes = new Uint8Array(buffer);
+  let binary = '';
+  for (let i = 0; i < bytes.length; i++) {
+    binary += String.fromCharCode(bytes[i]);
+  }
+  return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+};
+
+const decodeCredentialList = (list) => (list || []).map((item) => ({ ...item, id: base64urlToBuffer(item.id) }));
+
+// createPasskey 使用注册开始接口返回的选项创建凭据，返回可直接提交给完成接口的对象
+export const createPasskey = async (options) => {
+  const publicKey = {
+    ...options.publicKey,
+    challenge: base64urlToBuffer(options.publicKey.challenge),
+    user: { ...options.publicKey.user, id: base64urlToBuffer(options.publicKey.user.id) },
+    excludeCredentials: decodeCredentialList(options.publicKey.excludeCredentials)
+  };
+
+  const credential = await navigator.credentials.create({ publicKey });
+  return {
+    id: credential.id,
+    rawId: bufferToBase64url(credential.rawId),
+    type: credential.type,
+    response: {
+      clientDataJSON: bufferToBase64url(credential.response.clientDataJSON),
+      attestationObject: bufferToBase64url(credential.response.attestationObject),
+      transports: credential.response.getTransports ? credential.response.getTransports() : []
+    }
+  };
+};
+
+// getPasskey 使用登录开始接口返回的选项获取断言
+export const getPasskey = async (options) => {
+  const publicKey = {
+    ...options.publicKey,
+    challenge: base64urlToBuffer(options.publicKey.challenge),
+    allowCredentials: decodeCredentialList(options.publicKey.allowCredentials)
+  };
+
+  const credential = await navigator.credentials.get({ publicKey });
+  return {
+    id: credential.id,
+    rawId: bufferToBase64url(credential.rawId),
+    type: credential.type,
+    response: {
+      clientDataJSON: bufferToBase64url(credential.response.clientDataJSON),
+      authenticatorData: bufferToBase64url(credential.response.authenticatorData),
+      signature: bufferToBase64url(credential.response.signature),
+      userHandle: credential.response.userHandle ? bufferToBase64url(credential.response.userHandle) : null
+    }
+  };
+};
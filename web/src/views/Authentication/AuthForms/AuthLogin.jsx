@@ -1,6 +1,6 @@
-import { useState } from 'react';
+import { useEffect, useState } from 'react';
 import { useSelector } from 'react-redux';
-import { Link } from 'react-router-dom';
+import { Link, useLocation, useNavigate } from 'react-router-dom';
 
 // material-ui
 import { useTheme } from '@mui/material/styles';
@@ -32,12 +32,14 @@ import WechatModal from 'views/Authentication/AuthForms/WechatModal';
 // assets
 import Visibility from '@mui/icons-material/Visibility';
 import VisibilityOff from '@mui/icons-material/VisibilityOff';
+import { IconFingerprint } from '@tabler/icons-react';
 
 import Github from 'assets/images/icons/github.svg';
 import Wechat from 'assets/images/icons/wechat.svg';
 import Lark from 'assets/images/icons/lark.svg';
 import Oidc from 'assets/images/icons/oidc.svg';
-import { onGitHubOAuthClicked, onLarkOAuthClicked,onOIDCAuthClicked } from 'utils/common';
+import { onGitHubOAuthClicked, onLarkOAuthClicked,onOIDCAuthClicked, showError } from 'utils/common';
+import { isWebAuthnSupported } from 'utils/webauthn';
 import { useTranslation } from 'react-i18next';
 
 // ============================|| FIREBASE - LOGIN ||============================ //
@@ -45,8 +47,19 @@ import { useTranslation } from 'react-i18next';
 const LoginForm = ({ ...others }) => {
   const { t } = useTranslation();
   const theme = useTheme();
-  const { login, wechatLogin } = useLogin();
+  const { login, loginTwoFactor, passkeyLogin, wechatLogin } = useLogin();
+  const location = useLocation();
+  const navigate = useNavigate();
   const [openWechat, setOpenWechat] = useState(false);
+  // 密码或第三方登录通过后，启用了两步验证的账号需要再输入验证码
+  const [twoFactor, setTwoFactor] = useState(Boolean(location.state?.require2fa));
+  const [passkeyLoading, setPasskeyLoading] = useState(false);
+
+  useEffect(() => {
+    if (location.state?.require2fa) {
+      setTwoFactor(true);
+    }
+  }, [location.state]);
   const matchDownSM = useMediaQuery(theme.breakpoints.down('md'));
   const customization = useSelector((state) => state.customization);
   const siteInfo = useSelector((state) => state.siteInfo);
@@ -74,6 +87,92 @@ const LoginForm = ({ ...others }) => {
     event.preventDefault();
   };
 
+  const handlePasskeyLogin = async () => {
+    setPasskeyLoading(true);
+    const { success, message } = await passkeyLogin();
+    if (!success && message) {
+      showError(message);
+    }
+    setPasskeyLoading(false);
+  };
+
+  if (twoFactor) {
+    return (
+      <Formik
+        initialValues={{
+          code: '',
+          submit: null
+        }}
+        validationSchema={Yup.object().shape({
+          code: Yup.string().max(20).required(t('login.codeRequired'))
+        })}
+        onSubmit={async (values, { setErrors, setStatus, setSubmitting }) => {
+          const { success, message } = await loginTwoFactor(values.code);
+          if (success) {
+            setStatus({ success: true });
+          } else {
+            setStatus({ success: false });
+            if (message) {
+              setErrors({ submit: message });
+            }
+          }
+          setSubmitting(false);
+        }}
+      >
+        {({ errors, handleBlur, handleChange, handleSubmit, isSubmitting, touched, values }) => (
+          <form noValidate onSubmit={handleSubmit} {...others}>
+            <Typography variant="body2" sx={{ mb: 2 }}>
+              {t('login.twoFactorInfo')}
+            </Typography>
+            <FormControl fullWidth error={Boolean(touched.code && errors.code)} sx={{ ...theme.typography.customInput }}>
+              <InputLabel htmlFor="outlined-adornment-code-login">{t('login.twoFactorCode')}</InputLabel>
+              <OutlinedInput
+                id="outlined-adornment-code-login"
+                type="text"
+                value={values.code}
+                name="code"
+                onBlur={handleBlur}
+                onChange={handleChange}
+                label={t('login.twoFactorCode')}
+                inputProps={{ autoComplete: 'one-time-code' }}
+                autoFocus
+              />
+              {touched.code && errors.code && (
+                <FormHelperText error id="standard-weight-helper-text-code-login">
+                  {errors.code}
+                </FormHelperText>
+              )}
+            </FormControl>
+            {errors.submit && (
+              <Box sx={{ mt: 3 }}>
+                <FormHelperText error>{errors.submit}</FormHelperText>
+              </Box>
+            )}
+
+            <Box sx={{ mt: 2 }}>
+              <AnimateButton>
+                <Button disableElevation disabled={isSubmitting} fullWidth size="large" type="submit" variant="contained" color="primary">
+                  {t('login.verify')}
+                </Button>
+              </AnimateButton>
+            </Box>
+            <Box sx={{ mt: 2 }}>
+              <Button
+                fullWidth
+                onClick={() => {
+                  setTwoFactor(false);
+                  navigate('/login', { replace: true, state: null });
+                }}
+              >
+                {t('login.backToLogin')}
+              </Button>
+            </Box>
+          </form>
+        )}
+      </Formik>
+    );
+  }
+
   return (
     <>
       {tripartiteLogin && (
@@ -208,8 +307,10 @@ const LoginForm = ({ ...others }) => {
           password: Yup.string().max(255).required(t('login.passwordRequired'))
         })}
         onSubmit={async (values, { setErrors, setStatus, setSubmitting }) => {
-          const { success, message } = await login(values.username, values.password);
-          if (success) {
+          const { success, message, require2fa } = await login(values.username, values.password);
+          if (success && require2fa) {
+            setTwoFactor(true);
+          } else if (success) {
             setStatus({ success: true });
           } else {
             setStatus({ success: false });
@@ -295,6 +396,22 @@ const LoginForm = ({ ...others }) => {
                 </Button>
               </AnimateButton>
             </Box>
+            {siteInfo.passkey_login && isWebAuthnSupported() && (
+              <Box sx={{ mt: 2 }}>
+                <Button
+                  disableElevation
+                  disabled={passkeyLoading}
+                  fullWidth
+                  size="large"
+                  variant="outlined"
+                  color="primary"
+                  startIcon={<IconFingerprint />}
+                  onClick={handlePasskeyLogin}
+                >
+                  {t('login.usePasskeyLogin')}
+                </Button>
+              </Box>
+            )}
           </form>
         )}
       </Formik>
@@ -69,7 +69,9 @@ const getValidationSchema = (t) =>
     }),
     model_mapping: Yup.array(),
     model_headers: Yup.array(),
-    custom_parameter: Yup.string().nullable()
+    custom_parameter: Yup.string().nullable(),
+    cost_ratio: Yup.number().min(0),
+    cost_prices: Yup.string().nullable()
   });
 
 const EditModal = ({ open, channelId, onCancel, onOk, groupOptions, isTag, modelOptions, prices }) => {
@@ -249,6 +251,16 @@ const EditModal = ({ open, channelId, onCancel, onOk, groupOptions, isTag, model
       }
     }
 
+    let costPrices = null
+    if (values.cost_prices) {
+      try {
+        costPrices = JSON.parse(values.cost_prices)
+      } catch (error) {
+        showError('Error parsing cost_prices: ' + error.message)
+        return
+      }
+    }
+
     if (values.disabled_stream) {
       values.disabled_stream = removeDuplicates(values.disabled_stream)
     }
@@ -274,9 +286,15 @@ const EditModal = ({ open, channelId, onCancel, onOk, groupOptions, isTag, model
 
     try {
       if (channelId) {
-        res = await API.put(baseApiUrl, { ...values, id: parseInt(channelId), models: modelsStr })
+        res = await API.put(baseApiUrl, {
+          ...values,
+          id: parseInt(channelId),
+          models: modelsStr,
+          cost_ratio: Number(values.cost_ratio) || 0,
+          cost_prices: costPrices
+        })
       } else {
-        res = await API.post(baseApiUrl, { ...values, models: modelsStr })
+        res = await API.post(baseApiUrl, { ...values, models: modelsStr, cost_ratio: Number(values.cost_ratio) || 0, cost_prices: costPrices })
       }
       const { success, message } = res.data
       if (success) {
@@ -376,6 +394,9 @@ const EditModal = ({ open, channelId, onCancel, onOk, groupOptions, isTag, model
           data.custom_parameter = ''
         }
 
+        data.cost_ratio = data.cost_ratio ?? 0
+        data.cost_prices = data.cost_prices ? JSON.stringify(data.cost_prices, null, 2) : ''
+
         data.base_url = data.base_url ?? ''
         data.is_edit = true
         if (data.plugin === null) {
@@ -943,6 +964,61 @@ const EditModal = ({ open, channelId, onCancel, onOk, groupOptions, isTag, model
                     )}
                   </FormControl>
                 )}
+                {inputPrompt.cost_ratio && (
+                  <FormControl
+                    fullWidth
+                    error={Boolean(touched.cost_ratio && errors.cost_ratio)}
+                    sx={{ ...theme.typography.otherInput }}
+                  >
+                    <InputLabel htmlFor="channel-cost_ratio-label">{customizeT(inputLabel.cost_ratio)}</InputLabel>
+                    <OutlinedInput
+                      id="channel-cost_ratio-label"
+                      label={customizeT(inputLabel.cost_ratio)}
+                      type="number"
+                      disabled={hasTag}
+                      value={values.cost_ratio}
+                      name="cost_ratio"
+                      onBlur={handleBlur}
+                      onChange={handleChange}
+                      inputProps={{ min: 0, step: 0.01 }}
+                      aria-describedby="helper-text-channel-cost_ratio-label"
+                    />
+                    {touched.cost_ratio && errors.cost_ratio ? (
+                      <FormHelperText error id="helper-tex-channel-cost_ratio-label">
+                        {errors.cost_ratio}
+                      </FormHelperText>
+                    ) : (
+                      <FormHelperText id="helper-tex-channel-cost_ratio-label">{customizeT(inputPrompt.cost_ratio)}</FormHelperText>
+                    )}
+                  </FormControl>
+                )}
+                {inputPrompt.cost_prices && (
+                  <FormControl
+                    fullWidth
+                    error={Boolean(touched.cost_prices && errors.cost_prices)}
+                    sx={{ ...theme.typography.otherInput }}
+                  >
+                    <TextField
+                      id="channel-cost_prices-label"
+                      label={customizeT(inputLabel.cost_prices)}
+                      multiline
+                      minRows={values.cost_prices ? 4 : 1}
+                      value={values.cost_prices}
+                      name="cost_prices"
+                      disabled={hasTag}
+                      error={Boolean(touched.cost_prices && errors.cost_prices)}
+                      onChange={handleChange}
+                      onBlur={handleBlur}
+                    />
+                    {touched.cost_prices && errors.cost_prices ? (
+                      <FormHelperText error id="helper-tex-channel-cost_prices-label">
+                        {errors.cost_prices}
+                      </FormHelperText>
+                    ) : (
+                      <FormHelperText id="helper-tex-channel-cost_prices-label">{customizeT(inputPrompt.cost_prices)}</FormHelperText>
+                    )}
+                  </FormControl>
+                )}
                 {inputPrompt.disabled_stream && (
                   <FormControl
                     fullWidth
@@ -17,7 +17,9 @@ const defaultConfig = {
     only_chat: false,
     pre_cost: 1,
     disabled_stream: [],
-    compatible_response: false
+    compatible_response: false,
+    cost_ratio: 0,
+    cost_prices: ''
   },
   inputLabel: {
     name: '渠道名称',
@@ -37,7 +39,9 @@ const defaultConfig = {
     provider_models_list: '',
     pre_cost: '预计费选项',
     disabled_stream: '禁用流式的模型',
-    compatible_response: '兼容Response API'
+    compatible_response: '兼容Response API',
+    cost_ratio: '成本倍率',
+    cost_prices: '成本价格'
   },
   prompt: {
     type: '请选择渠道类型',
@@ -61,7 +65,10 @@ const defaultConfig = {
     pre_cost:
       '这里选择预计费选项，用于预估费用，如果你觉得计算图片占用太多资源，可以选择关闭图片计费。但是请注意：有些渠道在stream下是不会返回tokens的，这会导致输入tokens计算错误。',
     disabled_stream: '这里填写禁用流式的模型，注意：如果填写了禁用流式的模型，那么这些模型在流式请求时会跳过该渠道',
-    compatible_response: '兼容Response API'
+    compatible_response: '兼容Response API',
+    cost_ratio: '上游成本相对模型基础价格的倍率，用于统计渠道成本和利润，0 表示不统计成本',
+    cost_prices:
+      '按模型设置上游价格，优先于成本倍率，价格单位与模型价格相同，例如：{"gpt-4o": {"input": 1.25, "output": 5}}'
   },
   modelGroup: 'OpenAI'
 }
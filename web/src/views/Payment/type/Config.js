@@ -3,6 +3,7 @@ const PaymentType = {
   alipay: '支付宝',
   wxpay: '微信支付',
   stripe: 'Stripe',
+  paypal: 'PayPal'
 };
 
 const CurrencyType = {
@@ -168,6 +169,42 @@ const PaymentConfig = {
       type: 'text',
       value: ''
     },
+  },
+  paypal: {
+    client_id: {
+      name: 'Client ID',
+      description: 'PayPal 开发者后台应用的 Client ID',
+      type: 'text',
+      value: ''
+    },
+    client_secret: {
+      name: 'Client Secret',
+      description: 'PayPal 开发者后台应用的 Secret',
+      type: 'text',
+      value: ''
+    },
+    webhook_id: {
+      name: 'Webhook ID',
+      description: '回调验证使用的 Webhook ID，不用填写，创建网关后会自动在PayPal后台创建webhook并获取ID',
+      type: 'text',
+      value: ''
+    },
+    mode: {
+      name: '环境',
+      description: '沙箱环境用于测试，需要使用沙箱应用的凭据',
+      type: 'select',
+      value: 'live',
+      options: [
+        {
+          name: '正式环境',
+          value: 'live'
+        },
+        {
+          name: '沙箱环境',
+          value: 'sandbox'
+        }
+      ]
+    }
   }
 };
 
@@ -7,6 +7,8 @@ import SubCard from 'ui-component/cards/SubCard';
 // import { Link } from 'react-router-dom';
 import { useSelector } from 'react-redux';
 
+const PLAYGROUND_TOKEN_KEY = 'playground_token';
+
 function TabPanel(props) {
   const { children, value, index, ...other } = props;
 
@@ -50,10 +52,22 @@ const Playground = () => {
 
   const loadTokens = useCallback(async () => {
     setIsLoading(true);
-    const res = await API.get(`/api/token/playground`);
+    let res = await API.get(`/api/token/playground`);
+    // 新版令牌只返回展示前缀，本地保存的令牌与前缀不符时才轮换
+    if (res.data.success && !res.data.data.key) {
+      const savedKey = localStorage.getItem(PLAYGROUND_TOKEN_KEY);
+      if (savedKey && savedKey.startsWith(res.data.data.prefix)) {
+        setValue(savedKey);
+        setIsLoading(false);
+        return;
+      }
+      res = await API.get(`/api/token/playground`, { params: { rotate: true } });
+    }
+
     const { success, message, data } = res.data;
     if (success) {
-      setValue(data);
+      localStorage.setItem(PLAYGROUND_TOKEN_KEY, data.key);
+      setValue(data.key);
     } else {
       showError(message);
     }
@@ -78,13 +92,15 @@ const Playground = () => {
   );
 
   useEffect(() => {
-    loadTokens().then(() => {
-      if (value !== '') {
-        handleTabChange(null, 0);
-      }
-    });
+    loadTokens().then();
+  }, [loadTokens]);
+
+  useEffect(() => {
+    if (value !== '') {
+      handleTabChange(null, 0);
+    }
     // eslint-disable-next-line react-hooks/exhaustive-deps
-  }, [loadTokens, value]);
+  }, [value]);
 
   if (chatLinks.length === 0 || isLoading || value === '') {
     return (
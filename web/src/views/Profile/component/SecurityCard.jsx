@@ -0,0 +1,305 @@
+import { useState, useEffect, useCallback } from 'react';
+import { useTranslation } from 'react-i18next';
+import {
+  Alert,
+  Box,
+  Button,
+  Dialog,
+  DialogActions,
+  DialogContent,
+  DialogTitle,
+  FormControl,
+  IconButton,
+  InputLabel,
+  List,
+  ListItem,
+  ListItemText,
+  OutlinedInput,
+  Stack,
+  Typography
+} from '@mui/material';
+import Grid from '@mui/material/Unstable_Grid2';
+import { QRCode } from 'react-qrcode-logo';
+import { IconTrash } from '@tabler/icons-react';
+import SubCard from 'ui-component/cards/SubCard';
+import { API } from 'utils/api';
+import { showError, showSuccess, copy, timestamp2string } from 'utils/common';
+import { createPasskey, isWebAuthnSupported } from 'utils/webauthn';
+
+// 需要输入动态口令的操作：启用、关闭和重新生成恢复码
+const CODE_ACTION_ENABLE = 'enable';
+const CODE_ACTION_DISABLE = 'disable';
+const CODE_ACTION_RECOVERY = 'recovery_codes';
+
+export default function SecurityCard() {
+  const { t } = useTranslation();
+  const [status, setStatus] = useState(null);
+  const [setupData, setSetupData] = useState(null);
+  const [codeAction, setCodeAction] = useState('');
+  const [code, setCode] = useState('');
+  const [recoveryCodes, setRecoveryCodes] = useState([]);
+  const [passkeyName, setPasskeyName] = useState('');
+  const [loading, setLoading] = useState(false);
+
+  const loadStatus = useCallback(async () => {
+    try {
+      const res = await API.get('/api/user/2fa');
+      const { success, message, data } = res.data;
+      if (success) {
+        setStatus(data);
+      } else {
+        showError(message);
+      }
+    } catch (error) {
+      return;
+    }
+  }, []);
+
+  useEffect(() => {
+    loadStatus().then();
+  }, [loadStatus]);
+
+  const setupTwoFactor = async () => {
+    try {
+      const res = await API.post('/api/user/2fa/setup');
+      const { success, message, data } = res.data;
+      if (success) {
+        setSetupData(data);
+        setCode('');
+        setCodeAction(CODE_ACTION_ENABLE);
+      } else {
+        showError(message);
+      }
+    } catch (error) {
+      return;
+    }
+  };
+
+  const openCodeDialog = (action) => {
+    setCode('');
+    setCodeAction(action);
+  };
+
+  const closeCodeDialog = () => {
+    setCodeAction('');
+    setSetupData(null);
+  };
+
+  const submitCode = async () => {
+    if (!code.trim()) {
+      showError(t('login.codeRequired'));
+      return;
+    }
+    setLoading(true);
+    try {
+      const res = await API.post(`/api/user/2fa/${codeAction}`, { code: code.trim() });
+      const { success, message, data } = res.data;
+      if (success) {
+        if (data?.recovery_codes) {
+          setRecoveryCodes(data.recovery_codes);
+        }
+        showSuccess(t('common.saveSuccess'));
+        closeCodeDialog();
+        loadStatus().then();
+      } else {
+        showError(message);
+      }
+    } catch (error) {
+      // 错误已由拦截器提示
+    }
+    setLoading(false);
+  };
+
+  const addPasskey = async () => {
+    setLoading(true);
+    try {
+      let res = await API.post('/api/user/passkey/register/begin');
+      if (!res.data.success) {
+        showError(res.data.message);
+      } else {
+        const credential = await createPasskey(res.data.data);
+        res = await API.post(`/api/user/passkey/register/finish?name=${encodeURIComponent(passkeyName.trim())}`, credential);
+        const { success, message } = res.data;
+        if (success) {
+          showSuccess(t('profilePage.passkeyAdded'));
+          setPasskeyName('');
+          loadStatus().then();
+        } else {
+          showError(message);
+        }
+      }
+    } catch (error) {
+      showError(t('login.passkeyError'));
+    }
+    setLoading(false);
+  };
+
+  const deletePasskey = async (id) => {
+    try {
+      const res = await API.delete(`/api/user/passkey/${id}`);
+      const { success, message } = res.data;
+      if (success) {
+        showSuccess(t('common.deleteSuccess'));
+        loadStatus().then();
+      } else {
+        showError(message);
+      }
+    } catch (error) {
+      return;
+    }
+  };
+
+  if (!status) {
+    return null;
+  }
+
+  return (
+    <>
+      <SubCard title={t('profilePage.security')}>
+        <Grid container spacing={2}>
+          {status.required && !status.verified && (
+            <Grid xs={12}>
+              <Alert severity="warning">{t('profilePage.twoFactorRequired')}</Alert>
+            </Grid>
+          )}
+          <Grid xs={12}>
+            <Typography variant="h4">{t('profilePage.twoFactor')}</Typography>
+          </Grid>
+          <Grid xs={12}>
+            {status.totp_enabled ? (
+              <Alert severity="success">
+                {t('profilePage.twoFactorEnabled', { count: status.recovery_codes_remaining })}
+              </Alert>
+            ) : (
+              <Alert severity="info">{t('profilePage.twoFactorNotice')}</Alert>
+            )}
+          </Grid>
+          <Grid xs={12}>
+            {status.totp_enabled ? (
+              <Stack direction="row" spacing={2}>
+                <Button variant="outlined" onClick={() => openCodeDialog(CODE_ACTION_RECOVERY)}>
+                  {t('profilePage.regenerateRecoveryCodes')}
+                </Button>
+                <Button variant="outlined" color="error" onClick={() => openCodeDialog(CODE_ACTION_DISABLE)}>
+                  {t('profilePage.disableTwoFactor')}
+                </Button>
+              </Stack>
+            ) : (
+              <Button variant="contained" onClick={setupTwoFactor}>
+                {t('profilePage.enableTwoFactor')}
+              </Button>
+            )}
+          </Grid>
+
+          <Grid xs={12}>
+            <Typography variant="h4">{t('profilePage.passkey')}</Typography>
+          </Grid>
+          <Grid xs={12}>
+            {status.passkeys?.length > 0 ? (
+              <List dense disablePadding>
+                {status.passkeys.map((passkey) => (
+                  <ListItem
+                    key={passkey.id}
+                    secondaryAction={
+                      <IconButton edge="end" color="error" onClick={() => deletePasskey(passkey.id)}>
+                        <IconTrash />
+                      </IconButton>
+                    }
+                  >
+                    <ListItemText
+                      primary={passkey.name || t('profilePage.passkey')}
+                      secondary={`${t('profilePage.passkeyCreatedTime')} ${timestamp2string(passkey.created_time)}${
+                        passkey.last_used_time ? ` / ${t('profilePage.passkeyLastUsedTime')} ${timestamp2string(passkey.last_used_time)}` : ''
+                      }`}
+                    />
+                  </ListItem>
+                ))}
+              </List>
+            ) : (
+              <Alert severity="info">{t('profilePage.passkeyNotice')}</Alert>
+            )}
+          </Grid>
+          {isWebAuthnSupported() && (
+            <Grid xs={12}>
+              <Stack direction="row" spacing={2}>
+                <FormControl variant="outlined" size="small">
+                  <InputLabel htmlFor="passkey_name">{t('profilePage.passkeyName')}</InputLabel>
+                  <OutlinedInput
+                    id="passkey_name"
+                    label={t('profilePage.passkeyName')}
+                    value={passkeyName}
+                    onChange={(e) => setPasskeyName(e.target.value)}
+                    inputProps={{ maxLength: 50 }}
+                  />
+                </FormControl>
+                <Button variant="contained" disabled={loading} onClick={addPasskey}>
+                  {t('profilePage.addPasskey')}
+                </Button>
+              </Stack>
+            </Grid>
+          )}
+        </Grid>
+      </SubCard>
+
+      <Dialog open={codeAction !== ''} onClose={closeCodeDialog} maxWidth="xs" fullWidth>
+        <DialogTitle>
+          {codeAction === CODE_ACTION_ENABLE
+            ? t('profilePage.enableTwoFactor')
+            : codeAction === CODE_ACTION_DISABLE
+              ? t('profilePage.disableTwoFactor')
+              : t('profilePage.regenerateRecoveryCodes')}
+        </DialogTitle>
+        <DialogContent>
+          <Stack spacing={2} sx={{ pt: 1 }}>
+            {setupData && (
+              <>
+                <Typography variant="body2">{t('profilePage.twoFactorScan')}</Typography>
+                <Box sx={{ display: 'flex', justifyContent: 'center' }}>
+                  <QRCode value={setupData.url} size={200} />
+                </Box>
+                <Typography variant="body2" sx={{ wordBreak: 'break-all' }}>
+                  {t('profilePage.twoFactorSecret')} <b>{setupData.secret}</b>
+                </Typography>
+              </>
+            )}
+            <FormControl fullWidth variant="outlined">
+              <InputLabel htmlFor="two_factor_code">{t('login.twoFactorCode')}</InputLabel>
+              <OutlinedInput
+                id="two_factor_code"
+                label={t('login.twoFactorCode')}
+                value={code}
+                onChange={(e) => setCode(e.target.value)}
+                inputProps={{ autoComplete: 'one-time-code' }}
+                autoFocus
+              />
+            </FormControl>
+          </Stack>
+        </DialogContent>
+        <DialogActions>
+          <Button onClick={closeCodeDialog}>{t('common.cancel')}</Button>
+          <Button variant="contained" disabled={loading} onClick={submitCode}>
+            {t('common.submit')}
+          </Button>
+        </DialogActions>
+      </Dialog>
+
+      <Dialog open={recoveryCodes.length > 0} onClose={() => setRecoveryCodes([])} maxWidth="xs" fullWidth>
+        <DialogTitle>{t('profilePage.recoveryCodes')}</DialogTitle>
+        <DialogContent>
+          <Stack spacing={2}>
+            <Alert severity="warning">{t('profilePage.recoveryCodesNotice')}</Alert>
+            <Box component="pre" sx={{ m: 0, fontFamily: 'monospace' }}>
+              {recoveryCodes.join('\n')}
+            </Box>
+          </Stack>
+        </DialogContent>
+        <DialogActions>
+          <Button onClick={() => copy(recoveryCodes.join('\n'), t('profilePage.recoveryCodes'))}>{t('profilePage.copyRecoveryCodes')}</Button>
+          <Button variant="contained" onClick={() => setRecoveryCodes([])}>
+            {t('common.close')}
+          </Button>
+        </DialogActions>
+      </Dialog>
+    </>
+  );
+}
@@ -25,6 +25,7 @@ import * as Yup from 'yup';
 import WechatModal from 'views/Authentication/AuthForms/WechatModal';
 import { useSelector } from 'react-redux';
 import EmailModal from './component/EmailModal';
+import SecurityCard from './component/SecurityCard';
 import Turnstile from 'react-turnstile';
 import LarkIcon from 'assets/images/icons/lark.svg';
 import { useTheme } from '@mui/material/styles';
@@ -302,6 +303,7 @@ export default function Profile() {
                 )}
               </Grid>
             </SubCard>
+            <SecurityCard />
             <SubCard title={t('profilePage.other')}>
               <Grid container spacing={2}>
                 <Grid xs={12}>
@@ -84,16 +84,17 @@ const EditModal = ({ open, tokenId, onCancel, onOk, userGroupOptions }) => {
       } else {
         res = await API.post(`/api/token/`, values);
       }
-      const { success, message } = res.data;
+      const { success, message, data } = res.data;
       if (success) {
         if (values.is_edit) {
           showSuccess('令牌更新成功！');
         } else {
-          showSuccess('令牌创建成功，请在列表页面点击复制获取令牌！');
+          showSuccess('令牌创建成功！');
         }
         setSubmitting(false);
         setStatus({ success: true });
-        onOk(true);
+        // 完整令牌只在创建时返回一次，交由列表页展示
+        onOk(true, values.is_edit ? '' : data.key);
       } else {
         showError(message);
         setErrors({ submit: message });
@@ -0,0 +1,46 @@
+import PropTypes from 'prop-types';
+import { Alert, Box, Button, Dialog, DialogActions, DialogContent, DialogTitle, Stack } from '@mui/material';
+import { useTranslation } from 'react-i18next';
+import { copy } from 'utils/common';
+
+// 完整令牌只在创建或轮换时返回一次，关闭后无法再次查看
+export default function KeyDialog({ tokenKey, onClose }) {
+  const { t } = useTranslation();
+  const fullKey = `sk-${tokenKey}`;
+
+  return (
+    <Dialog open={!!tokenKey} onClose={onClose} maxWidth="sm" fullWidth>
+      <DialogTitle>{t('token_index.tokenKey')}</DialogTitle>
+      <DialogContent>
+        <Stack spacing={2}>
+          <Alert severity="warning">{t('token_index.tokenKeyOnce')}</Alert>
+          <Box
+            component="pre"
+            sx={{
+              m: 0,
+              p: 1.5,
+              fontFamily: 'monospace',
+              wordBreak: 'break-all',
+              whiteSpace: 'pre-wrap',
+              bgcolor: 'action.hover',
+              borderRadius: 1
+            }}
+          >
+            {fullKey}
+          </Box>
+        </Stack>
+      </DialogContent>
+      <DialogActions>
+        <Button onClick={() => copy(fullKey, t('token_index.token'))}>{t('token_index.copy')}</Button>
+        <Button variant="contained" onClick={onClose}>
+          {t('token_index.close')}
+        </Button>
+      </DialogActions>
+    </Dialog>
+  );
+}
+
+KeyDialog.propTypes = {
+  tokenKey: PropTypes.string,
+  onClose: PropTypes.func
+};
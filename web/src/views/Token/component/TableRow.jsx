@@ -16,7 +16,8 @@ import {
   Button,
   Tooltip,
   Stack,
-  ButtonGroup
+  ButtonGroup,
+  Typography
 } from '@mui/material';
 
 import TableSwitch from 'ui-component/Switch';
@@ -62,6 +63,9 @@ export default function TokensTableRow({ item, manageToken, handleOpenModal, set
   const [open, setOpen] = useState(null);
   const [menuItems, setMenuItems] = useState(null);
   const [openDelete, setOpenDelete] = useState(false);
+  const [openRotate, setOpenRotate] = useState(false);
+  // 新版令牌的 key 只是展示前缀，完整令牌只在轮换后的本次会话中可用
+  const [plainKey, setPlainKey] = useState(item.hashed ? '' : item.key);
   const [statusSwitch, setStatusSwitch] = useState(item.status);
   const siteInfo = useSelector((state) => state.siteInfo);
   const chatLinks = getChatLinks();
@@ -77,6 +81,31 @@ export default function TokensTableRow({ item, manageToken, handleOpenModal, set
     setOpenDelete(false);
   };
 
+  const handleRotateOpen = () => {
+    handleCloseMenu();
+    setOpenRotate(true);
+  };
+
+  const handleRotateClose = () => {
+    setOpenRotate(false);
+  };
+
+  const handleRotate = async () => {
+    setOpenRotate(false);
+    const res = await manageToken(item.id, 'rotate', '');
+    if (res?.success) {
+      setPlainKey(res.data);
+    }
+  };
+
+  const handleCopyKey = () => {
+    if (!plainKey) {
+      setOpenRotate(true);
+      return;
+    }
+    copy(`sk-${plainKey}`, t('token_index.token'));
+  };
+
   const handleOpenMenu = (event, type) => {
     switch (type) {
       case 'copy':
@@ -119,6 +148,12 @@ export default function TokensTableRow({ item, manageToken, handleOpenModal, set
       },
       color: undefined
     },
+    {
+      text: t('token_index.rotateToken'),
+      icon: <Icon icon="solar:refresh-bold-duotone" style={{ marginRight: '16px' }} />,
+      onClick: handleRotateOpen,
+      color: undefined
+    },
     {
       text: t('common.delete'),
       icon: <Icon icon="solar:trash-bin-trash-bold-duotone" style={{ marginRight: '16px' }} />,
@@ -128,6 +163,11 @@ export default function TokensTableRow({ item, manageToken, handleOpenModal, set
   ]);
 
   const handleCopy = (option, type) => {
+    if (!plainKey) {
+      handleRotateOpen();
+      return;
+    }
+
     let server = '';
     if (siteInfo?.server_address) {
       server = siteInfo.server_address;
@@ -139,7 +179,7 @@ export default function TokensTableRow({ item, manageToken, handleOpenModal, set
 
     let url = option.url;
 
-    const key = 'sk-' + item.key;
+    const key = 'sk-' + plainKey;
     const text = replaceChatPlaceholders(url, key, server);
     if (type === 'link') {
       window.open(text);
@@ -171,10 +211,21 @@ export default function TokensTableRow({ item, manageToken, handleOpenModal, set
     setStatusSwitch(item.status);
   }, [item.status]);
 
+  useEffect(() => {
+    setPlainKey(item.hashed ? '' : item.key);
+  }, [item.hashed, item.key]);
+
   return (
     <>
       <TableRow tabIndex={item.id}>
-        <TableCell>{item.name}</TableCell>
+        <TableCell>
+          {item.name}
+          {item.hashed && (
+            <Typography variant="caption" color="text.secondary" display="block">
+              {`sk-${item.key}...`}
+            </Typography>
+          )}
+        </TableCell>
         <TableCell>
           <Label color={userGroup[item.group]?.color}>{userGroup[item.group]?.name || '跟随用户'}</Label>
         </TableCell>
@@ -206,12 +257,7 @@ export default function TokensTableRow({ item, manageToken, handleOpenModal, set
         <TableCell>
           <Stack direction="row" justifyContent="center" alignItems="center" spacing={1}>
             <ButtonGroup size="small" aria-label="split button">
-              <Button
-                color="primary"
-                onClick={() => {
-                  copy(`sk-${item.key}`, t('token_index.token'));
-                }}
-              >
+              <Button color="primary" onClick={handleCopyKey}>
                 {isMobile ? <Icon icon="mdi:content-copy" /> : t('token_index.copy')}
               </Button>
               <Button size="small" onClick={(e) => handleOpenMenu(e, 'copy')}>
@@ -245,6 +291,19 @@ export default function TokensTableRow({ item, manageToken, handleOpenModal, set
         {menuItems}
       </Popover>
 
+      <Dialog open={openRotate} onClose={handleRotateClose}>
+        <DialogTitle>{t('token_index.rotateToken')}</DialogTitle>
+        <DialogContent>
+          <DialogContentText>{t('token_index.confirmRotateToken')}</DialogContentText>
+        </DialogContent>
+        <DialogActions>
+          <Button onClick={handleRotateClose}>{t('token_index.close')}</Button>
+          <Button onClick={handleRotate} sx={{ color: 'error.main' }} autoFocus>
+            {t('token_index.rotateToken')}
+          </Button>
+        </DialogActions>
+      </Dialog>
+
       <Dialog open={openDelete} onClose={handleDeleteClose}>
         <DialogTitle>{t('token_index.deleteToken')}</DialogTitle>
         <DialogContent>
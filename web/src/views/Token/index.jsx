@@ -18,6 +18,7 @@ import TableToolBar from 'ui-component/TableToolBar';
 import { API } from 'utils/api';
 import { Icon } from '@iconify/react';
 import EditeModal from './component/EditModal';
+import KeyDialog from './component/KeyDialog';
 import { useSelector } from 'react-redux';
 import { PAGE_SIZE_OPTIONS, getPageSize, savePageSize } from 'constants';
 import { useTranslation } from 'react-i18next';
@@ -39,6 +40,7 @@ export default function Token() {
 
   const [openModal, setOpenModal] = useState(false);
   const [editTokenId, setEditTokenId] = useState(0);
+  const [newTokenKey, setNewTokenKey] = useState('');
   const siteInfo = useSelector((state) => state.siteInfo);
   const { userGroup } = useSelector((state) => state.account);
 
@@ -134,10 +136,16 @@ export default function Token() {
             status: value
           });
           break;
+        case 'rotate':
+          res = await API.post(url + `${id}/rotate`);
+          break;
       }
       const { success, message } = res.data;
       if (success) {
         showSuccess('操作成功完成！');
+        if (action === 'rotate') {
+          setNewTokenKey(res.data.data);
+        }
         if (action === 'delete') {
           await handleRefresh();
         }
@@ -161,10 +169,13 @@ export default function Token() {
     setEditTokenId(0);
   };
 
-  const handleOkModal = (status) => {
+  const handleOkModal = (status, key) => {
     if (status === true) {
       handleCloseModal();
       handleRefresh();
+      if (key) {
+        setNewTokenKey(key);
+      }
     }
   };
 
@@ -288,6 +299,7 @@ export default function Token() {
         tokenId={editTokenId}
         userGroupOptions={userGroupOptions}
       />
+      <KeyDialog tokenKey={newTokenKey} onClose={() => setNewTokenKey('')} />
     </>
   );
 }
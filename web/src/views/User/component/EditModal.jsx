@@ -70,7 +70,10 @@ const EditModal = ({ open, userId, onCancel, onOk }) => {
     values = trims(values);
     try {
       if (values.is_edit) {
-        res = await API.put(`/api/user/`, { ...values, id: parseInt(userId) });
+        // 额度通过增减额度单独修改，这里不提交加载时的旧值
+        const data = { ...values, id: parseInt(userId) };
+        delete data.quota;
+        res = await API.put(`/api/user/`, data);
       } else {
         res = await API.post(`/api/user/`, values);
       }